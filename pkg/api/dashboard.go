@@ -318,6 +318,21 @@ func (hs *HTTPServer) PostDashboard(c *models.ReqContext, cmd models.SaveDashboa
 		allowUiUpdate = hs.ProvisioningService.GetAllowUIUpdatesFromConfig(provisioningData.Name)
 	}
 
+	// library panels only used by this dashboard are moved along when it's moved to another folder, their folder
+	// is the one the dashboard is in before it's saved
+	moveLibraryPanels := hs.Cfg.IsPanelLibraryEnabled() && c.QueryBoolWithDefault("moveLibraryPanels", false) && !newDashboard
+	var libraryPanelsFolderID int64
+	if moveLibraryPanels {
+		existing, err := hs.SQLStore.GetDashboard(dash.Id, c.OrgId, dash.Uid, "")
+		if errors.Is(err, models.ErrDashboardNotFound) {
+			moveLibraryPanels = false
+		} else if err != nil {
+			return response.Error(500, "Error while getting dashboard folder", err)
+		} else {
+			libraryPanelsFolderID = existing.FolderId
+		}
+	}
+
 	if hs.Cfg.IsPanelLibraryEnabled() {
 		// clean up all unnecessary library panels JSON properties so we store a minimum JSON
 		err = hs.LibraryPanelService.CleanLibraryPanelsForDashboard(dash)
@@ -373,23 +388,45 @@ func (hs *HTTPServer) PostDashboard(c *models.ReqContext, cmd models.SaveDashboa
 		}
 	}
 
+	result := util.DynMap{
+		"status":  "success",
+		"slug":    dashboard.Slug,
+		"version": dashboard.Version,
+		"id":      dashboard.Id,
+		"uid":     dashboard.Uid,
+		"url":     dashboard.GetUrl(),
+	}
+
 	if hs.Cfg.IsPanelLibraryEnabled() {
 		// connect library panels for this dashboard after the dashboard is stored and has an ID
 		err = hs.LibraryPanelService.ConnectLibraryPanelsForDashboard(c, dashboard)
 		if err != nil {
 			return response.Error(500, "Error while connecting library panels", err)
 		}
+
+		if moveLibraryPanels {
+			// the dashboard is saved already, library panels that can't be moved are left where they are and listed
+			// with their error
+			moved, err := hs.LibraryPanelService.MoveLibraryPanelsWithDashboard(c, dashboard, libraryPanelsFolderID)
+			if err != nil {
+				hs.log.Warn("Failed to move library panels with dashboard", "dashboard", dashboard.Uid, "error", err)
+				result["moveLibraryPanelsError"] = "Library panels could not be moved with the dashboard"
+			} else if len(moved) > 0 {
+				result["movedLibraryPanels"] = moved
+			}
+		}
+
+		// panels that match existing library panels are only suggested, the dashboard is saved as it is
+		suggestions, err := hs.LibraryPanelService.SuggestLibraryPanelsForDashboard(c, dashboard)
+		if err != nil {
+			hs.log.Warn("Failed to suggest library panels", "dashboard", dashboard.Uid, "error", err)
+		} else if len(suggestions) > 0 {
+			result["libraryPanelSuggestions"] = suggestions
+		}
 	}
 
 	c.TimeRequest(metrics.MApiDashboardSave)
-	return response.JSON(200, util.DynMap{
-		"status":  "success",
-		"slug":    dashboard.Slug,
-		"version": dashboard.Version,
-		"id":      dashboard.Id,
-		"uid":     dashboard.Uid,
-		"url":     dashboard.GetUrl(),
-	})
+	return response.JSON(200, result)
 }
 
 func (hs *HTTPServer) dashboardSaveErrorToApiResponse(err error) response.Response {
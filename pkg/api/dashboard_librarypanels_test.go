@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	dboards "github.com/grafana/grafana/pkg/dashboards"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/librarypanels"
+	"github.com/grafana/grafana/pkg/services/provisioning"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestPostDashboardMovesLibraryPanels(t *testing.T) {
+	moveLibraryPanelsScenario(t, "When a dashboard is moved with moveLibraryPanels, it should move its library panels along",
+		func(t *testing.T, sc *moveLibraryPanelsScenarioContext) {
+			uid := sc.importLibraryPanel(t, "Panel", sc.from.Id)
+			saved := sc.postDashboard(t, newLibraryPanelDashboard(sc.from.Id, 0, uid), false)
+			require.NotContains(t, saved, "movedLibraryPanels")
+
+			moved := sc.postDashboard(t, newLibraryPanelDashboard(sc.to.Id, int64(saved["id"].(float64)), uid), true)
+			require.Equal(t, "success", moved["status"])
+			require.NotContains(t, moved, "moveLibraryPanelsError")
+			require.Len(t, moved["movedLibraryPanels"], 1)
+			movedPanel := moved["movedLibraryPanels"].([]interface{})[0].(map[string]interface{})
+			require.Equal(t, uid, movedPanel["uid"])
+			require.Equal(t, float64(sc.to.Id), movedPanel["folderId"])
+		})
+
+	moveLibraryPanelsScenario(t, "When a library panel of a moved dashboard can't be moved, it should move the others and report it",
+		func(t *testing.T, sc *moveLibraryPanelsScenarioContext) {
+			clashing := sc.importLibraryPanel(t, "Panel", sc.from.Id)
+			// a library panel with the same name in the target folder makes the move fail
+			sc.importLibraryPanel(t, "Panel", sc.to.Id)
+			other := sc.importLibraryPanel(t, "Other", sc.from.Id)
+			saved := sc.postDashboard(t, newLibraryPanelDashboard(sc.from.Id, 0, clashing, other), false)
+
+			moved := sc.postDashboard(t, newLibraryPanelDashboard(sc.to.Id, int64(saved["id"].(float64)), clashing, other), true)
+			require.Equal(t, "success", moved["status"])
+			require.NotContains(t, moved, "moveLibraryPanelsError")
+			results := make(map[string]map[string]interface{})
+			for _, result := range moved["movedLibraryPanels"].([]interface{}) {
+				result := result.(map[string]interface{})
+				results[result["uid"].(string)] = result
+			}
+			require.Len(t, results, 2)
+			require.Equal(t, float64(sc.from.Id), results[clashing]["folderId"])
+			require.NotEmpty(t, results[clashing]["error"])
+			require.Equal(t, float64(sc.to.Id), results[other]["folderId"])
+			require.NotContains(t, results[other], "error")
+
+			dash, err := sc.sqlStore.GetDashboard(0, sc.user.OrgId, moved["uid"].(string), "")
+			require.NoError(t, err)
+			require.Equal(t, sc.to.Id, dash.FolderId)
+		})
+}
+
+type moveLibraryPanelsScenarioContext struct {
+	*scenarioContext
+	hs       *HTTPServer
+	sqlStore *sqlstore.SQLStore
+	user     *models.SignedInUser
+	from     *models.Dashboard
+	to       *models.Dashboard
+	cmd      models.SaveDashboardCommand
+}
+
+func newLibraryPanelDashboard(folderID int64, id int64, uids ...string) models.SaveDashboardCommand {
+	panels := make([]interface{}, 0, len(uids))
+	for i, uid := range uids {
+		panels = append(panels, map[string]interface{}{
+			"id":           i + 1,
+			"gridPos":      map[string]interface{}{"h": 6, "w": 6, "x": 0, "y": i * 6},
+			"libraryPanel": map[string]interface{}{"uid": uid, "name": "Panel"},
+		})
+	}
+	dashboard := simplejson.NewFromAny(map[string]interface{}{
+		"title":  "Dashboard",
+		"panels": panels,
+	})
+	if id != 0 {
+		dashboard.Set("id", id)
+	}
+
+	return models.SaveDashboardCommand{Dashboard: dashboard, FolderId: folderID, Overwrite: true}
+}
+
+// importLibraryPanel creates a library panel in a folder through the exported import of library panels.
+func (sc *moveLibraryPanelsScenarioContext) importLibraryPanel(t *testing.T, name string, folderID int64) string {
+	t.Helper()
+
+	dashboard := simplejson.NewFromAny(map[string]interface{}{
+		"__inputs": []interface{}{
+			map[string]interface{}{
+				"name":  "LIBRARY_PANEL",
+				"type":  "librarypanel",
+				"label": name,
+				"model": map[string]interface{}{"type": "text", "title": name},
+			},
+		},
+	})
+	c := &models.ReqContext{
+		Context:      &macaron.Context{Req: macaron.Request{Request: &http.Request{}}},
+		SignedInUser: sc.user,
+	}
+	inputs, err := sc.hs.LibraryPanelService.ImportLibraryPanelsForDashboard(c, dashboard,
+		[]plugins.ImportDashboardInput{{Name: "LIBRARY_PANEL", Type: "librarypanel"}}, folderID)
+	require.NoError(t, err)
+	return inputs[0].Value
+}
+
+func (sc *moveLibraryPanelsScenarioContext) postDashboard(t *testing.T, cmd models.SaveDashboardCommand, move bool) map[string]interface{} {
+	t.Helper()
+
+	sc.cmd = cmd
+	params := map[string]string{}
+	if move {
+		params["moveLibraryPanels"] = "true"
+	}
+	sc.fakeReqWithParams("POST", sc.url, params).exec()
+	require.Equal(t, 200, sc.resp.Code, sc.resp.Body.String())
+
+	var result map[string]interface{}
+	err := json.Unmarshal(sc.resp.Body.Bytes(), &result)
+	require.NoError(t, err)
+	return result
+}
+
+func moveLibraryPanelsScenario(t *testing.T, desc string, fn func(t *testing.T, sc *moveLibraryPanelsScenarioContext)) {
+	t.Run(desc, func(t *testing.T) {
+		origUpdateAlerting := dashboards.UpdateAlerting
+		t.Cleanup(func() {
+			dashboards.UpdateAlerting = origUpdateAlerting
+		})
+		dashboards.UpdateAlerting = func(store dboards.Store, orgID int64, dashboard *models.Dashboard,
+			user *models.SignedInUser) error {
+			return nil
+		}
+
+		// other scenarios clear the bus, the guardian and the library panel service need these sqlstore handlers
+		bus.AddHandler("sql", sqlstore.GetDashboard)
+		bus.AddHandler("sql", sqlstore.GetDashboardAclInfoList)
+
+		cfg := setting.NewCfg()
+		cfg.FeatureToggles = map[string]bool{"panelLibrary": true}
+		sc := &moveLibraryPanelsScenarioContext{scenarioContext: setupScenarioContext(t, "/api/dashboards/db")}
+		sc.sqlStore = sqlstore.InitTestDB(t)
+		lps := &librarypanels.LibraryPanelService{Cfg: cfg, SQLStore: sc.sqlStore, RouteRegister: routing.NewRouteRegister()}
+		// the library panel tables are only migrated when the panel library is enabled
+		sqlstore.MigrateTestDB(t, sc.sqlStore, lps)
+		require.NoError(t, lps.Init())
+		sc.hs = &HTTPServer{
+			Cfg:                 cfg,
+			SQLStore:            sc.sqlStore,
+			LibraryPanelService: lps,
+			ProvisioningService: provisioning.NewProvisioningServiceMock(),
+			QuotaService:        &quota.QuotaService{Cfg: cfg},
+			log:                 log.New("http.server"),
+		}
+		sc.user = &models.SignedInUser{OrgId: 1, UserId: 1, OrgRole: models.ROLE_ADMIN}
+
+		createFolder := func(title string) *models.Dashboard {
+			folder, err := dashboards.NewService(sc.sqlStore).SaveDashboard(&dashboards.SaveDashboardDTO{
+				Dashboard: models.NewDashboardFolder(title),
+				OrgId:     sc.user.OrgId,
+				User:      sc.user,
+			}, false)
+			require.NoError(t, err)
+			return folder
+		}
+		sc.from = createFolder("From")
+		sc.to = createFolder("To")
+
+		sc.m.Post(sc.url, routing.Wrap(func(c *models.ReqContext) response.Response {
+			c.SignedInUser = sc.user
+			return sc.hs.PostDashboard(c, sc.cmd)
+		}))
+
+		fn(t, sc)
+	})
+}
@@ -219,12 +219,31 @@ func (hs *HTTPServer) ImportDashboard(c *models.ReqContext, apiCmd dtos.ImportDa
 		}
 	}
 
+	if hs.Cfg.IsPanelLibraryEnabled() {
+		// create or map the library panels declared as inputs before the inputs are evaluated
+		apiCmd.Inputs, err = hs.LibraryPanelService.ImportLibraryPanelsForDashboard(c, apiCmd.Dashboard, apiCmd.Inputs, apiCmd.FolderId)
+		if err != nil {
+			return response.Error(500, "Error while importing library panels", err)
+		}
+	}
+
 	dashInfo, err := hs.PluginManager.ImportDashboard(apiCmd.PluginId, apiCmd.Path, c.OrgId, apiCmd.FolderId,
 		apiCmd.Dashboard, apiCmd.Overwrite, apiCmd.Inputs, c.SignedInUser, hs.DataService)
 	if err != nil {
 		return hs.dashboardSaveErrorToApiResponse(err)
 	}
 
+	if hs.Cfg.IsPanelLibraryEnabled() {
+		// connect library panels for the imported dashboard now that it's stored and has an ID
+		query := models.GetDashboardQuery{Id: dashInfo.DashboardId, OrgId: c.OrgId}
+		if err := bus.Dispatch(&query); err != nil {
+			return response.Error(500, "Error while connecting library panels", err)
+		}
+		if err := hs.LibraryPanelService.ConnectLibraryPanelsForDashboard(c, query.Result); err != nil {
+			return response.Error(500, "Error while connecting library panels", err)
+		}
+	}
+
 	return response.JSON(200, dashInfo)
 }
 
@@ -64,6 +64,13 @@ func runPluginCommand(command func(commandLine utils.CommandLine) error) func(co
 	}
 }
 
+func runCommand(command func(commandLine utils.CommandLine) error) func(context *cli.Context) error {
+	return func(context *cli.Context) error {
+		cmd := &utils.ContextCommandLine{Context: context}
+		return command(cmd)
+	}
+}
+
 // Command contains command state.
 type Command struct {
 	Client utils.ApiClient
@@ -132,6 +139,17 @@ var adminCommands = []*cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "library-panels",
+		Usage: "Library panel commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "validate-provisioning",
+				Usage:  "validate-provisioning <path>. Validates library panel provisioning files without touching the database.",
+				Action: runCommand(validateLibraryPanelsProvisioningCommand),
+			},
+		},
+	},
 }
 
 var cueCommands = []*cli.Command{
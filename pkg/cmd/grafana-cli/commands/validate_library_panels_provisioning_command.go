@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/services/provisioning/librarypanels"
+)
+
+func validateLibraryPanelsProvisioningCommand(c utils.CommandLine) error {
+	path := c.Args().First()
+	if path == "" {
+		return fmt.Errorf("missing path to library panel provisioning files")
+	}
+
+	if err := librarypanels.Validate(path); err != nil {
+		return err
+	}
+
+	logger.Info(color.GreenString("Library panel provisioning files in %s are valid.\n\n", path))
+	return nil
+}
@@ -144,6 +144,15 @@ func TestMiddlewareContext(t *testing.T) {
 		assert.Equal(t, contexthandler.InvalidAPIKey, sc.respJson["message"])
 	})
 
+	middlewareScenario(t, "Bearer token on the library panel embed endpoint isn't read as an api key", func(t *testing.T, sc *scenarioContext) {
+		sc.m.Get("/api/library-panels/embed", sc.defaultHandler)
+		sc.apiKey = "invalid_key_test"
+		sc.fakeReq("GET", "/api/library-panels/embed").exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.False(t, sc.context.IsSignedIn)
+	})
+
 	middlewareScenario(t, "Valid API key", func(t *testing.T, sc *scenarioContext) {
 		const orgID int64 = 12
 		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
@@ -1,6 +1,7 @@
 package accesscontrol
 
 import (
+	"strconv"
 	"time"
 )
 
@@ -75,11 +76,34 @@ const (
 	ActionLDAPUsersSync  = "ldap.user:sync"
 	ActionLDAPStatusRead = "ldap.status:read"
 
+	// Library panels actions
+	ActionLibraryPanelsRead   = "library.panels:read"
+	ActionLibraryPanelsCreate = "library.panels:create"
+	ActionLibraryPanelsWrite  = "library.panels:write"
+	ActionLibraryPanelsDelete = "library.panels:delete"
+
+	// ActionLibraryPanelsFreezeOverride allows changing published library panels during a freeze window
+	ActionLibraryPanelsFreezeOverride = "library.panels.freeze:override"
+
 	// Global Scopes
 	ScopeGlobalUsersAll = "global:users:*"
 
 	ScopeUsersSelf = "users:self"
 	ScopeUsersAll  = "users:*"
+
+	// Library panels scopes, a library panel is in scope of its uid and of the folder it's in
+	ScopeLibraryPanelsAll = "library.panels:uid:*"
+	ScopeFoldersAll       = "folders:id:*"
 )
 
+// ScopeLibraryPanel is the scope of the library panel with the given uid.
+func ScopeLibraryPanel(uid string) string {
+	return "library.panels:uid:" + uid
+}
+
+// ScopeFolder is the scope of the folder with the given id, the General folder has id 0.
+func ScopeFolder(folderID int64) string {
+	return "folders:id:" + strconv.FormatInt(folderID, 10)
+}
+
 const RoleGrafanaAdmin = "Grafana Admin"
@@ -25,6 +25,47 @@ var ldapAdminEditRole = RoleDTO{
 	}),
 }
 
+var libraryPanelsReadRole = RoleDTO{
+	Name:    libraryPanelsRead,
+	Version: 1,
+	Permissions: []Permission{
+		{
+			Action: ActionLibraryPanelsRead,
+			Scope:  ScopeLibraryPanelsAll,
+		},
+	},
+}
+
+var libraryPanelsEditRole = RoleDTO{
+	Name:    libraryPanelsEdit,
+	Version: 1,
+	Permissions: ConcatPermissions(libraryPanelsReadRole.Permissions, []Permission{
+		{
+			Action: ActionLibraryPanelsCreate,
+			Scope:  ScopeFoldersAll,
+		},
+		{
+			Action: ActionLibraryPanelsWrite,
+			Scope:  ScopeLibraryPanelsAll,
+		},
+		{
+			Action: ActionLibraryPanelsDelete,
+			Scope:  ScopeLibraryPanelsAll,
+		},
+	}),
+}
+
+var libraryPanelsFreezeOverrideRole = RoleDTO{
+	Name:    libraryPanelsFreezeOverride,
+	Version: 1,
+	Permissions: []Permission{
+		{
+			Action: ActionLibraryPanelsFreezeOverride,
+			Scope:  ScopeLibraryPanelsAll,
+		},
+	},
+}
+
 var usersOrgReadRole = RoleDTO{
 	Name:    usersOrgRead,
 	Version: 1,
@@ -139,6 +180,11 @@ var PredefinedRoles = map[string]RoleDTO{
 
 	ldapAdminRead: ldapAdminReadRole,
 	ldapAdminEdit: ldapAdminEditRole,
+
+	libraryPanelsRead: libraryPanelsReadRole,
+	libraryPanelsEdit: libraryPanelsEditRole,
+
+	libraryPanelsFreezeOverride: libraryPanelsFreezeOverrideRole,
 }
 
 const (
@@ -150,6 +196,11 @@ const (
 
 	ldapAdminEdit = "grafana:roles:ldap:admin:edit"
 	ldapAdminRead = "grafana:roles:ldap:admin:read"
+
+	libraryPanelsEdit = "grafana:roles:library.panels:edit"
+	libraryPanelsRead = "grafana:roles:library.panels:read"
+
+	libraryPanelsFreezeOverride = "grafana:roles:library.panels:freeze:override"
 )
 
 // PredefinedRoleGrants specifies which organization roles are assigned
@@ -164,9 +215,16 @@ var PredefinedRoleGrants = map[string][]string{
 		usersOrgRead,
 	},
 	string(models.ROLE_ADMIN): {
+		libraryPanelsFreezeOverride,
 		usersOrgEdit,
 		usersOrgRead,
 	},
+	string(models.ROLE_EDITOR): {
+		libraryPanelsEdit,
+	},
+	string(models.ROLE_VIEWER): {
+		libraryPanelsRead,
+	},
 }
 
 func ConcatPermissions(permissions ...[]Permission) []Permission {
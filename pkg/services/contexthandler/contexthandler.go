@@ -137,7 +137,15 @@ func (h *ContextHandler) initContextWithAnonymousUser(ctx *models.ReqContext) bo
 	return true
 }
 
+// libraryPanelEmbedPath is the endpoint that reads library panel tokens from the Authorization header. Those tokens
+// aren't API keys, so the header is left to the endpoint.
+const libraryPanelEmbedPath = "/api/library-panels/embed"
+
 func (h *ContextHandler) initContextWithAPIKey(ctx *models.ReqContext) bool {
+	if strings.HasSuffix(strings.TrimSuffix(ctx.Req.URL.Path, "/"), libraryPanelEmbedPath) {
+		return false
+	}
+
 	header := ctx.Req.Header.Get("Authorization")
 	parts := strings.SplitN(header, " ", 2)
 	var keyString string
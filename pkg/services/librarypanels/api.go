@@ -19,26 +19,120 @@ func (lps *LibraryPanelService) registerAPIEndpoints() {
 
 	lps.RouteRegister.Group("/api/library-panels", func(libraryPanels routing.RouteRegister) {
 		libraryPanels.Post("/", middleware.ReqSignedIn, binding.Bind(createLibraryPanelCommand{}), routing.Wrap(lps.createHandler))
+		libraryPanels.Post("/dedupe", middleware.ReqOrgAdmin, binding.Bind(dedupeLibraryPanelsCommand{}), routing.Wrap(lps.dedupeHandler))
+		libraryPanels.Post("/delete", middleware.ReqSignedIn, binding.Bind(deleteLibraryPanelsCommand{}), routing.Wrap(lps.deleteManyHandler))
+		libraryPanels.Post("/manifest", middleware.ReqSignedIn, binding.Bind(createManifestCommand{}), routing.Wrap(lps.createManifestHandler))
+		libraryPanels.Post("/manifest/verify", middleware.ReqSignedIn, binding.Bind(verifyManifestCommand{}), routing.Wrap(lps.verifyManifestHandler))
+		libraryPanels.Post("/move", middleware.ReqSignedIn, binding.Bind(moveLibraryPanelsCommand{}), routing.Wrap(lps.moveHandler))
+		libraryPanels.Post("/:uid/clone", middleware.ReqSignedIn, binding.Bind(cloneLibraryPanelCommand{}), routing.Wrap(lps.cloneHandler))
+		libraryPanels.Post("/:uid/model", middleware.ReqSignedIn, binding.Bind(renderLibraryPanelModelCommand{}), routing.Wrap(lps.renderModelHandler))
+		libraryPanels.Post("/:uid/sandbox", middleware.ReqEditorRole, binding.Bind(createLibraryPanelSandboxCommand{}), routing.Wrap(lps.createSandboxHandler))
 		libraryPanels.Post("/:uid/dashboards/:dashboardId", middleware.ReqSignedIn, routing.Wrap(lps.connectHandler))
 		libraryPanels.Delete("/:uid", middleware.ReqSignedIn, routing.Wrap(lps.deleteHandler))
 		libraryPanels.Delete("/:uid/dashboards/:dashboardId", middleware.ReqSignedIn, routing.Wrap(lps.disconnectHandler))
+		libraryPanels.Post("/:uid/dashboards/:dashboardId/accept", middleware.ReqSignedIn, routing.Wrap(lps.acceptUpdateHandler))
+		libraryPanels.Post("/:uid/dashboards/:dashboardId/reject", middleware.ReqSignedIn, routing.Wrap(lps.rejectUpdateHandler))
+		libraryPanels.Post("/:uid/unlink", middleware.ReqSignedIn, routing.Wrap(lps.unlinkHandler))
+		libraryPanels.Put("/:uid/dashboards/:dashboardId/overrides", middleware.ReqSignedIn, binding.Bind(setConnectionOverridesCommand{}), routing.Wrap(lps.setConnectionOverridesHandler))
 		libraryPanels.Get("/", middleware.ReqSignedIn, routing.Wrap(lps.getAllHandler))
+		libraryPanels.Get("/capabilities", middleware.ReqSignedIn, routing.Wrap(lps.getCapabilitiesHandler))
+		libraryPanels.Get("/broken", middleware.ReqEditorRole, routing.Wrap(lps.getBrokenHandler))
+		libraryPanels.Get("/catalog", routing.Wrap(lps.getCatalogHandler))
+		libraryPanels.Post("/datasource/rewrite", middleware.ReqOrgAdmin, binding.Bind(rewriteDatasourceCommand{}), routing.Wrap(lps.rewriteDatasourceHandler))
+		libraryPanels.Get("/datasource/:uid", middleware.ReqSignedIn, routing.Wrap(lps.getByDatasourceHandler))
+		libraryPanels.Get("/embed", routing.Wrap(lps.embedHandler))
+		libraryPanels.Get("/folders", middleware.ReqSignedIn, routing.Wrap(lps.getFoldersHandler))
+		libraryPanels.Get("/folders/stats", middleware.ReqSignedIn, routing.Wrap(lps.getFolderStatsHandler))
+		libraryPanels.Get("/stats/top", middleware.ReqSignedIn, routing.Wrap(lps.getTopStatsHandler))
+		libraryPanels.Get("/pending-updates", middleware.ReqSignedIn, routing.Wrap(lps.getPendingUpdatesHandler))
+		libraryPanels.Get("/recommendations", middleware.ReqSignedIn, routing.Wrap(lps.getRecommendationsHandler))
+		libraryPanels.Get("/similar", middleware.ReqSignedIn, routing.Wrap(lps.getSimilarHandler))
+		libraryPanels.Get("/stale", middleware.ReqSignedIn, routing.Wrap(lps.getStaleHandler))
+		libraryPanels.Post("/packs/export", middleware.ReqSignedIn, binding.Bind(exportPackCommand{}), routing.Wrap(lps.exportPackHandler))
+		libraryPanels.Post("/packs/install", middleware.ReqEditorRole, binding.Bind(installPackCommand{}), routing.Wrap(lps.installPackHandler))
+		libraryPanels.Get("/owned", middleware.ReqSignedIn, routing.Wrap(lps.getOwnedHandler))
+		libraryPanels.Get("/trash", middleware.ReqSignedIn, routing.Wrap(lps.getTrashHandler))
+		libraryPanels.Post("/trash/:uid/restore", middleware.ReqSignedIn, routing.Wrap(lps.restoreHandler))
+		libraryPanels.Delete("/trash/:uid", middleware.ReqSignedIn, routing.Wrap(lps.purgeHandler))
+		libraryPanels.Get("/freeze-windows", middleware.ReqSignedIn, routing.Wrap(lps.getFreezeWindowsHandler))
+		libraryPanels.Post("/freeze-windows", middleware.ReqOrgAdmin, binding.Bind(createFreezeWindowCommand{}), routing.Wrap(lps.createFreezeWindowHandler))
+		libraryPanels.Delete("/freeze-windows/:id", middleware.ReqOrgAdmin, routing.Wrap(lps.deleteFreezeWindowHandler))
+		libraryPanels.Get("/archive-policy", middleware.ReqOrgAdmin, routing.Wrap(lps.getArchivePolicyHandler))
+		libraryPanels.Put("/archive-policy", middleware.ReqOrgAdmin, binding.Bind(setArchivePolicyCommand{}), routing.Wrap(lps.setArchivePolicyHandler))
+		libraryPanels.Get("/findings", middleware.ReqOrgAdmin, routing.Wrap(lps.getFindingsHandler))
+		libraryPanels.Post("/scan", middleware.ReqOrgAdmin, routing.Wrap(lps.scanHandler))
+		libraryPanels.Post("/reorg", middleware.ReqOrgAdmin, binding.Bind(reorgLibraryPanelsCommand{}), routing.Wrap(lps.reorgHandler))
+		libraryPanels.Get("/reorgs", middleware.ReqOrgAdmin, routing.Wrap(lps.getReorgsHandler))
+		libraryPanels.Post("/reorgs/rollback", middleware.ReqOrgAdmin, binding.Bind(rollbackReorgCommand{}), routing.Wrap(lps.rollbackReorgHandler))
+		libraryPanels.Get("/reorgs/:id/rollback", middleware.ReqOrgAdmin, routing.Wrap(lps.getReorgRollbackHandler))
+		libraryPanels.Get("/groups", middleware.ReqSignedIn, routing.Wrap(lps.getGroupsHandler))
+		libraryPanels.Post("/groups", middleware.ReqEditorRole, binding.Bind(setLibraryPanelGroupCommand{}), routing.Wrap(lps.createGroupHandler))
+		libraryPanels.Get("/groups/:uid", middleware.ReqSignedIn, routing.Wrap(lps.getGroupHandler))
+		libraryPanels.Put("/groups/:uid", middleware.ReqEditorRole, binding.Bind(setLibraryPanelGroupCommand{}), routing.Wrap(lps.updateGroupHandler))
+		libraryPanels.Delete("/groups/:uid", middleware.ReqEditorRole, routing.Wrap(lps.deleteGroupHandler))
+		libraryPanels.Post("/groups/:uid/publish", middleware.ReqSignedIn, binding.Bind(publishLibraryPanelGroupCommand{}), routing.Wrap(lps.publishGroupHandler))
+		libraryPanels.Get("/label-policy", middleware.ReqSignedIn, routing.Wrap(lps.getLabelPolicyHandler))
+		libraryPanels.Put("/label-policy", middleware.ReqOrgAdmin, binding.Bind(setLabelPolicyCommand{}), routing.Wrap(lps.setLabelPolicyHandler))
 		libraryPanels.Get("/:uid", middleware.ReqSignedIn, routing.Wrap(lps.getHandler))
 		libraryPanels.Get("/:uid/dashboards/", middleware.ReqSignedIn, routing.Wrap(lps.getConnectedDashboardsHandler))
-		libraryPanels.Patch("/:uid", middleware.ReqSignedIn, binding.Bind(patchLibraryPanelCommand{}), routing.Wrap(lps.patchHandler))
+		libraryPanels.Get("/:uid/connections", middleware.ReqSignedIn, routing.Wrap(lps.getConnectionsHandler))
+		libraryPanels.Get("/:uid/canary", middleware.ReqSignedIn, routing.Wrap(lps.getCanaryHandler))
+		libraryPanels.Put("/:uid/catalog", middleware.ReqSignedIn, binding.Bind(publishLibraryPanelCommand{}), routing.Wrap(lps.publishHandler))
+		libraryPanels.Delete("/:uid/catalog", middleware.ReqSignedIn, routing.Wrap(lps.unpublishHandler))
+		libraryPanels.Get("/:uid/findings", middleware.ReqSignedIn, routing.Wrap(lps.getLibraryPanelFindingsHandler))
+		libraryPanels.Get("/:uid/permissions", middleware.ReqSignedIn, routing.Wrap(lps.getPermissionsHandler))
+		libraryPanels.Post("/:uid/permissions", middleware.ReqSignedIn, binding.Bind(setLibraryPanelPermissionsCommand{}), routing.Wrap(lps.setPermissionsHandler))
+		libraryPanels.Get("/:uid/tokens", middleware.ReqSignedIn, routing.Wrap(lps.getTokensHandler))
+		libraryPanels.Post("/:uid/tokens", middleware.ReqSignedIn, binding.Bind(createLibraryPanelTokenCommand{}), routing.Wrap(lps.createTokenHandler))
+		libraryPanels.Delete("/:uid/tokens/:tokenId", middleware.ReqSignedIn, routing.Wrap(lps.revokeTokenHandler))
+		libraryPanels.Patch("/:uid", middleware.ReqSignedIn, lps.jsonPatchHandler, binding.Bind(patchLibraryPanelCommand{}), routing.Wrap(lps.patchHandler))
 	})
+	lps.RouteRegister.Get("/render/library-panels/:uid", middleware.ReqSignedIn, routing.Wrap(lps.renderHandler))
+	lps.RouteRegister.Get("/api/admin/library-panels/integrity", middleware.ReqGrafanaAdmin, routing.Wrap(lps.getIntegrityHandler))
+	lps.RouteRegister.Get("/api/admin/library-panels/audit/export", middleware.ReqGrafanaAdmin, routing.Wrap(lps.exportAuditLogHandler))
 }
 
 // createHandler handles POST /api/library-panels.
 func (lps *LibraryPanelService) createHandler(c *models.ReqContext, cmd createLibraryPanelCommand) response.Response {
+	observe := startOperation(operationCreate)
 	panel, err := lps.createLibraryPanel(c, cmd)
+	observe(err)
 	if err != nil {
 		return toLibraryPanelError(err, "Failed to create library panel")
 	}
 
+	// similarly named library panels are suggested so that near-duplicates can be replaced by the existing ones
+	similar, err := lps.getSimilarLibraryPanels(c, panel.Name, panel.UID)
+	if err != nil {
+		lps.log.Warn("Failed to get similarly named library panels", "uid", panel.UID, "error", err)
+	}
+	if len(similar) > 0 {
+		return response.JSON(200, util.DynMap{"result": panel, "similar": similar})
+	}
+
 	return response.JSON(200, util.DynMap{"result": panel})
 }
 
+// cloneHandler handles POST /api/library-panels/:uid/clone.
+func (lps *LibraryPanelService) cloneHandler(c *models.ReqContext, cmd cloneLibraryPanelCommand) response.Response {
+	panel, err := lps.cloneLibraryPanel(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to clone library panel")
+	}
+
+	return response.JSON(200, util.DynMap{"result": panel})
+}
+
+// createSandboxHandler handles POST /api/library-panels/:uid/sandbox.
+func (lps *LibraryPanelService) createSandboxHandler(c *models.ReqContext, cmd createLibraryPanelSandboxCommand) response.Response {
+	sandbox, err := lps.createSandbox(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to create library panel sandbox")
+	}
+
+	return response.JSON(200, util.DynMap{"result": sandbox})
+}
+
 // connectHandler handles POST /api/library-panels/:uid/dashboards/:dashboardId.
 func (lps *LibraryPanelService) connectHandler(c *models.ReqContext) response.Response {
 	err := lps.connectDashboard(c, c.Params(":uid"), c.ParamsInt64(":dashboardId"))
@@ -51,12 +145,100 @@ func (lps *LibraryPanelService) connectHandler(c *models.ReqContext) response.Re
 
 // deleteHandler handles DELETE /api/library-panels/:uid.
 func (lps *LibraryPanelService) deleteHandler(c *models.ReqContext) response.Response {
-	err := lps.deleteLibraryPanel(c, c.Params(":uid"))
+	version, err := parseIfMatch(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to delete library panel")
+	}
+	observe := startOperation(operationDelete)
+	dashboardUIDs, err := lps.deleteLibraryPanel(c, c.Params(":uid"), c.QueryBool("force"), version)
+	observe(err)
 	if err != nil {
 		return toLibraryPanelError(err, "Failed to delete library panel")
 	}
 
-	return response.Success("Library panel deleted")
+	return response.JSON(200, util.DynMap{"message": "Library panel deleted", "dashboardUids": dashboardUIDs})
+}
+
+// dedupeHandler handles POST /api/library-panels/dedupe.
+func (lps *LibraryPanelService) dedupeHandler(c *models.ReqContext, cmd dedupeLibraryPanelsCommand) response.Response {
+	result, err := lps.dedupeLibraryPanels(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to deduplicate panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": result})
+}
+
+// exportPackHandler handles POST /api/library-panels/packs/export.
+func (lps *LibraryPanelService) exportPackHandler(c *models.ReqContext, cmd exportPackCommand) response.Response {
+	pack, err := lps.exportPack(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to export pack")
+	}
+
+	return response.JSON(200, util.DynMap{"result": pack})
+}
+
+// installPackHandler handles POST /api/library-panels/packs/install.
+func (lps *LibraryPanelService) installPackHandler(c *models.ReqContext, cmd installPackCommand) response.Response {
+	result, err := lps.installPack(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to install pack")
+	}
+
+	return response.JSON(200, util.DynMap{"result": result})
+}
+
+// unlinkHandler handles POST /api/library-panels/:uid/unlink.
+func (lps *LibraryPanelService) unlinkHandler(c *models.ReqContext) response.Response {
+	dashboardUIDs, err := lps.unlinkLibraryPanel(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to unlink library panel")
+	}
+
+	return response.JSON(200, util.DynMap{"message": "Library panel unlinked", "dashboardUids": dashboardUIDs})
+}
+
+// deleteManyHandler handles POST /api/library-panels/delete.
+func (lps *LibraryPanelService) deleteManyHandler(c *models.ReqContext, cmd deleteLibraryPanelsCommand) response.Response {
+	observe := startOperation(operationDelete)
+	results, err := lps.deleteLibraryPanels(c, cmd.UIDs)
+	observe(err)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to delete library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": results})
+}
+
+// moveHandler handles POST /api/library-panels/move.
+func (lps *LibraryPanelService) moveHandler(c *models.ReqContext, cmd moveLibraryPanelsCommand) response.Response {
+	results, err := lps.moveLibraryPanels(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to move library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": results})
+}
+
+// createManifestHandler handles POST /api/library-panels/manifest.
+func (lps *LibraryPanelService) createManifestHandler(c *models.ReqContext, cmd createManifestCommand) response.Response {
+	manifest, err := lps.createManifest(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to create library panel manifest")
+	}
+
+	return response.JSON(200, util.DynMap{"result": manifest})
+}
+
+// verifyManifestHandler handles POST /api/library-panels/manifest/verify.
+func (lps *LibraryPanelService) verifyManifestHandler(c *models.ReqContext, cmd verifyManifestCommand) response.Response {
+	verification, err := lps.verifyManifest(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to verify library panel manifest")
+	}
+
+	return response.JSON(200, util.DynMap{"result": verification})
 }
 
 // disconnectHandler handles DELETE /api/library-panels/:uid/dashboards/:dashboardId.
@@ -69,35 +251,238 @@ func (lps *LibraryPanelService) disconnectHandler(c *models.ReqContext) response
 	return response.Success("Library panel disconnected")
 }
 
+// setConnectionOverridesHandler handles PUT /api/library-panels/:uid/dashboards/:dashboardId/overrides.
+func (lps *LibraryPanelService) setConnectionOverridesHandler(c *models.ReqContext, cmd setConnectionOverridesCommand) response.Response {
+	err := lps.setConnectionOverrides(c, c.Params(":uid"), c.ParamsInt64(":dashboardId"), cmd.Overrides)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to set library panel connection overrides")
+	}
+
+	return response.Success("Library panel connection overrides set")
+}
+
+// renderModelHandler handles POST /api/library-panels/:uid/model.
+func (lps *LibraryPanelService) renderModelHandler(c *models.ReqContext, cmd renderLibraryPanelModelCommand) response.Response {
+	model, err := lps.renderLibraryPanelModel(c, c.Params(":uid"), cmd.Inputs)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to render library panel model")
+	}
+
+	return response.JSON(200, util.DynMap{"result": model})
+}
+
+// getRecommendationsHandler handles GET /api/library-panels/recommendations.
+func (lps *LibraryPanelService) getRecommendationsHandler(c *models.ReqContext) response.Response {
+	recommendations, err := lps.getRecommendations(c, c.Query("type"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel recommendations")
+	}
+
+	return response.JSON(200, util.DynMap{"result": recommendations})
+}
+
+// getSimilarHandler handles GET /api/library-panels/similar.
+func (lps *LibraryPanelService) getSimilarHandler(c *models.ReqContext) response.Response {
+	similar, err := lps.getSimilarLibraryPanels(c, c.Query("name"), "")
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get similar library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": similar})
+}
+
+// acceptUpdateHandler handles POST /api/library-panels/:uid/dashboards/:dashboardId/accept.
+func (lps *LibraryPanelService) acceptUpdateHandler(c *models.ReqContext) response.Response {
+	err := lps.acceptLibraryPanelUpdate(c, c.Params(":uid"), c.ParamsInt64(":dashboardId"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to accept library panel update")
+	}
+
+	return response.Success("Library panel update accepted")
+}
+
+// rejectUpdateHandler handles POST /api/library-panels/:uid/dashboards/:dashboardId/reject.
+func (lps *LibraryPanelService) rejectUpdateHandler(c *models.ReqContext) response.Response {
+	err := lps.rejectLibraryPanelUpdate(c, c.Params(":uid"), c.ParamsInt64(":dashboardId"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to reject library panel update")
+	}
+
+	return response.Success("Library panel update rejected")
+}
+
+// getPendingUpdatesHandler handles GET /api/library-panels/pending-updates.
+func (lps *LibraryPanelService) getPendingUpdatesHandler(c *models.ReqContext) response.Response {
+	pending, err := lps.getPendingLibraryPanelUpdates(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get pending library panel updates")
+	}
+
+	return response.JSON(200, util.DynMap{"result": pending})
+}
+
 // getHandler handles GET /api/library-panels/:uid.
 func (lps *LibraryPanelService) getHandler(c *models.ReqContext) response.Response {
 	libraryPanel, err := lps.getLibraryPanel(c, c.Params(":uid"))
 	if err != nil {
 		return toLibraryPanelError(err, "Failed to get library panel")
 	}
+	if c.QueryBool("resolveDefaultDatasource") {
+		if err := lps.resolveDefaultDatasource(c, &libraryPanel); err != nil {
+			return toLibraryPanelError(err, "Failed to resolve default datasource")
+		}
+	}
 
-	return response.JSON(200, util.DynMap{"result": libraryPanel})
+	return response.JSON(200, util.DynMap{"result": libraryPanel}).SetHeader("ETag", libraryPanelETag(libraryPanel.UID, libraryPanel.Version))
 }
 
 // getAllHandler handles GET /api/library-panels/.
 func (lps *LibraryPanelService) getAllHandler(c *models.ReqContext) response.Response {
+	query := searchLibraryPanelsQuery{
+		perPage:           c.QueryInt("perPage"),
+		page:              c.QueryInt("page"),
+		searchString:      c.Query("searchString"),
+		sortDirection:     c.Query("sortDirection"),
+		panelFilter:       c.Query("panelFilter"),
+		excludeUID:        c.Query("excludeUid"),
+		folderFilter:      c.Query("folderFilter"),
+		labelSelector:     c.Query("label"),
+		tags:              c.QueryStrings("tag"),
+		searchIn:          c.Query("searchIn"),
+		datasourceUID:     c.Query("datasourceUid"),
+		continueToken:     c.Query("continueToken"),
+		allOrgs:           c.Query("orgId") == "all",
+		connected:         c.Query("connected"),
+		notUpdatedSince:   c.Query("notUpdatedSince"),
+		notConnectedSince: c.Query("notConnectedSince"),
+		notViewedSince:    c.Query("notViewedSince"),
+		sort:              c.Query("sort"),
+	}
+	observe := startOperation(operationSearch)
+	libraryPanels, err := lps.getAllLibraryPanels(c, query)
+	observe(err)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panels")
+	}
+	libraryPanelSearchResults.Observe(float64(len(libraryPanels.LibraryPanels)))
+
+	return response.JSON(200, util.DynMap{"result": libraryPanels})
+}
+
+// getByDatasourceHandler handles GET /api/library-panels/datasource/:uid.
+func (lps *LibraryPanelService) getByDatasourceHandler(c *models.ReqContext) response.Response {
 	query := searchLibraryPanelsQuery{
 		perPage:       c.QueryInt("perPage"),
 		page:          c.QueryInt("page"),
-		searchString:  c.Query("searchString"),
-		sortDirection: c.Query("sortDirection"),
-		panelFilter:   c.Query("panelFilter"),
-		excludeUID:    c.Query("excludeUid"),
-		folderFilter:  c.Query("folderFilter"),
+		datasourceUID: c.Params(":uid"),
+		continueToken: c.Query("continueToken"),
 	}
+	observe := startOperation(operationSearch)
 	libraryPanels, err := lps.getAllLibraryPanels(c, query)
+	observe(err)
 	if err != nil {
 		return toLibraryPanelError(err, "Failed to get library panels")
 	}
+	libraryPanelSearchResults.Observe(float64(len(libraryPanels.LibraryPanels)))
 
 	return response.JSON(200, util.DynMap{"result": libraryPanels})
 }
 
+// getOwnedHandler handles GET /api/library-panels/owned.
+func (lps *LibraryPanelService) getOwnedHandler(c *models.ReqContext) response.Response {
+	query := searchLibraryPanelsQuery{
+		perPage:       c.QueryInt("perPage"),
+		page:          c.QueryInt("page"),
+		continueToken: c.Query("continueToken"),
+	}
+	libraryPanels, err := lps.getOwnedLibraryPanels(c, c.QueryInt64("userId"), query)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get owned library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": libraryPanels})
+}
+
+// rewriteDatasourceHandler handles POST /api/library-panels/datasource/rewrite.
+func (lps *LibraryPanelService) rewriteDatasourceHandler(c *models.ReqContext, cmd rewriteDatasourceCommand) response.Response {
+	results, err := lps.rewriteDatasource(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to rewrite datasource references")
+	}
+
+	return response.JSON(200, util.DynMap{"result": results, "dryRun": cmd.DryRun})
+}
+
+// scanHandler handles POST /api/library-panels/scan.
+func (lps *LibraryPanelService) scanHandler(c *models.ReqContext) response.Response {
+	result, err := lps.scanLibraryPanels(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to scan library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": result})
+}
+
+// getFindingsHandler handles GET /api/library-panels/findings.
+func (lps *LibraryPanelService) getFindingsHandler(c *models.ReqContext) response.Response {
+	findings, err := lps.getFindings(c, c.Query("scanner"), c.Query("severity"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel findings")
+	}
+
+	return response.JSON(200, util.DynMap{"result": findings})
+}
+
+// getLibraryPanelFindingsHandler handles GET /api/library-panels/:uid/findings.
+func (lps *LibraryPanelService) getLibraryPanelFindingsHandler(c *models.ReqContext) response.Response {
+	findings, err := lps.getFindingsForLibraryPanel(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel findings")
+	}
+
+	return response.JSON(200, util.DynMap{"result": findings})
+}
+
+// reorgHandler handles POST /api/library-panels/reorg.
+func (lps *LibraryPanelService) reorgHandler(c *models.ReqContext, cmd reorgLibraryPanelsCommand) response.Response {
+	result, err := lps.reorgLibraryPanels(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to reorg library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": result})
+}
+
+// getReorgsHandler handles GET /api/library-panels/reorgs.
+func (lps *LibraryPanelService) getReorgsHandler(c *models.ReqContext) response.Response {
+	reorgs, err := lps.getReorgs(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get reorgs")
+	}
+
+	return response.JSON(200, util.DynMap{"result": reorgs})
+}
+
+// getReorgRollbackHandler handles GET /api/library-panels/reorgs/:id/rollback.
+func (lps *LibraryPanelService) getReorgRollbackHandler(c *models.ReqContext) response.Response {
+	rollback, err := lps.getReorgRollback(c, c.ParamsInt64(":id"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get reorg rollback file")
+	}
+
+	return response.JSON(200, rollback)
+}
+
+// rollbackReorgHandler handles POST /api/library-panels/reorgs/rollback.
+func (lps *LibraryPanelService) rollbackReorgHandler(c *models.ReqContext, cmd rollbackReorgCommand) response.Response {
+	result, err := lps.rollbackReorg(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to roll back reorg")
+	}
+
+	return response.JSON(200, util.DynMap{"result": result})
+}
+
 // getConnectedDashboardsHandler handles GET /api/library-panels/:uid/dashboards/.
 func (lps *LibraryPanelService) getConnectedDashboardsHandler(c *models.ReqContext) response.Response {
 	dashboardIDs, err := lps.getConnectedDashboards(c, c.Params(":uid"))
@@ -108,14 +493,420 @@ func (lps *LibraryPanelService) getConnectedDashboardsHandler(c *models.ReqConte
 	return response.JSON(200, util.DynMap{"result": dashboardIDs})
 }
 
+// getConnectionsHandler handles GET /api/library-panels/:uid/connections.
+func (lps *LibraryPanelService) getConnectionsHandler(c *models.ReqContext) response.Response {
+	query := searchConnectionsQuery{
+		perPage:  c.QueryInt("perPage"),
+		page:     c.QueryInt("page"),
+		sort:     c.Query("sort"),
+		folderID: -1,
+	}
+	if c.Query("folderId") != "" {
+		query.folderID = c.QueryInt64("folderId")
+	}
+	connections, err := lps.getConnections(c, c.Params(":uid"), query)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get connections")
+	}
+
+	return response.JSON(200, util.DynMap{"result": connections})
+}
+
 // patchHandler handles PATCH /api/library-panels/:uid
 func (lps *LibraryPanelService) patchHandler(c *models.ReqContext, cmd patchLibraryPanelCommand) response.Response {
+	version, err := parseIfMatch(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to update library panel")
+	}
+	if version != 0 {
+		if cmd.Version != 0 && cmd.Version != version {
+			return toLibraryPanelError(errLibraryPanelVersionMismatch, "Failed to update library panel")
+		}
+		cmd.Version = version
+	}
+	observe := startOperation(operationPatch)
 	libraryPanel, err := lps.patchLibraryPanel(c, cmd, c.Params(":uid"))
+	observe(err)
 	if err != nil {
 		return toLibraryPanelError(err, "Failed to update library panel")
 	}
 
-	return response.JSON(200, util.DynMap{"result": libraryPanel})
+	return response.JSON(200, util.DynMap{"result": libraryPanel}).SetHeader("ETag", libraryPanelETag(libraryPanel.UID, libraryPanel.Version))
+}
+
+// getPermissionsHandler handles GET /api/library-panels/:uid/permissions.
+func (lps *LibraryPanelService) getPermissionsHandler(c *models.ReqContext) response.Response {
+	items, err := lps.getLibraryPanelPermissions(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel permissions")
+	}
+
+	return response.JSON(200, util.DynMap{"result": items})
+}
+
+// setPermissionsHandler handles POST /api/library-panels/:uid/permissions.
+func (lps *LibraryPanelService) setPermissionsHandler(c *models.ReqContext, cmd setLibraryPanelPermissionsCommand) response.Response {
+	if err := lps.setLibraryPanelPermissions(c, c.Params(":uid"), cmd); err != nil {
+		return toLibraryPanelError(err, "Failed to update library panel permissions")
+	}
+
+	return response.Success("Library panel permissions updated")
+}
+
+// createTokenHandler handles POST /api/library-panels/:uid/tokens.
+func (lps *LibraryPanelService) createTokenHandler(c *models.ReqContext, cmd createLibraryPanelTokenCommand) response.Response {
+	token, err := lps.createToken(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to create library panel token")
+	}
+
+	return response.JSON(200, util.DynMap{"result": token})
+}
+
+// getTokensHandler handles GET /api/library-panels/:uid/tokens.
+func (lps *LibraryPanelService) getTokensHandler(c *models.ReqContext) response.Response {
+	tokens, err := lps.getTokens(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel tokens")
+	}
+
+	return response.JSON(200, util.DynMap{"result": tokens})
+}
+
+// revokeTokenHandler handles DELETE /api/library-panels/:uid/tokens/:tokenId.
+func (lps *LibraryPanelService) revokeTokenHandler(c *models.ReqContext) response.Response {
+	err := lps.revokeToken(c, c.Params(":uid"), c.ParamsInt64(":tokenId"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to revoke library panel token")
+	}
+
+	return response.Success("Library panel token revoked")
+}
+
+// embedHandler handles GET /api/library-panels/embed with an Authorization: Bearer <token> header.
+// It doesn't require a signed in user, the token only grants read access to a single library panel.
+func (lps *LibraryPanelService) embedHandler(c *models.ReqContext) response.Response {
+	panel, err := lps.getLibraryPanelByToken(c, lps.getEmbedToken(c))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel")
+	}
+
+	return response.JSON(200, util.DynMap{"result": panel})
+}
+
+// renderHandler handles GET /render/library-panels/:uid.
+func (lps *LibraryPanelService) renderHandler(c *models.ReqContext) response.Response {
+	opts, err := parseRenderOpts(c.Req.URL)
+	if err != nil {
+		return response.Error(400, "Render parameters error", err)
+	}
+
+	image, err := lps.renderLibraryPanel(c, c.Params(":uid"), opts)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to render library panel")
+	}
+
+	return response.Respond(200, image).SetHeader("Content-Type", "image/png")
+}
+
+// getBrokenHandler handles GET /api/library-panels/broken.
+func (lps *LibraryPanelService) getBrokenHandler(c *models.ReqContext) response.Response {
+	broken, err := lps.getBrokenLibraryPanels(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get broken library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": broken})
+}
+
+// getStaleHandler handles GET /api/library-panels/stale.
+func (lps *LibraryPanelService) getStaleHandler(c *models.ReqContext) response.Response {
+	stale, err := lps.getStaleLibraryPanels(c, c.Query("notUpdatedSince"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get stale library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": stale})
+}
+
+// getCanaryHandler handles GET /api/library-panels/:uid/canary.
+func (lps *LibraryPanelService) getCanaryHandler(c *models.ReqContext) response.Response {
+	canary, err := lps.getCanary(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel canary")
+	}
+
+	return response.JSON(200, util.DynMap{"result": canary})
+}
+
+// getIntegrityHandler handles GET /api/admin/library-panels/integrity.
+func (lps *LibraryPanelService) getIntegrityHandler(c *models.ReqContext) response.Response {
+	report, err := lps.getIntegrityReport(c.Context.Req.Context())
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to check library panel integrity")
+	}
+
+	return response.JSON(200, util.DynMap{"result": report})
+}
+
+// exportAuditLogHandler handles GET /api/admin/library-panels/audit/export.
+// The from and to query parameters are in epoch milliseconds, and the action query parameter can be repeated.
+func (lps *LibraryPanelService) exportAuditLogHandler(c *models.ReqContext) response.Response {
+	query := auditExportQuery{
+		format:  c.Query("format"),
+		orgID:   c.QueryInt64("orgId"),
+		from:    auditExportTime(c.QueryInt64("from")),
+		to:      auditExportTime(c.QueryInt64("to")),
+		actions: c.QueryStrings("action"),
+	}
+	if query.format == "" {
+		query.format = auditExportFormatJSONLines
+	}
+
+	export, err := lps.exportAuditLog(c.Context.Req.Context(), query)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to export library panel audit log")
+	}
+
+	contentType := "application/x-ndjson"
+	if query.format == auditExportFormatCEF {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return response.Respond(200, export).SetHeader("Content-Type", contentType)
+}
+
+// publishHandler handles PUT /api/library-panels/:uid/catalog.
+func (lps *LibraryPanelService) publishHandler(c *models.ReqContext, cmd publishLibraryPanelCommand) response.Response {
+	entry, err := lps.publishLibraryPanel(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to publish library panel")
+	}
+
+	return response.JSON(200, util.DynMap{"result": entry})
+}
+
+// unpublishHandler handles DELETE /api/library-panels/:uid/catalog.
+func (lps *LibraryPanelService) unpublishHandler(c *models.ReqContext) response.Response {
+	err := lps.unpublishLibraryPanel(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to unpublish library panel")
+	}
+
+	return response.Success("Library panel unpublished")
+}
+
+// getCatalogHandler handles GET /api/library-panels/catalog.
+// Users that are not signed in can read the catalog of the org given by the required orgId query parameter if
+// the public catalog is enabled.
+func (lps *LibraryPanelService) getCatalogHandler(c *models.ReqContext) response.Response {
+	orgID := c.OrgId
+	if !c.IsSignedIn {
+		if !lps.Cfg.PanelLibraryPublicCatalogEnabled {
+			return response.Error(401, "Unauthorized", nil)
+		}
+		orgID = c.QueryInt64("orgId")
+		if orgID <= 0 {
+			return response.Error(400, "orgId is required", nil)
+		}
+	}
+
+	catalog, err := lps.getCatalog(c, orgID)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel catalog")
+	}
+
+	return response.JSON(200, util.DynMap{"result": catalog})
+}
+
+// getCapabilitiesHandler handles GET /api/library-panels/capabilities.
+func (lps *LibraryPanelService) getCapabilitiesHandler(c *models.ReqContext) response.Response {
+	return response.JSON(200, util.DynMap{"result": lps.getCapabilities()})
+}
+
+// getFoldersHandler handles GET /api/library-panels/folders.
+func (lps *LibraryPanelService) getFoldersHandler(c *models.ReqContext) response.Response {
+	folders, err := lps.getFolders(c, c.Query("permission"), c.Query("query"), c.QueryInt("limit"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel folders")
+	}
+
+	return response.JSON(200, util.DynMap{"result": folders})
+}
+
+// getTopStatsHandler handles GET /api/library-panels/stats/top.
+func (lps *LibraryPanelService) getTopStatsHandler(c *models.ReqContext) response.Response {
+	stats, err := lps.getTopStats(c, c.QueryInt("limit"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get most used library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": stats})
+}
+
+// getFolderStatsHandler handles GET /api/library-panels/folders/stats.
+func (lps *LibraryPanelService) getFolderStatsHandler(c *models.ReqContext) response.Response {
+	stats, err := lps.getFolderStats(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel folder stats")
+	}
+
+	return response.JSON(200, util.DynMap{"result": stats})
+}
+
+// getTrashHandler handles GET /api/library-panels/trash.
+func (lps *LibraryPanelService) getTrashHandler(c *models.ReqContext) response.Response {
+	trash, err := lps.getTrash(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get trashed library panels")
+	}
+
+	return response.JSON(200, util.DynMap{"result": trash})
+}
+
+// restoreHandler handles POST /api/library-panels/trash/:uid/restore.
+func (lps *LibraryPanelService) restoreHandler(c *models.ReqContext) response.Response {
+	if err := lps.restoreLibraryPanel(c, c.Params(":uid")); err != nil {
+		return toLibraryPanelError(err, "Failed to restore library panel")
+	}
+
+	return response.Success("Library panel restored")
+}
+
+// purgeHandler handles DELETE /api/library-panels/trash/:uid.
+func (lps *LibraryPanelService) purgeHandler(c *models.ReqContext) response.Response {
+	if err := lps.purgeLibraryPanel(c, c.Params(":uid")); err != nil {
+		return toLibraryPanelError(err, "Failed to purge library panel")
+	}
+
+	return response.Success("Library panel purged")
+}
+
+// getLabelPolicyHandler handles GET /api/library-panels/label-policy.
+func (lps *LibraryPanelService) getLabelPolicyHandler(c *models.ReqContext) response.Response {
+	policy, err := lps.getLabelPolicy(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get label policy")
+	}
+
+	return response.JSON(200, util.DynMap{"result": policy})
+}
+
+// setLabelPolicyHandler handles PUT /api/library-panels/label-policy.
+func (lps *LibraryPanelService) setLabelPolicyHandler(c *models.ReqContext, cmd setLabelPolicyCommand) response.Response {
+	policy, err := lps.setLabelPolicy(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to set label policy")
+	}
+
+	return response.JSON(200, util.DynMap{"result": policy})
+}
+
+// getFreezeWindowsHandler handles GET /api/library-panels/freeze-windows.
+func (lps *LibraryPanelService) getFreezeWindowsHandler(c *models.ReqContext) response.Response {
+	windows, err := lps.getFreezeWindows(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get freeze windows")
+	}
+
+	return response.JSON(200, util.DynMap{"result": windows})
+}
+
+// createFreezeWindowHandler handles POST /api/library-panels/freeze-windows.
+func (lps *LibraryPanelService) createFreezeWindowHandler(c *models.ReqContext, cmd createFreezeWindowCommand) response.Response {
+	window, err := lps.createFreezeWindow(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to create freeze window")
+	}
+
+	return response.JSON(200, util.DynMap{"result": window})
+}
+
+// deleteFreezeWindowHandler handles DELETE /api/library-panels/freeze-windows/:id.
+func (lps *LibraryPanelService) deleteFreezeWindowHandler(c *models.ReqContext) response.Response {
+	if err := lps.deleteFreezeWindow(c, c.ParamsInt64(":id")); err != nil {
+		return toLibraryPanelError(err, "Failed to delete freeze window")
+	}
+
+	return response.Success("Freeze window deleted")
+}
+
+// getArchivePolicyHandler handles GET /api/library-panels/archive-policy.
+func (lps *LibraryPanelService) getArchivePolicyHandler(c *models.ReqContext) response.Response {
+	policy, err := lps.getArchivePolicy(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get archive policy")
+	}
+
+	return response.JSON(200, util.DynMap{"result": policy})
+}
+
+// setArchivePolicyHandler handles PUT /api/library-panels/archive-policy.
+func (lps *LibraryPanelService) setArchivePolicyHandler(c *models.ReqContext, cmd setArchivePolicyCommand) response.Response {
+	policy, err := lps.setArchivePolicy(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to set archive policy")
+	}
+
+	return response.JSON(200, util.DynMap{"result": policy})
+}
+
+// getGroupsHandler handles GET /api/library-panels/groups.
+func (lps *LibraryPanelService) getGroupsHandler(c *models.ReqContext) response.Response {
+	groups, err := lps.getLibraryPanelGroups(c)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel groups")
+	}
+
+	return response.JSON(200, util.DynMap{"result": groups})
+}
+
+// createGroupHandler handles POST /api/library-panels/groups.
+func (lps *LibraryPanelService) createGroupHandler(c *models.ReqContext, cmd setLibraryPanelGroupCommand) response.Response {
+	group, err := lps.createLibraryPanelGroup(c, cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to create library panel group")
+	}
+
+	return response.JSON(200, util.DynMap{"result": group})
+}
+
+// getGroupHandler handles GET /api/library-panels/groups/:uid.
+func (lps *LibraryPanelService) getGroupHandler(c *models.ReqContext) response.Response {
+	group, err := lps.getLibraryPanelGroupByUID(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to get library panel group")
+	}
+
+	return response.JSON(200, util.DynMap{"result": group})
+}
+
+// updateGroupHandler handles PUT /api/library-panels/groups/:uid.
+func (lps *LibraryPanelService) updateGroupHandler(c *models.ReqContext, cmd setLibraryPanelGroupCommand) response.Response {
+	group, err := lps.updateLibraryPanelGroup(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to update library panel group")
+	}
+
+	return response.JSON(200, util.DynMap{"result": group})
+}
+
+// deleteGroupHandler handles DELETE /api/library-panels/groups/:uid.
+func (lps *LibraryPanelService) deleteGroupHandler(c *models.ReqContext) response.Response {
+	if err := lps.deleteLibraryPanelGroup(c, c.Params(":uid")); err != nil {
+		return toLibraryPanelError(err, "Failed to delete library panel group")
+	}
+
+	return response.Success("Library panel group deleted")
+}
+
+// publishGroupHandler handles POST /api/library-panels/groups/:uid/publish.
+func (lps *LibraryPanelService) publishGroupHandler(c *models.ReqContext, cmd publishLibraryPanelGroupCommand) response.Response {
+	panels, err := lps.publishLibraryPanelGroup(c, c.Params(":uid"), cmd)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to publish library panel group")
+	}
+
+	return response.JSON(200, util.DynMap{"result": panels})
 }
 
 func toLibraryPanelError(err error, message string) response.Response {
@@ -128,17 +919,187 @@ func toLibraryPanelError(err error, message string) response.Response {
 	if errors.Is(err, errLibraryPanelDashboardNotFound) {
 		return response.Error(404, errLibraryPanelDashboardNotFound.Error(), err)
 	}
+	if errors.Is(err, errLibraryPanelHeaderUIDMissing) {
+		return response.Error(400, errLibraryPanelHeaderUIDMissing.Error(), err)
+	}
+	var mismatch *versionMismatchError
+	if errors.As(err, &mismatch) {
+		return response.JSON(412, util.DynMap{"message": errLibraryPanelVersionMismatch.Error(), "version": mismatch.version, "diff": mismatch.diff})
+	}
 	if errors.Is(err, errLibraryPanelVersionMismatch) {
 		return response.Error(412, errLibraryPanelVersionMismatch.Error(), err)
 	}
+	if errors.Is(err, errLibraryPanelVersionRequired) {
+		return response.Error(400, errLibraryPanelVersionRequired.Error(), err)
+	}
+	if errors.Is(err, models.ErrDataSourceNotFound) {
+		return response.Error(404, models.ErrDataSourceNotFound.Error(), err)
+	}
 	if errors.Is(err, models.ErrFolderNotFound) {
 		return response.Error(404, models.ErrFolderNotFound.Error(), err)
 	}
+	if errors.Is(err, errLibraryPanelInvalidPermission) {
+		return response.Error(400, errLibraryPanelInvalidPermission.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelAccessDenied) {
+		return response.Error(403, errLibraryPanelAccessDenied.Error(), err)
+	}
 	if errors.Is(err, models.ErrFolderAccessDenied) {
 		return response.Error(403, models.ErrFolderAccessDenied.Error(), err)
 	}
 	if errors.Is(err, errLibraryPanelHasConnectedDashboards) {
 		return response.Error(403, errLibraryPanelHasConnectedDashboards.Error(), err)
 	}
+	if errors.Is(err, errLibraryPanelProvisioned) {
+		return response.Error(400, errLibraryPanelProvisioned.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelTokenNotFound) {
+		return response.Error(404, errLibraryPanelTokenNotFound.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelTokenInvalid) || errors.Is(err, errLibraryPanelTokenExpired) {
+		return response.Error(401, err.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelTokenInvalidSecondsToLive) {
+		return response.Error(400, errLibraryPanelTokenInvalidSecondsToLive.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelSandboxInvalidSecondsToLive) {
+		return response.Error(400, errLibraryPanelSandboxInvalidSecondsToLive.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidContinueToken) {
+		return response.Error(400, errLibraryPanelInvalidContinueToken.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidFolderPermission) {
+		return response.Error(400, errLibraryPanelInvalidFolderPermission.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidScreenshotURL) {
+		return response.Error(400, errLibraryPanelInvalidScreenshotURL.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelNotPublished) {
+		return response.Error(404, errLibraryPanelNotPublished.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidModel) {
+		return response.Error(400, errLibraryPanelInvalidModel.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidLabel) {
+		return response.Error(400, errLibraryPanelInvalidLabel.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidTag) {
+		return response.Error(400, errLibraryPanelInvalidTag.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidLabelSelector) {
+		return response.Error(400, errLibraryPanelInvalidLabelSelector.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelMissingRequiredLabels) {
+		return response.Error(400, errLibraryPanelMissingRequiredLabels.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelQuotaReached) {
+		return response.Error(403, errLibraryPanelQuotaReached.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelFrozen) {
+		return response.Error(423, err.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidFreezeWindow) {
+		return response.Error(400, errLibraryPanelInvalidFreezeWindow.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelFreezeWindowNotFound) {
+		return response.Error(404, errLibraryPanelFreezeWindowNotFound.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelFreezeWindowProvisioned) {
+		return response.Error(400, errLibraryPanelFreezeWindowProvisioned.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidReorgMapping) {
+		return response.Error(400, errLibraryPanelInvalidReorgMapping.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidConnectionSort) {
+		return response.Error(400, errLibraryPanelInvalidConnectionSort.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelReorgNotFound) {
+		return response.Error(404, errLibraryPanelReorgNotFound.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidGroup) {
+		return response.Error(400, errLibraryPanelInvalidGroup.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelGroupAlreadyExists) {
+		return response.Error(400, errLibraryPanelGroupAlreadyExists.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelGroupNotFound) {
+		return response.Error(404, errLibraryPanelGroupNotFound.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelGroupIncomplete) {
+		return response.Error(400, errLibraryPanelGroupIncomplete.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidJSONPatch) {
+		return response.Error(400, err.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelJSONPatchTestFailed) {
+		return response.Error(409, err.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidOverrides) {
+		return response.Error(400, err.Error(), err)
+	}
+	if errors.Is(err, models.ErrDashboardUpdateAccessDenied) {
+		return response.Error(403, models.ErrDashboardUpdateAccessDenied.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelNoPendingUpdate) {
+		return response.Error(400, errLibraryPanelNoPendingUpdate.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidInputs) {
+		return response.Error(400, errLibraryPanelInvalidInputs.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidInputValues) {
+		return response.Error(400, err.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidPack) {
+		return response.Error(400, errLibraryPanelInvalidPack.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidRecommendationType) {
+		return response.Error(400, errLibraryPanelInvalidRecommendationType.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidConnectedFilter) {
+		return response.Error(400, errLibraryPanelInvalidConnectedFilter.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidStaleWindow) {
+		return response.Error(400, errLibraryPanelInvalidStaleWindow.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidArchivePolicy) {
+		return response.Error(400, errLibraryPanelInvalidArchivePolicy.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidAuditExportFormat) {
+		return response.Error(400, errLibraryPanelInvalidAuditExportFormat.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidAuditTimeRange) {
+		return response.Error(400, errLibraryPanelInvalidAuditTimeRange.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelVersionNotFound) {
+		return response.Error(404, errLibraryPanelVersionNotFound.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidSort) {
+		return response.Error(400, errLibraryPanelInvalidSort.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelSortWithContinueToken) {
+		return response.Error(400, errLibraryPanelSortWithContinueToken.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelAllOrgsWithContinueToken) {
+		return response.Error(400, errLibraryPanelAllOrgsWithContinueToken.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidUID) {
+		return response.Error(400, errLibraryPanelInvalidUID.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelUIDAlreadyExists) {
+		return response.Error(409, errLibraryPanelUIDAlreadyExists.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelInvalidRedactionProfile) {
+		return response.Error(400, errLibraryPanelInvalidRedactionProfile.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelCanaryInProgress) {
+		return response.Error(409, errLibraryPanelCanaryInProgress.Error(), err)
+	}
+	if errors.Is(err, errLibraryPanelCanaryNotFound) {
+		return response.Error(404, errLibraryPanelCanaryNotFound.Error(), err)
+	}
+	var dashboardErr models.DashboardErr
+	if errors.As(err, &dashboardErr) && dashboardErr.StatusCode > 0 {
+		return response.Error(dashboardErr.StatusCode, dashboardErr.Error(), err)
+	}
 	return response.Error(500, message, err)
 }
@@ -0,0 +1,112 @@
+package librarypanels
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// LibraryPanelPendingUpdatesDTO is the number of dashboards that haven't accepted or rejected the latest version of
+// a library panel.
+type LibraryPanelPendingUpdatesDTO struct {
+	UID               string `json:"uid" xorm:"uid"`
+	Name              string `json:"name" xorm:"name"`
+	Version           int64  `json:"version" xorm:"'version'"`
+	PendingDashboards int64  `json:"pendingDashboards" xorm:"pending_dashboards"`
+}
+
+// isUpdatePending returns whether a connection that requires update approval is behind the latest version of its
+// library panel and hasn't rejected it.
+func isUpdatePending(connection libraryPanelDashboard, version int64) bool {
+	return connection.AcceptedVersion != 0 && connection.AcceptedVersion < version && connection.RejectedVersion < version
+}
+
+// applyAcceptedVersions replaces the library panels of a dashboard with the versions its connections accepted and
+// returns the pending versions by library panel uid.
+func applyAcceptedVersions(libraryPanels map[string]LibraryPanelDTO, connections map[int64]libraryPanelDashboard) map[string]int64 {
+	pendingVersions := make(map[string]int64)
+	for uid, panel := range libraryPanels {
+		connection, ok := connections[panel.ID]
+		if !ok || connection.AcceptedVersion == 0 || connection.AcceptedVersion == panel.Version {
+			continue
+		}
+		if isUpdatePending(connection, panel.Version) {
+			pendingVersions[uid] = panel.Version
+		}
+		panel.Model = connection.AcceptedModel
+		panel.ModelHash = hashModel(connection.AcceptedModel)
+		panel.Version = connection.AcceptedVersion
+		libraryPanels[uid] = panel
+	}
+
+	return pendingVersions
+}
+
+// getConnectionForApproval gets a Library Panel and its connection to a Dashboard that has a pending update. The
+// signed in user must be allowed to edit the Dashboard.
+func getConnectionForApproval(session *sqlstore.DBSession, c *models.ReqContext, uid string, dashboardID int64) (LibraryPanelWithMeta, libraryPanelDashboard, error) {
+	panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+	if err != nil {
+		return LibraryPanelWithMeta{}, libraryPanelDashboard{}, err
+	}
+	if err := requireDashboardEdit(c.SignedInUser, dashboardID); err != nil {
+		return LibraryPanelWithMeta{}, libraryPanelDashboard{}, err
+	}
+
+	var connections []libraryPanelDashboard
+	if err := session.SQL("SELECT * FROM library_panel_dashboard WHERE librarypanel_id=? AND dashboard_id=?", panel.ID, dashboardID).Find(&connections); err != nil {
+		return LibraryPanelWithMeta{}, libraryPanelDashboard{}, err
+	}
+	if len(connections) == 0 {
+		return LibraryPanelWithMeta{}, libraryPanelDashboard{}, errLibraryPanelDashboardNotFound
+	}
+	if !isUpdatePending(connections[0], panel.Version) {
+		return LibraryPanelWithMeta{}, libraryPanelDashboard{}, errLibraryPanelNoPendingUpdate
+	}
+
+	return panel, connections[0], nil
+}
+
+// acceptLibraryPanelUpdate updates a Dashboard to the latest version of a Library Panel.
+func (lps *LibraryPanelService) acceptLibraryPanelUpdate(c *models.ReqContext, uid string, dashboardID int64) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, connection, err := getConnectionForApproval(session, c, uid, dashboardID)
+		if err != nil {
+			return err
+		}
+
+		_, err = session.Exec("UPDATE library_panel_dashboard SET accepted_version=?, accepted_model=?, rejected_version=0 WHERE id=?",
+			panel.Version, jsonColumn(panel.Model), connection.ID)
+		return err
+	})
+}
+
+// rejectLibraryPanelUpdate keeps a Dashboard on the version of a Library Panel it accepted. The Dashboard has a
+// pending update again when the Library Panel changes.
+func (lps *LibraryPanelService) rejectLibraryPanelUpdate(c *models.ReqContext, uid string, dashboardID int64) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, connection, err := getConnectionForApproval(session, c, uid, dashboardID)
+		if err != nil {
+			return err
+		}
+
+		_, err = session.Exec("UPDATE library_panel_dashboard SET rejected_version=? WHERE id=?", panel.Version, connection.ID)
+		return err
+	})
+}
+
+// getPendingLibraryPanelUpdates gets the Library Panels in the signed in user's org that have dashboards with pending
+// updates, ordered by name.
+func (lps *LibraryPanelService) getPendingLibraryPanelUpdates(c *models.ReqContext) ([]LibraryPanelPendingUpdatesDTO, error) {
+	pending := make([]LibraryPanelPendingUpdatesDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return session.SQL(`SELECT lp.uid, lp.name, lp.version, COUNT(lpd.id) AS pending_dashboards
+FROM library_panel AS lp
+INNER JOIN library_panel_dashboard AS lpd ON lpd.librarypanel_id = lp.id
+WHERE lp.org_id=? AND lp.deleted_at IS NULL
+AND lpd.accepted_version > 0 AND lpd.accepted_version < lp.version AND lpd.rejected_version < lp.version
+GROUP BY lp.uid, lp.name, lp.version
+ORDER BY lp.name ASC`, c.SignedInUser.OrgId).Find(&pending)
+	})
+
+	return pending, err
+}
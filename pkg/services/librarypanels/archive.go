@@ -0,0 +1,278 @@
+package librarypanels
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	archiveActionTrash  = "trash"
+	archiveActionFolder = "folder"
+
+	// archiveFolderTitle is the title of the folder the folder action uses when the policy has no folder.
+	archiveFolderTitle = "Archive"
+)
+
+// libraryPanelArchivePolicy is the model for the archive policy of an org. Library panels of the org without
+// connected dashboards that weren't updated for Days are moved to the trash or to FolderID by a background task.
+type libraryPanelArchivePolicy struct {
+	ID       int64  `xorm:"pk autoincr 'id'"`
+	OrgID    int64  `xorm:"org_id"`
+	Enabled  bool   `xorm:"enabled"`
+	Action   string `xorm:"action"`
+	Days     int64  `xorm:"days"`
+	FolderID int64  `xorm:"folder_id"`
+
+	Updated   time.Time
+	UpdatedBy int64
+}
+
+// LibraryPanelArchivePolicyDTO is the DTO for the archive policy of an org.
+type LibraryPanelArchivePolicyDTO struct {
+	Enabled   bool       `json:"enabled"`
+	Action    string     `json:"action"`
+	Days      int64      `json:"days"`
+	FolderID  int64      `json:"folderId"`
+	Updated   *time.Time `json:"updated,omitempty"`
+	UpdatedBy int64      `json:"updatedBy"`
+}
+
+// archiveResult is the outcome of applying the archive policies of all orgs.
+type archiveResult struct {
+	Archived int64
+	Skipped  int64
+}
+
+func toArchivePolicyDTO(policy libraryPanelArchivePolicy) LibraryPanelArchivePolicyDTO {
+	dto := LibraryPanelArchivePolicyDTO{
+		Enabled:   policy.Enabled,
+		Action:    policy.Action,
+		Days:      policy.Days,
+		FolderID:  policy.FolderID,
+		UpdatedBy: policy.UpdatedBy,
+	}
+	if !policy.Updated.IsZero() {
+		dto.Updated = &policy.Updated
+	}
+
+	return dto
+}
+
+func getArchivePolicy(session *sqlstore.DBSession, orgID int64) (libraryPanelArchivePolicy, bool, error) {
+	var policies []libraryPanelArchivePolicy
+	if err := session.SQL("SELECT * FROM library_panel_archive_policy WHERE org_id=?", orgID).Find(&policies); err != nil {
+		return libraryPanelArchivePolicy{}, false, err
+	}
+	if len(policies) == 0 {
+		return libraryPanelArchivePolicy{OrgID: orgID, Action: archiveActionTrash}, false, nil
+	}
+
+	return policies[0], true, nil
+}
+
+// getArchivePolicy gets the archive policy of the signed in user's org, orgs without a policy get a disabled one.
+func (lps *LibraryPanelService) getArchivePolicy(c *models.ReqContext) (LibraryPanelArchivePolicyDTO, error) {
+	var policy libraryPanelArchivePolicy
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var err error
+		policy, _, err = getArchivePolicy(session, c.SignedInUser.OrgId)
+		return err
+	})
+	if err != nil {
+		return LibraryPanelArchivePolicyDTO{}, err
+	}
+
+	return toArchivePolicyDTO(policy), nil
+}
+
+// getOrCreateArchiveFolder gets the folder the folder action moves library panels to. Without a folder id the
+// Archive folder is used, it's created when it doesn't exist yet.
+func (lps *LibraryPanelService) getOrCreateArchiveFolder(user *models.SignedInUser, folderID int64) (int64, error) {
+	s := dashboards.NewFolderService(user.OrgId, user, lps.SQLStore)
+	if folderID != 0 {
+		folder, err := s.GetFolderByID(folderID)
+		if err != nil {
+			return 0, err
+		}
+		return folder.Id, nil
+	}
+
+	folder, err := s.GetFolderByTitle(archiveFolderTitle)
+	if errors.Is(err, models.ErrFolderNotFound) {
+		folder, err = s.CreateFolder(archiveFolderTitle, "")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return folder.Id, nil
+}
+
+// setArchivePolicy sets the archive policy of the signed in user's org.
+func (lps *LibraryPanelService) setArchivePolicy(c *models.ReqContext, cmd setArchivePolicyCommand) (LibraryPanelArchivePolicyDTO, error) {
+	if cmd.Days <= 0 || (cmd.Action != archiveActionTrash && cmd.Action != archiveActionFolder) {
+		return LibraryPanelArchivePolicyDTO{}, errLibraryPanelInvalidArchivePolicy
+	}
+	folderID := int64(0)
+	if cmd.Action == archiveActionFolder {
+		var err error
+		if folderID, err = lps.getOrCreateArchiveFolder(c.SignedInUser, cmd.FolderID); err != nil {
+			return LibraryPanelArchivePolicyDTO{}, err
+		}
+	}
+
+	var policy libraryPanelArchivePolicy
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		existing, ok, err := getArchivePolicy(session, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+
+		policy = existing
+		policy.Enabled = cmd.Enabled
+		policy.Action = cmd.Action
+		policy.Days = cmd.Days
+		policy.FolderID = folderID
+		policy.Updated = time.Now()
+		policy.UpdatedBy = c.SignedInUser.UserId
+		if !ok {
+			_, err = session.Insert(&policy)
+			return err
+		}
+		_, err = session.ID(policy.ID).AllCols().Update(&policy)
+		return err
+	})
+	if err != nil {
+		return LibraryPanelArchivePolicyDTO{}, err
+	}
+
+	return toArchivePolicyDTO(policy), nil
+}
+
+// archiveLibraryPanel moves a single Library Panel to the trash or to the archive folder of a policy and adds an
+// entry to the audit log. It reports false when the archive folder already has a Library Panel with the same name,
+// and returns errLibraryPanelNotOrphaned when the Library Panel was connected or updated after cutoff since it was
+// selected.
+func archiveLibraryPanel(session *sqlstore.DBSession, policy libraryPanelArchivePolicy, panel LibraryPanel, cutoff time.Time) (bool, error) {
+	// the update is guarded by the same conditions the Library Panel was selected with
+	guard := ` WHERE id=? AND deleted_at IS NULL AND updated < ?
+	AND NOT EXISTS (SELECT 1 FROM library_panel_dashboard WHERE librarypanel_id=?)`
+	var detail string
+	var result sql.Result
+	var err error
+	newVersion := int64(0)
+	switch policy.Action {
+	case archiveActionFolder:
+		var existing []LibraryPanel
+		sql := "SELECT * FROM library_panel WHERE org_id=? AND folder_id=? AND name=?"
+		if err := session.SQL(sql, panel.OrgID, policy.FolderID, panel.Name).Find(&existing); err != nil {
+			return false, err
+		}
+		if len(existing) > 0 {
+			return false, nil
+		}
+		result, err = session.Exec("UPDATE library_panel SET folder_id=?, version=version+1"+guard, policy.FolderID, panel.ID, cutoff, panel.ID)
+		detail = fmt.Sprintf("moved from folder %d to folder %d", panel.FolderID, policy.FolderID)
+		newVersion = panel.Version + 1
+	default:
+		result, err = session.Exec(trashLibraryPanelSQL+guard, time.Now(), trashedName(panel.UID), panel.ID, cutoff, panel.ID)
+		detail = "moved to the trash"
+	}
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if rowsAffected != 1 {
+		return false, errLibraryPanelNotOrphaned
+	}
+	if newVersion != 0 {
+		if err := recordLibraryPanelVersion(session, panel.ID, 0); err != nil {
+			return false, err
+		}
+	}
+	detail = fmt.Sprintf("%s, no connected dashboards and not updated for %d days", detail, policy.Days)
+
+	return true, addAuditEntry(session, panel, auditActionArchive, detail, panel.Version, newVersion, 0)
+}
+
+// archiveOrphanedLibraryPanels applies the enabled archive policies of all orgs. Library Panels without connected
+// dashboards that weren't updated within the days of their org's policy are archived one by one, so a failure
+// leaves the Library Panels archived before it in place. Provisioned Library Panels are left to their provisioning,
+// and published Library Panels aren't archived during a freeze window.
+func (lps *LibraryPanelService) archiveOrphanedLibraryPanels(ctx context.Context) (archiveResult, error) {
+	result := archiveResult{}
+	var policies []libraryPanelArchivePolicy
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		sql := "SELECT * FROM library_panel_archive_policy WHERE enabled=" + lps.SQLStore.Dialect.BooleanStr(true) + " ORDER BY org_id"
+		return session.SQL(sql).Find(&policies)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for _, policy := range policies {
+		var panels []LibraryPanel
+		cutoff := time.Now().Add(-time.Duration(policy.Days) * 24 * time.Hour)
+		err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+			sql := `SELECT lp.* FROM library_panel AS lp
+WHERE lp.org_id=? AND lp.deleted_at IS NULL AND lp.updated < ? AND lp.provisioned=` + lps.SQLStore.Dialect.BooleanStr(false) + `
+	AND NOT EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id)`
+			params := []interface{}{policy.OrgID, cutoff}
+			if policy.Action == archiveActionFolder {
+				sql += " AND lp.folder_id<>?"
+				params = append(params, policy.FolderID)
+			}
+			return session.SQL(sql+" ORDER BY lp.id", params...).Find(&panels)
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, panel := range panels {
+			var archived, frozen bool
+			err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+				window, err := getFreezeWindowForLibraryPanel(session, panel.OrgID, panel.ID)
+				if err != nil {
+					return err
+				}
+				if window != nil {
+					frozen = true
+					return nil
+				}
+				archived, err = archiveLibraryPanel(session, policy, panel, cutoff)
+				return err
+			})
+			if errors.Is(err, errLibraryPanelNotOrphaned) {
+				lps.log.Info("Skipped archiving library panel, it was connected or updated since it was selected",
+					"orgId", panel.OrgID, "uid", panel.UID)
+				result.Skipped++
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			if frozen {
+				lps.log.Info("Skipped archiving library panel during a freeze window", "orgId", panel.OrgID, "uid", panel.UID)
+				result.Skipped++
+				continue
+			}
+			if !archived {
+				lps.log.Warn("Skipped archiving library panel, the archive folder has a library panel with the same name",
+					"orgId", panel.OrgID, "uid", panel.UID, "folderId", policy.FolderID)
+				result.Skipped++
+				continue
+			}
+			result.Archived++
+		}
+	}
+
+	return result, nil
+}
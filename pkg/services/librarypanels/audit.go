@@ -0,0 +1,238 @@
+package librarypanels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	auditActionCreate  = "create"
+	auditActionUpdate  = "update"
+	auditActionDelete  = "delete"
+	auditActionArchive = "archive"
+)
+
+const (
+	auditExportFormatJSONLines = "jsonl"
+	auditExportFormatCEF       = "cef"
+
+	// auditCEFSeverity is the CEF severity of audit entries, 0 is the lowest and 10 the highest.
+	auditCEFSeverity = 3
+)
+
+// libraryPanelAudit is the model for an entry in the audit log of library panels. Entries keep the uid and name of
+// the library panel so they stay readable after it's purged. CreatedBy is 0 for changes made by background tasks.
+// OldVersion is 0 for created library panels and NewVersion is 0 for deleted ones.
+type libraryPanelAudit struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	OrgID          int64  `xorm:"org_id"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	UID            string `xorm:"uid"`
+	Name           string `xorm:"name"`
+	Action         string `xorm:"action"`
+	Detail         string `xorm:"detail"`
+	OldVersion     int64  `xorm:"old_version"`
+	NewVersion     int64  `xorm:"new_version"`
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// addAuditEntry adds an entry for a change to a library panel from oldVersion to newVersion to the audit log.
+func addAuditEntry(session *sqlstore.DBSession, panel LibraryPanel, action string, detail string, oldVersion int64,
+	newVersion int64, userID int64) error {
+	_, err := session.Insert(&libraryPanelAudit{
+		OrgID:          panel.OrgID,
+		LibraryPanelID: panel.ID,
+		UID:            panel.UID,
+		Name:           panel.Name,
+		Action:         action,
+		Detail:         detail,
+		OldVersion:     oldVersion,
+		NewVersion:     newVersion,
+		Created:        time.Now(),
+		CreatedBy:      userID,
+	})
+	return err
+}
+
+// patchAuditDetail describes which fields of a library panel a patch changed, labels, tags and inputs count as
+// changed when the patch sets them.
+func patchAuditDetail(cmd patchLibraryPanelCommand, before LibraryPanelWithMeta, after LibraryPanel) string {
+	var changed []string
+	if after.Name != before.Name {
+		changed = append(changed, "name")
+	}
+	if after.FolderID != before.FolderID {
+		changed = append(changed, fmt.Sprintf("folder from %d to %d", before.FolderID, after.FolderID))
+	}
+	if cmd.Model != nil && after.ModelHash != storedModelHash(before.ModelHash, before.Model) {
+		changed = append(changed, "model")
+	}
+	if cmd.Labels != nil {
+		changed = append(changed, "labels")
+	}
+	if cmd.Tags != nil {
+		changed = append(changed, "tags")
+	}
+	if cmd.Inputs != nil {
+		changed = append(changed, "inputs")
+	}
+	if len(changed) == 0 {
+		return "updated without changes"
+	}
+
+	return "updated " + strings.Join(changed, ", ")
+}
+
+// LibraryPanelAuditEntryDTO is the DTO for an entry in the audit log of library panels.
+type LibraryPanelAuditEntryDTO struct {
+	ID             int64     `json:"id"`
+	OrgID          int64     `json:"orgId"`
+	LibraryPanelID int64     `json:"libraryPanelId"`
+	UID            string    `json:"uid"`
+	Name           string    `json:"name"`
+	Action         string    `json:"action"`
+	Detail         string    `json:"detail"`
+	OldVersion     int64     `json:"oldVersion"`
+	NewVersion     int64     `json:"newVersion"`
+	Created        time.Time `json:"created"`
+	CreatedBy      int64     `json:"createdBy"`
+}
+
+// auditExportQuery is the query used for exporting the audit log of library panels. A zero from or to leaves the
+// time range open on that side, and no actions exports entries of all actions.
+type auditExportQuery struct {
+	format  string
+	orgID   int64
+	from    time.Time
+	to      time.Time
+	actions []string
+}
+
+// auditExportTime converts epoch milliseconds to a time, 0 leaves the time range of an export open.
+func auditExportTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// getAuditEntries gets the entries of the audit log of library panels matching a query, oldest first.
+func (lps *LibraryPanelService) getAuditEntries(ctx context.Context, query auditExportQuery) ([]libraryPanelAudit, error) {
+	var entries []libraryPanelAudit
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		builder := sqlstore.SQLBuilder{}
+		builder.Write("SELECT * FROM library_panel_audit WHERE 1=1")
+		if query.orgID != 0 {
+			builder.Write(" AND org_id=?", query.orgID)
+		}
+		if !query.from.IsZero() {
+			builder.Write(" AND created >= ?", query.from)
+		}
+		if !query.to.IsZero() {
+			builder.Write(" AND created <= ?", query.to)
+		}
+		if len(query.actions) > 0 {
+			builder.Write(" AND action IN (?" + strings.Repeat(",?", len(query.actions)-1) + ")")
+			for _, action := range query.actions {
+				builder.AddParams(action)
+			}
+		}
+		builder.Write(" ORDER BY created ASC, id ASC")
+		return session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&entries)
+	})
+
+	return entries, err
+}
+
+// exportAuditLog exports the entries of the audit log of library panels matching a query as JSON Lines or CEF
+// (ArcSight Common Event Format), one entry per line, for ingestion by a SIEM.
+func (lps *LibraryPanelService) exportAuditLog(ctx context.Context, query auditExportQuery) ([]byte, error) {
+	if query.format != auditExportFormatJSONLines && query.format != auditExportFormatCEF {
+		return nil, errLibraryPanelInvalidAuditExportFormat
+	}
+	if !query.from.IsZero() && !query.to.IsZero() && query.to.Before(query.from) {
+		return nil, errLibraryPanelInvalidAuditTimeRange
+	}
+
+	entries, err := lps.getAuditEntries(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		if query.format == auditExportFormatCEF {
+			buf.WriteString(formatAuditCEF(entry, lps.Cfg.BuildVersion))
+			buf.WriteByte('\n')
+			continue
+		}
+		line, err := json.Marshal(LibraryPanelAuditEntryDTO{
+			ID:             entry.ID,
+			OrgID:          entry.OrgID,
+			LibraryPanelID: entry.LibraryPanelID,
+			UID:            entry.UID,
+			Name:           entry.Name,
+			Action:         entry.Action,
+			Detail:         entry.Detail,
+			OldVersion:     entry.OldVersion,
+			NewVersion:     entry.NewVersion,
+			Created:        entry.Created,
+			CreatedBy:      entry.CreatedBy,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+)
+
+// formatAuditCEF formats an audit entry as a CEF event. The signature id is the action, and the uid, name, ids and
+// versions of the library panel are in custom extension fields.
+func formatAuditCEF(entry libraryPanelAudit, version string) string {
+	header := []string{
+		"CEF:0",
+		"Grafana",
+		"Grafana",
+		cefHeaderEscaper.Replace(version),
+		cefHeaderEscaper.Replace("library-panel:" + entry.Action),
+		cefHeaderEscaper.Replace("Library panel " + entry.Action),
+		fmt.Sprint(auditCEFSeverity),
+	}
+	extension := []string{
+		fmt.Sprintf("rt=%d", entry.Created.UnixNano()/int64(time.Millisecond)),
+		"act=" + cefExtensionEscaper.Replace(entry.Action),
+		fmt.Sprintf("suid=%d", entry.CreatedBy),
+		"cs1Label=uid",
+		"cs1=" + cefExtensionEscaper.Replace(entry.UID),
+		"cs2Label=name",
+		"cs2=" + cefExtensionEscaper.Replace(entry.Name),
+		"cn1Label=orgId",
+		fmt.Sprintf("cn1=%d", entry.OrgID),
+		"cn2Label=libraryPanelId",
+		fmt.Sprintf("cn2=%d", entry.LibraryPanelID),
+		"cs3Label=oldVersion",
+		fmt.Sprintf("cs3=%d", entry.OldVersion),
+		"cs4Label=newVersion",
+		fmt.Sprintf("cs4=%d", entry.NewVersion),
+		"msg=" + cefExtensionEscaper.Replace(entry.Detail),
+	}
+
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
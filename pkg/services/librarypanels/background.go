@@ -0,0 +1,121 @@
+package librarypanels
+
+import (
+	"context"
+	"time"
+)
+
+// Run runs the background jobs of the service until ctx is done: it deletes expired sandbox dashboards, cleans
+// dangling connections, processes canaries, archives orphaned library panels, writes the views served by this
+// instance and, if enabled, smoke renders the library panels published to the catalog once a day.
+func (lps *LibraryPanelService) Run(ctx context.Context) error {
+	if !lps.IsEnabled() {
+		return nil
+	}
+
+	sandboxTicker := time.NewTicker(time.Minute * 10)
+	defer sandboxTicker.Stop()
+	smokeRenderTicker := time.NewTicker(time.Hour)
+	defer smokeRenderTicker.Stop()
+	danglingTicker := time.NewTicker(time.Hour)
+	defer danglingTicker.Stop()
+	canaryTicker := time.NewTicker(time.Minute)
+	defer canaryTicker.Stop()
+	archiveTicker := time.NewTicker(time.Hour)
+	defer archiveTicker.Stop()
+	viewsTicker := time.NewTicker(time.Minute)
+	defer viewsTicker.Stop()
+	for {
+		select {
+		case <-sandboxTicker.C:
+			lps.runDeleteExpiredSandboxes(ctx)
+		case <-smokeRenderTicker.C:
+			lps.runSmokeRender(ctx)
+		case <-danglingTicker.C:
+			lps.runCleanDanglingConnections(ctx)
+		case <-canaryTicker.C:
+			lps.runProcessCanaries(ctx)
+		case <-archiveTicker.C:
+			lps.runArchiveOrphanedLibraryPanels(ctx)
+		case <-viewsTicker.C:
+			lps.runFlushViews(ctx)
+		case <-ctx.Done():
+			lps.runFlushViews(context.Background())
+			return ctx.Err()
+		}
+	}
+}
+
+// lockAndExecute runs fn under the server lock name, so a job runs at most once per maxInterval across instances.
+func (lps *LibraryPanelService) lockAndExecute(ctx context.Context, name string, maxInterval time.Duration, fn func()) {
+	if err := lps.ServerLockService.LockAndExecute(ctx, name, maxInterval, fn); err != nil {
+		lps.log.Error("Failed to lock and "+name, "error", err)
+	}
+}
+
+func (lps *LibraryPanelService) runDeleteExpiredSandboxes(ctx context.Context) {
+	lps.lockAndExecute(ctx, "delete expired library panel sandboxes", time.Minute*10, func() {
+		if deleted, err := lps.deleteExpiredSandboxes(ctx); err != nil {
+			lps.log.Error("Failed to delete expired library panel sandboxes", "error", err)
+		} else if deleted > 0 {
+			lps.log.Debug("Deleted expired library panel sandboxes", "count", deleted)
+		}
+	})
+}
+
+func (lps *LibraryPanelService) runSmokeRender(ctx context.Context) {
+	if !lps.Cfg.PanelLibrarySmokeRenderEnabled || !lps.RenderService.IsAvailable() {
+		return
+	}
+
+	// the server lock makes sure library panels are smoke rendered at most once a day across instances
+	lps.lockAndExecute(ctx, "smoke render library panels", time.Hour*24, func() {
+		if result, err := lps.smokeRenderLibraryPanels(ctx); err != nil {
+			lps.log.Error("Failed to smoke render library panels", "error", err)
+		} else {
+			lps.log.Info("Smoke rendered library panels", "rendered", result.Rendered, "failed", result.Failed)
+		}
+	})
+}
+
+func (lps *LibraryPanelService) runCleanDanglingConnections(ctx context.Context) {
+	lps.lockAndExecute(ctx, "clean dangling library panel connections", time.Hour, func() {
+		if result, err := lps.cleanDanglingConnections(ctx); err != nil {
+			lps.log.Error("Failed to clean dangling library panel connections", "error", err)
+		} else if result.DashboardDeleted > 0 || result.LibraryPanelDeleted > 0 {
+			lps.log.Info("Cleaned dangling library panel connections", "dashboardDeleted", result.DashboardDeleted,
+				"libraryPanelDeleted", result.LibraryPanelDeleted)
+		}
+	})
+}
+
+func (lps *LibraryPanelService) runProcessCanaries(ctx context.Context) {
+	lps.lockAndExecute(ctx, "process library panel canaries", time.Minute, func() {
+		if result, err := lps.processCanaries(ctx); err != nil {
+			lps.log.Error("Failed to process library panel canaries", "error", err)
+		} else if result.Completed > 0 || result.RolledBack > 0 {
+			lps.log.Info("Processed library panel canaries", "completed", result.Completed, "rolledBack", result.RolledBack)
+		}
+	})
+}
+
+func (lps *LibraryPanelService) runArchiveOrphanedLibraryPanels(ctx context.Context) {
+	lps.lockAndExecute(ctx, "archive orphaned library panels", time.Hour, func() {
+		if result, err := lps.archiveOrphanedLibraryPanels(ctx); err != nil {
+			lps.log.Error("Failed to archive orphaned library panels", "error", err)
+		} else if result.Archived > 0 || result.Skipped > 0 {
+			lps.log.Info("Archived orphaned library panels", "archived", result.Archived, "skipped", result.Skipped)
+		}
+	})
+}
+
+// runFlushViews writes the views and last used times counted by this instance. Every instance counts the views it
+// served, so they're written without a server lock.
+func (lps *LibraryPanelService) runFlushViews(ctx context.Context) {
+	if _, err := lps.flushViews(ctx); err != nil {
+		lps.log.Error("Failed to write library panel views", "error", err)
+	}
+	if err := lps.flushLastUsed(ctx); err != nil {
+		lps.log.Error("Failed to write library panel last used times", "error", err)
+	}
+}
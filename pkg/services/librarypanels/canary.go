@@ -0,0 +1,270 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	canaryStatePending    = "pending"
+	canaryStateCompleted  = "completed"
+	canaryStateRolledBack = "rolled_back"
+)
+
+// libraryPanelCanary is the model for a library panel update that is applied to a sample of the connected
+// dashboards first. The other dashboards are held on the previous version until the canary soaked.
+type libraryPanelCanary struct {
+	ID              int64           `xorm:"pk autoincr 'id'"`
+	OrgID           int64           `xorm:"org_id"`
+	LibraryPanelID  int64           `xorm:"librarypanel_id"`
+	Version         int64           `xorm:"'version'"`
+	PreviousVersion int64           `xorm:"previous_version"`
+	PreviousModel   json.RawMessage `xorm:"previous_model"`
+	Sample          int             `xorm:"sample"`
+	Held            int             `xorm:"held"`
+	State           string          `xorm:"state"`
+	Error           string          `xorm:"error"`
+	Started         time.Time       `xorm:"started"`
+	SoakUntil       time.Time       `xorm:"soak_until"`
+	Finished        *time.Time      `xorm:"finished"`
+	CreatedBy       int64           `xorm:"created_by"`
+}
+
+// LibraryPanelCanaryDTO is the DTO for the canary update of a library panel.
+type LibraryPanelCanaryDTO struct {
+	UID             string     `json:"uid"`
+	Version         int64      `json:"version"`
+	PreviousVersion int64      `json:"previousVersion"`
+	Sample          int        `json:"sample"`
+	Held            int        `json:"held"`
+	State           string     `json:"state"`
+	Error           string     `json:"error,omitempty"`
+	Started         time.Time  `json:"started"`
+	SoakUntil       time.Time  `json:"soakUntil"`
+	Finished        *time.Time `json:"finished,omitempty"`
+}
+
+// canaryResult is the number of canary updates that were applied to all dashboards or rolled back.
+type canaryResult struct {
+	Completed  int
+	RolledBack int
+}
+
+// canarySampleSize returns how many of the connected dashboards get a canary update, at least one.
+func canarySampleSize(connected int, percent int) int {
+	sample := (connected*percent + 99) / 100
+	if sample < 1 {
+		sample = 1
+	}
+	if sample > connected {
+		sample = connected
+	}
+	return sample
+}
+
+// requireNoCanaryInProgress checks that a library panel has no canary update soaking, its model can't be changed
+// until the canary is applied or rolled back.
+func requireNoCanaryInProgress(session *sqlstore.DBSession, panelID int64) error {
+	count, err := session.Where("librarypanel_id=? AND state=?", panelID, canaryStatePending).Count(&libraryPanelCanary{})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errLibraryPanelCanaryInProgress
+	}
+
+	return nil
+}
+
+// startCanary starts a canary update of a library panel that was updated from panelInDB to libraryPanel. Of the
+// connected dashboards that follow the latest version, a sample keeps the update and the others are held on the
+// previous version until processCanaries applies or rolls back the update. Nothing happens when no connected
+// dashboard follows the latest version.
+func (lps *LibraryPanelService) startCanary(session *sqlstore.DBSession, user *models.SignedInUser, panelInDB LibraryPanelWithMeta, libraryPanel LibraryPanel) error {
+	var connections []libraryPanelDashboard
+	sql := "SELECT * FROM library_panel_dashboard WHERE librarypanel_id=? AND accepted_version=0 ORDER BY dashboard_id ASC"
+	if err := session.SQL(sql, panelInDB.ID).Find(&connections); err != nil {
+		return err
+	}
+	if len(connections) == 0 {
+		return nil
+	}
+
+	sample := canarySampleSize(len(connections), lps.Cfg.PanelLibraryCanarySamplePercent)
+	canary := libraryPanelCanary{
+		OrgID:           panelInDB.OrgID,
+		LibraryPanelID:  panelInDB.ID,
+		Version:         libraryPanel.Version,
+		PreviousVersion: panelInDB.Version,
+		PreviousModel:   panelInDB.Model,
+		Sample:          sample,
+		Held:            len(connections) - sample,
+		State:           canaryStatePending,
+		Started:         time.Now(),
+		SoakUntil:       time.Now().Add(lps.Cfg.PanelLibraryCanarySoak),
+		CreatedBy:       user.UserId,
+	}
+	if _, err := session.Insert(&canary); err != nil {
+		return err
+	}
+	for _, connection := range connections[sample:] {
+		if _, err := session.Exec("UPDATE library_panel_dashboard SET accepted_version=?, accepted_model=?, canary_id=? WHERE id=?",
+			panelInDB.Version, jsonColumn(panelInDB.Model), canary.ID, connection.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getCanary gets the latest canary update of a Library Panel the signed in user can view.
+func (lps *LibraryPanelService) getCanary(c *models.ReqContext, uid string) (LibraryPanelCanaryDTO, error) {
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return LibraryPanelCanaryDTO{}, err
+	}
+
+	var dto LibraryPanelCanaryDTO
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var canaries []libraryPanelCanary
+		if err := session.Where("librarypanel_id=?", panel.ID).Desc("id").Limit(1).Find(&canaries); err != nil {
+			return err
+		}
+		if len(canaries) == 0 {
+			return errLibraryPanelCanaryNotFound
+		}
+
+		canary := canaries[0]
+		dto = LibraryPanelCanaryDTO{
+			UID:             panel.UID,
+			Version:         canary.Version,
+			PreviousVersion: canary.PreviousVersion,
+			Sample:          canary.Sample,
+			Held:            canary.Held,
+			State:           canary.State,
+			Error:           canary.Error,
+			Started:         canary.Started,
+			SoakUntil:       canary.SoakUntil,
+			Finished:        canary.Finished,
+		}
+		return nil
+	})
+
+	return dto, err
+}
+
+// checkCanary returns why the canary version of a library panel is broken, or an empty string. The version is broken
+// when its last smoke render failed or, if the image renderer is available, it fails to render now.
+func (lps *LibraryPanelService) checkCanary(ctx context.Context, panel LibraryPanel) (string, error) {
+	var smokeRenders []libraryPanelSmokeRender
+	var datasources int64
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		err := session.Where("librarypanel_id=? AND version=? AND passed="+lps.SQLStore.Dialect.BooleanStr(false), panel.ID, panel.Version).Find(&smokeRenders)
+		if err != nil || lps.Cfg.PanelLibrarySmokeRenderDatasource == "" {
+			return err
+		}
+		datasources, err = session.Table("data_source").Where("org_id=? AND name=?", panel.OrgID, lps.Cfg.PanelLibrarySmokeRenderDatasource).Count()
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(smokeRenders) > 0 {
+		return smokeRenders[0].Error, nil
+	}
+
+	if lps.RenderService == nil || !lps.RenderService.IsAvailable() {
+		return "", nil
+	}
+	if err := lps.smokeRenderLibraryPanel(ctx, panel, datasources > 0, lps.smokeRenderOpts()); err != nil {
+		return err.Error(), nil
+	}
+
+	return "", nil
+}
+
+// finishCanary applies a canary update that soaked to all dashboards, or rolls the library panel back to the
+// previous model when the canary version is broken. A rollback is a new version of the library panel.
+func (lps *LibraryPanelService) finishCanary(ctx context.Context, canary libraryPanelCanary) (string, error) {
+	var panels []LibraryPanel
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		return session.SQL("SELECT * FROM library_panel WHERE id=? AND deleted_at IS NULL", canary.LibraryPanelID).Find(&panels)
+	})
+	if err != nil {
+		return "", err
+	}
+	failure := ""
+	if len(panels) > 0 && panels[0].Version == canary.Version {
+		if failure, err = lps.checkCanary(ctx, panels[0]); err != nil {
+			return "", err
+		}
+	}
+
+	state := canaryStateCompleted
+	if failure != "" {
+		state = canaryStateRolledBack
+	}
+	err = lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		if state == canaryStateRolledBack {
+			normalizedHash, err := normalizedModelHash(canary.PreviousModel)
+			if err != nil {
+				return err
+			}
+			if _, err := session.Exec("UPDATE library_panel SET model=?, model_hash=?, normalized_hash=?, version=version+1, updated=? WHERE id=? AND version=?",
+				string(canary.PreviousModel), hashModel(canary.PreviousModel), normalizedHash, time.Now(), canary.LibraryPanelID, canary.Version); err != nil {
+				return err
+			}
+			if err := setDatasourcesForLibraryPanel(session, canary.LibraryPanelID, canary.PreviousModel); err != nil {
+				return err
+			}
+			if err := recordLibraryPanelVersion(session, canary.LibraryPanelID, 0); err != nil {
+				return err
+			}
+		}
+		if _, err := session.Exec("UPDATE library_panel_dashboard SET accepted_version=0, accepted_model=NULL, canary_id=0 WHERE canary_id=?", canary.ID); err != nil {
+			return err
+		}
+		_, err := session.Exec("UPDATE library_panel_canary SET state=?, error=?, finished=? WHERE id=?", state, failure, time.Now(), canary.ID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return state, nil
+}
+
+// processCanaries finishes the canary updates whose soak period is over.
+func (lps *LibraryPanelService) processCanaries(ctx context.Context) (canaryResult, error) {
+	var canaries []libraryPanelCanary
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		return session.Where("state=? AND soak_until<=?", canaryStatePending, time.Now()).Asc("id").Find(&canaries)
+	})
+	if err != nil {
+		return canaryResult{}, err
+	}
+
+	var result canaryResult
+	for _, canary := range canaries {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		state, err := lps.finishCanary(ctx, canary)
+		if err != nil {
+			return result, err
+		}
+		if state == canaryStateRolledBack {
+			lps.log.Warn("Rolled back library panel canary update", "libraryPanelId", canary.LibraryPanelID, "version", canary.Version)
+			result.RolledBack++
+		} else {
+			result.Completed++
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,40 @@
+package librarypanels
+
+// LibraryPanelCapabilitiesDTO describes which optional Panel Library features are available on this instance.
+// Fields are only ever added, so clients can rely on a missing field meaning an older server.
+type LibraryPanelCapabilitiesDTO struct {
+	// Versions is true when library panels have a version that's bumped on every change.
+	Versions bool `json:"versions"`
+	// VersionHistory is true when previous versions of library panels are kept.
+	VersionHistory bool `json:"versionHistory"`
+	Drafts         bool `json:"drafts"`
+	Collections    bool `json:"collections"`
+	GitSync        bool `json:"gitSync"`
+	Tags           bool `json:"tags"`
+	Labels         bool `json:"labels"`
+	Trash          bool `json:"trash"`
+	Catalog        bool `json:"catalog"`
+	PublicCatalog  bool `json:"publicCatalog"`
+	Tokens         bool `json:"tokens"`
+	Sandbox        bool `json:"sandbox"`
+	Render         bool `json:"render"`
+	Permissions    bool `json:"permissions"`
+	AccessControl  bool `json:"accessControl"`
+}
+
+// getCapabilities gets the optional Panel Library features available on this instance.
+func (lps *LibraryPanelService) getCapabilities() LibraryPanelCapabilitiesDTO {
+	return LibraryPanelCapabilitiesDTO{
+		Versions:      true,
+		Tags:          true,
+		Labels:        true,
+		Trash:         true,
+		Catalog:       true,
+		PublicCatalog: lps.Cfg.PanelLibraryPublicCatalogEnabled,
+		Tokens:        true,
+		Sandbox:       true,
+		Render:        lps.RenderService != nil && lps.RenderService.IsAvailable(),
+		Permissions:   true,
+		AccessControl: lps.AccessControl != nil && !lps.AccessControl.IsDisabled(),
+	}
+}
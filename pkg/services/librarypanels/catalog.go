@@ -0,0 +1,167 @@
+package librarypanels
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelCatalogEntry is the model for library panels published to the catalog of an org.
+type libraryPanelCatalogEntry struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	OrgID          int64  `xorm:"org_id"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Readme         string `xorm:"readme"`
+	ScreenshotURL  string `xorm:"screenshot_url"`
+
+	Created time.Time
+	Updated time.Time
+
+	CreatedBy int64
+	UpdatedBy int64
+}
+
+// LibraryPanelCatalogEntryDTO is the DTO for library panels published to the catalog. It never contains the
+// panel model.
+type LibraryPanelCatalogEntryDTO struct {
+	UID           string    `json:"uid"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Readme        string    `json:"readme"`
+	ScreenshotURL string    `json:"screenshotUrl"`
+	Updated       time.Time `json:"updated"`
+}
+
+func validateScreenshotURL(screenshotURL string) error {
+	if screenshotURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(screenshotURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errLibraryPanelInvalidScreenshotURL
+	}
+
+	return nil
+}
+
+// publishLibraryPanel publishes a Library Panel to the catalog of the signed in user's org, or updates its
+// catalog entry if it's already published.
+func (lps *LibraryPanelService) publishLibraryPanel(c *models.ReqContext, uid string, cmd publishLibraryPanelCommand) (LibraryPanelCatalogEntryDTO, error) {
+	if err := validateScreenshotURL(cmd.ScreenshotURL); err != nil {
+		return LibraryPanelCatalogEntryDTO{}, err
+	}
+
+	var dto LibraryPanelCatalogEntryDTO
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+
+		entry := libraryPanelCatalogEntry{
+			OrgID:          panel.OrgID,
+			LibraryPanelID: panel.ID,
+			Readme:         cmd.Readme,
+			ScreenshotURL:  cmd.ScreenshotURL,
+			Created:        time.Now(),
+			Updated:        time.Now(),
+			CreatedBy:      c.SignedInUser.UserId,
+			UpdatedBy:      c.SignedInUser.UserId,
+		}
+		var entries []libraryPanelCatalogEntry
+		if err := session.SQL("SELECT * FROM library_panel_catalog_entry WHERE librarypanel_id=?", panel.ID).Find(&entries); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			if _, err := session.Insert(&entry); err != nil {
+				return err
+			}
+		} else {
+			entry.ID = entries[0].ID
+			entry.Created = entries[0].Created
+			entry.CreatedBy = entries[0].CreatedBy
+			if _, err := session.ID(entry.ID).AllCols().Update(&entry); err != nil {
+				return err
+			}
+		}
+
+		dto = LibraryPanelCatalogEntryDTO{
+			UID:           panel.UID,
+			Name:          panel.Name,
+			Description:   panel.Description,
+			Readme:        entry.Readme,
+			ScreenshotURL: entry.ScreenshotURL,
+			Updated:       entry.Updated,
+		}
+		return nil
+	})
+
+	return dto, err
+}
+
+// unpublishLibraryPanel removes a Library Panel from the catalog of the signed in user's org.
+func (lps *LibraryPanelService) unpublishLibraryPanel(c *models.ReqContext, uid string) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+
+		result, err := session.Exec("DELETE FROM library_panel_catalog_entry WHERE librarypanel_id=?", panel.ID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected != 1 {
+			return errLibraryPanelNotPublished
+		}
+
+		return nil
+	})
+}
+
+// getCatalog gets all Library Panels published to the catalog of an org.
+func (lps *LibraryPanelService) getCatalog(c *models.ReqContext, orgID int64) ([]LibraryPanelCatalogEntryDTO, error) {
+	catalog := make([]LibraryPanelCatalogEntryDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var entries []struct {
+			UID           string    `xorm:"uid"`
+			Name          string    `xorm:"name"`
+			Description   string    `xorm:"description"`
+			Readme        string    `xorm:"readme"`
+			ScreenshotURL string    `xorm:"screenshot_url"`
+			Updated       time.Time `xorm:"updated"`
+		}
+		sql := `SELECT lp.uid, lp.name, lp.description, lpce.readme, lpce.screenshot_url, lpce.updated
+FROM library_panel_catalog_entry AS lpce
+INNER JOIN library_panel AS lp ON lp.id = lpce.librarypanel_id
+WHERE lpce.org_id=? AND lp.deleted_at IS NULL
+ORDER BY lp.name ASC`
+		if err := session.SQL(sql, orgID).Find(&entries); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			catalog = append(catalog, LibraryPanelCatalogEntryDTO{
+				UID:           entry.UID,
+				Name:          entry.Name,
+				Description:   entry.Description,
+				Readme:        entry.Readme,
+				ScreenshotURL: entry.ScreenshotURL,
+				Updated:       entry.Updated,
+			})
+		}
+		return nil
+	})
+
+	return catalog, err
+}
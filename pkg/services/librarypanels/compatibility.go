@@ -0,0 +1,89 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// modelFeatureVersions are the Grafana versions that introduced panel model features. A library panel using one of
+// them can't be rendered by an older Grafana.
+var modelFeatureVersions = []struct {
+	version string
+	uses    func(model *simplejson.Json) bool
+}{
+	{version: "7.0.0", uses: func(model *simplejson.Json) bool {
+		_, ok := model.CheckGet("fieldConfig")
+		return ok
+	}},
+	{version: "7.0.0", uses: func(model *simplejson.Json) bool {
+		return len(model.Get("transformations").MustArray()) > 0
+	}},
+	{version: "8.3.0", uses: func(model *simplejson.Json) bool {
+		_, ok := model.Get("datasource").CheckGet("uid")
+		return ok
+	}},
+}
+
+// minGrafanaVersion returns the lowest Grafana version that supports every feature used by a library panel model,
+// an empty string if the model doesn't use any feature with a known minimum version. The plugin version of the
+// panel counts as well since core panels are versioned with Grafana.
+func minGrafanaVersion(model json.RawMessage) string {
+	modelJSON, err := simplejson.NewJson(model)
+	if err != nil {
+		return ""
+	}
+
+	var min *version.Version
+	raise := func(v string) {
+		parsed, err := version.NewVersion(v)
+		if err != nil {
+			return
+		}
+		if min == nil || parsed.GreaterThan(min) {
+			min = parsed
+		}
+	}
+	for _, feature := range modelFeatureVersions {
+		if feature.uses(modelJSON) {
+			raise(feature.version)
+		}
+	}
+	raise(modelJSON.Get("pluginVersion").MustString())
+
+	if min == nil {
+		return ""
+	}
+	return min.String()
+}
+
+// isNewerThanGrafana returns true when a library panel requires a newer Grafana than buildVersion. Unparsable
+// versions, such as those of development builds, are considered compatible.
+func isNewerThanGrafana(required string, buildVersion string) bool {
+	if required == "" {
+		return false
+	}
+	requiredVersion, err := version.NewVersion(required)
+	if err != nil {
+		return false
+	}
+	grafanaVersion, err := version.NewVersion(buildVersion)
+	if err != nil {
+		return false
+	}
+
+	return coreVersion(requiredVersion).GreaterThan(coreVersion(grafanaVersion))
+}
+
+// coreVersion strips the pre-release and metadata of a version so that a beta of a release satisfies the release.
+func coreVersion(v *version.Version) *version.Version {
+	segments := v.Segments()
+	core, err := version.NewVersion(fmt.Sprintf("%d.%d.%d", segments[0], segments[1], segments[2]))
+	if err != nil {
+		return v
+	}
+	return core
+}
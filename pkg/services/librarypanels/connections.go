@@ -0,0 +1,207 @@
+package librarypanels
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	connectionSortUpdatedAsc  = "updated-asc"
+	connectionSortUpdatedDesc = "updated-desc"
+)
+
+// searchConnectionsQuery is the query used for paging through the dashboards connected to a LibraryPanel
+type searchConnectionsQuery struct {
+	perPage int
+	page    int
+	sort    string
+	// folderID limits the connections to dashboards in a folder, -1 doesn't filter by folder.
+	folderID int64
+}
+
+// LibraryPanelConnectionsResult is a page of the dashboards connected to a library panel.
+type LibraryPanelConnectionsResult struct {
+	TotalCount  int64                       `json:"totalCount"`
+	Connections []LibraryPanelConnectionDTO `json:"connections"`
+	Page        int                         `json:"page"`
+	PerPage     int                         `json:"perPage"`
+}
+
+func connectionsOrderBy(sort string) (string, error) {
+	switch sort {
+	case "", search.SortAlphaAsc.Name:
+		return " ORDER BY dashboard.title ASC, dashboard.id ASC", nil
+	case search.SortAlphaDesc.Name:
+		return " ORDER BY dashboard.title DESC, dashboard.id DESC", nil
+	case connectionSortUpdatedAsc:
+		return " ORDER BY dashboard.updated ASC, dashboard.id ASC", nil
+	case connectionSortUpdatedDesc:
+		return " ORDER BY dashboard.updated DESC, dashboard.id DESC", nil
+	default:
+		return "", errLibraryPanelInvalidConnectionSort
+	}
+}
+
+func countConnections(session *sqlstore.DBSession, builder sqlstore.SQLBuilder) (int64, error) {
+	var counts []struct {
+		Count int64 `xorm:"count"`
+	}
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&counts); err != nil {
+		return 0, err
+	}
+	if len(counts) == 0 {
+		return 0, nil
+	}
+
+	return counts[0].Count, nil
+}
+
+// getConnections gets a page of the dashboards connected to a Library Panel with their metadata. Dashboards the
+// signed in user can view are sorted and filtered by folder. Dashboards they can't view only have their id, come
+// after the ones they can view ordered by id and are left out when filtering by folder, so their titles and
+// folders aren't leaked.
+func (lps *LibraryPanelService) getConnections(c *models.ReqContext, uid string, query searchConnectionsQuery) (LibraryPanelConnectionsResult, error) {
+	if query.perPage <= 0 {
+		query.perPage = 100
+	}
+	if query.page <= 0 {
+		query.page = 1
+	}
+	orderBy, err := connectionsOrderBy(query.sort)
+	if err != nil {
+		return LibraryPanelConnectionsResult{}, err
+	}
+
+	result := LibraryPanelConnectionsResult{
+		Connections: make([]LibraryPanelConnectionDTO, 0),
+		Page:        query.page,
+		PerPage:     query.perPage,
+	}
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+
+		writeViewableSQL := func(builder *sqlstore.SQLBuilder, joinMeta bool) {
+			builder.Write(" FROM library_panel_dashboard AS lpd")
+			builder.Write(" INNER JOIN dashboard AS dashboard ON lpd.dashboard_id = dashboard.id")
+			if joinMeta {
+				builder.Write(" LEFT JOIN dashboard AS folder ON folder.id = dashboard.folder_id")
+				builder.Write(" LEFT JOIN " + lps.SQLStore.Dialect.Quote("user") + " AS u ON u.id = lpd.created_by")
+			}
+			builder.Write(" WHERE lpd.librarypanel_id=?", panel.ID)
+			if query.folderID >= 0 {
+				builder.Write(" AND dashboard.folder_id=?", query.folderID)
+			}
+			if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
+				builder.WriteDashboardPermissionFilter(c.SignedInUser, models.PERMISSION_VIEW)
+			}
+		}
+		countBuilder := sqlstore.SQLBuilder{}
+		countBuilder.Write("SELECT COUNT(*) AS count")
+		writeViewableSQL(&countBuilder, false)
+		viewableCount, err := countConnections(session, countBuilder)
+		if err != nil {
+			return err
+		}
+
+		// dashboards the user can't view are all connections that aren't in the viewable ones
+		writeHiddenSQL := func(builder *sqlstore.SQLBuilder) {
+			viewable := sqlstore.SQLBuilder{}
+			viewable.Write("SELECT lpd.dashboard_id")
+			writeViewableSQL(&viewable, false)
+			builder.Write(" FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id=?", panel.ID)
+			builder.Write(" AND lpd.dashboard_id NOT IN ("+viewable.GetSQLString()+")", viewable.GetParams()...)
+		}
+		var hiddenCount int64
+		if c.SignedInUser.OrgRole != models.ROLE_ADMIN && query.folderID < 0 {
+			countBuilder = sqlstore.SQLBuilder{}
+			countBuilder.Write("SELECT COUNT(*) AS count")
+			writeHiddenSQL(&countBuilder)
+			if hiddenCount, err = countConnections(session, countBuilder); err != nil {
+				return err
+			}
+		}
+		result.TotalCount = viewableCount + hiddenCount
+
+		offset := int64(query.perPage * (query.page - 1))
+		if offset < viewableCount {
+			var rows []struct {
+				DashboardID     int64     `xorm:"dashboard_id"`
+				DashboardUID    string    `xorm:"dashboard_uid"`
+				DashboardTitle  string    `xorm:"dashboard_title"`
+				FolderID        int64     `xorm:"folder_id"`
+				FolderUID       string    `xorm:"folder_uid"`
+				FolderTitle     string    `xorm:"folder_title"`
+				Created         time.Time `xorm:"created"`
+				CreatedBy       int64     `xorm:"created_by"`
+				CreatedByName   string    `xorm:"created_by_name"`
+				CreatedByEmail  string    `xorm:"created_by_email"`
+				Overrides       []byte    `xorm:"overrides"`
+				AcceptedVersion int64     `xorm:"accepted_version"`
+			}
+			builder := sqlstore.SQLBuilder{}
+			builder.Write(`SELECT lpd.dashboard_id, dashboard.uid AS dashboard_uid, dashboard.title AS dashboard_title, dashboard.folder_id
+	, folder.uid AS folder_uid, folder.title AS folder_title, lpd.created, lpd.created_by, lpd.overrides, lpd.accepted_version
+	, u.login AS created_by_name, u.email AS created_by_email`)
+			writeViewableSQL(&builder, true)
+			builder.Write(orderBy)
+			builder.Write(lps.SQLStore.Dialect.LimitOffset(int64(query.perPage), offset))
+			if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if row.FolderID == 0 {
+					row.FolderTitle = "General"
+				}
+				result.Connections = append(result.Connections, LibraryPanelConnectionDTO{
+					DashboardID:    row.DashboardID,
+					DashboardUID:   row.DashboardUID,
+					DashboardTitle: row.DashboardTitle,
+					FolderID:       row.FolderID,
+					FolderUID:      row.FolderUID,
+					FolderTitle:    row.FolderTitle,
+					CanView:        true,
+					Created:        row.Created,
+					CreatedBy: LibraryPanelDTOMetaUser{
+						ID:        row.CreatedBy,
+						Name:      row.CreatedByName,
+						AvatarUrl: dtos.GetGravatarUrl(row.CreatedByEmail),
+					},
+					Overrides:       row.Overrides,
+					AcceptedVersion: row.AcceptedVersion,
+				})
+			}
+		}
+
+		remaining := int64(query.perPage - len(result.Connections))
+		if hiddenCount == 0 || remaining == 0 {
+			return nil
+		}
+		hiddenOffset := offset - viewableCount
+		if hiddenOffset < 0 {
+			hiddenOffset = 0
+		}
+		var hidden []libraryPanelDashboard
+		builder := sqlstore.SQLBuilder{}
+		builder.Write("SELECT lpd.*")
+		writeHiddenSQL(&builder)
+		builder.Write(" ORDER BY lpd.dashboard_id ASC")
+		builder.Write(lps.SQLStore.Dialect.LimitOffset(remaining, hiddenOffset))
+		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&hidden); err != nil {
+			return err
+		}
+		for _, lpd := range hidden {
+			result.Connections = append(result.Connections, LibraryPanelConnectionDTO{DashboardID: lpd.DashboardID})
+		}
+
+		return nil
+	})
+
+	return result, err
+}
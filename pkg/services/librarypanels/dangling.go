@@ -0,0 +1,62 @@
+package librarypanels
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	danglingReasonDashboardDeleted    = "dashboard_deleted"
+	danglingReasonLibraryPanelDeleted = "library_panel_deleted"
+)
+
+var danglingConnectionsCleaned *prometheus.CounterVec
+
+func init() {
+	danglingConnectionsCleaned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "library_panels_dangling_connections_cleaned_total",
+		Help:      "Number of library panel connections removed because their dashboard or library panel was deleted",
+		Namespace: "grafana",
+	}, []string{"reason"})
+}
+
+// danglingConnectionsResult is the number of dangling connections that were removed, by what they pointed at.
+type danglingConnectionsResult struct {
+	DashboardDeleted    int64
+	LibraryPanelDeleted int64
+}
+
+// cleanDanglingConnections removes the connections to dashboards or library panels that no longer exist. They're
+// left behind when a dashboard or library panel is deleted without going through this service, and would otherwise
+// count as usage and block deleting library panels. Library panels in the trash keep their connections.
+func (lps *LibraryPanelService) cleanDanglingConnections(ctx context.Context) (danglingConnectionsResult, error) {
+	var result danglingConnectionsResult
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		deleted, err := session.Exec("DELETE FROM library_panel_dashboard WHERE NOT EXISTS (SELECT 1 FROM dashboard WHERE dashboard.id = library_panel_dashboard.dashboard_id)")
+		if err != nil {
+			return err
+		}
+		if result.DashboardDeleted, err = deleted.RowsAffected(); err != nil {
+			return err
+		}
+
+		deleted, err = session.Exec("DELETE FROM library_panel_dashboard WHERE NOT EXISTS (SELECT 1 FROM library_panel WHERE library_panel.id = library_panel_dashboard.librarypanel_id)")
+		if err != nil {
+			return err
+		}
+		result.LibraryPanelDeleted, err = deleted.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return danglingConnectionsResult{}, err
+	}
+
+	danglingConnectionsCleaned.WithLabelValues(danglingReasonDashboardDeleted).Add(float64(result.DashboardDeleted))
+	danglingConnectionsCleaned.WithLabelValues(danglingReasonLibraryPanelDeleted).Add(float64(result.LibraryPanelDeleted))
+
+	return result, nil
+}
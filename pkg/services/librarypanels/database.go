@@ -1,28 +1,37 @@
 package librarypanels
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/models"
-	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
-	"github.com/grafana/grafana/pkg/util"
 )
 
 var (
-	selectLibrayPanelDTOWithMeta = `
+	selectLibrayPanelDTOWithMetaWithoutConnections = `
 SELECT DISTINCT
-	lp.name, lp.id, lp.org_id, lp.folder_id, lp.uid, lp.type, lp.description, lp.model, lp.created, lp.created_by, lp.updated, lp.updated_by, lp.version
+	lp.name, lp.id, lp.org_id, lp.folder_id, lp.uid, lp.type, lp.description, lp.model, lp.model_hash, lp.created, lp.created_by, lp.updated, lp.updated_by, lp.version, lp.provisioned, lp.last_connected_at, lp.last_viewed_at
 	, 0 AS can_edit
 	, u1.login AS created_by_name
 	, u1.email AS created_by_email
 	, u2.login AS updated_by_name
 	, u2.email AS updated_by_email
-	, (SELECT COUNT(dashboard_id) FROM library_panel_dashboard WHERE librarypanel_id = lp.id) AS connected_dashboards
+`
+	selectLibrayPanelDTOWithMeta = selectLibrayPanelDTOWithMetaWithoutConnections + `	, (SELECT COUNT(dashboard_id) FROM library_panel_dashboard WHERE librarypanel_id = lp.id) AS connected_dashboards
+`
+	// selectLibrayPanelDTOForSearch leaves out the connections subquery, search fetches them per page with getConnectedDashboardCounts.
+	selectLibrayPanelDTOForSearch = selectLibrayPanelDTOWithMetaWithoutConnections + `	, 0 AS connected_dashboards
 `
 	fromLibrayPanelDTOWithMeta = `
 FROM library_panel AS lp
@@ -30,6 +39,13 @@ FROM library_panel AS lp
 	LEFT JOIN user AS u2 ON lp.updated_by = u2.id
 `
 	sqlStatmentLibrayPanelDTOWithMeta = selectLibrayPanelDTOWithMeta + fromLibrayPanelDTOWithMeta
+	// sqlStatmentLibraryPanelByUID and sqlStatmentLibraryPanelsForDashboard are the queries used to hydrate library
+	// panels, their query plans are covered by TestLibraryPanelQueryPlans.
+	sqlStatmentLibraryPanelByUID         = sqlStatmentLibrayPanelDTOWithMeta + "WHERE lp.uid=? AND lp.org_id=? AND lp.deleted_at IS NULL"
+	sqlStatmentLibraryPanelsForDashboard = selectLibrayPanelDTOWithMeta + ", coalesce(dashboard.title, 'General') AS folder_name, coalesce(dashboard.uid, '') AS folder_uid " + fromLibrayPanelDTOWithMeta + `
+LEFT JOIN dashboard AS dashboard ON dashboard.id = lp.folder_id AND dashboard.id=?
+INNER JOIN library_panel_dashboard AS lpd ON lpd.librarypanel_id = lp.id AND lpd.dashboard_id=?
+`
 )
 
 func syncFieldsWithModel(libraryPanel *LibraryPanel) error {
@@ -40,12 +56,20 @@ func syncFieldsWithModel(libraryPanel *LibraryPanel) error {
 
 	model["title"] = libraryPanel.Name
 	if model["type"] != nil {
-		libraryPanel.Type = model["type"].(string)
+		panelType, ok := model["type"].(string)
+		if !ok {
+			return errLibraryPanelInvalidModel
+		}
+		libraryPanel.Type = panelType
 	} else {
 		model["type"] = libraryPanel.Type
 	}
 	if model["description"] != nil {
-		libraryPanel.Description = model["description"].(string)
+		description, ok := model["description"].(string)
+		if !ok {
+			return errLibraryPanelInvalidModel
+		}
+		libraryPanel.Description = description
 	} else {
 		model["description"] = libraryPanel.Description
 	}
@@ -54,17 +78,109 @@ func syncFieldsWithModel(libraryPanel *LibraryPanel) error {
 		return err
 	}
 
+	normalizedHash, err := normalizedModelHash(syncedModel)
+	if err != nil {
+		return err
+	}
+
 	libraryPanel.Model = syncedModel
+	libraryPanel.ModelHash = hashModel(syncedModel)
+	libraryPanel.NormalizedHash = normalizedHash
+
+	return nil
+}
+
+// hashModel returns the content hash of a library panel model that is stored in model_hash.
+func hashModel(model json.RawMessage) string {
+	sum := sha256.Sum256(model)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// storedModelHash returns the stored content hash of a library panel model. Library panels that weren't written
+// since model_hash was added have no stored hash, so it's computed instead.
+func storedModelHash(hash string, model json.RawMessage) string {
+	if hash == "" {
+		return hashModel(model)
+	}
+
+	return hash
+}
+
+// replaceDefaultDatasource replaces the default datasource of the panel model and its targets by datasourceUID. A
+// panel with queries is on the default datasource when its datasource is "default", null or missing, a target only
+// when its datasource is "default", otherwise it uses the datasource of its panel.
+func replaceDefaultDatasource(model json.RawMessage, datasourceUID string) (json.RawMessage, error) {
+	var panel map[string]interface{}
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return nil, err
+	}
+
+	targets, hasTargets := panel["targets"].([]interface{})
+	if datasource, ok := panel["datasource"]; datasource == defaultDatasource || (hasTargets && (!ok || datasource == nil)) {
+		panel["datasource"] = datasourceUID
+	}
+	for _, t := range targets {
+		target, ok := t.(map[string]interface{})
+		if ok && target["datasource"] == defaultDatasource {
+			target["datasource"] = datasourceUID
+		}
+	}
+
+	return json.Marshal(&panel)
+}
+
+func getDefaultDatasourceUID(session *sqlstore.DBSession, orgID int64) (string, error) {
+	var datasources []struct {
+		UID string `xorm:"uid"`
+	}
+	err := session.SQL("SELECT uid FROM data_source WHERE org_id=? AND is_default=?", orgID, true).Find(&datasources)
+	if err != nil {
+		return "", err
+	}
+	if len(datasources) == 0 {
+		return "", nil
+	}
+
+	return datasources[0].UID, nil
+}
+
+// resolveDefaultDatasource resolves the default datasource in the given library panel models to the UID of the
+// default datasource of the signed in user's org.
+func (lps *LibraryPanelService) resolveDefaultDatasource(c *models.ReqContext, libraryPanels ...*LibraryPanelDTO) error {
+	var datasourceUID string
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		uid, err := getDefaultDatasourceUID(session, c.SignedInUser.OrgId)
+		datasourceUID = uid
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(datasourceUID) == 0 {
+		return nil
+	}
+
+	for _, libraryPanel := range libraryPanels {
+		model, err := replaceDefaultDatasource(libraryPanel.Model, datasourceUID)
+		if err != nil {
+			return err
+		}
+		libraryPanel.Model = model
+	}
 
 	return nil
 }
 
 // createLibraryPanel adds a Library Panel.
 func (lps *LibraryPanelService) createLibraryPanel(c *models.ReqContext, cmd createLibraryPanelCommand) (LibraryPanelDTO, error) {
+	uid, err := lps.generateUID()
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
 	libraryPanel := LibraryPanel{
 		OrgID:    c.SignedInUser.OrgId,
 		FolderID: cmd.FolderID,
-		UID:      util.GenerateShortUID(),
+		UID:      uid,
 		Name:     cmd.Name,
 		Model:    cmd.Model,
 		Version:  1,
@@ -79,20 +195,81 @@ func (lps *LibraryPanelService) createLibraryPanel(c *models.ReqContext, cmd cre
 	if err := syncFieldsWithModel(&libraryPanel); err != nil {
 		return LibraryPanelDTO{}, err
 	}
+	if err := validateLabels(cmd.Labels); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	tags, err := normalizeTags(cmd.Tags)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	inputs, err := normalizeInputs(cmd.Inputs)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
 
-	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+	folderName := "General"
+	folderUID := ""
+	span, ctx := startSpan(c.Context.Req.Context(), "createLibraryPanel", c.SignedInUser.OrgId)
+	span.SetTag("uid", libraryPanel.UID)
+	err = lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
 		if err := lps.requirePermissionsOnFolder(c.SignedInUser, cmd.FolderID); err != nil {
 			return err
 		}
+		if err := lps.requireLibraryPanelAccess(c.SignedInUser, accesscontrol.ActionLibraryPanelsCreate, "", cmd.FolderID); err != nil {
+			return err
+		}
+		if err := requireLabelPolicy(session, c.SignedInUser.OrgId, cmd.Labels); err != nil {
+			return err
+		}
+		// count the used quota in the insert transaction, so concurrent creates can't both pass the check.
+		if lps.QuotaService != nil {
+			limitReached, err := lps.QuotaService.QuotaReachedWithSession(c, session, sqlstore.LIBRARY_PANEL_TARGET)
+			if err != nil {
+				return err
+			}
+			if limitReached {
+				return errLibraryPanelQuotaReached
+			}
+		}
+		if !isGeneralFolder(cmd.FolderID) {
+			s := dashboards.NewFolderService(c.SignedInUser.OrgId, c.SignedInUser, lps.SQLStore)
+			folder, err := s.GetFolderByID(cmd.FolderID)
+			if err != nil {
+				return err
+			}
+			folderName = folder.Title
+			folderUID = folder.Uid
+		}
 		if _, err := session.Insert(&libraryPanel); err != nil {
 			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
 				return errLibraryPanelAlreadyExists
 			}
 			return err
 		}
-		return nil
+		if err := setLabelsForLibraryPanel(session, libraryPanel.ID, cmd.Labels); err != nil {
+			return err
+		}
+		if err := setDatasourcesForLibraryPanel(session, libraryPanel.ID, libraryPanel.Model); err != nil {
+			return err
+		}
+		if err := setInputsForLibraryPanel(session, libraryPanel.ID, inputs); err != nil {
+			return err
+		}
+		if err := recordLibraryPanelVersion(session, libraryPanel.ID, c.SignedInUser.UserId); err != nil {
+			return err
+		}
+		detail := fmt.Sprintf("created in folder %d", libraryPanel.FolderID)
+		if err := addAuditEntry(session, libraryPanel, auditActionCreate, detail, 0, libraryPanel.Version, c.SignedInUser.UserId); err != nil {
+			return err
+		}
+		return setTagsForLibraryPanel(session, libraryPanel.ID, tags)
 	})
+	finishSpan(span, err)
 
+	labels := cmd.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
 	dto := LibraryPanelDTO{
 		ID:          libraryPanel.ID,
 		OrgID:       libraryPanel.OrgID,
@@ -102,9 +279,16 @@ func (lps *LibraryPanelService) createLibraryPanel(c *models.ReqContext, cmd cre
 		Type:        libraryPanel.Type,
 		Description: libraryPanel.Description,
 		Model:       libraryPanel.Model,
+		ModelHash:   libraryPanel.ModelHash,
 		Version:     libraryPanel.Version,
+		Labels:      labels,
+		Tags:        tags,
+		Inputs:      inputs,
 		Meta: LibraryPanelDTOMeta{
 			CanEdit:             true,
+			MinGrafanaVersion:   minGrafanaVersion(libraryPanel.Model),
+			FolderName:          folderName,
+			FolderUID:           folderUID,
 			ConnectedDashboards: 0,
 			Created:             libraryPanel.Created,
 			Updated:             libraryPanel.Updated,
@@ -121,26 +305,63 @@ func (lps *LibraryPanelService) createLibraryPanel(c *models.ReqContext, cmd cre
 		},
 	}
 
+	if err == nil {
+		lps.scanSavedLibraryPanel(c.Context.Req.Context(), dto)
+	}
 	return dto, err
 }
 
+// cloneLibraryPanel copies a Library Panel into a new Library Panel.
+func (lps *LibraryPanelService) cloneLibraryPanel(c *models.ReqContext, uid string, cmd cloneLibraryPanelCommand) (LibraryPanelDTO, error) {
+	source, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+
+	createCmd := createLibraryPanelCommand{
+		FolderID: cmd.FolderID,
+		Name:     cmd.Name,
+		Model:    source.Model,
+		Labels:   source.Labels,
+		Tags:     source.Tags,
+		Inputs:   source.Inputs,
+	}
+	if createCmd.FolderID == -1 {
+		createCmd.FolderID = source.FolderID
+	}
+	if createCmd.Name == "" {
+		createCmd.Name = source.Name + " Copy"
+	}
+
+	return lps.createLibraryPanel(c, createCmd)
+}
+
 // connectDashboard adds a connection between a Library Panel and a Dashboard.
 func (lps *LibraryPanelService) connectDashboard(c *models.ReqContext, uid string, dashboardID int64) error {
+	var panelID int64
 	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
-		return lps.internalConnectDashboard(session, c.SignedInUser, uid, dashboardID)
+		var err error
+		panelID, err = lps.internalConnectDashboard(session, c.SignedInUser, uid, dashboardID)
+		return err
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	lps.recordConnected(panelID)
+	return nil
 }
 
+// internalConnectDashboard adds a connection between a Library Panel and a Dashboard and returns the id of the
+// Library Panel.
 func (lps *LibraryPanelService) internalConnectDashboard(session *sqlstore.DBSession, user *models.SignedInUser,
-	uid string, dashboardID int64) error {
+	uid string, dashboardID int64) (int64, error) {
 	panel, err := getLibraryPanel(session, uid, user.OrgId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if err := lps.requirePermissionsOnFolder(user, panel.FolderID); err != nil {
-		return err
+		return 0, err
 	}
 
 	libraryPanelDashboard := libraryPanelDashboard{
@@ -149,66 +370,183 @@ func (lps *LibraryPanelService) internalConnectDashboard(session *sqlstore.DBSes
 		Created:        time.Now(),
 		CreatedBy:      user.UserId,
 	}
+	if lps.Cfg.PanelLibraryRequireUpdateApproval {
+		libraryPanelDashboard.AcceptedVersion = panel.Version
+		libraryPanelDashboard.AcceptedModel = panel.Model
+	}
 	if _, err := session.Insert(&libraryPanelDashboard); err != nil {
 		if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
-			return nil
+			return panel.ID, nil
 		}
-		return err
+		return 0, err
 	}
-	return nil
+	return panel.ID, nil
 }
 
 // connectLibraryPanelsForDashboard adds connections for all Library Panels in a Dashboard.
 func (lps *LibraryPanelService) connectLibraryPanelsForDashboard(c *models.ReqContext, uids []string, dashboardID int64) error {
+	panelIDs := make([]int64, 0, len(uids))
 	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
-		_, err := session.Exec("DELETE FROM library_panel_dashboard WHERE dashboard_id=?", dashboardID)
+		// connections are recreated on every save, their overrides, accepted versions and who connected them when
+		// are kept for the library panels that remain
+		previous, err := getConnectionsForDashboard(session, dashboardID)
+		if err != nil {
+			return err
+		}
+		_, err = session.Exec("DELETE FROM library_panel_dashboard WHERE dashboard_id=?", dashboardID)
 		if err != nil {
 			return err
 		}
 		for _, uid := range uids {
-			err := lps.internalConnectDashboard(session, c.SignedInUser, uid, dashboardID)
+			panelID, err := lps.internalConnectDashboard(session, c.SignedInUser, uid, dashboardID)
 			if err != nil {
 				return err
 			}
+			panelIDs = append(panelIDs, panelID)
+		}
+		for libraryPanelID, connection := range previous {
+			if _, err := session.Exec(`UPDATE library_panel_dashboard SET overrides=?, accepted_version=?, accepted_model=?, rejected_version=?, canary_id=?, created=?, created_by=?
+WHERE librarypanel_id=? AND dashboard_id=?`, jsonColumn(connection.Overrides), connection.AcceptedVersion, jsonColumn(connection.AcceptedModel),
+				connection.RejectedVersion, connection.CanaryID, connection.Created, connection.CreatedBy, libraryPanelID, dashboardID); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	lps.recordConnected(panelIDs...)
+	return nil
 }
 
-// deleteLibraryPanel deletes a Library Panel.
-func (lps *LibraryPanelService) deleteLibraryPanel(c *models.ReqContext, uid string) error {
-	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+// deleteLibraryPanel deletes a Library Panel. When force is set, the Library Panel is unlinked from all dashboards in
+// the same transaction, they keep a copy of it in its place, and the uids of those dashboards are returned.
+func (lps *LibraryPanelService) deleteLibraryPanel(c *models.ReqContext, uid string, force bool, version int64) ([]string, error) {
+	dashboardUIDs := make([]string, 0)
+	span, ctx := startSpan(c.Context.Req.Context(), "deleteLibraryPanel", c.SignedInUser.OrgId)
+	span.SetTag("uid", uid)
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
 		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
 		if err != nil {
 			return err
 		}
-		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
-			return err
-		}
-		var dashIDs []struct {
-			DashboardID int64 `xorm:"dashboard_id"`
-		}
-		sql := "SELECT dashboard_id FROM library_panel_dashboard WHERE librarypanel_id=?"
-		if err := session.SQL(sql, panel.ID).Find(&dashIDs); err != nil {
-			return err
-		} else if len(dashIDs) > 0 {
-			return errLibraryPanelHasConnectedDashboards
+		if version != 0 && panel.Version != version {
+			return errLibraryPanelVersionMismatch
 		}
-
-		result, err := session.Exec("DELETE FROM library_panel WHERE id=?", panel.ID)
-		if err != nil {
+		if err := lps.requireDeletePermissions(c.SignedInUser, panel); err != nil {
 			return err
 		}
-		if rowsAffected, err := result.RowsAffected(); err != nil {
-			return err
-		} else if rowsAffected != 1 {
-			return errLibraryPanelNotFound
+		if force {
+			if dashboardUIDs, err = unlinkConnectedDashboards(session, c.SignedInUser, panel); err != nil {
+				return err
+			}
 		}
 
+		return lps.trashLibraryPanel(session, c.SignedInUser, panel)
+	})
+	span.SetTag("disconnectedDashboards", len(dashboardUIDs))
+	finishSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboardUIDs, nil
+}
+
+// deleteLibraryPanels deletes several Library Panels in one transaction and reports the outcome for each uid.
+func (lps *LibraryPanelService) deleteLibraryPanels(c *models.ReqContext, uids []string) ([]DeleteLibraryPanelResultDTO, error) {
+	results := make([]DeleteLibraryPanelResultDTO, 0, len(uids))
+	span, ctx := startSpan(c.Context.Req.Context(), "deleteLibraryPanels", c.SignedInUser.OrgId)
+	span.SetTag("uidCount", len(uids))
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		for _, uid := range uids {
+			status, err := toDeleteLibraryPanelStatus(lps.internalDeleteLibraryPanel(session, c.SignedInUser, uid))
+			if err != nil {
+				return err
+			}
+			results = append(results, DeleteLibraryPanelResultDTO{UID: uid, Status: status})
+		}
 		return nil
 	})
+	finishSpan(span, err)
+
+	return results, err
+}
+
+func toDeleteLibraryPanelStatus(err error) (string, error) {
+	switch {
+	case err == nil:
+		return deleteStatusDeleted, nil
+	case errors.Is(err, errLibraryPanelNotFound):
+		return deleteStatusNotFound, nil
+	case errors.Is(err, errLibraryPanelHasConnectedDashboards):
+		return deleteStatusHasConnections, nil
+	case errors.Is(err, errLibraryPanelProvisioned):
+		return deleteStatusProvisioned, nil
+	case errors.Is(err, errLibraryPanelFrozen):
+		return deleteStatusFrozen, nil
+	case errors.Is(err, models.ErrFolderNotFound), errors.Is(err, models.ErrFolderAccessDenied), errors.Is(err, errLibraryPanelAccessDenied):
+		return deleteStatusAccessDenied, nil
+	default:
+		return "", err
+	}
+}
+
+// requireDeletePermissions checks that a user is allowed to delete a Library Panel.
+func (lps *LibraryPanelService) requireDeletePermissions(user *models.SignedInUser, panel LibraryPanelWithMeta) error {
+	if err := lps.requirePermissionsOnFolder(user, panel.FolderID); err != nil {
+		return err
+	}
+
+	return lps.requireLibraryPanelAccess(user, accesscontrol.ActionLibraryPanelsDelete, panel.UID, panel.FolderID)
+}
+
+func (lps *LibraryPanelService) internalDeleteLibraryPanel(session *sqlstore.DBSession, user *models.SignedInUser, uid string) error {
+	panel, err := getLibraryPanel(session, uid, user.OrgId)
+	if err != nil {
+		return err
+	}
+	if err := lps.requireDeletePermissions(user, panel); err != nil {
+		return err
+	}
+
+	return lps.trashLibraryPanel(session, user, panel)
+}
+
+// trashLibraryPanel moves a Library Panel without connections to the trash. It only reads through session, so it can
+// run after the Dashboards of a force delete were unlinked in the same transaction.
+func (lps *LibraryPanelService) trashLibraryPanel(session *sqlstore.DBSession, user *models.SignedInUser, panel LibraryPanelWithMeta) error {
+	if panel.Provisioned {
+		return errLibraryPanelProvisioned
+	}
+	if err := lps.requireNotFrozen(session, user, panel); err != nil {
+		return err
+	}
+	var dashIDs []struct {
+		DashboardID int64 `xorm:"dashboard_id"`
+	}
+	sql := "SELECT dashboard_id FROM library_panel_dashboard WHERE librarypanel_id=?"
+	if err := session.SQL(sql, panel.ID).Find(&dashIDs); err != nil {
+		return err
+	} else if len(dashIDs) > 0 {
+		return errLibraryPanelHasConnectedDashboards
+	}
+
+	// Library panels are moved to the trash, purgeLibraryPanel removes them for good
+	result, err := session.Exec(trashLibraryPanelSQL+" WHERE id=?", time.Now(), trashedName(panel.UID), panel.ID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected != 1 {
+		return errLibraryPanelNotFound
+	}
+
+	deleted := LibraryPanel{ID: panel.ID, OrgID: panel.OrgID, UID: panel.UID, Name: panel.Name}
+	return addAuditEntry(session, deleted, auditActionDelete, "moved to the trash", panel.Version, 0, user.UserId)
 }
 
 // disconnectDashboard deletes a connection between a Library Panel and a Dashboard.
@@ -294,14 +632,10 @@ func (lps *LibraryPanelService) deleteLibraryPanelsInFolder(c *models.ReqContext
 			return err
 		}
 		for _, panelID := range panelIDs {
-			_, err := session.Exec("DELETE FROM library_panel_dashboard WHERE librarypanel_id=?", panelID.ID)
-			if err != nil {
+			if err := purgeLibraryPanelRows(session, panelID.ID); err != nil {
 				return err
 			}
 		}
-		if _, err := session.Exec("DELETE FROM library_panel WHERE folder_id=? AND org_id=?", folderID, c.SignedInUser.OrgId); err != nil {
-			return err
-		}
 
 		return nil
 	})
@@ -309,8 +643,7 @@ func (lps *LibraryPanelService) deleteLibraryPanelsInFolder(c *models.ReqContext
 
 func getLibraryPanel(session *sqlstore.DBSession, uid string, orgID int64) (LibraryPanelWithMeta, error) {
 	libraryPanels := make([]LibraryPanelWithMeta, 0)
-	sql := sqlStatmentLibrayPanelDTOWithMeta + "WHERE lp.uid=? AND lp.org_id=?"
-	sess := session.SQL(sql, uid, orgID)
+	sess := session.SQL(sqlStatmentLibraryPanelByUID, uid, orgID)
 	err := sess.Find(&libraryPanels)
 	if err != nil {
 		return LibraryPanelWithMeta{}, err
@@ -325,28 +658,65 @@ func getLibraryPanel(session *sqlstore.DBSession, uid string, orgID int64) (Libr
 	return libraryPanels[0], nil
 }
 
+func getConnectedDashboardCounts(session *sqlstore.DBSession, panelIDs ...int64) (map[int64]int64, error) {
+	counts := make(map[int64]int64)
+	if len(panelIDs) == 0 {
+		return counts, nil
+	}
+
+	params := make([]interface{}, 0, len(panelIDs))
+	for _, id := range panelIDs {
+		params = append(params, id)
+	}
+	var rows []struct {
+		LibraryPanelID int64 `xorm:"librarypanel_id"`
+		Count          int64 `xorm:"count"`
+	}
+	sql := "SELECT librarypanel_id, COUNT(dashboard_id) AS count FROM library_panel_dashboard WHERE librarypanel_id IN (?" +
+		strings.Repeat(",?", len(panelIDs)-1) + ") GROUP BY librarypanel_id"
+	if err := session.SQL(sql, params...).Find(&rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.LibraryPanelID] = row.Count
+	}
+
+	return counts, nil
+}
+
 // getLibraryPanel gets a Library Panel.
 func (lps *LibraryPanelService) getLibraryPanel(c *models.ReqContext, uid string) (LibraryPanelDTO, error) {
 	var libraryPanel LibraryPanelWithMeta
-	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+	var labels map[string]string
+	var tags []string
+	var inputs []LibraryPanelInputDTO
+	var views int64
+	span, ctx := startSpan(c.Context.Req.Context(), "getLibraryPanel", c.SignedInUser.OrgId)
+	span.SetTag("uid", uid)
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
 		libraryPanels := make([]LibraryPanelWithMeta, 0)
 		builder := sqlstore.SQLBuilder{}
 		builder.Write(selectLibrayPanelDTOWithMeta)
 		builder.Write(", 'General' as folder_name ")
 		builder.Write(", '' as folder_uid ")
 		builder.Write(fromLibrayPanelDTOWithMeta)
-		builder.Write(` WHERE lp.uid=? AND lp.org_id=? AND lp.folder_id=0`, uid, c.SignedInUser.OrgId)
+		builder.Write(` WHERE lp.uid=? AND lp.org_id=? AND lp.folder_id=0 AND lp.deleted_at IS NULL`, uid, c.SignedInUser.OrgId)
 		builder.Write(" UNION ")
 		builder.Write(selectLibrayPanelDTOWithMeta)
 		builder.Write(", dashboard.title as folder_name ")
 		builder.Write(", dashboard.uid as folder_uid ")
 		builder.Write(fromLibrayPanelDTOWithMeta)
 		builder.Write(" INNER JOIN dashboard AS dashboard on lp.folder_id = dashboard.id AND lp.folder_id <> 0")
-		builder.Write(` WHERE lp.uid=? AND lp.org_id=?`, uid, c.SignedInUser.OrgId)
+		builder.Write(` WHERE lp.uid=? AND lp.org_id=? AND lp.deleted_at IS NULL`, uid, c.SignedInUser.OrgId)
 		if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
 			builder.WriteDashboardPermissionFilter(c.SignedInUser, models.PERMISSION_VIEW)
 		}
 		builder.Write(` OR dashboard.id=0`)
+		if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
+			builder.Write(` OR (lp.uid=? AND lp.org_id=? AND lp.deleted_at IS NULL`, uid, c.SignedInUser.OrgId)
+			writeSharedWithUserSQL(c.SignedInUser, &builder)
+			builder.Write(")")
+		}
 		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanels); err != nil {
 			return err
 		}
@@ -358,10 +728,47 @@ func (lps *LibraryPanelService) getLibraryPanel(c *models.ReqContext, uid string
 		}
 
 		libraryPanel = libraryPanels[0]
+		if err := lps.requireLibraryPanelAccess(c.SignedInUser, accesscontrol.ActionLibraryPanelsRead, libraryPanel.UID, libraryPanel.FolderID); err != nil {
+			return err
+		}
+
+		labelsByPanel, err := getLabelsForLibraryPanels(session, libraryPanel.ID)
+		if err != nil {
+			return err
+		}
+		labels = labelsByPanel[libraryPanel.ID]
+
+		tagsByPanel, err := getTagsForLibraryPanels(session, libraryPanel.ID)
+		if err != nil {
+			return err
+		}
+		tags = tagsByPanel[libraryPanel.ID]
+
+		inputsByPanel, err := getInputsForLibraryPanels(session, libraryPanel.ID)
+		if err != nil {
+			return err
+		}
+		inputs = inputsByPanel[libraryPanel.ID]
+
+		viewsByPanel, err := getViewsLast30Days(session, libraryPanel.ID)
+		if err != nil {
+			return err
+		}
+		views = viewsByPanel[libraryPanel.ID]
 
 		return nil
 	})
+	finishSpan(span, err)
 
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if tags == nil {
+		tags = make([]string, 0)
+	}
+	if inputs == nil {
+		inputs = make([]LibraryPanelInputDTO, 0)
+	}
 	dto := LibraryPanelDTO{
 		ID:          libraryPanel.ID,
 		OrgID:       libraryPanel.OrgID,
@@ -371,12 +778,21 @@ func (lps *LibraryPanelService) getLibraryPanel(c *models.ReqContext, uid string
 		Type:        libraryPanel.Type,
 		Description: libraryPanel.Description,
 		Model:       libraryPanel.Model,
+		ModelHash:   storedModelHash(libraryPanel.ModelHash, libraryPanel.Model),
 		Version:     libraryPanel.Version,
+		Labels:      labels,
+		Tags:        tags,
+		Inputs:      inputs,
 		Meta: LibraryPanelDTOMeta{
 			CanEdit:             true,
+			MinGrafanaVersion:   minGrafanaVersion(libraryPanel.Model),
 			FolderName:          libraryPanel.FolderName,
 			FolderUID:           libraryPanel.FolderUID,
 			ConnectedDashboards: libraryPanel.ConnectedDashboards,
+			ViewsLast30Days:     views,
+			LastConnectedAt:     libraryPanel.LastConnectedAt,
+			LastViewedAt:        libraryPanel.LastViewedAt,
+			Provisioned:         libraryPanel.Provisioned,
 			Created:             libraryPanel.Created,
 			Updated:             libraryPanel.Updated,
 			CreatedBy: LibraryPanelDTOMetaUser{
@@ -405,69 +821,117 @@ func (lps *LibraryPanelService) getAllLibraryPanels(c *models.ReqContext, query
 	if query.page <= 0 {
 		query.page = 1
 	}
-	var panelFilter []string
-	if len(strings.TrimSpace(query.panelFilter)) > 0 {
-		panelFilter = strings.Split(query.panelFilter, ",")
+	if err := lps.requireLibraryPanelAction(c.SignedInUser, accesscontrol.ActionLibraryPanelsRead); err != nil {
+		return LibraryPanelSearchResult{}, err
 	}
-	folderFilter := parseFolderFilter(query)
-	if folderFilter.parseError != nil {
-		return LibraryPanelSearchResult{}, folderFilter.parseError
+	if query.allOrgs && !c.SignedInUser.IsGrafanaAdmin {
+		return LibraryPanelSearchResult{}, errLibraryPanelAccessDenied
 	}
-	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
-		builder := sqlstore.SQLBuilder{}
-		if folderFilter.includeGeneralFolder {
-			builder.Write(selectLibrayPanelDTOWithMeta)
-			builder.Write(", 'General' as folder_name ")
-			builder.Write(", '' as folder_uid ")
-			builder.Write(fromLibrayPanelDTOWithMeta)
-			builder.Write(` WHERE lp.org_id=?  AND lp.folder_id=0`, c.SignedInUser.OrgId)
-			writeSearchStringSQL(query, lps.SQLStore, &builder)
-			writeExcludeSQL(query, &builder)
-			writePanelFilterSQL(panelFilter, &builder)
-			builder.Write(" UNION ")
-		}
-		builder.Write(selectLibrayPanelDTOWithMeta)
-		builder.Write(", dashboard.title as folder_name ")
-		builder.Write(", dashboard.uid as folder_uid ")
-		builder.Write(fromLibrayPanelDTOWithMeta)
-		builder.Write(" INNER JOIN dashboard AS dashboard on lp.folder_id = dashboard.id AND lp.folder_id<>0")
-		builder.Write(` WHERE lp.org_id=?`, c.SignedInUser.OrgId)
-		writeSearchStringSQL(query, lps.SQLStore, &builder)
-		writeExcludeSQL(query, &builder)
-		writePanelFilterSQL(panelFilter, &builder)
-		if err := folderFilter.writeFolderFilterSQL(false, &builder); err != nil {
+	// Connection counts are fetched for the whole page with a single grouped query instead of a subquery per row,
+	// with a latency budget they're skipped when the budget is spent.
+	start := time.Now()
+	latencyBudget := lps.Cfg.PanelLibrarySearchLatencyBudget
+	if len(strings.TrimSpace(query.datasourceUID)) > 0 {
+		err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+			refs, err := getDatasourceReferences(session, c.SignedInUser.OrgId, strings.TrimSpace(query.datasourceUID))
+			query.datasourceRefs = refs
 			return err
+		})
+		if err != nil {
+			return LibraryPanelSearchResult{}, err
 		}
-		if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
-			builder.WriteDashboardPermissionFilter(c.SignedInUser, models.PERMISSION_VIEW)
-		}
-		if query.sortDirection == search.SortAlphaDesc.Name {
-			builder.Write(" ORDER BY 1 DESC")
-		} else {
-			builder.Write(" ORDER BY 1 ASC")
-		}
-		writePerPageSQL(query, lps.SQLStore, &builder)
+	}
+	builder, countBuilder, err := lps.buildSearchLibraryPanelsSQL(c.SignedInUser, query, selectLibrayPanelDTOForSearch)
+	if err != nil {
+		return LibraryPanelSearchResult{}, err
+	}
+	partial := false
+	span, ctx := startSpan(c.Context.Req.Context(), "getAllLibraryPanels", c.SignedInUser.OrgId)
+	span.SetTag("page", query.page)
+	span.SetTag("perPage", query.perPage)
+	err = lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
 		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanels); err != nil {
 			return err
 		}
 
-		retDTOs := make([]LibraryPanelDTO, 0)
+		panelIDs := make([]int64, 0, len(libraryPanels))
 		for _, panel := range libraryPanels {
-			retDTOs = append(retDTOs, LibraryPanelDTO{
-				ID:          panel.ID,
-				OrgID:       panel.OrgID,
-				FolderID:    panel.FolderID,
-				UID:         panel.UID,
-				Name:        panel.Name,
-				Type:        panel.Type,
-				Description: panel.Description,
-				Model:       panel.Model,
-				Version:     panel.Version,
-				Meta: LibraryPanelDTOMeta{
-					CanEdit:             true,
-					FolderName:          panel.FolderName,
-					FolderUID:           panel.FolderUID,
+			panelIDs = append(panelIDs, panel.ID)
+		}
+		labelsByPanel, err := getLabelsForLibraryPanels(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		tagsByPanel, err := getTagsForLibraryPanels(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		inputsByPanel, err := getInputsForLibraryPanels(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		viewsByPanel, err := getViewsLast30Days(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		orgNames := make(map[int64]string)
+		if query.allOrgs {
+			if orgNames, err = lps.getOrgNamesForLibraryPanels(session, libraryPanels); err != nil {
+				return err
+			}
+		}
+		if latencyBudget <= 0 || time.Since(start) < latencyBudget {
+			counts, err := getConnectedDashboardCounts(session, panelIDs...)
+			if err != nil {
+				return err
+			}
+			for i := range libraryPanels {
+				libraryPanels[i].ConnectedDashboards = counts[libraryPanels[i].ID]
+			}
+		} else {
+			lps.log.Warn("Library panel search exceeded latency budget, skipping connected dashboards", "budget", latencyBudget)
+			partial = true
+		}
+
+		retDTOs := make([]LibraryPanelDTO, 0)
+		for _, panel := range libraryPanels {
+			labels := labelsByPanel[panel.ID]
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			tags := tagsByPanel[panel.ID]
+			if tags == nil {
+				tags = make([]string, 0)
+			}
+			inputs := inputsByPanel[panel.ID]
+			if inputs == nil {
+				inputs = make([]LibraryPanelInputDTO, 0)
+			}
+			retDTOs = append(retDTOs, LibraryPanelDTO{
+				ID:          panel.ID,
+				OrgID:       panel.OrgID,
+				FolderID:    panel.FolderID,
+				UID:         panel.UID,
+				Name:        panel.Name,
+				Type:        panel.Type,
+				Description: panel.Description,
+				Model:       panel.Model,
+				ModelHash:   storedModelHash(panel.ModelHash, panel.Model),
+				Version:     panel.Version,
+				Labels:      labels,
+				Tags:        tags,
+				Inputs:      inputs,
+				Meta: LibraryPanelDTOMeta{
+					CanEdit:             true,
+					MinGrafanaVersion:   minGrafanaVersion(panel.Model),
+					FolderName:          panel.FolderName,
+					FolderUID:           panel.FolderUID,
 					ConnectedDashboards: panel.ConnectedDashboards,
+					ViewsLast30Days:     viewsByPanel[panel.ID],
+					LastConnectedAt:     panel.LastConnectedAt,
+					LastViewedAt:        panel.LastViewedAt,
+					Provisioned:         panel.Provisioned,
+					OrgName:             orgNames[panel.OrgID],
 					Created:             panel.Created,
 					Updated:             panel.Updated,
 					CreatedBy: LibraryPanelDTOMetaUser{
@@ -484,33 +948,213 @@ func (lps *LibraryPanelService) getAllLibraryPanels(c *models.ReqContext, query
 			})
 		}
 
-		var panels []LibraryPanel
-		countBuilder := sqlstore.SQLBuilder{}
-		countBuilder.Write("SELECT * FROM library_panel AS lp")
-		countBuilder.Write(` WHERE lp.org_id=?`, c.SignedInUser.OrgId)
-		writeSearchStringSQL(query, lps.SQLStore, &countBuilder)
-		writeExcludeSQL(query, &countBuilder)
-		writePanelFilterSQL(panelFilter, &countBuilder)
-		if err := folderFilter.writeFolderFilterSQL(true, &countBuilder); err != nil {
-			return err
+		var counts []struct {
+			Count int64 `xorm:"count"`
 		}
-		if err := session.SQL(countBuilder.GetSQLString(), countBuilder.GetParams()...).Find(&panels); err != nil {
+		if err := session.SQL(countBuilder.GetSQLString(), countBuilder.GetParams()...).Find(&counts); err != nil {
 			return err
 		}
 
+		continueToken := ""
+		if canContinueSearch(query) && len(libraryPanels) == query.perPage {
+			last := libraryPanels[len(libraryPanels)-1]
+			continueToken = encodeContinueToken(last.Name, last.UID)
+		}
+
 		result = LibraryPanelSearchResult{
-			TotalCount:    int64(len(panels)),
+			TotalCount:    counts[0].Count,
 			LibraryPanels: retDTOs,
 			Page:          query.page,
 			PerPage:       query.perPage,
+			Partial:       partial,
+			ContinueToken: continueToken,
 		}
 
 		return nil
 	})
+	span.SetTag("resultCount", len(result.LibraryPanels))
+	span.SetTag("totalCount", result.TotalCount)
+	span.SetTag("partial", result.Partial)
+	finishSpan(span, err)
 
 	return result, err
 }
 
+func (lps *LibraryPanelService) getOrgNamesForLibraryPanels(session *sqlstore.DBSession, libraryPanels []LibraryPanelWithMeta) (map[int64]string, error) {
+	orgNames := make(map[int64]string)
+	if len(libraryPanels) == 0 {
+		return orgNames, nil
+	}
+
+	params := make([]interface{}, 0, len(libraryPanels))
+	for _, panel := range libraryPanels {
+		params = append(params, panel.OrgID)
+	}
+	var orgs []struct {
+		ID   int64  `xorm:"id"`
+		Name string `xorm:"name"`
+	}
+	sql := "SELECT id, name FROM " + lps.SQLStore.Dialect.Quote("org") + " WHERE id IN (?" + strings.Repeat(",?", len(params)-1) + ")"
+	if err := session.SQL(sql, params...).Find(&orgs); err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		orgNames[org.ID] = org.Name
+	}
+
+	return orgNames, nil
+}
+
+// getOwnedLibraryPanels gets the Library Panels created by a user. Only org admins can get the Library Panels
+// created by other users.
+func (lps *LibraryPanelService) getOwnedLibraryPanels(c *models.ReqContext, userID int64, query searchLibraryPanelsQuery) (LibraryPanelSearchResult, error) {
+	if userID == 0 {
+		userID = c.SignedInUser.UserId
+	}
+	if userID != c.SignedInUser.UserId && !c.SignedInUser.HasRole(models.ROLE_ADMIN) {
+		return LibraryPanelSearchResult{}, errLibraryPanelAccessDenied
+	}
+
+	query.createdBy = userID
+	return lps.getAllLibraryPanels(c, query)
+}
+
+// buildSearchLibraryPanelsSQL builds the query used to search library panels and the query used to count them.
+func (lps *LibraryPanelService) buildSearchLibraryPanelsSQL(user *models.SignedInUser, query searchLibraryPanelsQuery, selectSQL string) (sqlstore.SQLBuilder, sqlstore.SQLBuilder, error) {
+	builder := sqlstore.SQLBuilder{}
+	countBuilder := sqlstore.SQLBuilder{}
+	var panelFilter []string
+	if len(strings.TrimSpace(query.panelFilter)) > 0 {
+		panelFilter = strings.Split(query.panelFilter, ",")
+	}
+	folderFilter := parseFolderFilter(query)
+	if folderFilter.parseError != nil {
+		return builder, countBuilder, folderFilter.parseError
+	}
+	labelSelectors, err := parseLabelSelectors(query.labelSelector)
+	if err != nil {
+		return builder, countBuilder, err
+	}
+	tags, err := normalizeTags(query.tags)
+	if err != nil {
+		return builder, countBuilder, err
+	}
+	cursor, err := parseContinueToken(query.continueToken)
+	if err != nil {
+		return builder, countBuilder, err
+	}
+	sortFields, err := parseSearchSort(query.sort)
+	if err != nil {
+		return builder, countBuilder, err
+	}
+	if len(sortFields) > 0 && cursor != nil {
+		return builder, countBuilder, errLibraryPanelSortWithContinueToken
+	}
+	if query.allOrgs && cursor != nil {
+		return builder, countBuilder, errLibraryPanelAllOrgsWithContinueToken
+	}
+	if query.connected != "" && query.connected != "true" && query.connected != "false" {
+		return builder, countBuilder, errLibraryPanelInvalidConnectedFilter
+	}
+	var notUpdatedSince *time.Time
+	if query.notUpdatedSince != "" {
+		since, err := parseStaleWindow(query.notUpdatedSince, time.Now())
+		if err != nil {
+			return builder, countBuilder, err
+		}
+		notUpdatedSince = &since
+	}
+	var notConnectedSince, notViewedSince *time.Time
+	if query.notConnectedSince != "" {
+		since, err := parseStaleWindow(query.notConnectedSince, time.Now())
+		if err != nil {
+			return builder, countBuilder, err
+		}
+		notConnectedSince = &since
+	}
+	if query.notViewedSince != "" {
+		since, err := parseStaleWindow(query.notViewedSince, time.Now())
+		if err != nil {
+			return builder, countBuilder, err
+		}
+		notViewedSince = &since
+	}
+
+	writeWhereSQL := func(builder *sqlstore.SQLBuilder) {
+		// searching all orgs pages through every library panel, so it scans library_panel in full and is exempt
+		// from the query plan test. It's only allowed for server admins.
+		if query.allOrgs {
+			builder.Write(` WHERE lp.deleted_at IS NULL`)
+			return
+		}
+		builder.Write(` WHERE lp.org_id=? AND lp.deleted_at IS NULL`, user.OrgId)
+	}
+	writeFilterSQL := func(builder *sqlstore.SQLBuilder) {
+		writeSearchStringSQL(query, lps.SQLStore, builder)
+		writeDatasourceFilterSQL(query, builder)
+		writeExcludeSQL(query, builder)
+		writeCreatedBySQL(query, builder)
+		writeConnectedSQL(query, builder)
+		writeNotUpdatedSinceSQL(notUpdatedSince, builder)
+		writeNotUsedSinceSQL(notConnectedSince, notViewedSince, builder)
+		writePanelFilterSQL(panelFilter, builder)
+		writeLabelSelectorSQL(labelSelectors, builder)
+		writeTagFilterSQL(tags, builder)
+	}
+
+	if folderFilter.includeGeneralFolder {
+		builder.Write(selectSQL)
+		builder.Write(", 'General' as folder_name ")
+		builder.Write(", '' as folder_uid ")
+		builder.Write(fromLibrayPanelDTOWithMeta)
+		writeWhereSQL(&builder)
+		builder.Write(" AND lp.folder_id=0")
+		writeFilterSQL(&builder)
+		writeCursorSQL(query, cursor, &builder)
+		builder.Write(" UNION ")
+	}
+	builder.Write(selectSQL)
+	builder.Write(", dashboard.title as folder_name ")
+	builder.Write(", dashboard.uid as folder_uid ")
+	builder.Write(fromLibrayPanelDTOWithMeta)
+	builder.Write(" INNER JOIN dashboard AS dashboard on lp.folder_id = dashboard.id AND lp.folder_id<>0")
+	writeWhereSQL(&builder)
+	writeFilterSQL(&builder)
+	writeCursorSQL(query, cursor, &builder)
+	if err := folderFilter.writeFolderFilterSQL(false, &builder); err != nil {
+		return builder, countBuilder, err
+	}
+	// server admins searching all orgs see every library panel
+	if user.OrgRole != models.ROLE_ADMIN && !query.allOrgs {
+		builder.WriteDashboardPermissionFilter(user, models.PERMISSION_VIEW)
+		// library panels shared with the user or one of their teams are found regardless of folder permissions
+		builder.Write(" UNION ")
+		builder.Write(selectSQL)
+		builder.Write(", dashboard.title as folder_name ")
+		builder.Write(", dashboard.uid as folder_uid ")
+		builder.Write(fromLibrayPanelDTOWithMeta)
+		builder.Write(" INNER JOIN dashboard AS dashboard on lp.folder_id = dashboard.id AND lp.folder_id<>0")
+		builder.Write(` WHERE lp.org_id=? AND lp.deleted_at IS NULL`, user.OrgId)
+		writeFilterSQL(&builder)
+		writeCursorSQL(query, cursor, &builder)
+		if err := folderFilter.writeFolderFilterSQL(false, &builder); err != nil {
+			return builder, countBuilder, err
+		}
+		writeSharedWithUserSQL(user, &builder)
+	}
+	writeOrderBySQL(query, sortFields, &builder)
+	writePerPageSQL(query, lps.SQLStore, &builder)
+
+	countBuilder.Write("SELECT COUNT(*) AS count FROM library_panel AS lp")
+	writeWhereSQL(&countBuilder)
+	writeFilterSQL(&countBuilder)
+	if err := folderFilter.writeFolderFilterSQL(true, &countBuilder); err != nil {
+		return builder, countBuilder, err
+	}
+
+	return builder, countBuilder, nil
+}
+
 // getConnectedDashboards gets all dashboards connected to a Library Panel.
 func (lps *LibraryPanelService) getConnectedDashboards(c *models.ReqContext, uid string) ([]int64, error) {
 	connectedDashboardIDs := make([]int64, 0)
@@ -519,38 +1163,40 @@ func (lps *LibraryPanelService) getConnectedDashboards(c *models.ReqContext, uid
 		if err != nil {
 			return err
 		}
-		var libraryPanelDashboards []libraryPanelDashboard
-		builder := sqlstore.SQLBuilder{}
-		builder.Write("SELECT lpd.* FROM library_panel_dashboard lpd")
-		builder.Write(" INNER JOIN dashboard AS dashboard on lpd.dashboard_id = dashboard.id")
-		builder.Write(` WHERE lpd.librarypanel_id=?`, panel.ID)
-		if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
-			builder.WriteDashboardPermissionFilter(c.SignedInUser, models.PERMISSION_VIEW)
-		}
-		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanelDashboards); err != nil {
-			return err
-		}
-
-		for _, lpd := range libraryPanelDashboards {
-			connectedDashboardIDs = append(connectedDashboardIDs, lpd.DashboardID)
-		}
-
-		return nil
+		connectedDashboardIDs, err = getViewableConnectedDashboardIDs(session, c.SignedInUser, panel.ID)
+		return err
 	})
 
 	return connectedDashboardIDs, err
 }
 
-func (lps *LibraryPanelService) getLibraryPanelsForDashboardID(c *models.ReqContext, dashboardID int64) (map[string]LibraryPanelDTO, error) {
+// getViewableConnectedDashboardIDs gets the ids of the dashboards connected to a Library Panel that the user can view.
+func getViewableConnectedDashboardIDs(session *sqlstore.DBSession, user *models.SignedInUser, panelID int64) ([]int64, error) {
+	var libraryPanelDashboards []libraryPanelDashboard
+	builder := sqlstore.SQLBuilder{}
+	builder.Write("SELECT lpd.* FROM library_panel_dashboard lpd")
+	builder.Write(" INNER JOIN dashboard AS dashboard on lpd.dashboard_id = dashboard.id")
+	builder.Write(` WHERE lpd.librarypanel_id=?`, panelID)
+	if user.OrgRole != models.ROLE_ADMIN {
+		builder.WriteDashboardPermissionFilter(user, models.PERMISSION_VIEW)
+	}
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanelDashboards); err != nil {
+		return nil, err
+	}
+
+	connectedDashboardIDs := make([]int64, 0, len(libraryPanelDashboards))
+	for _, lpd := range libraryPanelDashboards {
+		connectedDashboardIDs = append(connectedDashboardIDs, lpd.DashboardID)
+	}
+
+	return connectedDashboardIDs, nil
+}
+
+func (lps *LibraryPanelService) getLibraryPanelsForDashboardID(ctx context.Context, dashboardID int64) (map[string]LibraryPanelDTO, error) {
 	libraryPanelMap := make(map[string]LibraryPanelDTO)
-	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
 		var libraryPanels []LibraryPanelWithMeta
-		sql := selectLibrayPanelDTOWithMeta + ", coalesce(dashboard.title, 'General') AS folder_name, coalesce(dashboard.uid, '') AS folder_uid " + fromLibrayPanelDTOWithMeta + `
-LEFT JOIN dashboard AS dashboard ON dashboard.id = lp.folder_id AND dashboard.id=?
-INNER JOIN library_panel_dashboard AS lpd ON lpd.librarypanel_id = lp.id AND lpd.dashboard_id=?
-`
-
-		sess := session.SQL(sql, dashboardID, dashboardID)
+		sess := session.SQL(sqlStatmentLibraryPanelsForDashboard, dashboardID, dashboardID)
 		err := sess.Find(&libraryPanels)
 		if err != nil {
 			return err
@@ -566,12 +1212,15 @@ INNER JOIN library_panel_dashboard AS lpd ON lpd.librarypanel_id = lp.id AND lpd
 				Type:        panel.Type,
 				Description: panel.Description,
 				Model:       panel.Model,
+				ModelHash:   storedModelHash(panel.ModelHash, panel.Model),
 				Version:     panel.Version,
 				Meta: LibraryPanelDTOMeta{
 					CanEdit:             panel.CanEdit,
+					MinGrafanaVersion:   minGrafanaVersion(panel.Model),
 					FolderName:          panel.FolderName,
 					FolderUID:           panel.FolderUID,
 					ConnectedDashboards: panel.ConnectedDashboards,
+					Provisioned:         panel.Provisioned,
 					Created:             panel.Created,
 					Updated:             panel.Updated,
 					CreatedBy: LibraryPanelDTOMetaUser{
@@ -594,7 +1243,122 @@ INNER JOIN library_panel_dashboard AS lpd ON lpd.librarypanel_id = lp.id AND lpd
 	return libraryPanelMap, err
 }
 
-func (lps *LibraryPanelService) handleFolderIDPatches(panelToPatch *LibraryPanel, fromFolderID int64,
+func getFolderIDByUID(session *sqlstore.DBSession, folderUID string, orgID int64) (int64, error) {
+	if len(folderUID) == 0 {
+		return 0, nil
+	}
+
+	var folders []struct {
+		ID int64 `xorm:"id"`
+	}
+	err := session.SQL("SELECT id from dashboard WHERE uid=? AND org_id=? AND is_folder=?", folderUID, orgID, true).Find(&folders)
+	if err != nil {
+		return 0, err
+	}
+	if len(folders) == 0 {
+		return 0, models.ErrFolderNotFound
+	}
+
+	return folders[0].ID, nil
+}
+
+// getFolderNameAndUID gets the title and uid of the folder of a Library Panel, the General folder has no uid.
+func getFolderNameAndUID(session *sqlstore.DBSession, folderID int64, orgID int64) (string, string, error) {
+	if isGeneralFolder(folderID) {
+		return "General", "", nil
+	}
+
+	var folders []struct {
+		Title string `xorm:"title"`
+		UID   string `xorm:"uid"`
+	}
+	err := session.SQL("SELECT title, uid from dashboard WHERE id=? AND org_id=? AND is_folder=?", folderID, orgID, true).Find(&folders)
+	if err != nil {
+		return "", "", err
+	}
+	if len(folders) == 0 {
+		return "", "", models.ErrFolderNotFound
+	}
+
+	return folders[0].Title, folders[0].UID, nil
+}
+
+// provisionLibraryPanel creates or updates a Library Panel matched by UID.
+func (lps *LibraryPanelService) provisionLibraryPanel(ctx context.Context, cmd ProvisionLibraryPanelCommand) error {
+	if err := validateUID(cmd.UID); err != nil {
+		return err
+	}
+	return lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		folderID, err := getFolderIDByUID(session, cmd.FolderUID, cmd.OrgID)
+		if err != nil {
+			return err
+		}
+
+		libraryPanel := LibraryPanel{
+			OrgID:       cmd.OrgID,
+			FolderID:    folderID,
+			UID:         cmd.UID,
+			Name:        cmd.Name,
+			Model:       cmd.Model,
+			Version:     1,
+			Provisioned: true,
+
+			Created: time.Now(),
+			Updated: time.Now(),
+		}
+		if err := syncFieldsWithModel(&libraryPanel); err != nil {
+			return err
+		}
+
+		panelInDB, err := getLibraryPanel(session, cmd.UID, cmd.OrgID)
+		if errors.Is(err, errLibraryPanelNotFound) {
+			// the uid of a Library Panel in the trash stays taken, so it can be restored
+			if _, err := getTrashedLibraryPanel(session, cmd.UID, cmd.OrgID); err == nil {
+				return errLibraryPanelUIDAlreadyExists
+			} else if !errors.Is(err, errLibraryPanelNotFound) {
+				return err
+			}
+			if _, err := session.Insert(&libraryPanel); err != nil {
+				if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+					return errLibraryPanelAlreadyExists
+				}
+				return err
+			}
+			if err := recordLibraryPanelVersion(session, libraryPanel.ID, 0); err != nil {
+				return err
+			}
+			return setDatasourcesForLibraryPanel(session, libraryPanel.ID, libraryPanel.Model)
+		}
+		if err != nil {
+			return err
+		}
+
+		if panelInDB.FolderID == libraryPanel.FolderID && panelInDB.Name == libraryPanel.Name &&
+			bytes.Equal(panelInDB.Model, libraryPanel.Model) {
+			return nil
+		}
+
+		libraryPanel.ID = panelInDB.ID
+		libraryPanel.Version = panelInDB.Version + 1
+		libraryPanel.Created = panelInDB.Created
+		libraryPanel.CreatedBy = panelInDB.CreatedBy
+		if rowsAffected, err := session.ID(panelInDB.ID).Update(&libraryPanel); err != nil {
+			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return errLibraryPanelAlreadyExists
+			}
+			return err
+		} else if rowsAffected != 1 {
+			return errLibraryPanelNotFound
+		}
+		if err := recordLibraryPanelVersion(session, panelInDB.ID, 0); err != nil {
+			return err
+		}
+
+		return setDatasourcesForLibraryPanel(session, panelInDB.ID, libraryPanel.Model)
+	})
+}
+
+func (lps *LibraryPanelService) handleFolderIDPatches(session *sqlstore.DBSession, panelToPatch *LibraryPanel, fromFolderID int64,
 	toFolderID int64, user *models.SignedInUser) error {
 	// FolderID was not provided in the PATCH request
 	if toFolderID == -1 {
@@ -606,10 +1370,14 @@ func (lps *LibraryPanelService) handleFolderIDPatches(panelToPatch *LibraryPanel
 		if err := lps.requirePermissionsOnFolder(user, toFolderID); err != nil {
 			return err
 		}
+		// the library panel is written in the folder it's moved to as well
+		if err := lps.requireLibraryPanelAccess(user, accesscontrol.ActionLibraryPanelsWrite, "", toFolderID); err != nil {
+			return err
+		}
 	}
 
-	// Always check permissions for the folder where library panel resides
-	if err := lps.requirePermissionsOnFolder(user, fromFolderID); err != nil {
+	// Always check permissions for the folder where library panel resides, or the library panel itself
+	if err := lps.requirePermissionsOnLibraryPanel(session, user, panelToPatch.ID, fromFolderID); err != nil {
 		return err
 	}
 
@@ -621,82 +1389,316 @@ func (lps *LibraryPanelService) handleFolderIDPatches(panelToPatch *LibraryPanel
 // patchLibraryPanel updates a Library Panel.
 func (lps *LibraryPanelService) patchLibraryPanel(c *models.ReqContext, cmd patchLibraryPanelCommand, uid string) (LibraryPanelDTO, error) {
 	var dto LibraryPanelDTO
-	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
-		panelInDB, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
-		if err != nil {
-			return err
+	span, ctx := startSpan(c.Context.Req.Context(), "patchLibraryPanel", c.SignedInUser.OrgId)
+	span.SetTag("uid", uid)
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		var err error
+		dto, err = lps.patchLibraryPanelInSession(session, c, cmd, uid)
+		return err
+	})
+	finishSpan(span, err)
+
+	if err == nil {
+		lps.scanSavedLibraryPanel(c.Context.Req.Context(), dto)
+	}
+	return dto, err
+}
+
+// patchLibraryPanelInSession updates a Library Panel in session, so that several Library Panels can be updated in
+// one transaction.
+func (lps *LibraryPanelService) patchLibraryPanelInSession(session *sqlstore.DBSession, c *models.ReqContext, cmd patchLibraryPanelCommand, uid string) (LibraryPanelDTO, error) {
+	panelInDB, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	if panelInDB.Provisioned {
+		return LibraryPanelDTO{}, errLibraryPanelProvisioned
+	}
+	if cmd.Version == 0 {
+		return LibraryPanelDTO{}, errLibraryPanelVersionRequired
+	}
+	if err := lps.requireLibraryPanelAccess(c.SignedInUser, accesscontrol.ActionLibraryPanelsWrite, uid, panelInDB.FolderID); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	if err := lps.requireNotFrozen(session, c.SignedInUser, panelInDB); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+
+	var libraryPanel = LibraryPanel{
+		ID:          panelInDB.ID,
+		OrgID:       c.SignedInUser.OrgId,
+		FolderID:    cmd.FolderID,
+		UID:         uid,
+		Name:        cmd.Name,
+		Type:        panelInDB.Type,
+		Description: panelInDB.Description,
+		Model:       cmd.Model,
+		Version:     panelInDB.Version + 1,
+		Created:     panelInDB.Created,
+		CreatedBy:   panelInDB.CreatedBy,
+		Updated:     time.Now(),
+		UpdatedBy:   c.SignedInUser.UserId,
+	}
+
+	if cmd.Name == "" {
+		libraryPanel.Name = panelInDB.Name
+	}
+	if cmd.Model == nil {
+		libraryPanel.Model = panelInDB.Model
+	}
+	if err := lps.handleFolderIDPatches(session, &libraryPanel, panelInDB.FolderID, cmd.FolderID, c.SignedInUser); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	// the diff holds the stored model, so it's only built once all permissions are checked
+	if panelInDB.Version != cmd.Version {
+		return LibraryPanelDTO{}, newVersionMismatchError(panelInDB.Version, panelInDB.Model, cmd.Model)
+	}
+	if cmd.Model != nil {
+		if err := requireNoCanaryInProgress(session, panelInDB.ID); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-		if panelInDB.Version != cmd.Version {
-			return errLibraryPanelVersionMismatch
+	}
+	if err := syncFieldsWithModel(&libraryPanel); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	labelsByPanel, err := getLabelsForLibraryPanels(session, panelInDB.ID)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	labels := labelsByPanel[panelInDB.ID]
+	if cmd.Labels != nil {
+		if err := validateLabels(cmd.Labels); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-
-		var libraryPanel = LibraryPanel{
-			ID:          panelInDB.ID,
-			OrgID:       c.SignedInUser.OrgId,
-			FolderID:    cmd.FolderID,
-			UID:         uid,
-			Name:        cmd.Name,
-			Type:        panelInDB.Type,
-			Description: panelInDB.Description,
-			Model:       cmd.Model,
-			Version:     panelInDB.Version + 1,
-			Created:     panelInDB.Created,
-			CreatedBy:   panelInDB.CreatedBy,
-			Updated:     time.Now(),
-			UpdatedBy:   c.SignedInUser.UserId,
+		labels = cmd.Labels
+	}
+	if err := requireLabelPolicy(session, c.SignedInUser.OrgId, labels); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	tagsByPanel, err := getTagsForLibraryPanels(session, panelInDB.ID)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	tags := tagsByPanel[panelInDB.ID]
+	if cmd.Tags != nil {
+		if tags, err = normalizeTags(cmd.Tags); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-
-		if cmd.Name == "" {
-			libraryPanel.Name = panelInDB.Name
+	}
+	inputsByPanel, err := getInputsForLibraryPanels(session, panelInDB.ID)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	inputs := inputsByPanel[panelInDB.ID]
+	if cmd.Inputs != nil {
+		if inputs, err = normalizeInputs(cmd.Inputs); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-		if cmd.Model == nil {
-			libraryPanel.Model = panelInDB.Model
+	}
+	if rowsAffected, err := session.ID(panelInDB.ID).Update(&libraryPanel); err != nil {
+		if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+			return LibraryPanelDTO{}, errLibraryPanelAlreadyExists
 		}
-		if err := lps.handleFolderIDPatches(&libraryPanel, panelInDB.FolderID, cmd.FolderID, c.SignedInUser); err != nil {
-			return err
+		return LibraryPanelDTO{}, err
+	} else if rowsAffected != 1 {
+		return LibraryPanelDTO{}, errLibraryPanelNotFound
+	}
+	if err := recordLibraryPanelVersion(session, panelInDB.ID, c.SignedInUser.UserId); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	detail := patchAuditDetail(cmd, panelInDB, libraryPanel)
+	if err := addAuditEntry(session, libraryPanel, auditActionUpdate, detail, panelInDB.Version, libraryPanel.Version, c.SignedInUser.UserId); err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	if cmd.Labels != nil {
+		if err := setLabelsForLibraryPanel(session, panelInDB.ID, cmd.Labels); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-		if err := syncFieldsWithModel(&libraryPanel); err != nil {
-			return err
+	}
+	if cmd.Model != nil {
+		if err := setDatasourcesForLibraryPanel(session, panelInDB.ID, libraryPanel.Model); err != nil {
+			return LibraryPanelDTO{}, err
 		}
-		if rowsAffected, err := session.ID(panelInDB.ID).Update(&libraryPanel); err != nil {
-			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
-				return errLibraryPanelAlreadyExists
-			}
-			return err
-		} else if rowsAffected != 1 {
-			return errLibraryPanelNotFound
+	}
+	if cmd.Canary && libraryPanel.ModelHash != storedModelHash(panelInDB.ModelHash, panelInDB.Model) {
+		if err := lps.startCanary(session, c.SignedInUser, panelInDB, libraryPanel); err != nil {
+			return LibraryPanelDTO{}, err
+		}
+	}
+	if cmd.Tags != nil {
+		if err := setTagsForLibraryPanel(session, panelInDB.ID, tags); err != nil {
+			return LibraryPanelDTO{}, err
 		}
+	}
+	if cmd.Inputs != nil {
+		if err := setInputsForLibraryPanel(session, panelInDB.ID, inputs); err != nil {
+			return LibraryPanelDTO{}, err
+		}
+	}
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if tags == nil {
+		tags = make([]string, 0)
+	}
+	if inputs == nil {
+		inputs = make([]LibraryPanelInputDTO, 0)
+	}
+	folderName, folderUID, err := getFolderNameAndUID(session, libraryPanel.FolderID, libraryPanel.OrgID)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
 
-		dto = LibraryPanelDTO{
-			ID:          libraryPanel.ID,
-			OrgID:       libraryPanel.OrgID,
-			FolderID:    libraryPanel.FolderID,
-			UID:         libraryPanel.UID,
-			Name:        libraryPanel.Name,
-			Type:        libraryPanel.Type,
-			Description: libraryPanel.Description,
-			Model:       libraryPanel.Model,
-			Version:     libraryPanel.Version,
-			Meta: LibraryPanelDTOMeta{
-				CanEdit:             true,
-				ConnectedDashboards: panelInDB.ConnectedDashboards,
-				Created:             libraryPanel.Created,
-				Updated:             libraryPanel.Updated,
-				CreatedBy: LibraryPanelDTOMetaUser{
-					ID:        panelInDB.CreatedBy,
-					Name:      panelInDB.CreatedByName,
-					AvatarUrl: dtos.GetGravatarUrl(panelInDB.CreatedByEmail),
-				},
-				UpdatedBy: LibraryPanelDTOMetaUser{
-					ID:        libraryPanel.UpdatedBy,
-					Name:      c.SignedInUser.Login,
-					AvatarUrl: dtos.GetGravatarUrl(c.SignedInUser.Email),
-				},
+	dto := LibraryPanelDTO{
+		ID:          libraryPanel.ID,
+		OrgID:       libraryPanel.OrgID,
+		FolderID:    libraryPanel.FolderID,
+		UID:         libraryPanel.UID,
+		Name:        libraryPanel.Name,
+		Type:        libraryPanel.Type,
+		Description: libraryPanel.Description,
+		Model:       libraryPanel.Model,
+		ModelHash:   libraryPanel.ModelHash,
+		Version:     libraryPanel.Version,
+		Labels:      labels,
+		Tags:        tags,
+		Inputs:      inputs,
+		Meta: LibraryPanelDTOMeta{
+			CanEdit:             true,
+			MinGrafanaVersion:   minGrafanaVersion(libraryPanel.Model),
+			FolderName:          folderName,
+			FolderUID:           folderUID,
+			ConnectedDashboards: panelInDB.ConnectedDashboards,
+			Created:             libraryPanel.Created,
+			Updated:             libraryPanel.Updated,
+			CreatedBy: LibraryPanelDTOMetaUser{
+				ID:        panelInDB.CreatedBy,
+				Name:      panelInDB.CreatedByName,
+				AvatarUrl: dtos.GetGravatarUrl(panelInDB.CreatedByEmail),
 			},
-		}
+			UpdatedBy: LibraryPanelDTOMetaUser{
+				ID:        libraryPanel.UpdatedBy,
+				Name:      c.SignedInUser.Login,
+				AvatarUrl: dtos.GetGravatarUrl(c.SignedInUser.Email),
+			},
+		},
+	}
 
+	return dto, nil
+}
+
+// moveLibraryPanels moves several Library Panels to another folder in one transaction.
+func (lps *LibraryPanelService) moveLibraryPanels(c *models.ReqContext, cmd moveLibraryPanelsCommand) ([]MoveLibraryPanelResultDTO, error) {
+	results := make([]MoveLibraryPanelResultDTO, 0, len(cmd.UIDs))
+	span, ctx := startSpan(c.Context.Req.Context(), "moveLibraryPanels", c.SignedInUser.OrgId)
+	span.SetTag("uidCount", len(cmd.UIDs))
+	span.SetTag("folderId", cmd.FolderID)
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		for _, uid := range cmd.UIDs {
+			result, err := lps.moveLibraryPanelInSession(session, c, uid, cmd.FolderID)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
 		return nil
 	})
+	finishSpan(span, err)
 
-	return dto, err
+	return results, err
+}
+
+// moveLibraryPanelInSession moves a Library Panel to another folder and bumps its version.
+func (lps *LibraryPanelService) moveLibraryPanelInSession(session *sqlstore.DBSession, c *models.ReqContext, uid string,
+	folderID int64) (MoveLibraryPanelResultDTO, error) {
+	panelInDB, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+	if err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+	if panelInDB.Provisioned {
+		return MoveLibraryPanelResultDTO{}, errLibraryPanelProvisioned
+	}
+	if err := lps.requireLibraryPanelAccess(c.SignedInUser, accesscontrol.ActionLibraryPanelsWrite, uid, panelInDB.FolderID); err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+	if err := lps.requireNotFrozen(session, c.SignedInUser, panelInDB); err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+
+	libraryPanel := LibraryPanel{
+		ID:        panelInDB.ID,
+		OrgID:     panelInDB.OrgID,
+		UID:       panelInDB.UID,
+		Name:      panelInDB.Name,
+		Version:   panelInDB.Version + 1,
+		Updated:   time.Now(),
+		UpdatedBy: c.SignedInUser.UserId,
+	}
+	if err := lps.handleFolderIDPatches(session, &libraryPanel, panelInDB.FolderID, folderID, c.SignedInUser); err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+	if rowsAffected, err := session.ID(panelInDB.ID).Cols("folder_id", "version", "updated", "updated_by").Update(&libraryPanel); err != nil {
+		if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+			return MoveLibraryPanelResultDTO{}, errLibraryPanelAlreadyExists
+		}
+		return MoveLibraryPanelResultDTO{}, err
+	} else if rowsAffected != 1 {
+		return MoveLibraryPanelResultDTO{}, errLibraryPanelNotFound
+	}
+	if err := recordLibraryPanelVersion(session, panelInDB.ID, c.SignedInUser.UserId); err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+	detail := patchAuditDetail(patchLibraryPanelCommand{}, panelInDB, libraryPanel)
+	if err := addAuditEntry(session, libraryPanel, auditActionUpdate, detail, panelInDB.Version, libraryPanel.Version, c.SignedInUser.UserId); err != nil {
+		return MoveLibraryPanelResultDTO{}, err
+	}
+
+	return MoveLibraryPanelResultDTO{
+		UID:      uid,
+		FolderID: libraryPanel.FolderID,
+		Version:  libraryPanel.Version,
+	}, nil
+}
+
+// moveExclusiveLibraryPanels moves the Library Panels in fromFolderID that are connected to no other dashboard than
+// dashboardID to toFolderID, so they stay in the same folder as the dashboard. Provisioned Library Panels are left
+// where they are. Each Library Panel is moved on its own, one that can't be moved stays where it is and its result
+// has the error.
+func (lps *LibraryPanelService) moveExclusiveLibraryPanels(c *models.ReqContext, dashboardID int64, fromFolderID int64,
+	toFolderID int64) ([]MoveLibraryPanelResultDTO, error) {
+	var panels []struct {
+		UID     string `xorm:"uid"`
+		Version int64  `xorm:"version"`
+	}
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		sql := `SELECT lp.uid, lp.version FROM library_panel AS lp
+WHERE lp.org_id=? AND lp.folder_id=? AND lp.deleted_at IS NULL AND lp.provisioned=` + lps.SQLStore.Dialect.BooleanStr(false) + `
+	AND EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id AND lpd.dashboard_id = ?)
+	AND NOT EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id AND lpd.dashboard_id <> ?)
+ORDER BY lp.uid`
+		return session.SQL(sql, c.SignedInUser.OrgId, fromFolderID, dashboardID, dashboardID).Find(&panels)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(panels) == 0 {
+		return make([]MoveLibraryPanelResultDTO, 0), nil
+	}
+
+	results := make([]MoveLibraryPanelResultDTO, 0, len(panels))
+	for _, panel := range panels {
+		var result MoveLibraryPanelResultDTO
+		err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+			var err error
+			result, err = lps.moveLibraryPanelInSession(session, c, panel.UID, toFolderID)
+			return err
+		})
+		if err != nil {
+			lps.log.Warn("Failed to move library panel with dashboard", "uid", panel.UID, "dashboardId", dashboardID, "error", err)
+			result = MoveLibraryPanelResultDTO{UID: panel.UID, FolderID: fromFolderID, Version: panel.Version, Error: err.Error()}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
 }
@@ -0,0 +1,241 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// libraryPanelDatasource is the model for datasources referenced by a library panel model.
+type libraryPanelDatasource struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Ref            string `xorm:"ref"`
+}
+
+// getDatasourceRefsFromModel gets the datasources referenced by the panel and its targets. A reference is a
+// datasource uid, a datasource name or the default datasource placeholder.
+func getDatasourceRefsFromModel(model json.RawMessage) ([]string, error) {
+	var panel struct {
+		Datasource interface{} `json:"datasource"`
+		Targets    []struct {
+			Datasource interface{} `json:"datasource"`
+		} `json:"targets"`
+	}
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	refs := make([]string, 0)
+	add := func(datasource interface{}) {
+		ref, ok := datasource.(string)
+		if !ok || len(ref) == 0 || seen[ref] {
+			return
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	add(panel.Datasource)
+	for _, target := range panel.Targets {
+		add(target.Datasource)
+	}
+	sort.Strings(refs)
+
+	return refs, nil
+}
+
+func setDatasourcesForLibraryPanel(session *sqlstore.DBSession, panelID int64, model json.RawMessage) error {
+	refs, err := getDatasourceRefsFromModel(model)
+	if err != nil {
+		return err
+	}
+	if _, err := session.Exec("DELETE FROM library_panel_datasource WHERE librarypanel_id=?", panelID); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		datasource := libraryPanelDatasource{
+			LibraryPanelID: panelID,
+			Ref:            ref,
+		}
+		if _, err := session.Insert(&datasource); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getDatasourceReferences gets the values a panel model can use to reference a datasource: its uid, its name and
+// the default datasource placeholder if it's the default datasource of the org.
+func getDatasourceReferences(session *sqlstore.DBSession, orgID int64, datasourceUID string) ([]string, error) {
+	var datasources []struct {
+		Name      string `xorm:"name"`
+		IsDefault bool   `xorm:"is_default"`
+	}
+	err := session.SQL("SELECT name, is_default FROM data_source WHERE org_id=? AND uid=?", orgID, datasourceUID).Find(&datasources)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []string{datasourceUID}
+	if len(datasources) > 0 {
+		refs = append(refs, datasources[0].Name)
+		if datasources[0].IsDefault {
+			refs = append(refs, defaultDatasource)
+		}
+	}
+
+	return refs, nil
+}
+
+const (
+	rewriteStatusRewritten   = "rewritten"
+	rewriteStatusProvisioned = "provisioned"
+)
+
+// rewriteDatasourceRefs replaces the datasource references of a panel and its targets that have a replacement.
+func rewriteDatasourceRefs(model json.RawMessage, replacements map[string]string) (json.RawMessage, error) {
+	var panel map[string]interface{}
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return nil, err
+	}
+
+	if ref, ok := panel["datasource"].(string); ok && replacements[ref] != "" {
+		panel["datasource"] = replacements[ref]
+	}
+	if targets, ok := panel["targets"].([]interface{}); ok {
+		for _, t := range targets {
+			target, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if ref, ok := target["datasource"].(string); ok && replacements[ref] != "" {
+				target["datasource"] = replacements[ref]
+			}
+		}
+	}
+
+	return json.Marshal(&panel)
+}
+
+// rewriteDatasource replaces all references to one datasource with references to another datasource in the
+// Library Panels of the signed in user's org. References by uid are replaced by the new uid and references by name
+// by the new name. Provisioned Library Panels are skipped, and nothing is changed if cmd.DryRun is set.
+func (lps *LibraryPanelService) rewriteDatasource(c *models.ReqContext, cmd rewriteDatasourceCommand) ([]RewriteDatasourceResultDTO, error) {
+	results := make([]RewriteDatasourceResultDTO, 0)
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var targets []struct {
+			Name string `xorm:"name"`
+		}
+		if err := session.SQL("SELECT name FROM data_source WHERE org_id=? AND uid=?", c.SignedInUser.OrgId, cmd.ToUID).Find(&targets); err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return models.ErrDataSourceNotFound
+		}
+		replacements := map[string]string{cmd.FromUID: cmd.ToUID}
+		refs, err := getDatasourceReferences(session, c.SignedInUser.OrgId, cmd.FromUID)
+		if err != nil {
+			return err
+		}
+		// the default placeholder follows the org's default datasource and is left alone
+		if len(refs) > 1 {
+			replacements[refs[1]] = targets[0].Name
+		}
+
+		params := []interface{}{c.SignedInUser.OrgId}
+		for ref := range replacements {
+			params = append(params, ref)
+		}
+		var panels []LibraryPanel
+		sql := "SELECT * FROM library_panel AS lp WHERE lp.org_id=? AND lp.deleted_at IS NULL" +
+			" AND EXISTS (SELECT 1 FROM library_panel_datasource AS lpds WHERE lpds.librarypanel_id = lp.id AND lpds.ref IN (?" +
+			strings.Repeat(",?", len(replacements)-1) + ")) ORDER BY lp.name"
+		if err := session.SQL(sql, params...).Find(&panels); err != nil {
+			return err
+		}
+
+		for _, panel := range panels {
+			if panel.Provisioned {
+				results = append(results, RewriteDatasourceResultDTO{UID: panel.UID, Name: panel.Name, Version: panel.Version, Status: rewriteStatusProvisioned})
+				continue
+			}
+			model, err := rewriteDatasourceRefs(panel.Model, replacements)
+			if err != nil {
+				return err
+			}
+			normalizedHash, err := normalizedModelHash(model)
+			if err != nil {
+				return err
+			}
+			libraryPanel := LibraryPanel{
+				Model:          model,
+				ModelHash:      hashModel(model),
+				NormalizedHash: normalizedHash,
+				Version:        panel.Version + 1,
+				Updated:        time.Now(),
+				UpdatedBy:      c.SignedInUser.UserId,
+			}
+			results = append(results, RewriteDatasourceResultDTO{UID: panel.UID, Name: panel.Name, Version: libraryPanel.Version, Status: rewriteStatusRewritten})
+			if cmd.DryRun {
+				continue
+			}
+			if _, err := session.ID(panel.ID).Cols("model", "model_hash", "normalized_hash", "version", "updated", "updated_by").Update(&libraryPanel); err != nil {
+				return err
+			}
+			if err := recordLibraryPanelVersion(session, panel.ID, c.SignedInUser.UserId); err != nil {
+				return err
+			}
+			if err := setDatasourcesForLibraryPanel(session, panel.ID, model); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return results, err
+}
+
+// populateLibraryPanelDatasourcesMigration fills library_panel_datasource for library panels created before the
+// table existed.
+type populateLibraryPanelDatasourcesMigration struct {
+	migrator.MigrationBase
+}
+
+func (m *populateLibraryPanelDatasourcesMigration) SQL(dialect migrator.Dialect) string {
+	return "code migration"
+}
+
+func (m *populateLibraryPanelDatasourcesMigration) Exec(sess *xorm.Session, mg *migrator.Migrator) error {
+	var panels []struct {
+		ID    int64           `xorm:"id"`
+		Model json.RawMessage `xorm:"model"`
+	}
+	if err := sess.SQL("SELECT id, model FROM library_panel").Find(&panels); err != nil {
+		return err
+	}
+
+	for _, panel := range panels {
+		refs, err := getDatasourceRefsFromModel(panel.Model)
+		if err != nil {
+			mg.Logger.Warn("Skipping library panel with invalid model", "id", panel.ID, "error", err)
+			continue
+		}
+		for _, ref := range refs {
+			if _, err := sess.Insert(&libraryPanelDatasource{LibraryPanelID: panel.ID, Ref: ref}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package librarypanels
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// defaultDedupeMinPanels is the number of identical panels from which they're promoted to a library panel.
+const defaultDedupeMinPanels = 2
+
+// dedupeIgnoredKeys are the panel properties that depend on where a panel is placed and are left out when panels
+// are compared.
+var dedupeIgnoredKeys = []string{"id", "gridPos", "libraryPanel", "pluginVersion"}
+
+// LibraryPanelDedupeGroupDTO is a group of identical panels that is, or would be, promoted to a library panel.
+type LibraryPanelDedupeGroupDTO struct {
+	Hash            string   `json:"hash"`
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	Panels          int      `json:"panels"`
+	DashboardUIDs   []string `json:"dashboardUids"`
+	LibraryPanelUID string   `json:"libraryPanelUid,omitempty"`
+}
+
+// LibraryPanelDedupeResultDTO is the outcome of deduplicating the panels of an org.
+type LibraryPanelDedupeResultDTO struct {
+	DryRun            bool                           `json:"dryRun"`
+	ScannedDashboards int                            `json:"scannedDashboards"`
+	Groups            []LibraryPanelDedupeGroupDTO   `json:"groups"`
+	Failures          []LibraryPanelDedupeFailureDTO `json:"failures"`
+}
+
+// LibraryPanelDedupeFailureDTO is a dashboard whose panels couldn't be replaced by library panels.
+type LibraryPanelDedupeFailureDTO struct {
+	DashboardUID string `json:"dashboardUid"`
+	Error        string `json:"error"`
+}
+
+// normalizePanelModel returns a panel without the properties in dedupeIgnoredKeys and the hash of it, which is the
+// same for panels that only differ in placement. Rows and library panels can't be promoted and return an empty hash.
+func normalizePanelModel(panel map[string]interface{}) (map[string]interface{}, string, error) {
+	if panel["type"] == "row" || panel["libraryPanel"] != nil {
+		return nil, "", nil
+	}
+
+	normalized := make(map[string]interface{}, len(panel))
+	for key, value := range panel {
+		normalized[key] = value
+	}
+	for _, key := range dedupeIgnoredKeys {
+		delete(normalized, key)
+	}
+	// maps are marshaled with sorted keys, so the same model always has the same hash
+	model, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(model)
+
+	return normalized, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// normalizedModelHash returns the hash of a library panel model that is stored in normalized_hash. It's the hash
+// normalizePanelModel returns for the model as a dashboard panel, empty for rows.
+func normalizedModelHash(model json.RawMessage) (string, error) {
+	var panel map[string]interface{}
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return "", err
+	}
+	delete(panel, "libraryPanel")
+	_, hash, err := normalizePanelModel(panel)
+
+	return hash, err
+}
+
+// forEachDedupePanel calls fn for the panels, including those in collapsed rows, with the slice the panel is in and
+// its index, so fn can replace it.
+func forEachDedupePanel(panels []interface{}, fn func(panels []interface{}, i int, panel map[string]interface{}) error) error {
+	for i, element := range panels {
+		panel, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rowPanels, ok := panel["panels"].([]interface{}); ok {
+			if err := forEachDedupePanel(rowPanels, fn); err != nil {
+				return err
+			}
+		}
+		if err := fn(panels, i, panel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type dedupeGroup struct {
+	dto   LibraryPanelDedupeGroupDTO
+	model map[string]interface{}
+}
+
+// dedupeLibraryPanels finds the panels of the org's dashboards, including those in collapsed rows, that are identical
+// except for their placement and, unless it's a dry run, promotes every group of at least minPanels of them to a
+// library panel in folderID. A library panel in folderID with the same model is reused, so a run that failed part way
+// can be repeated. Matching panels are replaced by references to the library panel and the dashboards are connected
+// to it. Dashboards that fail to save are reported in the result and don't stop the run. Provisioned dashboards are
+// left out, they would be overwritten by their provisioning.
+func (lps *LibraryPanelService) dedupeLibraryPanels(c *models.ReqContext, cmd dedupeLibraryPanelsCommand) (LibraryPanelDedupeResultDTO, error) {
+	minPanels := cmd.MinPanels
+	if minPanels < defaultDedupeMinPanels {
+		minPanels = defaultDedupeMinPanels
+	}
+
+	var orgDashboards []*models.Dashboard
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return session.Where("org_id=? AND is_folder="+lps.SQLStore.Dialect.BooleanStr(false), c.SignedInUser.OrgId).
+			And("id NOT IN (SELECT dashboard_id FROM dashboard_provisioning)").
+			OrderBy("id").Find(&orgDashboards)
+	})
+	if err != nil {
+		return LibraryPanelDedupeResultDTO{}, err
+	}
+
+	groups := make(map[string]*dedupeGroup)
+	order := make([]string, 0)
+	for _, dash := range orgDashboards {
+		seen := make(map[string]bool)
+		err := forEachDedupePanel(dash.Data.Get("panels").MustArray(), func(_ []interface{}, _ int, panel map[string]interface{}) error {
+			model, hash, err := normalizePanelModel(panel)
+			if err != nil || hash == "" {
+				return err
+			}
+			group, ok := groups[hash]
+			if !ok {
+				name, _ := model["title"].(string)
+				panelType, _ := model["type"].(string)
+				group = &dedupeGroup{
+					dto:   LibraryPanelDedupeGroupDTO{Hash: hash, Name: name, Type: panelType, DashboardUIDs: make([]string, 0)},
+					model: model,
+				}
+				groups[hash] = group
+				order = append(order, hash)
+			}
+			group.dto.Panels++
+			if !seen[hash] {
+				seen[hash] = true
+				group.dto.DashboardUIDs = append(group.dto.DashboardUIDs, dash.Uid)
+			}
+			return nil
+		})
+		if err != nil {
+			return LibraryPanelDedupeResultDTO{}, err
+		}
+	}
+
+	result := LibraryPanelDedupeResultDTO{
+		DryRun:            cmd.DryRun,
+		ScannedDashboards: len(orgDashboards),
+		Groups:            make([]LibraryPanelDedupeGroupDTO, 0),
+		Failures:          make([]LibraryPanelDedupeFailureDTO, 0),
+	}
+	promoted := make(map[string]*dedupeGroup)
+	for _, hash := range order {
+		group := groups[hash]
+		if group.dto.Panels < minPanels {
+			continue
+		}
+		if !cmd.DryRun {
+			panel, err := lps.createDedupedLibraryPanel(c, cmd.FolderID, group)
+			if err != nil {
+				return LibraryPanelDedupeResultDTO{}, err
+			}
+			group.dto.LibraryPanelUID = panel.UID
+			group.dto.Name = panel.Name
+		}
+		promoted[hash] = group
+		result.Groups = append(result.Groups, group.dto)
+	}
+	sort.SliceStable(result.Groups, func(i, j int) bool {
+		return result.Groups[i].Panels > result.Groups[j].Panels
+	})
+	if cmd.DryRun {
+		return result, nil
+	}
+
+	for _, dash := range orgDashboards {
+		panels := dash.Data.Get("panels").MustArray()
+		changed := false
+		err := forEachDedupePanel(panels, func(panels []interface{}, i int, panel map[string]interface{}) error {
+			_, hash, err := normalizePanelModel(panel)
+			if err != nil {
+				return err
+			}
+			group, ok := promoted[hash]
+			if !ok {
+				return nil
+			}
+			reference := map[string]interface{}{
+				"libraryPanel": map[string]interface{}{"uid": group.dto.LibraryPanelUID, "name": group.dto.Name},
+			}
+			for _, key := range []string{"id", "gridPos"} {
+				if value, ok := panel[key]; ok {
+					reference[key] = value
+				}
+			}
+			panels[i] = reference
+			changed = true
+			return nil
+		})
+		if err != nil {
+			return LibraryPanelDedupeResultDTO{}, err
+		}
+		if !changed {
+			continue
+		}
+
+		if err := lps.saveDedupedDashboard(c, dash, panels); err != nil {
+			lps.log.Warn("Failed to replace identical panels with library panels", "dashboardUid", dash.Uid, "error", err)
+			result.Failures = append(result.Failures, LibraryPanelDedupeFailureDTO{DashboardUID: dash.Uid, Error: err.Error()})
+		}
+	}
+
+	return result, nil
+}
+
+// saveDedupedDashboard saves dash with panels, in which identical panels were replaced by library panel references,
+// and connects it to the library panels.
+func (lps *LibraryPanelService) saveDedupedDashboard(c *models.ReqContext, dash *models.Dashboard, panels []interface{}) error {
+	dash.Data.Set("id", dash.Id)
+	dash.Data.Set("uid", dash.Uid)
+	dash.Data.Set("panels", panels)
+	updated := models.NewDashboardFromJson(simplejson.NewFromAny(dash.Data.Interface()))
+	updated.OrgId = dash.OrgId
+	updated.FolderId = dash.FolderId
+	saved, err := dashboards.NewService(lps.SQLStore).SaveDashboard(&dashboards.SaveDashboardDTO{
+		OrgId:     dash.OrgId,
+		User:      c.SignedInUser,
+		Dashboard: updated,
+		Message:   "Replaced identical panels with library panels",
+		Overwrite: true,
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	return lps.ConnectLibraryPanelsForDashboard(c, saved)
+}
+
+// createDedupedLibraryPanel returns the library panel for a group of identical panels. A library panel in folderID
+// with the same model is reused, otherwise it's created with the panel title as name, with the start of the hash
+// added when the folder already has a library panel with that name.
+func (lps *LibraryPanelService) createDedupedLibraryPanel(c *models.ReqContext, folderID int64, group *dedupeGroup) (LibraryPanelDTO, error) {
+	var existing []LibraryPanel
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return session.Where("org_id=? AND folder_id=? AND normalized_hash=? AND deleted_at IS NULL",
+			c.SignedInUser.OrgId, folderID, group.dto.Hash).OrderBy("id").Limit(1).Find(&existing)
+	})
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	if len(existing) > 0 {
+		return lps.getLibraryPanel(c, existing[0].UID)
+	}
+
+	model, err := json.Marshal(group.model)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	name := group.dto.Name
+	if name == "" {
+		name = group.dto.Type + " panel"
+	}
+	suffix := group.dto.Hash[len("sha256:") : len("sha256:")+8]
+
+	panel, err := lps.createLibraryPanel(c, createLibraryPanelCommand{FolderID: folderID, Name: name, Model: model})
+	if errors.Is(err, errLibraryPanelAlreadyExists) {
+		panel, err = lps.createLibraryPanel(c, createLibraryPanelCommand{FolderID: folderID, Name: name + " (" + suffix + ")", Model: model})
+	}
+
+	return panel, err
+}
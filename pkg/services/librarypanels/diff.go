@@ -0,0 +1,44 @@
+package librarypanels
+
+import (
+	"encoding/json"
+
+	diff "github.com/yudai/gojsondiff"
+	deltaFormatter "github.com/yudai/gojsondiff/formatter"
+)
+
+// versionMismatchError is an errLibraryPanelVersionMismatch that carries the current version of the library panel
+// and the diff from its stored model to the submitted model, so clients can merge or overwrite.
+type versionMismatchError struct {
+	version int64
+	diff    json.RawMessage
+}
+
+func (e *versionMismatchError) Error() string {
+	return errLibraryPanelVersionMismatch.Error()
+}
+
+func (e *versionMismatchError) Unwrap() error {
+	return errLibraryPanelVersionMismatch
+}
+
+// newVersionMismatchError creates a versionMismatchError, the diff is in jsondiffpatch delta format and is left out
+// if no model was submitted or the models can't be compared.
+func newVersionMismatchError(version int64, storedModel json.RawMessage, submittedModel json.RawMessage) error {
+	mismatch := &versionMismatchError{version: version}
+	if len(submittedModel) == 0 {
+		return mismatch
+	}
+
+	modelDiff, err := diff.New().Compare(storedModel, submittedModel)
+	if err != nil || !modelDiff.Modified() {
+		return mismatch
+	}
+	delta, err := deltaFormatter.NewDeltaFormatter().Format(modelDiff)
+	if err != nil {
+		return mismatch
+	}
+	mismatch.diff = json.RawMessage(delta)
+
+	return mismatch
+}
@@ -0,0 +1,35 @@
+package librarypanels
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// libraryPanelETag is the entity tag of a version of a library panel.
+func libraryPanelETag(uid string, version int64) string {
+	return `"` + uid + "-" + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch gets the library panel version from the If-Match header of a request. It returns 0 if there is no
+// If-Match header or it matches any version, and errLibraryPanelVersionMismatch if it's the entity tag of another
+// library panel.
+func parseIfMatch(c *models.ReqContext, uid string) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Req.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+
+	etag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	i := strings.LastIndex(etag, "-")
+	if i < 0 || etag[:i] != uid {
+		return 0, errLibraryPanelVersionMismatch
+	}
+	version, err := strconv.ParseInt(etag[i+1:], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errLibraryPanelVersionMismatch
+	}
+
+	return version, nil
+}
@@ -0,0 +1,120 @@
+package librarypanels
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	folderPermissionView   = "view"
+	folderPermissionCreate = "create"
+)
+
+// LibraryPanelFolderDTO is the DTO for a folder that can hold library panels.
+type LibraryPanelFolderDTO struct {
+	ID    int64  `json:"id"`
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// LibraryPanelFolderStatsDTO is the DTO for the number of library panels in a folder, broken down by panel type.
+type LibraryPanelFolderStatsDTO struct {
+	FolderID int64            `json:"folderId"`
+	Total    int64            `json:"total"`
+	Types    map[string]int64 `json:"types"`
+}
+
+// getFolderStats gets the number of Library Panels per panel type for all folders the signed in user can view.
+func (lps *LibraryPanelService) getFolderStats(c *models.ReqContext) ([]LibraryPanelFolderStatsDTO, error) {
+	stats := make([]LibraryPanelFolderStatsDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var rows []struct {
+			FolderID int64  `xorm:"folder_id"`
+			Type     string `xorm:"type"`
+			Count    int64  `xorm:"count"`
+		}
+		builder := sqlstore.SQLBuilder{}
+		builder.Write("SELECT lp.folder_id, lp.type, COUNT(*) AS count FROM library_panel AS lp")
+		builder.Write(" WHERE lp.org_id=? AND lp.folder_id=0 AND lp.deleted_at IS NULL", c.SignedInUser.OrgId)
+		builder.Write(" GROUP BY lp.folder_id, lp.type")
+		builder.Write(" UNION ALL ")
+		builder.Write("SELECT lp.folder_id, lp.type, COUNT(*) AS count FROM library_panel AS lp")
+		builder.Write(" INNER JOIN dashboard AS dashboard on lp.folder_id = dashboard.id AND lp.folder_id<>0")
+		builder.Write(" WHERE lp.org_id=? AND lp.deleted_at IS NULL", c.SignedInUser.OrgId)
+		if c.SignedInUser.OrgRole != models.ROLE_ADMIN {
+			builder.WriteDashboardPermissionFilter(c.SignedInUser, models.PERMISSION_VIEW)
+		}
+		builder.Write(" GROUP BY lp.folder_id, lp.type")
+		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+			return err
+		}
+
+		byFolder := make(map[int64]int)
+		for _, row := range rows {
+			i, ok := byFolder[row.FolderID]
+			if !ok {
+				i = len(stats)
+				byFolder[row.FolderID] = i
+				stats = append(stats, LibraryPanelFolderStatsDTO{FolderID: row.FolderID, Types: make(map[string]int64)})
+			}
+			stats[i].Total += row.Count
+			stats[i].Types[row.Type] += row.Count
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// getFolders gets the folders where the signed in user has the given permission on Library Panels, ordered by
+// title. With folderPermissionCreate only folders the user can create Library Panels in are returned.
+func (lps *LibraryPanelService) getFolders(c *models.ReqContext, permission string, searchString string, limit int) ([]LibraryPanelFolderDTO, error) {
+	var dashboardPermission models.PermissionType
+	includeGeneralFolder := true
+	switch permission {
+	case "", folderPermissionView:
+		dashboardPermission = models.PERMISSION_VIEW
+	case folderPermissionCreate:
+		dashboardPermission = models.PERMISSION_EDIT
+		includeGeneralFolder = c.SignedInUser.HasRole(models.ROLE_EDITOR)
+	default:
+		return nil, errLibraryPanelInvalidFolderPermission
+	}
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	folders := make([]LibraryPanelFolderDTO, 0)
+	if includeGeneralFolder && strings.Contains(strings.ToLower("General"), strings.ToLower(searchString)) {
+		folders = append(folders, LibraryPanelFolderDTO{ID: 0, UID: "", Title: "General"})
+	}
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var rows []struct {
+			ID    int64  `xorm:"id"`
+			UID   string `xorm:"uid"`
+			Title string `xorm:"title"`
+		}
+		builder := sqlstore.SQLBuilder{}
+		builder.Write("SELECT dashboard.id, dashboard.uid, dashboard.title FROM dashboard AS dashboard")
+		builder.Write(" WHERE dashboard.org_id=? AND dashboard.is_folder="+lps.SQLStore.Dialect.BooleanStr(true), c.SignedInUser.OrgId)
+		if len(strings.TrimSpace(searchString)) > 0 {
+			builder.Write(" AND dashboard.title "+lps.SQLStore.Dialect.LikeStr()+" ?", "%"+searchString+"%")
+		}
+		builder.WriteDashboardPermissionFilter(c.SignedInUser, dashboardPermission)
+		builder.Write(" ORDER BY dashboard.title ASC")
+		builder.Write(lps.SQLStore.Dialect.Limit(int64(limit - len(folders))))
+		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			folders = append(folders, LibraryPanelFolderDTO{ID: row.ID, UID: row.UID, Title: row.Title})
+		}
+
+		return nil
+	})
+
+	return folders, err
+}
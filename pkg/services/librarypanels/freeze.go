@@ -0,0 +1,218 @@
+package librarypanels
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelFreezeWindow is the model for a period during which published library panels of an org can't be
+// changed or deleted.
+type libraryPanelFreezeWindow struct {
+	ID          int64     `xorm:"pk autoincr 'id'"`
+	OrgID       int64     `xorm:"org_id"`
+	Name        string    `xorm:"name"`
+	Reason      string    `xorm:"reason"`
+	Starts      time.Time `xorm:"starts"`
+	Ends        time.Time `xorm:"ends"`
+	Provisioned bool      `xorm:"provisioned"`
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// LibraryPanelFreezeWindowDTO is the frontend DTO for freeze windows.
+type LibraryPanelFreezeWindowDTO struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Reason      string    `json:"reason"`
+	Starts      time.Time `json:"starts"`
+	Ends        time.Time `json:"ends"`
+	Active      bool      `json:"active"`
+	Provisioned bool      `json:"provisioned"`
+}
+
+// freezeWindowError is returned when a published library panel is changed during a freeze window.
+type freezeWindowError struct {
+	name string
+	ends time.Time
+}
+
+func (e *freezeWindowError) Error() string {
+	return fmt.Sprintf("%s, freeze window %q ends at %s", errLibraryPanelFrozen.Error(), e.name, e.ends.UTC().Format(time.RFC3339))
+}
+
+func (e *freezeWindowError) Unwrap() error {
+	return errLibraryPanelFrozen
+}
+
+func validateFreezeWindow(name string, starts time.Time, ends time.Time) error {
+	if len(name) == 0 || starts.IsZero() || !ends.After(starts) {
+		return errLibraryPanelInvalidFreezeWindow
+	}
+
+	return nil
+}
+
+func toFreezeWindowDTO(window libraryPanelFreezeWindow, now time.Time) LibraryPanelFreezeWindowDTO {
+	return LibraryPanelFreezeWindowDTO{
+		ID:          window.ID,
+		Name:        window.Name,
+		Reason:      window.Reason,
+		Starts:      window.Starts,
+		Ends:        window.Ends,
+		Active:      !now.Before(window.Starts) && now.Before(window.Ends),
+		Provisioned: window.Provisioned,
+	}
+}
+
+func (lps *LibraryPanelService) canOverrideFreezeWindow(user *models.SignedInUser, uid string) (bool, error) {
+	if lps.AccessControl == nil || lps.AccessControl.IsDisabled() {
+		return user.HasRole(models.ROLE_ADMIN), nil
+	}
+
+	return lps.AccessControl.Evaluate(context.Background(), user, accesscontrol.ActionLibraryPanelsFreezeOverride, accesscontrol.ScopeLibraryPanel(uid))
+}
+
+// requireNotFrozen checks that a library panel can be changed, published library panels can't be changed during
+// a freeze window unless the user can override freeze windows.
+func (lps *LibraryPanelService) requireNotFrozen(session *sqlstore.DBSession, user *models.SignedInUser, panel LibraryPanelWithMeta) error {
+	window, err := getFreezeWindowForLibraryPanel(session, panel.OrgID, panel.ID)
+	if err != nil || window == nil {
+		return err
+	}
+
+	canOverride, err := lps.canOverrideFreezeWindow(user, panel.UID)
+	if err != nil {
+		return err
+	}
+	if canOverride {
+		return nil
+	}
+
+	return &freezeWindowError{name: window.Name, ends: window.Ends}
+}
+
+// getFreezeWindowForLibraryPanel gets the active freeze window that ends last when a library panel is published to
+// the catalog, or nil when the library panel isn't frozen.
+func getFreezeWindowForLibraryPanel(session *sqlstore.DBSession, orgID int64, panelID int64) (*libraryPanelFreezeWindow, error) {
+	var windows []libraryPanelFreezeWindow
+	now := time.Now()
+	sql := "SELECT * FROM library_panel_freeze_window WHERE org_id=? AND starts<=? AND ends>? ORDER BY ends DESC"
+	if err := session.SQL(sql, orgID, now, now).Find(&windows); err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	var entries []libraryPanelCatalogEntry
+	if err := session.SQL("SELECT * FROM library_panel_catalog_entry WHERE librarypanel_id=?", panelID).Find(&entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	return &windows[0], nil
+}
+
+// getFreezeWindows gets the freeze windows of the signed in user's org that haven't ended yet.
+func (lps *LibraryPanelService) getFreezeWindows(c *models.ReqContext) ([]LibraryPanelFreezeWindowDTO, error) {
+	dtos := make([]LibraryPanelFreezeWindowDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var windows []libraryPanelFreezeWindow
+		now := time.Now()
+		sql := "SELECT * FROM library_panel_freeze_window WHERE org_id=? AND ends>? ORDER BY starts ASC"
+		if err := session.SQL(sql, c.SignedInUser.OrgId, now).Find(&windows); err != nil {
+			return err
+		}
+		for _, window := range windows {
+			dtos = append(dtos, toFreezeWindowDTO(window, now))
+		}
+		return nil
+	})
+
+	return dtos, err
+}
+
+// createFreezeWindow declares a freeze window for the signed in user's org.
+func (lps *LibraryPanelService) createFreezeWindow(c *models.ReqContext, cmd createFreezeWindowCommand) (LibraryPanelFreezeWindowDTO, error) {
+	if err := validateFreezeWindow(cmd.Name, cmd.Starts, cmd.Ends); err != nil {
+		return LibraryPanelFreezeWindowDTO{}, err
+	}
+
+	window := libraryPanelFreezeWindow{
+		OrgID:     c.SignedInUser.OrgId,
+		Name:      cmd.Name,
+		Reason:    cmd.Reason,
+		Starts:    cmd.Starts,
+		Ends:      cmd.Ends,
+		Created:   time.Now(),
+		CreatedBy: c.SignedInUser.UserId,
+	}
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		_, err := session.Insert(&window)
+		return err
+	})
+	if err != nil {
+		return LibraryPanelFreezeWindowDTO{}, err
+	}
+
+	return toFreezeWindowDTO(window, time.Now()), nil
+}
+
+// deleteFreezeWindow deletes a freeze window of the signed in user's org, provisioned freeze windows can only be
+// removed from provisioning.
+func (lps *LibraryPanelService) deleteFreezeWindow(c *models.ReqContext, id int64) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var windows []libraryPanelFreezeWindow
+		sql := "SELECT * FROM library_panel_freeze_window WHERE id=? AND org_id=?"
+		if err := session.SQL(sql, id, c.SignedInUser.OrgId).Find(&windows); err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return errLibraryPanelFreezeWindowNotFound
+		}
+		if windows[0].Provisioned {
+			return errLibraryPanelFreezeWindowProvisioned
+		}
+
+		_, err := session.Exec("DELETE FROM library_panel_freeze_window WHERE id=?", id)
+		return err
+	})
+}
+
+// provisionFreezeWindows replaces all provisioned freeze windows.
+func (lps *LibraryPanelService) provisionFreezeWindows(ctx context.Context, cmds []ProvisionFreezeWindowCommand) error {
+	for _, cmd := range cmds {
+		if err := validateFreezeWindow(cmd.Name, cmd.Starts, cmd.Ends); err != nil {
+			return err
+		}
+	}
+
+	return lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		if _, err := session.Exec("DELETE FROM library_panel_freeze_window WHERE provisioned=" + lps.SQLStore.Dialect.BooleanStr(true)); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			window := libraryPanelFreezeWindow{
+				OrgID:       cmd.OrgID,
+				Name:        cmd.Name,
+				Reason:      cmd.Reason,
+				Starts:      cmd.Starts,
+				Ends:        cmd.Ends,
+				Provisioned: true,
+				Created:     time.Now(),
+			}
+			if _, err := session.Insert(&window); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
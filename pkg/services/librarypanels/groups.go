@@ -0,0 +1,340 @@
+package librarypanels
+
+import (
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// libraryPanelGroup is the model for a group of library panels that are published together.
+type libraryPanelGroup struct {
+	ID    int64  `xorm:"pk autoincr 'id'"`
+	OrgID int64  `xorm:"org_id"`
+	UID   string `xorm:"uid"`
+	Name  string `xorm:"name"`
+
+	Created time.Time
+	Updated time.Time
+
+	CreatedBy int64
+	UpdatedBy int64
+}
+
+// libraryPanelGroupMember is the model for the library panels of a group.
+type libraryPanelGroupMember struct {
+	ID             int64 `xorm:"pk autoincr 'id'"`
+	GroupID        int64 `xorm:"group_id"`
+	LibraryPanelID int64 `xorm:"librarypanel_id"`
+}
+
+// LibraryPanelGroupDTO is the frontend DTO for library panel groups.
+type LibraryPanelGroupDTO struct {
+	UID     string                       `json:"uid"`
+	Name    string                       `json:"name"`
+	Panels  []LibraryPanelGroupMemberDTO `json:"panels"`
+	Created time.Time                    `json:"created"`
+	Updated time.Time                    `json:"updated"`
+}
+
+// LibraryPanelGroupMemberDTO is a library panel of a group.
+type LibraryPanelGroupMemberDTO struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+func validateLibraryPanelGroup(cmd setLibraryPanelGroupCommand) error {
+	if cmd.Name == "" || len(cmd.UIDs) == 0 {
+		return errLibraryPanelInvalidGroup
+	}
+	seen := make(map[string]bool, len(cmd.UIDs))
+	for _, uid := range cmd.UIDs {
+		if seen[uid] {
+			return errLibraryPanelInvalidGroup
+		}
+		seen[uid] = true
+	}
+
+	return nil
+}
+
+func getLibraryPanelGroup(session *sqlstore.DBSession, uid string, orgID int64) (libraryPanelGroup, error) {
+	var groups []libraryPanelGroup
+	if err := session.SQL("SELECT * FROM library_panel_group WHERE uid=? AND org_id=?", uid, orgID).Find(&groups); err != nil {
+		return libraryPanelGroup{}, err
+	}
+	if len(groups) == 0 {
+		return libraryPanelGroup{}, errLibraryPanelGroupNotFound
+	}
+
+	return groups[0], nil
+}
+
+// getLibraryPanelGroupMembers gets the library panels of groups, ordered by name. With a user, only the library
+// panels the user can view are returned, like they're found by a search.
+func getLibraryPanelGroupMembers(session *sqlstore.DBSession, user *models.SignedInUser, groupIDs ...int64) (map[int64][]LibraryPanelGroupMemberDTO, error) {
+	members := make(map[int64][]LibraryPanelGroupMemberDTO, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+
+	var rows []struct {
+		GroupID int64  `xorm:"group_id"`
+		UID     string `xorm:"uid"`
+		Name    string `xorm:"name"`
+		Version int64  `xorm:"version"`
+	}
+	builder := sqlstore.SQLBuilder{}
+	builder.Write(`SELECT lpgm.group_id, lp.uid, lp.name, lp.version
+FROM library_panel_group_member AS lpgm
+INNER JOIN library_panel AS lp ON lp.id = lpgm.librarypanel_id
+LEFT JOIN dashboard AS dashboard ON dashboard.id = lp.folder_id AND lp.folder_id <> 0
+WHERE lp.deleted_at IS NULL AND lpgm.group_id IN (?` + strings.Repeat(",?", len(groupIDs)-1) + ")")
+	for _, groupID := range groupIDs {
+		builder.AddParams(groupID)
+	}
+	if user != nil && user.OrgRole != models.ROLE_ADMIN {
+		builder.Write(" AND (lp.folder_id = 0 OR (dashboard.id IS NOT NULL")
+		builder.WriteDashboardPermissionFilter(user, models.PERMISSION_VIEW)
+		builder.Write(") OR (lp.id IS NOT NULL")
+		writeSharedWithUserSQL(user, &builder)
+		builder.Write("))")
+	}
+	builder.Write(" ORDER BY lp.name ASC")
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		members[row.GroupID] = append(members[row.GroupID], LibraryPanelGroupMemberDTO{UID: row.UID, Name: row.Name, Version: row.Version})
+	}
+
+	return members, nil
+}
+
+func toLibraryPanelGroupDTO(group libraryPanelGroup, members []LibraryPanelGroupMemberDTO) LibraryPanelGroupDTO {
+	if members == nil {
+		members = make([]LibraryPanelGroupMemberDTO, 0)
+	}
+
+	return LibraryPanelGroupDTO{
+		UID:     group.UID,
+		Name:    group.Name,
+		Panels:  members,
+		Created: group.Created,
+		Updated: group.Updated,
+	}
+}
+
+// setLibraryPanelGroupMembers replaces the library panels of a group, the user must have permissions on the folder of
+// each of them.
+func (lps *LibraryPanelService) setLibraryPanelGroupMembers(session *sqlstore.DBSession, user *models.SignedInUser, group libraryPanelGroup, uids []string) error {
+	panelIDs := make([]int64, 0, len(uids))
+	for _, uid := range uids {
+		panel, err := getLibraryPanel(session, uid, group.OrgID)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(user, panel.FolderID); err != nil {
+			return err
+		}
+		panelIDs = append(panelIDs, panel.ID)
+	}
+
+	if _, err := session.Exec("DELETE FROM library_panel_group_member WHERE group_id=?", group.ID); err != nil {
+		return err
+	}
+	for _, panelID := range panelIDs {
+		member := libraryPanelGroupMember{GroupID: group.ID, LibraryPanelID: panelID}
+		if _, err := session.Insert(&member); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createLibraryPanelGroup creates a group of Library Panels in the signed in user's org.
+func (lps *LibraryPanelService) createLibraryPanelGroup(c *models.ReqContext, cmd setLibraryPanelGroupCommand) (LibraryPanelGroupDTO, error) {
+	if err := validateLibraryPanelGroup(cmd); err != nil {
+		return LibraryPanelGroupDTO{}, err
+	}
+
+	var dto LibraryPanelGroupDTO
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		group := libraryPanelGroup{
+			OrgID:     c.SignedInUser.OrgId,
+			UID:       util.GenerateShortUID(),
+			Name:      cmd.Name,
+			Created:   time.Now(),
+			Updated:   time.Now(),
+			CreatedBy: c.SignedInUser.UserId,
+			UpdatedBy: c.SignedInUser.UserId,
+		}
+		if _, err := session.Insert(&group); err != nil {
+			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return errLibraryPanelGroupAlreadyExists
+			}
+			return err
+		}
+		if err := lps.setLibraryPanelGroupMembers(session, c.SignedInUser, group, cmd.UIDs); err != nil {
+			return err
+		}
+		members, err := getLibraryPanelGroupMembers(session, c.SignedInUser, group.ID)
+		if err != nil {
+			return err
+		}
+		dto = toLibraryPanelGroupDTO(group, members[group.ID])
+		return nil
+	})
+
+	return dto, err
+}
+
+// updateLibraryPanelGroup renames a group of Library Panels and replaces its Library Panels.
+func (lps *LibraryPanelService) updateLibraryPanelGroup(c *models.ReqContext, uid string, cmd setLibraryPanelGroupCommand) (LibraryPanelGroupDTO, error) {
+	if err := validateLibraryPanelGroup(cmd); err != nil {
+		return LibraryPanelGroupDTO{}, err
+	}
+
+	var dto LibraryPanelGroupDTO
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		group, err := getLibraryPanelGroup(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		group.Name = cmd.Name
+		group.Updated = time.Now()
+		group.UpdatedBy = c.SignedInUser.UserId
+		if _, err := session.ID(group.ID).Update(&group); err != nil {
+			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return errLibraryPanelGroupAlreadyExists
+			}
+			return err
+		}
+		if err := lps.setLibraryPanelGroupMembers(session, c.SignedInUser, group, cmd.UIDs); err != nil {
+			return err
+		}
+		members, err := getLibraryPanelGroupMembers(session, c.SignedInUser, group.ID)
+		if err != nil {
+			return err
+		}
+		dto = toLibraryPanelGroupDTO(group, members[group.ID])
+		return nil
+	})
+
+	return dto, err
+}
+
+// getLibraryPanelGroups gets the groups of Library Panels in the signed in user's org.
+func (lps *LibraryPanelService) getLibraryPanelGroups(c *models.ReqContext) ([]LibraryPanelGroupDTO, error) {
+	dtos := make([]LibraryPanelGroupDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var groups []libraryPanelGroup
+		if err := session.SQL("SELECT * FROM library_panel_group WHERE org_id=? ORDER BY name ASC", c.SignedInUser.OrgId).Find(&groups); err != nil {
+			return err
+		}
+		groupIDs := make([]int64, 0, len(groups))
+		for _, group := range groups {
+			groupIDs = append(groupIDs, group.ID)
+		}
+		members, err := getLibraryPanelGroupMembers(session, c.SignedInUser, groupIDs...)
+		if err != nil {
+			return err
+		}
+		for _, group := range groups {
+			dtos = append(dtos, toLibraryPanelGroupDTO(group, members[group.ID]))
+		}
+		return nil
+	})
+
+	return dtos, err
+}
+
+// getLibraryPanelGroupByUID gets a group of Library Panels.
+func (lps *LibraryPanelService) getLibraryPanelGroupByUID(c *models.ReqContext, uid string) (LibraryPanelGroupDTO, error) {
+	var dto LibraryPanelGroupDTO
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		group, err := getLibraryPanelGroup(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		members, err := getLibraryPanelGroupMembers(session, c.SignedInUser, group.ID)
+		if err != nil {
+			return err
+		}
+		dto = toLibraryPanelGroupDTO(group, members[group.ID])
+		return nil
+	})
+
+	return dto, err
+}
+
+// deleteLibraryPanelGroup deletes a group of Library Panels, the Library Panels themselves are kept.
+func (lps *LibraryPanelService) deleteLibraryPanelGroup(c *models.ReqContext, uid string) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		group, err := getLibraryPanelGroup(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if _, err := session.Exec("DELETE FROM library_panel_group_member WHERE group_id=?", group.ID); err != nil {
+			return err
+		}
+		_, err = session.Exec("DELETE FROM library_panel_group WHERE id=?", group.ID)
+		return err
+	})
+}
+
+// publishLibraryPanelGroup updates every Library Panel of a group in one transaction, either all changes are
+// applied or none of them are. Each Library Panel of the group must be updated exactly once.
+func (lps *LibraryPanelService) publishLibraryPanelGroup(c *models.ReqContext, uid string, cmd publishLibraryPanelGroupCommand) ([]LibraryPanelDTO, error) {
+	published := make([]LibraryPanelDTO, 0, len(cmd.Panels))
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		group, err := getLibraryPanelGroup(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		// every library panel of the group must be published, the permissions are checked when each is updated
+		members, err := getLibraryPanelGroupMembers(session, nil, group.ID)
+		if err != nil {
+			return err
+		}
+		pending := make(map[string]bool, len(members[group.ID]))
+		for _, member := range members[group.ID] {
+			pending[member.UID] = true
+		}
+		for _, panel := range cmd.Panels {
+			if !pending[panel.UID] {
+				return errLibraryPanelGroupIncomplete
+			}
+			delete(pending, panel.UID)
+		}
+		if len(pending) > 0 {
+			return errLibraryPanelGroupIncomplete
+		}
+
+		for _, panel := range cmd.Panels {
+			dto, err := lps.patchLibraryPanelInSession(session, c, patchLibraryPanelCommand{
+				FolderID: -1,
+				Name:     panel.Name,
+				Model:    panel.Model,
+				Version:  panel.Version,
+			}, panel.UID)
+			if err != nil {
+				return err
+			}
+			published = append(published, dto)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dto := range published {
+		lps.scanSavedLibraryPanel(c.Context.Req.Context(), dto)
+	}
+	return published, nil
+}
@@ -1,7 +1,10 @@
 package librarypanels
 
 import (
+	"context"
+
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/guardian"
 )
@@ -37,3 +40,79 @@ func (lps *LibraryPanelService) requirePermissionsOnFolder(user *models.SignedIn
 
 	return nil
 }
+
+// folderViewChecker returns a function that checks whether the user can view a folder, remembering the folders
+// already checked.
+func (lps *LibraryPanelService) folderViewChecker(user *models.SignedInUser) func(folderID int64) (bool, error) {
+	canView := make(map[int64]bool)
+	return func(folderID int64) (bool, error) {
+		if isGeneralFolder(folderID) {
+			return true, nil
+		}
+		if allowed, ok := canView[folderID]; ok {
+			return allowed, nil
+		}
+
+		allowed, err := guardian.New(folderID, user.OrgId, user).CanView()
+		if err != nil {
+			return false, err
+		}
+		canView[folderID] = allowed
+		return allowed, nil
+	}
+}
+
+// requireDashboardEdit checks that the user can edit a dashboard, which is needed to change how a library panel
+// shows on it.
+func requireDashboardEdit(user *models.SignedInUser, dashboardID int64) error {
+	canEdit, err := guardian.New(dashboardID, user.OrgId, user).CanEdit()
+	if err != nil {
+		return err
+	}
+	if !canEdit {
+		return models.ErrDashboardUpdateAccessDenied
+	}
+
+	return nil
+}
+
+// requireLibraryPanelAccess checks an access control action for a library panel in addition to the folder permissions.
+// The library panel is in scope of its uid and of its folder, an empty uid leaves out the uid scope. Nothing is
+// checked when access control is disabled.
+func (lps *LibraryPanelService) requireLibraryPanelAccess(user *models.SignedInUser, action string, uid string, folderID int64) error {
+	if lps.AccessControl == nil || lps.AccessControl.IsDisabled() {
+		return nil
+	}
+
+	scopes := []string{accesscontrol.ScopeFolder(folderID)}
+	if uid != "" {
+		scopes = append(scopes, accesscontrol.ScopeLibraryPanel(uid))
+	}
+	hasAccess, err := lps.AccessControl.Evaluate(context.Background(), user, action, scopes...)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return errLibraryPanelAccessDenied
+	}
+
+	return nil
+}
+
+// requireLibraryPanelAction checks that the user has an access control action for any library panel. Nothing is
+// checked when access control is disabled.
+func (lps *LibraryPanelService) requireLibraryPanelAction(user *models.SignedInUser, action string) error {
+	if lps.AccessControl == nil || lps.AccessControl.IsDisabled() {
+		return nil
+	}
+
+	hasAccess, err := lps.AccessControl.Evaluate(context.Background(), user, action)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return errLibraryPanelAccessDenied
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package librarypanels
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// maxHydrateStaleness is the largest staleness budget accepted when loading the library panels of a dashboard,
+// cached library panels expire after it.
+const maxHydrateStaleness = 5 * time.Minute
+
+// hydratedLibraryPanels are the library panels and connections of a dashboard as they were when fetched.
+type hydratedLibraryPanels struct {
+	libraryPanels map[string]LibraryPanelDTO
+	connections   map[int64]libraryPanelDashboard
+	fetched       time.Time
+}
+
+// copyMaps copies the library panels and connections, so they can be changed without changing the cached ones.
+func (h hydratedLibraryPanels) copyMaps() (map[string]LibraryPanelDTO, map[int64]libraryPanelDashboard) {
+	libraryPanels := make(map[string]LibraryPanelDTO, len(h.libraryPanels))
+	for uid, panel := range h.libraryPanels {
+		libraryPanels[uid] = panel
+	}
+	connections := make(map[int64]libraryPanelDashboard, len(h.connections))
+	for id, connection := range h.connections {
+		connections[id] = connection
+	}
+	return libraryPanels, connections
+}
+
+func hydrateCacheKey(dashboardID int64) string {
+	return fmt.Sprintf("library-panels-hydrate-%d", dashboardID)
+}
+
+// getHydrateStaleness gets the staleness budget of the request from the maxStaleSeconds query parameter.
+func getHydrateStaleness(c *models.ReqContext) time.Duration {
+	maxStale := time.Duration(c.QueryInt64("maxStaleSeconds")) * time.Second
+	if maxStale > maxHydrateStaleness {
+		return maxHydrateStaleness
+	}
+	return maxStale
+}
+
+// fetchLibraryPanelsForDashboard fetches the library panels and connections of a dashboard and caches them for
+// stale reads.
+func (lps *LibraryPanelService) fetchLibraryPanelsForDashboard(ctx context.Context, dashboardID int64) (hydratedLibraryPanels, error) {
+	libraryPanels, err := lps.getLibraryPanelsForDashboardID(ctx, dashboardID)
+	if err != nil {
+		return hydratedLibraryPanels{}, err
+	}
+	connections, err := lps.getConnectionsForDashboard(ctx, dashboardID)
+	if err != nil {
+		return hydratedLibraryPanels{}, err
+	}
+
+	hydrated := hydratedLibraryPanels{libraryPanels: libraryPanels, connections: connections, fetched: time.Now()}
+	if lps.SQLStore.CacheService != nil {
+		lps.SQLStore.CacheService.Set(hydrateCacheKey(dashboardID), hydrated, maxHydrateStaleness)
+	}
+
+	return hydrated, nil
+}
+
+// revalidateLibraryPanelsForDashboard refreshes the cached library panels of a dashboard in the background, at
+// most one refresh per dashboard runs at a time.
+func (lps *LibraryPanelService) revalidateLibraryPanelsForDashboard(dashboardID int64) {
+	if _, refreshing := lps.hydrateRefreshing.LoadOrStore(dashboardID, true); refreshing {
+		return
+	}
+
+	go func() {
+		defer lps.hydrateRefreshing.Delete(dashboardID)
+		if _, err := lps.fetchLibraryPanelsForDashboard(context.Background(), dashboardID); err != nil {
+			lps.log.Warn("Failed to refresh library panels for dashboard", "dashboardId", dashboardID, "error", err)
+		}
+	}()
+}
+
+// getLibraryPanelsForDashboard gets the library panels and connections of a dashboard. With a staleness budget,
+// library panels cached within the budget are served immediately and refreshed in the background, so loading a
+// dashboard only waits on the database when nothing recent enough is cached. The returned maps can be changed.
+func (lps *LibraryPanelService) getLibraryPanelsForDashboard(c *models.ReqContext, dashboardID int64, maxStale time.Duration) (map[string]LibraryPanelDTO, map[int64]libraryPanelDashboard, error) {
+	if maxStale <= 0 || lps.SQLStore.CacheService == nil {
+		libraryPanels, err := lps.getLibraryPanelsForDashboardID(c.Context.Req.Context(), dashboardID)
+		if err != nil {
+			return nil, nil, err
+		}
+		connections, err := lps.getConnectionsForDashboard(c.Context.Req.Context(), dashboardID)
+		return libraryPanels, connections, err
+	}
+
+	if cached, ok := lps.SQLStore.CacheService.Get(hydrateCacheKey(dashboardID)); ok {
+		if hydrated := cached.(hydratedLibraryPanels); time.Since(hydrated.fetched) <= maxStale {
+			lps.revalidateLibraryPanelsForDashboard(dashboardID)
+
+			libraryPanels, connections := hydrated.copyMaps()
+			return libraryPanels, connections, nil
+		}
+	}
+
+	hydrated, err := lps.fetchLibraryPanelsForDashboard(c.Context.Req.Context(), dashboardID)
+	if err != nil {
+		return nil, nil, err
+	}
+	libraryPanels, connections := hydrated.copyMaps()
+	return libraryPanels, connections, nil
+}
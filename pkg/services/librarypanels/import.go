@@ -0,0 +1,116 @@
+package librarypanels
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelInputType is the type of dashboard inputs that are library panels. The input definition in
+// __inputs has the uid, name and model of the exported library panel.
+const libraryPanelInputType = "librarypanel"
+
+// ImportLibraryPanelsForDashboard resolves the library panel inputs of a dashboard that is being imported. An input
+// with a value is mapped to the existing library panel with that uid. An input without a value is mapped to the
+// library panel with the exported uid if the org has it, otherwise the exported library panel is created in
+// folderID. The returned inputs have a library panel uid as value for every library panel input.
+func (lps *LibraryPanelService) ImportLibraryPanelsForDashboard(c *models.ReqContext, dashboard *simplejson.Json, inputs []plugins.ImportDashboardInput, folderID int64) ([]plugins.ImportDashboardInput, error) {
+	if !lps.IsEnabled() || dashboard == nil {
+		return inputs, nil
+	}
+
+	resolved := make([]plugins.ImportDashboardInput, 0, len(inputs))
+	definitions := make(map[string]*simplejson.Json)
+	for _, definition := range dashboard.Get("__inputs").MustArray() {
+		definitionJSON := simplejson.NewFromAny(definition)
+		if definitionJSON.Get("type").MustString() == libraryPanelInputType {
+			definitions[definitionJSON.Get("name").MustString()] = definitionJSON
+		}
+	}
+	for _, input := range inputs {
+		if input.Type != libraryPanelInputType {
+			resolved = append(resolved, input)
+			continue
+		}
+		definition, ok := definitions[input.Name]
+		if !ok {
+			return nil, errLibraryPanelInvalidImportInput
+		}
+
+		uid, err := lps.importLibraryPanel(c, definition, input.Value, folderID)
+		if err != nil {
+			return nil, err
+		}
+		input.Value = uid
+		resolved = append(resolved, input)
+	}
+
+	return resolved, nil
+}
+
+func (lps *LibraryPanelService) importLibraryPanel(c *models.ReqContext, definition *simplejson.Json, value string, folderID int64) (string, error) {
+	if value != "" {
+		panel, err := lps.getLibraryPanel(c, value)
+		if err != nil {
+			return "", err
+		}
+		return panel.UID, nil
+	}
+
+	if uid := definition.Get("uid").MustString(); uid != "" {
+		panel, err := lps.getLibraryPanel(c, uid)
+		if err == nil {
+			return panel.UID, nil
+		}
+		if !errors.Is(err, errLibraryPanelNotFound) {
+			return "", err
+		}
+	}
+
+	if definition.Get("model").Interface() == nil {
+		return "", errLibraryPanelInvalidImportInput
+	}
+	model, err := definition.Get("model").MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	if required := minGrafanaVersion(model); isNewerThanGrafana(required, lps.Cfg.BuildVersion) {
+		lps.log.Warn("Importing library panel authored on a newer Grafana version", "uid",
+			definition.Get("uid").MustString(), "requiredVersion", required, "version", lps.Cfg.BuildVersion)
+	}
+	name := definition.Get("label").MustString()
+	if name == "" {
+		name = definition.Get("model").Get("title").MustString()
+	}
+
+	// importing the same dashboard again maps to the library panel created by the first import
+	var existing []LibraryPanel
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		sql := "SELECT * FROM library_panel WHERE org_id=? AND folder_id=? AND name=? AND deleted_at IS NULL"
+		return session.SQL(sql, c.SignedInUser.OrgId, folderID, name).Find(&existing)
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(existing) > 0 {
+		panel, err := lps.getLibraryPanel(c, existing[0].UID)
+		if err != nil {
+			return "", err
+		}
+		return panel.UID, nil
+	}
+
+	panel, err := lps.createLibraryPanel(c, createLibraryPanelCommand{
+		FolderID: folderID,
+		Name:     name,
+		Model:    model,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return panel.UID, nil
+}
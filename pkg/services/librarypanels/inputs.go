@@ -0,0 +1,224 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	// libraryPanelInputTypeText is an input that is replaced with its value as is.
+	libraryPanelInputTypeText = "text"
+	// libraryPanelInputTypeDatasource is an input whose value must be the name or uid of a datasource in the org.
+	libraryPanelInputTypeDatasource = "datasource"
+)
+
+// maxInputLabelLength and maxInputDefaultLength are the lengths of the label and default_value columns in
+// library_panel_input.
+const (
+	maxInputLabelLength   = 150
+	maxInputDefaultLength = 255
+)
+
+var inputNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,49}$`)
+
+// libraryPanelInput is the model for the input parameters of a library panel.
+type libraryPanelInput struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Name           string `xorm:"name"`
+	Label          string `xorm:"label"`
+	Type           string `xorm:"type"`
+	DefaultValue   string `xorm:"default_value"`
+	Required       bool   `xorm:"required"`
+}
+
+// LibraryPanelInputDTO is an input parameter of a library panel, referenced as ${name} in its model.
+type LibraryPanelInputDTO struct {
+	Name     string `json:"name"`
+	Label    string `json:"label,omitempty"`
+	Type     string `json:"type"`
+	Default  string `json:"default,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// normalizeInputs checks the inputs of a library panel and defaults their type to text.
+func normalizeInputs(inputs []LibraryPanelInputDTO) ([]LibraryPanelInputDTO, error) {
+	normalized := make([]LibraryPanelInputDTO, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		if !inputNameRegex.MatchString(input.Name) || seen[input.Name] {
+			return nil, errLibraryPanelInvalidInputs
+		}
+		if input.Type == "" {
+			input.Type = libraryPanelInputTypeText
+		}
+		if input.Type != libraryPanelInputTypeText && input.Type != libraryPanelInputTypeDatasource {
+			return nil, errLibraryPanelInvalidInputs
+		}
+		if len(input.Label) > maxInputLabelLength || len(input.Default) > maxInputDefaultLength {
+			return nil, errLibraryPanelInvalidInputs
+		}
+		seen[input.Name] = true
+		normalized = append(normalized, input)
+	}
+
+	return normalized, nil
+}
+
+// getInputsForLibraryPanels gets the inputs of library panels in the order they were declared.
+func getInputsForLibraryPanels(session *sqlstore.DBSession, panelIDs ...int64) (map[int64][]LibraryPanelInputDTO, error) {
+	inputsByPanel := make(map[int64][]LibraryPanelInputDTO)
+	if len(panelIDs) == 0 {
+		return inputsByPanel, nil
+	}
+
+	params := make([]interface{}, 0, len(panelIDs))
+	for _, id := range panelIDs {
+		params = append(params, id)
+	}
+	var inputs []libraryPanelInput
+	sql := "SELECT * FROM library_panel_input WHERE librarypanel_id IN (?" + strings.Repeat(",?", len(panelIDs)-1) + ") ORDER BY id"
+	if err := session.SQL(sql, params...).Find(&inputs); err != nil {
+		return nil, err
+	}
+	for _, input := range inputs {
+		inputsByPanel[input.LibraryPanelID] = append(inputsByPanel[input.LibraryPanelID], LibraryPanelInputDTO{
+			Name:     input.Name,
+			Label:    input.Label,
+			Type:     input.Type,
+			Default:  input.DefaultValue,
+			Required: input.Required,
+		})
+	}
+
+	return inputsByPanel, nil
+}
+
+func setInputsForLibraryPanel(session *sqlstore.DBSession, panelID int64, inputs []LibraryPanelInputDTO) error {
+	if _, err := session.Exec("DELETE FROM library_panel_input WHERE librarypanel_id=?", panelID); err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		libraryPanelInput := libraryPanelInput{
+			LibraryPanelID: panelID,
+			Name:           input.Name,
+			Label:          input.Label,
+			Type:           input.Type,
+			DefaultValue:   input.Default,
+			Required:       input.Required,
+		}
+		if _, err := session.Insert(&libraryPanelInput); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// substituteInputs replaces ${name} with the value of the input in every string of a library panel model.
+// References to anything that isn't an input, such as dashboard variables, are kept.
+func substituteInputs(model json.RawMessage, values map[string]string) (json.RawMessage, error) {
+	if len(values) == 0 {
+		return model, nil
+	}
+
+	pairs := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		pairs = append(pairs, "${"+name+"}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	var substitute func(value interface{}) interface{}
+	substitute = func(value interface{}) interface{} {
+		switch node := value.(type) {
+		case string:
+			return replacer.Replace(node)
+		case map[string]interface{}:
+			for key, child := range node {
+				node[key] = substitute(child)
+			}
+			return node
+		case []interface{}:
+			for i, child := range node {
+				node[i] = substitute(child)
+			}
+			return node
+		default:
+			return value
+		}
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(model, &doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(substitute(doc))
+}
+
+func datasourceExists(session *sqlstore.DBSession, orgID int64, nameOrUID string) (bool, error) {
+	var datasources []struct {
+		ID int64 `xorm:"id"`
+	}
+	if err := session.SQL("SELECT id FROM data_source WHERE org_id=? AND (name=? OR uid=?)", orgID, nameOrUID, nameOrUID).Find(&datasources); err != nil {
+		return false, err
+	}
+
+	return len(datasources) > 0, nil
+}
+
+// renderLibraryPanelModel returns the model of a Library Panel with its inputs replaced by values. Inputs without a
+// value get their default, required inputs must have one and datasource inputs must name a datasource in the org.
+func (lps *LibraryPanelService) renderLibraryPanelModel(c *models.ReqContext, uid string, values map[string]string) (json.RawMessage, error) {
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool, len(panel.Inputs))
+	for _, input := range panel.Inputs {
+		declared[input.Name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("%w: %q isn't an input of the library panel", errLibraryPanelInvalidInputValues, name)
+		}
+	}
+
+	resolved := make(map[string]string, len(panel.Inputs))
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		for _, input := range panel.Inputs {
+			value := values[input.Name]
+			if value == "" {
+				value = input.Default
+			}
+			if value == "" {
+				if input.Required {
+					return fmt.Errorf("%w: %q is required", errLibraryPanelInvalidInputValues, input.Name)
+				}
+				continue
+			}
+			if input.Type == libraryPanelInputTypeDatasource {
+				exists, err := datasourceExists(session, c.SignedInUser.OrgId, value)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					return fmt.Errorf("%w: %q", models.ErrDataSourceNotFound, value)
+				}
+			}
+			resolved[input.Name] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return substituteInputs(panel.Model, resolved)
+}
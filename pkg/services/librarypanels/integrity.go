@@ -0,0 +1,148 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	integrityCollisionUID  = "uid"
+	integrityCollisionName = "name"
+)
+
+// LibraryPanelIntegrityConnectionDTO is the DTO for a connection to a dashboard or library panel that doesn't exist.
+type LibraryPanelIntegrityConnectionDTO struct {
+	ID             int64  `json:"id" xorm:"id"`
+	LibraryPanelID int64  `json:"libraryPanelId" xorm:"librarypanel_id"`
+	DashboardID    int64  `json:"dashboardId" xorm:"dashboard_id"`
+	Reason         string `json:"reason" xorm:"-"`
+}
+
+// LibraryPanelIntegrityPanelDTO is the DTO for a library panel with an integrity problem.
+type LibraryPanelIntegrityPanelDTO struct {
+	OrgID    int64  `json:"orgId"`
+	UID      string `json:"uid"`
+	Name     string `json:"name"`
+	FolderID int64  `json:"folderId"`
+	Error    string `json:"error,omitempty"`
+}
+
+// LibraryPanelIntegrityCollisionDTO is the DTO for a uid or name that is used by library panels in several orgs.
+type LibraryPanelIntegrityCollisionDTO struct {
+	Field  string  `json:"field"`
+	Value  string  `json:"value"`
+	OrgIDs []int64 `json:"orgIds"`
+}
+
+// LibraryPanelIntegrityReportDTO is the DTO for the integrity report of the library panels of all orgs.
+type LibraryPanelIntegrityReportDTO struct {
+	Healthy             bool                                 `json:"healthy"`
+	DanglingConnections []LibraryPanelIntegrityConnectionDTO `json:"danglingConnections"`
+	MissingFolders      []LibraryPanelIntegrityPanelDTO      `json:"missingFolders"`
+	InvalidModels       []LibraryPanelIntegrityPanelDTO      `json:"invalidModels"`
+	Collisions          []LibraryPanelIntegrityCollisionDTO  `json:"collisions"`
+}
+
+// getIntegrityReport checks the Library Panels of all orgs for dangling connections, folders that don't exist,
+// models that can't be unmarshalled and uids or names that are used in several orgs. Nothing is changed, dangling
+// connections are removed by cleanDanglingConnections.
+func (lps *LibraryPanelService) getIntegrityReport(ctx context.Context) (LibraryPanelIntegrityReportDTO, error) {
+	report := LibraryPanelIntegrityReportDTO{
+		DanglingConnections: make([]LibraryPanelIntegrityConnectionDTO, 0),
+		MissingFolders:      make([]LibraryPanelIntegrityPanelDTO, 0),
+		InvalidModels:       make([]LibraryPanelIntegrityPanelDTO, 0),
+		Collisions:          make([]LibraryPanelIntegrityCollisionDTO, 0),
+	}
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		for _, dangling := range []struct {
+			reason string
+			sql    string
+		}{
+			{reason: danglingReasonDashboardDeleted, sql: "SELECT id, librarypanel_id, dashboard_id FROM library_panel_dashboard WHERE NOT EXISTS (SELECT 1 FROM dashboard WHERE dashboard.id = library_panel_dashboard.dashboard_id) ORDER BY id"},
+			{reason: danglingReasonLibraryPanelDeleted, sql: "SELECT id, librarypanel_id, dashboard_id FROM library_panel_dashboard WHERE NOT EXISTS (SELECT 1 FROM library_panel WHERE library_panel.id = library_panel_dashboard.librarypanel_id) ORDER BY id"},
+		} {
+			var connections []LibraryPanelIntegrityConnectionDTO
+			if err := session.SQL(dangling.sql).Find(&connections); err != nil {
+				return err
+			}
+			for _, connection := range connections {
+				connection.Reason = dangling.reason
+				report.DanglingConnections = append(report.DanglingConnections, connection)
+			}
+		}
+
+		var panels []LibraryPanel
+		sql := `SELECT lp.* FROM library_panel AS lp
+WHERE lp.folder_id<>0 AND NOT EXISTS (SELECT 1 FROM dashboard WHERE dashboard.id = lp.folder_id AND dashboard.org_id = lp.org_id AND dashboard.is_folder = ` + lps.SQLStore.Dialect.BooleanStr(true) + `)
+ORDER BY lp.org_id, lp.uid`
+		if err := session.SQL(sql).Find(&panels); err != nil {
+			return err
+		}
+		for _, panel := range panels {
+			report.MissingFolders = append(report.MissingFolders, LibraryPanelIntegrityPanelDTO{
+				OrgID:    panel.OrgID,
+				UID:      panel.UID,
+				Name:     panel.Name,
+				FolderID: panel.FolderID,
+			})
+		}
+
+		panels = nil
+		if err := session.SQL("SELECT * FROM library_panel ORDER BY org_id, uid").Find(&panels); err != nil {
+			return err
+		}
+		byUID := make(map[string][]int64)
+		byName := make(map[string][]int64)
+		var uids, names []string
+		for _, panel := range panels {
+			var model map[string]interface{}
+			if err := json.Unmarshal(panel.Model, &model); err != nil {
+				report.InvalidModels = append(report.InvalidModels, LibraryPanelIntegrityPanelDTO{
+					OrgID:    panel.OrgID,
+					UID:      panel.UID,
+					Name:     panel.Name,
+					FolderID: panel.FolderID,
+					Error:    err.Error(),
+				})
+			}
+			if _, ok := byUID[panel.UID]; !ok {
+				uids = append(uids, panel.UID)
+			}
+			byUID[panel.UID] = appendOrgID(byUID[panel.UID], panel.OrgID)
+			if _, ok := byName[panel.Name]; !ok {
+				names = append(names, panel.Name)
+			}
+			byName[panel.Name] = appendOrgID(byName[panel.Name], panel.OrgID)
+		}
+		for _, uid := range uids {
+			if len(byUID[uid]) > 1 {
+				report.Collisions = append(report.Collisions, LibraryPanelIntegrityCollisionDTO{Field: integrityCollisionUID, Value: uid, OrgIDs: byUID[uid]})
+			}
+		}
+		for _, name := range names {
+			if len(byName[name]) > 1 {
+				report.Collisions = append(report.Collisions, LibraryPanelIntegrityCollisionDTO{Field: integrityCollisionName, Value: name, OrgIDs: byName[name]})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return LibraryPanelIntegrityReportDTO{}, err
+	}
+
+	report.Healthy = len(report.DanglingConnections) == 0 && len(report.MissingFolders) == 0 &&
+		len(report.InvalidModels) == 0 && len(report.Collisions) == 0
+
+	return report, nil
+}
+
+// appendOrgID appends an org id to the org ids of a uid or name, which are in ascending order.
+func appendOrgID(orgIDs []int64, orgID int64) []int64 {
+	if len(orgIDs) > 0 && orgIDs[len(orgIDs)-1] == orgID {
+		return orgIDs
+	}
+	return append(orgIDs, orgID)
+}
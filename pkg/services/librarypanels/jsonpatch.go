@@ -0,0 +1,321 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// jsonPatchContentType is the content type of RFC 6902 JSON Patch documents.
+const jsonPatchContentType = "application/json-patch+json"
+
+// jsonPatchOperation is an operation of an RFC 6902 JSON Patch document.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", errLibraryPanelInvalidJSONPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%w: %q is not an array index", errLibraryPanelInvalidJSONPatch, token)
+	}
+	max := length - 1
+	if appending {
+		max = length
+	}
+	if index > max {
+		return 0, fmt.Errorf("%w: array index %d is out of bounds", errLibraryPanelInvalidJSONPatch, index)
+	}
+
+	return index, nil
+}
+
+// getJSONPointer returns the value a JSON Pointer refers to.
+func getJSONPointer(doc interface{}, tokens []string) (interface{}, error) {
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q doesn't exist", errLibraryPanelInvalidJSONPatch, token)
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("%w: %q can't be referenced in a value that is neither an object nor an array", errLibraryPanelInvalidJSONPatch, token)
+		}
+	}
+
+	return current, nil
+}
+
+// setJSONPointer applies change to the object or array that contains the location a JSON Pointer refers to and
+// returns the document with the change applied.
+func setJSONPointer(doc interface{}, tokens []string, change func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: the whole model can only be replaced", errLibraryPanelInvalidJSONPatch)
+	}
+	if len(tokens) == 1 {
+		return change(doc, tokens[0])
+	}
+
+	parent, err := getJSONPointer(doc, tokens[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err := setJSONPointer(parent, tokens[1:], change)
+	if err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[tokens[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(tokens[0], len(node), false)
+		node[index] = child
+	}
+
+	return doc, nil
+}
+
+func addJSONPointer(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	return setJSONPointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%w: %q can't be added to a value that is neither an object nor an array", errLibraryPanelInvalidJSONPatch, token)
+		}
+	})
+}
+
+func removeJSONPointer(doc interface{}, tokens []string) (interface{}, error) {
+	return setJSONPointer(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, fmt.Errorf("%w: member %q doesn't exist", errLibraryPanelInvalidJSONPatch, token)
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:index], node[index+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q can't be removed from a value that is neither an object nor an array", errLibraryPanelInvalidJSONPatch, token)
+		}
+	})
+}
+
+// deepCopyJSON copies a value decoded from JSON, so that copied values don't share objects or arrays.
+func deepCopyJSON(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for key, child := range node {
+			copied[key] = deepCopyJSON(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, child := range node {
+			copied[i] = deepCopyJSON(child)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch to a library panel model. The operations are applied in order and
+// nothing is applied if one of them fails. The patched model must still be an object.
+func applyJSONPatch(model json.RawMessage, operations []jsonPatchOperation) (json.RawMessage, error) {
+	var doc interface{}
+	if err := json.Unmarshal(model, &doc); err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		var err error
+		if doc, err = applyJSONPatchOperation(doc, operation); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%w: the patched model must be an object", errLibraryPanelInvalidJSONPatch)
+	}
+
+	return json.Marshal(doc)
+}
+
+func applyJSONPatchOperation(doc interface{}, operation jsonPatchOperation) (interface{}, error) {
+	path, err := parseJSONPointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, fmt.Errorf("%w: %s needs a value", errLibraryPanelInvalidJSONPatch, operation.Op)
+		}
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %s", errLibraryPanelInvalidJSONPatch, err)
+		}
+	}
+
+	switch operation.Op {
+	case "add":
+		return addJSONPointer(doc, path, value)
+	case "remove":
+		return removeJSONPointer(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = removeJSONPointer(doc, path); err != nil {
+			return nil, err
+		}
+		return addJSONPointer(doc, path, value)
+	case "move", "copy":
+		from, err := parseJSONPointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" && strings.HasPrefix(operation.Path, operation.From+"/") {
+			return nil, fmt.Errorf("%w: %q can't be moved into itself", errLibraryPanelInvalidJSONPatch, operation.From)
+		}
+		moved, err := getJSONPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if doc, err = removeJSONPointer(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			moved = deepCopyJSON(moved)
+		}
+		return addJSONPointer(doc, path, moved)
+	case "test":
+		current, err := getJSONPointer(doc, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errLibraryPanelJSONPatchTestFailed, err)
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, errLibraryPanelJSONPatchTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", errLibraryPanelInvalidJSONPatch, operation.Op)
+	}
+}
+
+// jsonPatchLibraryPanel applies a JSON Patch to the model of a Library Panel. The patch is applied to version if it
+// isn't 0, or otherwise to the current version of the Library Panel.
+func (lps *LibraryPanelService) jsonPatchLibraryPanel(c *models.ReqContext, uid string, operations []jsonPatchOperation, version int64) (LibraryPanelDTO, error) {
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+	if version != 0 && version != panel.Version {
+		return LibraryPanelDTO{}, errLibraryPanelVersionMismatch
+	}
+
+	model, err := applyJSONPatch(panel.Model, operations)
+	if err != nil {
+		return LibraryPanelDTO{}, err
+	}
+
+	return lps.patchLibraryPanel(c, patchLibraryPanelCommand{FolderID: -1, Model: model, Version: panel.Version}, uid)
+}
+
+func isJSONPatchRequest(c *models.ReqContext) bool {
+	mediaType, _, err := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	return err == nil && mediaType == jsonPatchContentType
+}
+
+// jsonPatchHandler handles PATCH /api/library-panels/:uid with a JSON Patch body. Other requests are left to
+// patchHandler.
+func (lps *LibraryPanelService) jsonPatchHandler(c *models.ReqContext) {
+	if !isJSONPatchRequest(c) {
+		return
+	}
+
+	lps.handleJSONPatch(c).WriteTo(c)
+}
+
+func (lps *LibraryPanelService) handleJSONPatch(c *models.ReqContext) response.Response {
+	body, err := c.Req.Body().Bytes()
+	if err != nil {
+		return response.Error(400, "Failed to read JSON Patch", err)
+	}
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return toLibraryPanelError(fmt.Errorf("%w: %s", errLibraryPanelInvalidJSONPatch, err), "Failed to update library panel")
+	}
+
+	version, err := parseIfMatch(c, c.Params(":uid"))
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to update library panel")
+	}
+	libraryPanel, err := lps.jsonPatchLibraryPanel(c, c.Params(":uid"), operations, version)
+	if err != nil {
+		return toLibraryPanelError(err, "Failed to update library panel")
+	}
+
+	return response.JSON(200, util.DynMap{"result": libraryPanel}).SetHeader("ETag", libraryPanelETag(libraryPanel.UID, libraryPanel.Version))
+}
@@ -0,0 +1,211 @@
+package librarypanels
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelLabel is the model for library panel labels.
+type libraryPanelLabel struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Name           string `xorm:"name"`
+	Value          string `xorm:"value"`
+}
+
+// libraryPanelLabelPolicy is the model for labels an org requires on all its library panels.
+type libraryPanelLabelPolicy struct {
+	ID    int64  `xorm:"pk autoincr 'id'"`
+	OrgID int64  `xorm:"org_id"`
+	Name  string `xorm:"name"`
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// LabelPolicyDTO is the frontend DTO for the label policy of an org.
+type LabelPolicyDTO struct {
+	RequiredLabels []string `json:"requiredLabels"`
+}
+
+// labelSelector is a single requirement in a label selector, e.g. tier=gold or env!=dev.
+type labelSelector struct {
+	name     string
+	value    string
+	notEqual bool
+}
+
+func validateLabels(labels map[string]string) error {
+	for name, value := range labels {
+		if len(strings.TrimSpace(name)) == 0 || strings.ContainsAny(name, "=!,") || strings.Contains(value, ",") {
+			return errLibraryPanelInvalidLabel
+		}
+	}
+
+	return nil
+}
+
+// parseLabelSelectors parses a comma separated list of label requirements like tier=gold,env!=dev.
+func parseLabelSelectors(selector string) ([]labelSelector, error) {
+	var selectors []labelSelector
+	if len(strings.TrimSpace(selector)) == 0 {
+		return selectors, nil
+	}
+
+	for _, requirement := range strings.Split(selector, ",") {
+		s := labelSelector{}
+		parts := strings.SplitN(requirement, "!=", 2)
+		if len(parts) == 2 {
+			s.notEqual = true
+		} else {
+			parts = strings.SplitN(requirement, "=", 2)
+		}
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, errLibraryPanelInvalidLabelSelector
+		}
+		s.name = strings.TrimSpace(parts[0])
+		s.value = strings.TrimSpace(parts[1])
+		selectors = append(selectors, s)
+	}
+
+	return selectors, nil
+}
+
+func writeLabelSelectorSQL(selectors []labelSelector, builder *sqlstore.SQLBuilder) {
+	for _, s := range selectors {
+		if s.notEqual {
+			builder.Write(" AND NOT EXISTS (SELECT 1 FROM library_panel_label AS lpl WHERE lpl.librarypanel_id = lp.id AND lpl.name=? AND lpl.value=?)", s.name, s.value)
+			continue
+		}
+		builder.Write(" AND EXISTS (SELECT 1 FROM library_panel_label AS lpl WHERE lpl.librarypanel_id = lp.id AND lpl.name=? AND lpl.value=?)", s.name, s.value)
+	}
+}
+
+func getLabelsForLibraryPanels(session *sqlstore.DBSession, panelIDs ...int64) (map[int64]map[string]string, error) {
+	labelsByPanel := make(map[int64]map[string]string)
+	if len(panelIDs) == 0 {
+		return labelsByPanel, nil
+	}
+
+	params := make([]interface{}, 0, len(panelIDs))
+	for _, id := range panelIDs {
+		params = append(params, id)
+	}
+	var labels []libraryPanelLabel
+	sql := "SELECT * FROM library_panel_label WHERE librarypanel_id IN (?" + strings.Repeat(",?", len(panelIDs)-1) + ")"
+	if err := session.SQL(sql, params...).Find(&labels); err != nil {
+		return nil, err
+	}
+	for _, label := range labels {
+		if labelsByPanel[label.LibraryPanelID] == nil {
+			labelsByPanel[label.LibraryPanelID] = make(map[string]string)
+		}
+		labelsByPanel[label.LibraryPanelID][label.Name] = label.Value
+	}
+
+	return labelsByPanel, nil
+}
+
+func setLabelsForLibraryPanel(session *sqlstore.DBSession, panelID int64, labels map[string]string) error {
+	if _, err := session.Exec("DELETE FROM library_panel_label WHERE librarypanel_id=?", panelID); err != nil {
+		return err
+	}
+	for name, value := range labels {
+		label := libraryPanelLabel{
+			LibraryPanelID: panelID,
+			Name:           name,
+			Value:          value,
+		}
+		if _, err := session.Insert(&label); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getRequiredLabels(session *sqlstore.DBSession, orgID int64) ([]string, error) {
+	var policies []libraryPanelLabelPolicy
+	if err := session.SQL("SELECT * FROM library_panel_label_policy WHERE org_id=? ORDER BY name", orgID).Find(&policies); err != nil {
+		return nil, err
+	}
+
+	requiredLabels := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		requiredLabels = append(requiredLabels, policy.Name)
+	}
+
+	return requiredLabels, nil
+}
+
+// requireLabelPolicy checks that labels contain all labels required by the org's label policy.
+func requireLabelPolicy(session *sqlstore.DBSession, orgID int64, labels map[string]string) error {
+	requiredLabels, err := getRequiredLabels(session, orgID)
+	if err != nil {
+		return err
+	}
+	for _, name := range requiredLabels {
+		if _, ok := labels[name]; !ok {
+			return errLibraryPanelMissingRequiredLabels
+		}
+	}
+
+	return nil
+}
+
+// getLabelPolicy gets the labels the signed in user's org requires on library panels.
+func (lps *LibraryPanelService) getLabelPolicy(c *models.ReqContext) (LabelPolicyDTO, error) {
+	var dto LabelPolicyDTO
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		requiredLabels, err := getRequiredLabels(session, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		dto.RequiredLabels = requiredLabels
+		return nil
+	})
+
+	return dto, err
+}
+
+// setLabelPolicy replaces the labels the signed in user's org requires on library panels.
+func (lps *LibraryPanelService) setLabelPolicy(c *models.ReqContext, cmd setLabelPolicyCommand) (LabelPolicyDTO, error) {
+	requiredLabels := make([]string, 0, len(cmd.RequiredLabels))
+	seen := make(map[string]bool)
+	for _, name := range cmd.RequiredLabels {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 || strings.ContainsAny(name, "=!,") {
+			return LabelPolicyDTO{}, errLibraryPanelInvalidLabel
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		requiredLabels = append(requiredLabels, name)
+	}
+	sort.Strings(requiredLabels)
+
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		if _, err := session.Exec("DELETE FROM library_panel_label_policy WHERE org_id=?", c.SignedInUser.OrgId); err != nil {
+			return err
+		}
+		for _, name := range requiredLabels {
+			policy := libraryPanelLabelPolicy{
+				OrgID:     c.SignedInUser.OrgId,
+				Name:      name,
+				Created:   time.Now(),
+				CreatedBy: c.SignedInUser.UserId,
+			}
+			if _, err := session.Insert(&policy); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return LabelPolicyDTO{RequiredLabels: requiredLabels}, err
+}
@@ -1,13 +1,20 @@
 package librarypanels
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/serverlock"
+	"github.com/grafana/grafana/pkg/infra/usagestats"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/rendering"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
 	"github.com/grafana/grafana/pkg/setting"
@@ -15,10 +22,21 @@ import (
 
 // LibraryPanelService is the service for the Panel Library feature.
 type LibraryPanelService struct {
-	Cfg           *setting.Cfg          `inject:""`
-	SQLStore      *sqlstore.SQLStore    `inject:""`
-	RouteRegister routing.RouteRegister `inject:""`
-	log           log.Logger
+	Cfg               *setting.Cfg                  `inject:""`
+	SQLStore          *sqlstore.SQLStore            `inject:""`
+	RouteRegister     routing.RouteRegister         `inject:""`
+	RenderService     rendering.Service             `inject:""`
+	ServerLockService *serverlock.ServerLockService `inject:""`
+	UsageStats        usagestats.UsageStats         `inject:""`
+	AccessControl     accesscontrol.AccessControl   `inject:""`
+	QuotaService      *quota.QuotaService           `inject:""`
+	log               log.Logger
+	scanners          []ContentScanner
+	scannersMu        sync.RWMutex
+	hydrateRefreshing sync.Map
+	pendingViews      map[libraryPanelViewKey]int64
+	pendingLastUsed   map[int64]libraryPanelLastUsed
+	viewsMu           sync.Mutex
 }
 
 func init() {
@@ -28,8 +46,16 @@ func init() {
 // Init initializes the LibraryPanel service
 func (lps *LibraryPanelService) Init() error {
 	lps.log = log.New("librarypanels")
+	if lps.IsEnabled() {
+		if err := validateUIDStrategy(lps.Cfg.PanelLibraryUIDStrategy, lps.Cfg.PanelLibraryUIDPrefix); err != nil {
+			return err
+		}
+	}
 
 	lps.registerAPIEndpoints()
+	if lps.UsageStats != nil && lps.IsEnabled() {
+		lps.registerUsageMetrics(lps.UsageStats)
+	}
 
 	return nil
 }
@@ -44,17 +70,36 @@ func (lps *LibraryPanelService) IsEnabled() bool {
 }
 
 // LoadLibraryPanelsForDashboard loops through all panels in dashboard JSON and replaces any library panel JSON
-// with JSON stored for library panel in db.
+// with JSON stored for library panel in db. If the request has the resolveDefaultDatasource query parameter set,
+// any "default" datasource placeholder in the library panels is replaced with the org's default datasource UID.
+// Connections that require update approval get the version they accepted, and the overrides of each connection are
+// merged into its library panel. If the request has the maxStaleSeconds query parameter set, library panels cached
+// within that many seconds may be used while they are refreshed in the background.
 func (lps *LibraryPanelService) LoadLibraryPanelsForDashboard(c *models.ReqContext, dash *models.Dashboard) error {
 	if !lps.IsEnabled() {
 		return nil
 	}
 
-	libraryPanels, err := lps.getLibraryPanelsForDashboardID(c, dash.Id)
+	libraryPanels, connections, err := lps.getLibraryPanelsForDashboard(c, dash.Id, getHydrateStaleness(c))
 	if err != nil {
 		return err
 	}
+	pendingVersions := applyAcceptedVersions(libraryPanels, connections)
+	if c.QueryBool("resolveDefaultDatasource") && len(libraryPanels) > 0 {
+		panelsToResolve := make([]*LibraryPanelDTO, 0, len(libraryPanels))
+		for uid := range libraryPanels {
+			panel := libraryPanels[uid]
+			panelsToResolve = append(panelsToResolve, &panel)
+		}
+		if err := lps.resolveDefaultDatasource(c, panelsToResolve...); err != nil {
+			return err
+		}
+		for _, panel := range panelsToResolve {
+			libraryPanels[panel.UID] = *panel
+		}
+	}
 
+	viewed := make(map[int64]bool)
 	panels := dash.Data.Get("panels").MustArray()
 	for i, panel := range panels {
 		panelAsJSON := simplejson.NewFromAny(panel)
@@ -83,10 +128,11 @@ func (lps *LibraryPanelService) LoadLibraryPanelsForDashboard(c *models.ReqConte
 			continue
 		}
 
-		// we have a match between what is stored in db and in dashboard json
-		libraryPanelModel, err := libraryPanelInDB.Model.MarshalJSON()
+		// we have a match between what is stored in db and in dashboard json, the connection's overrides are
+		// merged into the stored model
+		libraryPanelModel, err := applyConnectionOverrides(libraryPanelInDB.Model, connections[libraryPanelInDB.ID].Overrides)
 		if err != nil {
-			return fmt.Errorf("could not marshal library panel JSON: %w", err)
+			return fmt.Errorf("could not apply library panel overrides: %w", err)
 		}
 
 		libraryPanelModelAsJSON, err := simplejson.NewJson(libraryPanelModel)
@@ -112,6 +158,7 @@ func (lps *LibraryPanelService) LoadLibraryPanelsForDashboard(c *models.ReqConte
 				"folderName":          libraryPanelInDB.Meta.FolderName,
 				"folderUid":           libraryPanelInDB.Meta.FolderUID,
 				"connectedDashboards": libraryPanelInDB.Meta.ConnectedDashboards,
+				"provisioned":         libraryPanelInDB.Meta.Provisioned,
 				"created":             libraryPanelInDB.Meta.Created,
 				"updated":             libraryPanelInDB.Meta.Updated,
 				"createdBy": map[string]interface{}{
@@ -126,8 +173,25 @@ func (lps *LibraryPanelService) LoadLibraryPanelsForDashboard(c *models.ReqConte
 				},
 			},
 		})
+		if override := connections[libraryPanelInDB.ID].Overrides; len(override) > 0 {
+			overrideAsJSON, err := simplejson.NewJson(override)
+			if err != nil {
+				return fmt.Errorf("could not convert library panel overrides to simplejson model: %w", err)
+			}
+			elem.Get("libraryPanel").Set("overrides", overrideAsJSON.Interface())
+		}
+		if pendingVersion, ok := pendingVersions[libraryPanelInDB.UID]; ok {
+			elem.Get("libraryPanel").Set("pendingVersion", pendingVersion)
+		}
+		viewed[libraryPanelInDB.ID] = true
 	}
 
+	viewedIDs := make([]int64, 0, len(viewed))
+	for id := range viewed {
+		viewedIDs = append(viewedIDs, id)
+	}
+	lps.recordViews(viewedIDs...)
+
 	return nil
 }
 
@@ -198,6 +262,16 @@ func (lps *LibraryPanelService) ConnectLibraryPanelsForDashboard(c *models.ReqCo
 	return lps.connectLibraryPanelsForDashboard(c, libraryPanels, dash.Id)
 }
 
+// MoveLibraryPanelsWithDashboard moves the library panels that are only used by a dashboard from the folder the
+// dashboard was moved out of to the dashboard's new folder.
+func (lps *LibraryPanelService) MoveLibraryPanelsWithDashboard(c *models.ReqContext, dash *models.Dashboard, fromFolderID int64) ([]MoveLibraryPanelResultDTO, error) {
+	if !lps.IsEnabled() || dash.FolderId == fromFolderID {
+		return make([]MoveLibraryPanelResultDTO, 0), nil
+	}
+
+	return lps.moveExclusiveLibraryPanels(c, dash.Id, fromFolderID, dash.FolderId)
+}
+
 // DisconnectLibraryPanelsForDashboard loops through all panels in dashboard JSON and disconnects any library panels from the dashboard.
 func (lps *LibraryPanelService) DisconnectLibraryPanelsForDashboard(c *models.ReqContext, dash *models.Dashboard) error {
 	if !lps.IsEnabled() {
@@ -231,6 +305,37 @@ func (lps *LibraryPanelService) DeleteLibraryPanelsInFolder(c *models.ReqContext
 	return lps.deleteLibraryPanelsInFolder(c, folderUID)
 }
 
+// ProvisionLibraryPanel creates or updates a library panel from provisioning, matching on UID.
+func (lps *LibraryPanelService) ProvisionLibraryPanel(ctx context.Context, cmd ProvisionLibraryPanelCommand) error {
+	if !lps.IsEnabled() {
+		return nil
+	}
+	return lps.provisionLibraryPanel(ctx, cmd)
+}
+
+// ProvisionFreezeWindows replaces all provisioned freeze windows with the given freeze windows.
+func (lps *LibraryPanelService) ProvisionFreezeWindows(ctx context.Context, cmds []ProvisionFreezeWindowCommand) error {
+	if !lps.IsEnabled() {
+		return nil
+	}
+	return lps.provisionFreezeWindows(ctx, cmds)
+}
+
+// ValidateProvisionLibraryPanelCommand runs the same model validation and syncing as ProvisionLibraryPanel
+// without touching the database.
+func ValidateProvisionLibraryPanelCommand(cmd ProvisionLibraryPanelCommand) error {
+	if err := validateUID(cmd.UID); err != nil {
+		return err
+	}
+	libraryPanel := LibraryPanel{
+		OrgID: cmd.OrgID,
+		UID:   cmd.UID,
+		Name:  cmd.Name,
+		Model: cmd.Model,
+	}
+	return syncFieldsWithModel(&libraryPanel)
+}
+
 // AddMigration defines database migrations.
 // If Panel Library is not enabled does nothing.
 func (lps *LibraryPanelService) AddMigration(mg *migrator.Migrator) {
@@ -279,4 +384,493 @@ func (lps *LibraryPanelService) AddMigration(mg *migrator.Migrator) {
 
 	mg.AddMigration("create library_panel_dashboard table v1", migrator.NewAddTableMigration(libraryPanelDashboardV1))
 	mg.AddMigration("add index library_panel_dashboard librarypanel_id & dashboard_id", migrator.NewAddIndexMigration(libraryPanelDashboardV1, libraryPanelDashboardV1.Indices[0]))
+
+	mg.AddMigration("add provisioned column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "provisioned", Type: migrator.DB_Bool, Nullable: false, Default: "0",
+	}))
+
+	libraryPanelLabelV1 := migrator.Table{
+		Name: "library_panel_label",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "value", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "name"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"name", "value"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_label table v1", migrator.NewAddTableMigration(libraryPanelLabelV1))
+	mg.AddMigration("add index library_panel_label librarypanel_id & name", migrator.NewAddIndexMigration(libraryPanelLabelV1, libraryPanelLabelV1.Indices[0]))
+	mg.AddMigration("add index library_panel_label name & value", migrator.NewAddIndexMigration(libraryPanelLabelV1, libraryPanelLabelV1.Indices[1]))
+
+	libraryPanelLabelPolicyV1 := migrator.Table{
+		Name: "library_panel_label_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "name"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_panel_label_policy table v1", migrator.NewAddTableMigration(libraryPanelLabelPolicyV1))
+	mg.AddMigration("add index library_panel_label_policy org_id & name", migrator.NewAddIndexMigration(libraryPanelLabelPolicyV1, libraryPanelLabelPolicyV1.Indices[0]))
+
+	libraryPanelTokenV1 := migrator.Table{
+		Name: "library_panel_token",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "key", Type: migrator.DB_Varchar, Length: 190, Nullable: false},
+			{Name: "expires", Type: migrator.DB_BigInt, Nullable: true},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "uid"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"librarypanel_id"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_token table v1", migrator.NewAddTableMigration(libraryPanelTokenV1))
+	mg.AddMigration("add index library_panel_token org_id & uid", migrator.NewAddIndexMigration(libraryPanelTokenV1, libraryPanelTokenV1.Indices[0]))
+	mg.AddMigration("add index library_panel_token librarypanel_id", migrator.NewAddIndexMigration(libraryPanelTokenV1, libraryPanelTokenV1.Indices[1]))
+
+	libraryPanelCatalogEntryV1 := migrator.Table{
+		Name: "library_panel_catalog_entry",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "readme", Type: migrator.DB_Text, Nullable: false},
+			{Name: "screenshot_url", Type: migrator.DB_NVarchar, Length: 2048, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"org_id"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_catalog_entry table v1", migrator.NewAddTableMigration(libraryPanelCatalogEntryV1))
+	mg.AddMigration("add unique index library_panel_catalog_entry librarypanel_id", migrator.NewAddIndexMigration(libraryPanelCatalogEntryV1, libraryPanelCatalogEntryV1.Indices[0]))
+	mg.AddMigration("add index library_panel_catalog_entry org_id", migrator.NewAddIndexMigration(libraryPanelCatalogEntryV1, libraryPanelCatalogEntryV1.Indices[1]))
+
+	mg.AddMigration("add deleted_at column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "deleted_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+
+	libraryPanelTagV1 := migrator.Table{
+		Name: "library_panel_tag",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "term", Type: migrator.DB_NVarchar, Length: 50, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "term"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"term"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_tag table v1", migrator.NewAddTableMigration(libraryPanelTagV1))
+	mg.AddMigration("add index library_panel_tag librarypanel_id & term", migrator.NewAddIndexMigration(libraryPanelTagV1, libraryPanelTagV1.Indices[0]))
+	mg.AddMigration("add index library_panel_tag term", migrator.NewAddIndexMigration(libraryPanelTagV1, libraryPanelTagV1.Indices[1]))
+
+	libraryPanelDatasourceV1 := migrator.Table{
+		Name: "library_panel_datasource",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "ref", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "ref"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"ref"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_datasource table v1", migrator.NewAddTableMigration(libraryPanelDatasourceV1))
+	mg.AddMigration("add index library_panel_datasource librarypanel_id & ref", migrator.NewAddIndexMigration(libraryPanelDatasourceV1, libraryPanelDatasourceV1.Indices[0]))
+	mg.AddMigration("add index library_panel_datasource ref", migrator.NewAddIndexMigration(libraryPanelDatasourceV1, libraryPanelDatasourceV1.Indices[1]))
+	mg.AddMigration("populate library_panel_datasource", &populateLibraryPanelDatasourcesMigration{})
+
+	libraryPanelSandboxV1 := migrator.Table{
+		Name: "library_panel_sandbox",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "dashboard_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "expires", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"dashboard_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"expires"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_sandbox table v1", migrator.NewAddTableMigration(libraryPanelSandboxV1))
+	mg.AddMigration("add unique index library_panel_sandbox dashboard_id", migrator.NewAddIndexMigration(libraryPanelSandboxV1, libraryPanelSandboxV1.Indices[0]))
+	mg.AddMigration("add index library_panel_sandbox expires", migrator.NewAddIndexMigration(libraryPanelSandboxV1, libraryPanelSandboxV1.Indices[1]))
+
+	libraryPanelACLV1 := migrator.Table{
+		Name: "library_panel_acl",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "team_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "permission", Type: migrator.DB_SmallInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id"}},
+			{Cols: []string{"team_id"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_acl table v1", migrator.NewAddTableMigration(libraryPanelACLV1))
+	mg.AddMigration("add index library_panel_acl librarypanel_id", migrator.NewAddIndexMigration(libraryPanelACLV1, libraryPanelACLV1.Indices[0]))
+	mg.AddMigration("add index library_panel_acl team_id", migrator.NewAddIndexMigration(libraryPanelACLV1, libraryPanelACLV1.Indices[1]))
+
+	libraryPanelFreezeWindowV1 := migrator.Table{
+		Name: "library_panel_freeze_window",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "reason", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "starts", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "ends", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "provisioned", Type: migrator.DB_Bool, Nullable: false, Default: "0"},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "ends"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_freeze_window table v1", migrator.NewAddTableMigration(libraryPanelFreezeWindowV1))
+	mg.AddMigration("add index library_panel_freeze_window org_id & ends", migrator.NewAddIndexMigration(libraryPanelFreezeWindowV1, libraryPanelFreezeWindowV1.Indices[0]))
+
+	libraryPanelReorgV1 := migrator.Table{
+		Name: "library_panel_reorg",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "rollback_of", Type: migrator.DB_BigInt, Nullable: false, Default: "0"},
+			{Name: "mapping", Type: migrator.DB_Text, Nullable: false},
+			{Name: "rollback", Type: migrator.DB_MediumText, Nullable: false},
+			{Name: "changed", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_reorg table v1", migrator.NewAddTableMigration(libraryPanelReorgV1))
+	mg.AddMigration("add index library_panel_reorg org_id", migrator.NewAddIndexMigration(libraryPanelReorgV1, libraryPanelReorgV1.Indices[0]))
+
+	libraryPanelFindingV1 := migrator.Table{
+		Name: "library_panel_finding",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "scanner", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "rule", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "severity", Type: migrator.DB_NVarchar, Length: 50, Nullable: false},
+			{Name: "message", Type: migrator.DB_Text, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "scanner"}},
+			{Cols: []string{"org_id", "severity"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_finding table v1", migrator.NewAddTableMigration(libraryPanelFindingV1))
+	mg.AddMigration("add index library_panel_finding librarypanel_id & scanner", migrator.NewAddIndexMigration(libraryPanelFindingV1, libraryPanelFindingV1.Indices[0]))
+	mg.AddMigration("add index library_panel_finding org_id & severity", migrator.NewAddIndexMigration(libraryPanelFindingV1, libraryPanelFindingV1.Indices[1]))
+
+	libraryPanelSmokeRenderV1 := migrator.Table{
+		Name: "library_panel_smoke_render",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "version", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "passed", Type: migrator.DB_Bool, Nullable: false},
+			{Name: "error", Type: migrator.DB_Text, Nullable: false},
+			{Name: "rendered", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"org_id", "passed"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_smoke_render table v1", migrator.NewAddTableMigration(libraryPanelSmokeRenderV1))
+	mg.AddMigration("add unique index library_panel_smoke_render librarypanel_id", migrator.NewAddIndexMigration(libraryPanelSmokeRenderV1, libraryPanelSmokeRenderV1.Indices[0]))
+	mg.AddMigration("add index library_panel_smoke_render org_id & passed", migrator.NewAddIndexMigration(libraryPanelSmokeRenderV1, libraryPanelSmokeRenderV1.Indices[1]))
+
+	libraryPanelGroupV1 := migrator.Table{
+		Name: "library_panel_group",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "uid"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"org_id", "name"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_panel_group table v1", migrator.NewAddTableMigration(libraryPanelGroupV1))
+	mg.AddMigration("add unique index library_panel_group org_id & uid", migrator.NewAddIndexMigration(libraryPanelGroupV1, libraryPanelGroupV1.Indices[0]))
+	mg.AddMigration("add unique index library_panel_group org_id & name", migrator.NewAddIndexMigration(libraryPanelGroupV1, libraryPanelGroupV1.Indices[1]))
+
+	libraryPanelGroupMemberV1 := migrator.Table{
+		Name: "library_panel_group_member",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "group_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"group_id", "librarypanel_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"librarypanel_id"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_group_member table v1", migrator.NewAddTableMigration(libraryPanelGroupMemberV1))
+	mg.AddMigration("add unique index library_panel_group_member group_id & librarypanel_id", migrator.NewAddIndexMigration(libraryPanelGroupMemberV1, libraryPanelGroupMemberV1.Indices[0]))
+	mg.AddMigration("add index library_panel_group_member librarypanel_id", migrator.NewAddIndexMigration(libraryPanelGroupMemberV1, libraryPanelGroupMemberV1.Indices[1]))
+
+	mg.AddMigration("add overrides column to library_panel_dashboard", migrator.NewAddColumnMigration(libraryPanelDashboardV1, &migrator.Column{
+		Name: "overrides", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("add accepted_version column to library_panel_dashboard", migrator.NewAddColumnMigration(libraryPanelDashboardV1, &migrator.Column{
+		Name: "accepted_version", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add accepted_model column to library_panel_dashboard", migrator.NewAddColumnMigration(libraryPanelDashboardV1, &migrator.Column{
+		Name: "accepted_model", Type: migrator.DB_Text, Nullable: true,
+	}))
+	mg.AddMigration("add rejected_version column to library_panel_dashboard", migrator.NewAddColumnMigration(libraryPanelDashboardV1, &migrator.Column{
+		Name: "rejected_version", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	libraryPanelInputV1 := migrator.Table{
+		Name: "library_panel_input",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 50, Nullable: false},
+			{Name: "label", Type: migrator.DB_NVarchar, Length: 150, Nullable: false},
+			{Name: "type", Type: migrator.DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "default_value", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "required", Type: migrator.DB_Bool, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "name"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_panel_input table v1", migrator.NewAddTableMigration(libraryPanelInputV1))
+	mg.AddMigration("add unique index library_panel_input librarypanel_id & name", migrator.NewAddIndexMigration(libraryPanelInputV1, libraryPanelInputV1.Indices[0]))
+
+	mg.AddMigration("add model_hash column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "model_hash", Type: migrator.DB_NVarchar, Length: 71, Nullable: true,
+	}))
+
+	mg.AddMigration("add render_count column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "render_count", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add last_rendered column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "last_rendered", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+
+	libraryPanelCanaryV1 := migrator.Table{
+		Name: "library_panel_canary",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "version", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "previous_version", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "previous_model", Type: migrator.DB_Text, Nullable: false},
+			{Name: "sample", Type: migrator.DB_Int, Nullable: false},
+			{Name: "held", Type: migrator.DB_Int, Nullable: false},
+			{Name: "state", Type: migrator.DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "error", Type: migrator.DB_Text, Nullable: false},
+			{Name: "started", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "soak_until", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "finished", Type: migrator.DB_DateTime, Nullable: true},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id"}},
+			{Cols: []string{"state", "soak_until"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_canary table v1", migrator.NewAddTableMigration(libraryPanelCanaryV1))
+	mg.AddMigration("add index library_panel_canary librarypanel_id", migrator.NewAddIndexMigration(libraryPanelCanaryV1, libraryPanelCanaryV1.Indices[0]))
+	mg.AddMigration("add index library_panel_canary state & soak_until", migrator.NewAddIndexMigration(libraryPanelCanaryV1, libraryPanelCanaryV1.Indices[1]))
+	mg.AddMigration("add canary_id column to library_panel_dashboard", migrator.NewAddColumnMigration(libraryPanelDashboardV1, &migrator.Column{
+		Name: "canary_id", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	libraryPanelArchivePolicyV1 := migrator.Table{
+		Name: "library_panel_archive_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "enabled", Type: migrator.DB_Bool, Nullable: false, Default: "0"},
+			{Name: "action", Type: migrator.DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "days", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "folder_id", Type: migrator.DB_BigInt, Nullable: false, Default: "0"},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_panel_archive_policy table v1", migrator.NewAddTableMigration(libraryPanelArchivePolicyV1))
+	mg.AddMigration("add unique index library_panel_archive_policy org_id", migrator.NewAddIndexMigration(libraryPanelArchivePolicyV1, libraryPanelArchivePolicyV1.Indices[0]))
+
+	libraryPanelAuditV1 := migrator.Table{
+		Name: "library_panel_audit",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "action", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "detail", Type: migrator.DB_Text, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "created"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_audit table v1", migrator.NewAddTableMigration(libraryPanelAuditV1))
+	mg.AddMigration("add index library_panel_audit org_id & created", migrator.NewAddIndexMigration(libraryPanelAuditV1, libraryPanelAuditV1.Indices[0]))
+
+	libraryPanelViewV1 := migrator.Table{
+		Name: "library_panel_view",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "day", Type: migrator.DB_NVarchar, Length: 10, Nullable: false},
+			{Name: "views", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "day"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"day"}},
+		},
+	}
+
+	mg.AddMigration("create library_panel_view table v1", migrator.NewAddTableMigration(libraryPanelViewV1))
+	mg.AddMigration("add unique index library_panel_view librarypanel_id & day", migrator.NewAddIndexMigration(libraryPanelViewV1, libraryPanelViewV1.Indices[0]))
+	mg.AddMigration("add index library_panel_view day", migrator.NewAddIndexMigration(libraryPanelViewV1, libraryPanelViewV1.Indices[1]))
+
+	libraryPanelVersionV1 := migrator.Table{
+		Name: "library_panel_version",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "librarypanel_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "version", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "model", Type: migrator.DB_Text, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"librarypanel_id", "version"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create library_panel_version table v1", migrator.NewAddTableMigration(libraryPanelVersionV1))
+	mg.AddMigration("add unique index library_panel_version librarypanel_id & version", migrator.NewAddIndexMigration(libraryPanelVersionV1, libraryPanelVersionV1.Indices[0]))
+
+	mg.AddMigration("add last_connected_at column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "last_connected_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+	mg.AddMigration("add last_viewed_at column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "last_viewed_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+
+	mg.AddMigration("add old_version column to library_panel_audit", migrator.NewAddColumnMigration(libraryPanelAuditV1, &migrator.Column{
+		Name: "old_version", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+	mg.AddMigration("add new_version column to library_panel_audit", migrator.NewAddColumnMigration(libraryPanelAuditV1, &migrator.Column{
+		Name: "new_version", Type: migrator.DB_BigInt, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("add normalized_hash column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "normalized_hash", Type: migrator.DB_NVarchar, Length: 71, Nullable: true,
+	}))
+	mg.AddMigration("add index library_panel org_id & normalized_hash", migrator.NewAddIndexMigration(libraryPanelV1, &migrator.Index{
+		Cols: []string{"org_id", "normalized_hash"},
+	}))
+
+	mg.AddMigration("add trashed_name column to library_panel", migrator.NewAddColumnMigration(libraryPanelV1, &migrator.Column{
+		Name: "trashed_name", Type: migrator.DB_NVarchar, Length: 255, Nullable: true,
+	}))
+
+	// a Library Panel could be provisioned with the uid of one in the trash, and both could be live once that one was
+	// restored. Of the Library Panels that share a uid, the oldest live one keeps it and the others get a new uid so
+	// uids can be unique.
+	mg.AddMigration("change duplicate uids of library panels", migrator.NewRawSQLMigration(
+		"UPDATE library_panel SET uid = 'duplicate-' || id WHERE EXISTS ("+
+			"SELECT 1 FROM library_panel AS other WHERE other.org_id = library_panel.org_id AND other.uid = library_panel.uid "+
+			"AND other.id <> library_panel.id AND "+libraryPanelDuplicateUIDCondition("library_panel")+")").
+		Mysql("UPDATE library_panel AS duplicate INNER JOIN library_panel AS other ON other.org_id = duplicate.org_id "+
+			"AND other.uid = duplicate.uid AND other.id <> duplicate.id AND "+libraryPanelDuplicateUIDCondition("duplicate")+
+			" SET duplicate.uid = CONCAT('duplicate-', duplicate.id)"))
+	mg.AddMigration("add unique index library_panel org_id & uid", migrator.NewAddIndexMigration(libraryPanelV1, &migrator.Index{
+		Cols: []string{"org_id", "uid"}, Type: migrator.UniqueIndex,
+	}))
+}
+
+// libraryPanelDuplicateUIDCondition is the SQL condition for the Library Panel other to keep the uid it shares with the
+// Library Panel in table rather than that one: live Library Panels go before those in the trash, older before newer.
+func libraryPanelDuplicateUIDCondition(table string) string {
+	return "((" + table + ".deleted_at IS NOT NULL AND (other.deleted_at IS NULL OR other.id < " + table + ".id)) OR (" +
+		table + ".deleted_at IS NULL AND other.deleted_at IS NULL AND other.id < " + table + ".id))"
 }
@@ -0,0 +1,100 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/ossaccesscontrol"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// deniedScopeAccessControl grants every action, except on the denied scope.
+type deniedScopeAccessControl struct {
+	denied string
+}
+
+func (ac *deniedScopeAccessControl) Evaluate(_ context.Context, _ *models.SignedInUser, _ string, scopes ...string) (bool, error) {
+	for _, scope := range scopes {
+		if scope == ac.denied {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (ac *deniedScopeAccessControl) GetUserPermissions(context.Context, *models.SignedInUser) ([]*accesscontrol.Permission, error) {
+	return nil, nil
+}
+
+func (ac *deniedScopeAccessControl) IsDisabled() bool {
+	return false
+}
+
+func TestLibraryPanelAccessControl(t *testing.T) {
+	enableAccessControl := func(sc scenarioContext) {
+		sc.service.AccessControl = &ossaccesscontrol.OSSAccessControlService{
+			Cfg: &setting.Cfg{FeatureToggles: map[string]bool{"accesscontrol": true}},
+		}
+	}
+
+	testScenario(t, "When access control is enabled, a viewer with edit permissions on a folder can read but not change library panels",
+		func(t *testing.T, sc scenarioContext) {
+			folder := createFolderWithACL(t, sc.sqlStore, "Shared", sc.user, []folderACLItem{{models.ROLE_VIEWER, models.PERMISSION_EDIT}})
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(folder.Id, "Shared Panel"))
+			created := validateAndUnMarshalResponse(t, resp)
+
+			enableAccessControl(sc)
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+
+			resp = sc.service.createHandler(sc.reqContext, getCreateCommand(folder.Id, "Viewer Panel"))
+			require.Equal(t, 403, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 403, resp.Status())
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 403, resp.Status())
+		})
+
+	testScenario(t, "When access control is enabled, an editor can create, change and delete library panels",
+		func(t *testing.T, sc scenarioContext) {
+			enableAccessControl(sc)
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "Editor Panel"))
+			created := validateAndUnMarshalResponse(t, resp)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	testScenario(t, "When access control denies writing in a folder, library panels can't be moved into it",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "Moved Panel"))
+			created := validateAndUnMarshalResponse(t, resp)
+			denied := createFolderWithACL(t, sc.sqlStore, "Denied", sc.user, []folderACLItem{})
+			allowed := createFolderWithACL(t, sc.sqlStore, "Allowed", sc.user, []folderACLItem{})
+			sc.service.AccessControl = &deniedScopeAccessControl{denied: accesscontrol.ScopeFolder(denied.Id)}
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: denied.Id, Version: 1})
+			require.Equal(t, 403, resp.Status())
+			resp = sc.service.moveHandler(sc.reqContext, moveLibraryPanelsCommand{UIDs: []string{created.Result.UID}, FolderID: denied.Id})
+			require.Equal(t, 403, resp.Status())
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: allowed.Id, Version: 1})
+			moved := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, allowed.Id, moved.Result.FolderID)
+		})
+}
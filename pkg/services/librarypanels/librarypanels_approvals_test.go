@@ -0,0 +1,131 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelPendingUpdatesResult struct {
+	Result []LibraryPanelPendingUpdatesDTO `json:"result"`
+}
+
+func TestLibraryPanelUpdateApproval(t *testing.T) {
+	patchDescription := func(t *testing.T, sc scenarioContext, description string, version int64) {
+		t.Helper()
+
+		sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+		resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+			FolderID: -1,
+			Model:    []byte(`{"type": "text", "description": "` + description + `"}`),
+			Version:  version,
+		})
+		require.Equal(t, 200, resp.Status())
+	}
+	getPendingUpdates := func(t *testing.T, sc scenarioContext) []LibraryPanelPendingUpdatesDTO {
+		t.Helper()
+
+		resp := sc.service.getPendingUpdatesHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelPendingUpdatesResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When updates require approval, it should keep dashboards on their accepted version until an update is accepted",
+		func(t *testing.T, sc scenarioContext) {
+			sc.service.Cfg.PanelLibraryRequireUpdateApproval = true
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			require.Empty(t, getPendingUpdates(t, sc))
+
+			patchDescription(t, sc, "Version 2", 1)
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			panel := dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, "A description", panel.Get("description").MustString())
+			require.Equal(t, int64(1), panel.Get("libraryPanel").Get("version").MustInt64())
+			require.Equal(t, int64(2), panel.Get("libraryPanel").Get("pendingVersion").MustInt64())
+			require.Equal(t, []LibraryPanelPendingUpdatesDTO{
+				{UID: sc.initialResult.Result.UID, Name: sc.initialResult.Result.Name, Version: 2, PendingDashboards: 1},
+			}, getPendingUpdates(t, sc))
+
+			// saving the dashboard keeps the accepted version
+			err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			result := getConnections(t, sc, "")
+			require.Len(t, result.Connections, 1)
+			require.Equal(t, int64(1), result.Connections[0].AcceptedVersion)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.acceptUpdateHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.acceptUpdateHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			panel = dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, "Version 2", panel.Get("description").MustString())
+			require.Equal(t, int64(2), panel.Get("libraryPanel").Get("version").MustInt64())
+			require.Nil(t, panel.Get("libraryPanel").Get("pendingVersion").Interface())
+			require.Empty(t, getPendingUpdates(t, sc))
+		})
+
+	scenarioWithLibraryPanel(t, "When updates require approval and an update is rejected, it should not be pending until the next update",
+		func(t *testing.T, sc scenarioContext) {
+			sc.service.Cfg.PanelLibraryRequireUpdateApproval = true
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			patchDescription(t, sc, "Version 2", 1)
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.rejectUpdateHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			require.Empty(t, getPendingUpdates(t, sc))
+
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			panel := dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, "A description", panel.Get("description").MustString())
+			require.Nil(t, panel.Get("libraryPanel").Get("pendingVersion").Interface())
+
+			patchDescription(t, sc, "Version 3", 2)
+			require.Equal(t, []LibraryPanelPendingUpdatesDTO{
+				{UID: sc.initialResult.Result.UID, Name: sc.initialResult.Result.Name, Version: 3, PendingDashboards: 1},
+			}, getPendingUpdates(t, sc))
+		})
+
+	scenarioWithLibraryPanel(t, "When updates don't require approval, it should have no pending updates",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			patchDescription(t, sc, "Version 2", 1)
+			require.Empty(t, getPendingUpdates(t, sc))
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.acceptUpdateHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,195 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelArchivePolicyResult struct {
+	Result LibraryPanelArchivePolicyDTO `json:"result"`
+}
+
+func TestArchiveOrphanedLibraryPanels(t *testing.T) {
+	setPolicy := func(t *testing.T, sc scenarioContext, cmd setArchivePolicyCommand) LibraryPanelArchivePolicyDTO {
+		t.Helper()
+
+		resp := sc.service.setArchivePolicyHandler(sc.reqContext, cmd)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelArchivePolicyResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+	backdate := func(t *testing.T, sc scenarioContext, ids ...int64) {
+		t.Helper()
+
+		err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+			for _, id := range ids {
+				if _, err := session.Exec("UPDATE library_panel SET updated=? WHERE id=?", time.Now().Add(-40*24*time.Hour), id); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	getAudit := func(t *testing.T, sc scenarioContext) []libraryPanelAudit {
+		t.Helper()
+
+		var entries []libraryPanelAudit
+		err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+			return session.SQL("SELECT * FROM library_panel_audit WHERE action=? ORDER BY id", auditActionArchive).Find(&entries)
+		})
+		require.NoError(t, err)
+		return entries
+	}
+
+	scenarioWithLibraryPanel(t, "When an org has no archive policy, it should get a disabled policy and archive nothing",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.getArchivePolicyHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelArchivePolicyResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.False(t, result.Result.Enabled)
+			require.Nil(t, result.Result.Updated)
+
+			backdate(t, sc, sc.initialResult.Result.ID)
+			archived, err := sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{}, archived)
+		})
+
+	scenarioWithLibraryPanel(t, "When an archive policy is invalid, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			for _, cmd := range []setArchivePolicyCommand{
+				{Enabled: true, Action: archiveActionTrash},
+				{Enabled: true, Action: "delete", Days: 30},
+			} {
+				resp := sc.service.setArchivePolicyHandler(sc.reqContext, cmd)
+				require.Equal(t, 400, resp.Status())
+			}
+		})
+
+	scenarioWithLibraryPanel(t, "When the archive policy moves to the trash, it should trash orphaned library panels that weren't updated and audit them",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Connected")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			connected := validateAndUnMarshalResponse(t, resp)
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": connected.Result.UID, ":dashboardId": strconv.FormatInt(dashInDB.Id, 10)})
+			resp = sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			command = getCreateCommand(sc.folder.Id, "Recent")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			backdate(t, sc, sc.initialResult.Result.ID, connected.Result.ID)
+
+			policy := setPolicy(t, sc, setArchivePolicyCommand{Enabled: true, Action: archiveActionTrash, Days: 30})
+			require.True(t, policy.Enabled)
+			require.NotNil(t, policy.Updated)
+			result, err := sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{Archived: 1}, result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+			audit := getAudit(t, sc)
+			require.Len(t, audit, 1)
+			require.Equal(t, sc.initialResult.Result.UID, audit[0].UID)
+			require.Equal(t, auditActionArchive, audit[0].Action)
+			require.Equal(t, "moved to the trash, no connected dashboards and not updated for 30 days", audit[0].Detail)
+			require.Equal(t, int64(0), audit[0].CreatedBy)
+
+			result, err = sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{}, result)
+		})
+
+	scenarioWithLibraryPanel(t, "When the archive policy moves to a folder without a folder id, it should move orphaned library panels to the Archive folder",
+		func(t *testing.T, sc scenarioContext) {
+			policy := setPolicy(t, sc, setArchivePolicyCommand{Enabled: true, Action: archiveActionFolder, Days: 30})
+			require.NotZero(t, policy.FolderID)
+			require.Equal(t, policy.FolderID, setPolicy(t, sc, setArchivePolicyCommand{Enabled: true, Action: archiveActionFolder, Days: 30}).FolderID)
+
+			backdate(t, sc, sc.initialResult.Result.ID)
+			result, err := sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{Archived: 1}, result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			archived := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, policy.FolderID, archived.Result.FolderID)
+			require.Equal(t, int64(2), archived.Result.Version)
+			require.Len(t, getAudit(t, sc), 1)
+
+			result, err = sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{}, result)
+		})
+
+	scenarioWithLibraryPanel(t, "When orphaned library panels are provisioned or frozen, it should not archive them",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Published")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			published := validateAndUnMarshalResponse(t, resp)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": published.Result.UID})
+			resp = sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Incident",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			})
+			require.Equal(t, 200, resp.Status())
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				_, err := session.Exec("UPDATE library_panel SET provisioned=? WHERE id=?", true, sc.initialResult.Result.ID)
+				return err
+			})
+			require.NoError(t, err)
+			backdate(t, sc, sc.initialResult.Result.ID, published.Result.ID)
+
+			setPolicy(t, sc, setArchivePolicyCommand{Enabled: true, Action: archiveActionTrash, Days: 30})
+			result, err := sc.service.archiveOrphanedLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, archiveResult{Skipped: 1}, result)
+			require.Empty(t, getAudit(t, sc))
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel is connected after it was selected for archiving, it should not archive it",
+		func(t *testing.T, sc scenarioContext) {
+			backdate(t, sc, sc.initialResult.Result.ID)
+			var selected LibraryPanel
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				_, err := session.SQL("SELECT * FROM library_panel WHERE id=?", sc.initialResult.Result.ID).Get(&selected)
+				return err
+			})
+			require.NoError(t, err)
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": strconv.FormatInt(dashInDB.Id, 10)})
+			resp := sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			policy := libraryPanelArchivePolicy{OrgID: sc.user.OrgId, Enabled: true, Action: archiveActionTrash, Days: 30}
+			err = sc.sqlStore.WithTransactionalDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				_, err := archiveLibraryPanel(session, policy, selected, time.Now().Add(-30*24*time.Hour))
+				return err
+			})
+			require.ErrorIs(t, err, errLibraryPanelNotOrphaned)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			require.Empty(t, getAudit(t, sc))
+		})
+}
@@ -0,0 +1,161 @@
+package librarypanels
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestExportAuditLog(t *testing.T) {
+	export := func(t *testing.T, sc scenarioContext, query string) ([]string, int) {
+		t.Helper()
+
+		var err error
+		sc.ctx.Req.Request.URL, err = url.Parse("/?" + query)
+		require.NoError(t, err)
+		sc.ctx.Req.Form = nil
+		resp := sc.service.exportAuditLogHandler(sc.reqContext)
+		if resp.Status() != 200 {
+			return nil, resp.Status()
+		}
+		var lines []string
+		scanner := bufio.NewScanner(bytes.NewReader(resp.Body()))
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		require.NoError(t, scanner.Err())
+		return lines, resp.Status()
+	}
+	addEntries := func(t *testing.T, sc scenarioContext) {
+		t.Helper()
+
+		err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+			panel := LibraryPanel{
+				ID:    sc.initialResult.Result.ID,
+				OrgID: sc.user.OrgId,
+				UID:   sc.initialResult.Result.UID,
+				Name:  "CPU|usage=high",
+			}
+			if err := addAuditEntry(session, panel, auditActionArchive, "moved to the trash\nafter 30 days", 1, 0, 0); err != nil {
+				return err
+			}
+			if err := addAuditEntry(session, panel, "restore", "restored", 0, 1, sc.user.UserId); err != nil {
+				return err
+			}
+			_, err := session.Exec("UPDATE library_panel_audit SET created=? WHERE action=?", time.Now().Add(-48*time.Hour), auditActionArchive)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	scenarioWithLibraryPanel(t, "When a server admin exports the audit log as JSON Lines, it should return one entry per line oldest first",
+		func(t *testing.T, sc scenarioContext) {
+			addEntries(t, sc)
+
+			// the scenario's library panel was created with an audit entry
+			lines, status := export(t, sc, "")
+			require.Equal(t, 200, status)
+			require.Len(t, lines, 3)
+			var entry LibraryPanelAuditEntryDTO
+			err := json.Unmarshal([]byte(lines[0]), &entry)
+			require.NoError(t, err)
+			require.Equal(t, auditActionArchive, entry.Action)
+			require.Equal(t, sc.initialResult.Result.UID, entry.UID)
+			require.Equal(t, "moved to the trash\nafter 30 days", entry.Detail)
+
+			lines, status = export(t, sc, "format=jsonl&action=restore")
+			require.Equal(t, 200, status)
+			require.Len(t, lines, 1)
+			require.Contains(t, lines[0], `"action":"restore"`)
+
+			from := time.Now().Add(-24*time.Hour).UnixNano() / int64(time.Millisecond)
+			lines, status = export(t, sc, "from="+strconv.FormatInt(from, 10))
+			require.Equal(t, 200, status)
+			require.Len(t, lines, 2)
+			lines, status = export(t, sc, "to="+strconv.FormatInt(from, 10)+"&action=restore&action="+auditActionArchive)
+			require.Equal(t, 200, status)
+			require.Len(t, lines, 1)
+			require.Contains(t, lines[0], `"action":"archive"`)
+		})
+
+	scenarioWithLibraryPanel(t, "When a server admin exports the audit log as CEF, it should escape the header and extension",
+		func(t *testing.T, sc scenarioContext) {
+			addEntries(t, sc)
+
+			lines, status := export(t, sc, "format=cef&action="+auditActionArchive)
+			require.Equal(t, 200, status)
+			require.Len(t, lines, 1)
+			require.Regexp(t, `^CEF:0\|Grafana\|Grafana\|[^|]*\|library-panel:archive\|Library panel archive\|3\|rt=\d+ `, lines[0])
+			require.Contains(t, lines[0], " act=archive suid=0 cs1Label=uid cs1="+sc.initialResult.Result.UID+" ")
+			require.Contains(t, lines[0], ` cs2=CPU|usage\=high `)
+			require.Contains(t, lines[0], ` msg=moved to the trash\nafter 30 days`)
+		})
+
+	scenarioWithLibraryPanel(t, "When a server admin exports the audit log with an invalid format or time range, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			_, status := export(t, sc, "format=xml")
+			require.Equal(t, 400, status)
+			_, status = export(t, sc, "from=2000&to=1000")
+			require.Equal(t, 400, status)
+		})
+}
+
+func TestAuditLibraryPanelMutations(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a library panel is created, patched and deleted, it should add an audit entry for each change",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			entries, err := sc.service.getAuditEntries(context.Background(), auditExportQuery{orgID: sc.user.OrgId})
+			require.NoError(t, err)
+			require.Len(t, entries, 3)
+			for _, entry := range entries {
+				require.Equal(t, sc.initialResult.Result.ID, entry.LibraryPanelID)
+				require.Equal(t, sc.initialResult.Result.UID, entry.UID)
+				require.Equal(t, sc.user.UserId, entry.CreatedBy)
+			}
+			require.Equal(t, auditActionCreate, entries[0].Action)
+			require.Equal(t, int64(0), entries[0].OldVersion)
+			require.Equal(t, int64(1), entries[0].NewVersion)
+			require.Equal(t, auditActionUpdate, entries[1].Action)
+			require.Equal(t, "updated name", entries[1].Detail)
+			require.Equal(t, "Renamed", entries[1].Name)
+			require.Equal(t, int64(1), entries[1].OldVersion)
+			require.Equal(t, int64(2), entries[1].NewVersion)
+			require.Equal(t, auditActionDelete, entries[2].Action)
+			require.Equal(t, int64(2), entries[2].OldVersion)
+			require.Equal(t, int64(0), entries[2].NewVersion)
+		})
+}
+
+func TestFormatAuditCEF(t *testing.T) {
+	entry := libraryPanelAudit{
+		OrgID:          2,
+		LibraryPanelID: 3,
+		UID:            "uid",
+		Name:           `a\b`,
+		Action:         "up|date",
+		Detail:         "x=y",
+		OldVersion:     5,
+		NewVersion:     6,
+		Created:        time.Unix(1, 0),
+		CreatedBy:      4,
+	}
+
+	require.Equal(t, `CEF:0|Grafana|Grafana|7.5.0|library-panel:up\|date|Library panel up\|date|3|`+
+		`rt=1000 act=up|date suid=4 cs1Label=uid cs1=uid cs2Label=name cs2=a\\b cn1Label=orgId cn1=2 cn2Label=libraryPanelId cn2=3 `+
+		`cs3Label=oldVersion cs3=5 cs4Label=newVersion cs4=6 msg=x\=y`,
+		formatAuditCEF(entry, "7.5.0"))
+}
@@ -0,0 +1,144 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelCanaryResult struct {
+	Result LibraryPanelCanaryDTO `json:"result"`
+}
+
+func TestLibraryPanelCanary(t *testing.T) {
+	patchDescription := func(t *testing.T, sc scenarioContext, description string, version int64) int {
+		t.Helper()
+
+		sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+		resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+			FolderID: -1,
+			Model:    []byte(`{"type": "text", "description": "` + description + `"}`),
+			Version:  version,
+			Canary:   true,
+		})
+		return resp.Status()
+	}
+	getDescriptions := func(t *testing.T, sc scenarioContext, dashboards []*models.Dashboard) []string {
+		t.Helper()
+
+		descriptions := make([]string, 0, len(dashboards))
+		for _, dashInDB := range dashboards {
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			descriptions = append(descriptions, dash.Data.Get("panels").GetIndex(0).Get("description").MustString())
+		}
+		return descriptions
+	}
+	getCanary := func(t *testing.T, sc scenarioContext) LibraryPanelCanaryDTO {
+		t.Helper()
+
+		sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+		resp := sc.service.getCanaryHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelCanaryResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+	startCanary := func(t *testing.T, sc scenarioContext) []*models.Dashboard {
+		t.Helper()
+
+		sc.service.Cfg.PanelLibraryCanarySamplePercent = 50
+		sc.service.Cfg.PanelLibraryCanarySoak = time.Hour
+		var dashboards []*models.Dashboard
+		for _, title := range []string{"Canary", "Held"} {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, title, sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			dashboards = append(dashboards, dashInDB)
+		}
+
+		require.Equal(t, 200, patchDescription(t, sc, "Version 2", 1))
+		require.Equal(t, []string{"Version 2", "A description"}, getDescriptions(t, sc, dashboards))
+		canary := getCanary(t, sc)
+		require.Equal(t, canaryStatePending, canary.State)
+		require.Equal(t, 1, canary.Sample)
+		require.Equal(t, 1, canary.Held)
+
+		// the model can't change again while the canary soaks
+		require.Equal(t, 409, patchDescription(t, sc, "Version 3", 2))
+
+		// the soak period is over
+		err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+			_, err := session.Exec("UPDATE library_panel_canary SET soak_until=?", time.Now().Add(-time.Minute))
+			return err
+		})
+		require.NoError(t, err)
+		return dashboards
+	}
+
+	scenarioWithLibraryPanel(t, "When a canary update soaked without render errors, it should be applied to all dashboards",
+		func(t *testing.T, sc scenarioContext) {
+			dashboards := startCanary(t, sc)
+
+			result, err := sc.service.processCanaries(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, canaryResult{Completed: 1}, result)
+			require.Equal(t, []string{"Version 2", "Version 2"}, getDescriptions(t, sc, dashboards))
+			require.Equal(t, canaryStateCompleted, getCanary(t, sc).State)
+			require.Equal(t, 200, patchDescription(t, sc, "Version 3", 2))
+		})
+
+	scenarioWithLibraryPanel(t, "When a canary update failed to smoke render, it should be rolled back",
+		func(t *testing.T, sc scenarioContext) {
+			dashboards := startCanary(t, sc)
+			err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				_, err := session.Insert(&libraryPanelSmokeRender{
+					OrgID:          sc.user.OrgId,
+					LibraryPanelID: sc.initialResult.Result.ID,
+					Version:        2,
+					Passed:         false,
+					Error:          "panel plugin not found",
+					Rendered:       time.Now(),
+				})
+				return err
+			})
+			require.NoError(t, err)
+
+			result, err := sc.service.processCanaries(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, canaryResult{RolledBack: 1}, result)
+			require.Equal(t, []string{"A description", "A description"}, getDescriptions(t, sc, dashboards))
+			canary := getCanary(t, sc)
+			require.Equal(t, canaryStateRolledBack, canary.State)
+			require.Equal(t, "panel plugin not found", canary.Error)
+
+			panel, err := sc.service.getLibraryPanel(sc.reqContext, sc.initialResult.Result.UID)
+			require.NoError(t, err)
+			require.Equal(t, int64(3), panel.Version)
+		})
+
+	scenarioWithLibraryPanel(t, "When a viewer can't view the folder of a library panel, it should not get its canary update",
+		func(t *testing.T, sc scenarioContext) {
+			startCanary(t, sc)
+
+			updateFolderACL(t, sc.sqlStore, sc.folder.Id, []folderACLItem{{models.ROLE_ADMIN, models.PERMISSION_EDIT}})
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			resp := sc.service.getCanaryHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel never had a canary update, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getCanaryHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+		})
+}
@@ -0,0 +1,29 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLibraryPanelCapabilities(t *testing.T) {
+	testScenario(t, "When an user gets the capabilities, it should describe the features of this instance",
+		func(t *testing.T, sc scenarioContext) {
+			sc.service.Cfg.PanelLibraryPublicCatalogEnabled = true
+
+			resp := sc.service.getCapabilitiesHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result map[string]bool `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.True(t, result.Result["versions"])
+			require.True(t, result.Result["tags"])
+			require.True(t, result.Result["publicCatalog"])
+			require.False(t, result.Result["drafts"])
+			require.False(t, result.Result["gitSync"])
+			require.False(t, result.Result["accessControl"])
+		})
+}
@@ -0,0 +1,83 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelCatalogResult struct {
+	Result []LibraryPanelCatalogEntryDTO `json:"result"`
+}
+
+func TestLibraryPanelCatalog(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin publishes a library panel, it should be in the catalog without its model",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.IsSignedIn = true
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{
+				Readme:        "# Text panel",
+				ScreenshotURL: "https://example.com/screenshot.png",
+			})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.getCatalogHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			require.NotContains(t, string(resp.Body()), "model")
+			var result libraryPanelCatalogResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result, 1)
+			require.Equal(t, sc.initialResult.Result.UID, result.Result[0].UID)
+			require.Equal(t, "Text - Library Panel", result.Result[0].Name)
+			require.Equal(t, "A description", result.Result[0].Description)
+			require.Equal(t, "# Text panel", result.Result[0].Readme)
+			require.Equal(t, "https://example.com/screenshot.png", result.Result[0].ScreenshotURL)
+
+			resp = sc.service.unpublishHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.unpublishHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+
+			resp = sc.service.getCatalogHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result, 0)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin publishes a library panel with an invalid screenshot URL, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{ScreenshotURL: "javascript:alert(1)"})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a user that is not signed in reads the catalog, it should only succeed if the public catalog is enabled",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.IsSignedIn = false
+			resp = sc.service.getCatalogHandler(sc.reqContext)
+			require.Equal(t, 401, resp.Status())
+
+			sc.service.Cfg.PanelLibraryPublicCatalogEnabled = true
+			resp = sc.service.getCatalogHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?orgId=1")
+			require.NoError(t, err)
+			sc.ctx.Req.Form = nil
+			resp = sc.service.getCatalogHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelCatalogResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result, 1)
+		})
+}
@@ -0,0 +1,52 @@
+package librarypanels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneLibraryPanel(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin tries to clone a library panel that does not exist, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "unknown"})
+			resp := sc.service.cloneHandler(sc.reqContext, cloneLibraryPanelCommand{FolderID: -1})
+			require.Equal(t, 404, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to clone a library panel without a name or folder, it should be copied next to the original",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.cloneHandler(sc.reqContext, cloneLibraryPanelCommand{FolderID: -1})
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.NotEqual(t, sc.initialResult.Result.UID, result.Result.UID)
+			require.Equal(t, sc.folder.Id, result.Result.FolderID)
+			require.Equal(t, "Text - Library Panel Copy", result.Result.Name)
+			require.Equal(t, "Text - Library Panel Copy", result.Result.Model["title"])
+			require.Equal(t, sc.initialResult.Result.Type, result.Result.Type)
+			require.Equal(t, int64(1), result.Result.Version)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to clone a library panel into another folder with a new name, it should succeed",
+		func(t *testing.T, sc scenarioContext) {
+			// bump the version of the original to make sure the clone starts over
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Original", Version: 1})
+			require.Equal(t, 200, resp.Status())
+
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			resp = sc.service.cloneHandler(sc.reqContext, cloneLibraryPanelCommand{FolderID: newFolder.Id, Name: "Fork"})
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, newFolder.Id, result.Result.FolderID)
+			require.Equal(t, "Fork", result.Result.Name)
+			require.Equal(t, "NewFolder", result.Result.Meta.FolderName)
+			require.Equal(t, int64(1), result.Result.Version)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to clone a library panel with a name that already exists in the folder, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.cloneHandler(sc.reqContext, cloneLibraryPanelCommand{FolderID: -1, Name: sc.initialResult.Result.Name})
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,58 @@
+package librarypanels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinGrafanaVersion(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		model    string
+		expected string
+	}{
+		{desc: "no versioned features", model: `{"type": "text", "datasource": "${DS}"}`, expected: ""},
+		{desc: "field config", model: `{"type": "stat", "fieldConfig": {"defaults": {}}}`, expected: "7.0.0"},
+		{desc: "transformations", model: `{"type": "table", "transformations": [{"id": "merge"}]}`, expected: "7.0.0"},
+		{desc: "datasource reference", model: `{"type": "graph", "datasource": {"uid": "abc", "type": "prometheus"}}`, expected: "8.3.0"},
+		{desc: "plugin version is newer", model: `{"type": "stat", "fieldConfig": {}, "pluginVersion": "7.5.4"}`, expected: "7.5.4"},
+		{desc: "plugin version is older", model: `{"type": "graph", "datasource": {"uid": "abc"}, "pluginVersion": "7.1.0"}`, expected: "8.3.0"},
+		{desc: "invalid plugin version", model: `{"type": "graph", "pluginVersion": "latest"}`, expected: ""},
+		{desc: "invalid model", model: `not json`, expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expected, minGrafanaVersion([]byte(tc.model)))
+		})
+	}
+}
+
+func TestIsNewerThanGrafana(t *testing.T) {
+	require.False(t, isNewerThanGrafana("", "7.5.0"))
+	require.False(t, isNewerThanGrafana("7.0.0", "7.5.0"))
+	require.False(t, isNewerThanGrafana("7.5.0", "7.5.0-beta1"))
+	require.False(t, isNewerThanGrafana("8.3.0", "dev"))
+	require.True(t, isNewerThanGrafana("8.3.0", "7.5.0"))
+	require.True(t, isNewerThanGrafana("7.5.4", "7.5.0-pre"))
+}
+
+func TestLibraryPanelMinGrafanaVersion(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an API call creates a library panel using field config, it should return the minimum Grafana version in meta",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommandWithModel(sc.folder.Id, "Stat", []byte(`{"type": "stat", "fieldConfig": {"defaults": {}}}`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			created := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "7.0.0", created.Result.Meta.MinGrafanaVersion)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "7.0.0", result.Result.Meta.MinGrafanaVersion)
+		})
+
+	scenarioWithLibraryPanel(t, "When an API call gets a library panel without versioned features, it should leave the minimum Grafana version empty",
+		func(t *testing.T, sc scenarioContext) {
+			require.Empty(t, sc.initialResult.Result.Meta.MinGrafanaVersion)
+		})
+}
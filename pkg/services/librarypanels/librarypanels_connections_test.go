@@ -1,11 +1,15 @@
 package librarypanels
 
 import (
+	"context"
 	"encoding/json"
+	"net/url"
 	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
 )
 
 func TestConnectLibraryPanel(t *testing.T) {
@@ -95,3 +99,127 @@ func TestGetConnectedDashboards(t *testing.T) {
 			require.Equal(t, secondDash.Id, dashResult.Result[1])
 		})
 }
+
+type libraryPanelConnectionsResult struct {
+	Result LibraryPanelConnectionsResult `json:"result"`
+}
+
+func getConnections(t *testing.T, sc scenarioContext, rawQuery string) LibraryPanelConnectionsResult {
+	t.Helper()
+
+	var err error
+	sc.ctx.Req.Request.URL, err = url.Parse("/?" + rawQuery)
+	require.NoError(t, err)
+	sc.ctx.Req.Form = nil
+	resp := sc.service.getConnectionsHandler(sc.reqContext)
+	require.Equal(t, 200, resp.Status())
+	var result libraryPanelConnectionsResult
+	err = json.Unmarshal(resp.Body(), &result)
+	require.NoError(t, err)
+	return result.Result
+}
+
+func TestGetConnections(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an editor gets connections, it should return dashboard metadata and hide dashboards they can't view",
+		func(t *testing.T, sc scenarioContext) {
+			publicDash := createDashboard(t, sc.sqlStore, sc.user, "Public", 0)
+			folder := createFolderWithACL(t, sc.sqlStore, "Private", sc.user, []folderACLItem{{models.ROLE_ADMIN, models.PERMISSION_EDIT}})
+			privateDash := createDashboard(t, sc.sqlStore, sc.user, "Private Dash", folder.Id)
+			for _, dash := range []*models.Dashboard{publicDash, privateDash} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": strconv.FormatInt(dash.Id, 10)})
+				resp := sc.service.connectHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+			}
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			result := getConnections(t, sc, "")
+			require.Equal(t, int64(2), result.TotalCount)
+			require.Len(t, result.Connections, 2)
+			require.Equal(t, privateDash.Uid, result.Connections[0].DashboardUID)
+			require.Equal(t, "Private", result.Connections[0].FolderTitle)
+			require.True(t, result.Connections[0].CanView)
+			require.Equal(t, "General", result.Connections[1].FolderTitle)
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			result = getConnections(t, sc, "")
+			require.Equal(t, int64(2), result.TotalCount)
+			require.Equal(t, []LibraryPanelConnectionDTO{
+				{
+					DashboardID:    publicDash.Id,
+					DashboardUID:   publicDash.Uid,
+					DashboardTitle: "Public",
+					FolderTitle:    "General",
+					CanView:        true,
+					Created:        result.Connections[0].Created,
+					CreatedBy: LibraryPanelDTOMetaUser{
+						ID:        sc.user.UserId,
+						Name:      UserInDbName,
+						AvatarUrl: UserInDbAvatar,
+					},
+				},
+				{DashboardID: privateDash.Id},
+			}, result.Connections)
+
+			result = getConnections(t, sc, "folderId="+strconv.FormatInt(folder.Id, 10))
+			require.Equal(t, int64(0), result.TotalCount)
+			require.Len(t, result.Connections, 0)
+		})
+
+	scenarioWithLibraryPanel(t, "When a dashboard is saved again by another user, it should keep who connected the library panel and when",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			connected := getConnections(t, sc, "").Connections
+			require.Len(t, connected, 1)
+			require.Equal(t, sc.user.UserId, connected[0].CreatedBy.ID)
+
+			other, err := sc.sqlStore.CreateUser(context.Background(), models.CreateUserCommand{Login: "other", Email: "other@test.com"})
+			require.NoError(t, err)
+			sc.reqContext.SignedInUser.UserId = other.Id
+			err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			resaved := getConnections(t, sc, "").Connections
+			require.Len(t, resaved, 1)
+			require.Equal(t, sc.user.UserId, resaved[0].CreatedBy.ID)
+			require.Equal(t, connected[0].Created, resaved[0].Created)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin pages through connections, it should sort and filter them",
+		func(t *testing.T, sc scenarioContext) {
+			folder := createFolderWithACL(t, sc.sqlStore, "Team", sc.user, []folderACLItem{})
+			for _, dash := range []*models.Dashboard{
+				createDashboard(t, sc.sqlStore, sc.user, "A", 0),
+				createDashboard(t, sc.sqlStore, sc.user, "B", folder.Id),
+				createDashboard(t, sc.sqlStore, sc.user, "C", 0),
+			} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": strconv.FormatInt(dash.Id, 10)})
+				resp := sc.service.connectHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+			}
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+
+			result := getConnections(t, sc, "perPage=2&page=1&sort=alpha-desc")
+			require.Equal(t, int64(3), result.TotalCount)
+			require.Len(t, result.Connections, 2)
+			require.Equal(t, "C", result.Connections[0].DashboardTitle)
+			require.Equal(t, "B", result.Connections[1].DashboardTitle)
+			result = getConnections(t, sc, "perPage=2&page=2&sort=alpha-desc")
+			require.Len(t, result.Connections, 1)
+			require.Equal(t, "A", result.Connections[0].DashboardTitle)
+
+			result = getConnections(t, sc, "folderId=0")
+			require.Equal(t, int64(2), result.TotalCount)
+			require.Equal(t, "A", result.Connections[0].DashboardTitle)
+			require.Equal(t, "C", result.Connections[1].DashboardTitle)
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?sort=name")
+			require.NoError(t, err)
+			sc.ctx.Req.Form = nil
+			resp := sc.service.getConnectionsHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -36,6 +36,8 @@ func TestCreateLibraryPanel(t *testing.T) {
 					Version: 1,
 					Meta: LibraryPanelDTOMeta{
 						CanEdit:             true,
+						FolderName:          "ScenarioFolder",
+						FolderUID:           "ScenarioFolder",
 						ConnectedDashboards: 0,
 						Created:             sc.initialResult.Result.Meta.Created,
 						Updated:             sc.initialResult.Result.Meta.Updated,
@@ -81,6 +83,8 @@ func TestCreateLibraryPanel(t *testing.T) {
 					Version: 1,
 					Meta: LibraryPanelDTOMeta{
 						CanEdit:             true,
+						FolderName:          "ScenarioFolder",
+						FolderUID:           "ScenarioFolder",
 						ConnectedDashboards: 0,
 						Created:             result.Result.Meta.Created,
 						Updated:             result.Result.Meta.Updated,
@@ -101,4 +105,13 @@ func TestCreateLibraryPanel(t *testing.T) {
 				t.Fatalf("Result mismatch (-want +got):\n%s", diff)
 			}
 		})
+
+	testScenario(t, "When an admin tries to create a library panel in the General folder, it should return the General folder meta",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(0, "General Library Panel")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "General", result.Result.Meta.FolderName)
+			require.Equal(t, "", result.Result.Meta.FolderUID)
+		})
 }
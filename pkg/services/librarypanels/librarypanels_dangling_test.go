@@ -0,0 +1,47 @@
+package librarypanels
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestCleanDanglingConnections(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When dangling connections are cleaned, it should remove connections to deleted dashboards and library panels only",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			err = sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				for _, connection := range []libraryPanelDashboard{
+					{LibraryPanelID: sc.initialResult.Result.ID, DashboardID: dashInDB.Id + 1000},
+					{LibraryPanelID: sc.initialResult.Result.ID + 1000, DashboardID: dashInDB.Id},
+				} {
+					connection.Created = time.Now()
+					connection.CreatedBy = sc.user.UserId
+					if _, err := session.Insert(&connection); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+
+			result, err := sc.service.cleanDanglingConnections(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, danglingConnectionsResult{DashboardDeleted: 1, LibraryPanelDeleted: 1}, result)
+
+			connected, err := sc.service.getConnectedDashboards(sc.reqContext, sc.initialResult.Result.UID)
+			require.NoError(t, err)
+			require.Equal(t, []int64{dashInDB.Id}, connected)
+
+			result, err = sc.service.cleanDanglingConnections(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, danglingConnectionsResult{}, result)
+		})
+}
@@ -0,0 +1,142 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestGetLibraryPanelsByDatasource(t *testing.T) {
+	testScenario(t, "When an admin gets the library panels using a datasource, it should follow changes to the models",
+		func(t *testing.T, sc scenarioContext) {
+			err := sqlstore.AddDataSource(&models.AddDataSourceCommand{
+				OrgId:  sc.user.OrgId,
+				Name:   "Loki",
+				Type:   "loki",
+				Access: models.DS_ACCESS_PROXY,
+				Uid:    "loki-uid",
+			})
+			require.NoError(t, err)
+
+			command := getCreateCommandWithModel(sc.folder.Id, "Logs", []byte(`{"type": "logs", "datasource": "Loki"}`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			created := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommandWithModel(sc.folder.Id, "Graph", []byte(`{"type": "graph", "datasource": "Prometheus"}`))
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "loki-uid"})
+			resp = sc.service.getByDatasourceHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Equal(t, "Logs", result.Result.LibraryPanels[0].Name)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+				FolderID: -1,
+				Model:    []byte(`{"type": "logs", "datasource": "Elasticsearch"}`),
+				Version:  1,
+			})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "loki-uid"})
+			resp = sc.service.getByDatasourceHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			result = libraryPanelsSearch{}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), result.Result.TotalCount)
+		})
+}
+
+type rewriteDatasourceResult struct {
+	Result []RewriteDatasourceResultDTO `json:"result"`
+	DryRun bool                         `json:"dryRun"`
+}
+
+func TestRewriteDatasource(t *testing.T) {
+	testScenario(t, "When an admin rewrites a datasource, references by uid and name should be replaced",
+		func(t *testing.T, sc scenarioContext) {
+			for _, cmd := range []models.AddDataSourceCommand{
+				{OrgId: sc.user.OrgId, Name: "Loki", Type: "loki", Access: models.DS_ACCESS_PROXY, Uid: "loki-uid"},
+				{OrgId: sc.user.OrgId, Name: "Loki 2", Type: "loki", Access: models.DS_ACCESS_PROXY, Uid: "loki-2-uid"},
+			} {
+				cmd := cmd
+				err := sqlstore.AddDataSource(&cmd)
+				require.NoError(t, err)
+			}
+
+			command := getCreateCommandWithModel(sc.folder.Id, "Logs", []byte(`{"type": "logs", "datasource": "Loki", "targets": [{"datasource": "loki-uid"}, {"datasource": "Prometheus"}]}`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			created := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommandWithModel(sc.folder.Id, "Graph", []byte(`{"type": "graph", "datasource": "Prometheus"}`))
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.rewriteDatasourceHandler(sc.reqContext, rewriteDatasourceCommand{FromUID: "loki-uid", ToUID: "loki-2-uid", DryRun: true})
+			require.Equal(t, 200, resp.Status())
+			var result rewriteDatasourceResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.True(t, result.DryRun)
+			require.Equal(t, []RewriteDatasourceResultDTO{
+				{UID: created.Result.UID, Name: "Logs", Version: 2, Status: rewriteStatusRewritten},
+			}, result.Result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			unchanged := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(1), unchanged.Result.Version)
+			require.Equal(t, "Loki", unchanged.Result.Model["datasource"])
+
+			resp = sc.service.rewriteDatasourceHandler(sc.reqContext, rewriteDatasourceCommand{FromUID: "loki-uid", ToUID: "loki-2-uid"})
+			require.Equal(t, 200, resp.Status())
+			result = rewriteDatasourceResult{}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.False(t, result.DryRun)
+			require.Len(t, result.Result, 1)
+
+			resp = sc.service.getHandler(sc.reqContext)
+			rewritten := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), rewritten.Result.Version)
+			require.Equal(t, "Loki 2", rewritten.Result.Model["datasource"])
+			targets := rewritten.Result.Model["targets"].([]interface{})
+			require.Equal(t, "loki-2-uid", targets[0].(map[string]interface{})["datasource"])
+			require.Equal(t, "Prometheus", targets[1].(map[string]interface{})["datasource"])
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "loki-uid"})
+			resp = sc.service.getByDatasourceHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var search libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &search)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), search.Result.TotalCount)
+		})
+
+	testScenario(t, "When an admin rewrites a datasource to a datasource that does not exist, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.rewriteDatasourceHandler(sc.reqContext, rewriteDatasourceCommand{FromUID: "loki-uid", ToUID: "unknown"})
+			require.Equal(t, 404, resp.Status())
+		})
+}
+
+func TestGetDatasourceRefsFromModel(t *testing.T) {
+	refs, err := getDatasourceRefsFromModel([]byte(`{
+		"datasource": "-- Mixed --",
+		"targets": [{"datasource": "Loki"}, {"datasource": "default"}, {"datasource": "Loki"}, {"refId": "C"}]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"-- Mixed --", "Loki", "default"}, refs)
+
+	refs, err = getDatasourceRefsFromModel([]byte(`{"datasource": null}`))
+	require.NoError(t, err)
+	require.Empty(t, refs)
+}
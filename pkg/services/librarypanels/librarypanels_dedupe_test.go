@@ -0,0 +1,224 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelDedupeResult struct {
+	Result LibraryPanelDedupeResultDTO `json:"result"`
+}
+
+func TestNormalizePanelModel(t *testing.T) {
+	_, hash, err := normalizePanelModel(map[string]interface{}{
+		"id": 1, "gridPos": map[string]interface{}{"x": 0}, "title": "CPU", "type": "graph",
+	})
+	require.NoError(t, err)
+	_, other, err := normalizePanelModel(map[string]interface{}{
+		"type": "graph", "title": "CPU", "id": 7, "gridPos": map[string]interface{}{"x": 12}, "pluginVersion": "7.5.0",
+	})
+	require.NoError(t, err)
+	require.Equal(t, hash, other)
+
+	_, other, err = normalizePanelModel(map[string]interface{}{"id": 1, "title": "Memory", "type": "graph"})
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other)
+
+	_, hash, err = normalizePanelModel(map[string]interface{}{"id": 1, "type": "row"})
+	require.NoError(t, err)
+	require.Empty(t, hash)
+}
+
+func TestDedupeLibraryPanels(t *testing.T) {
+	saveDashboardWithPanels := func(t *testing.T, sc scenarioContext, title string, panels ...interface{}) *models.Dashboard {
+		t.Helper()
+
+		dashInDB := createDashboard(t, sc.sqlStore, sc.user, title, sc.folder.Id)
+		dash, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+			Dashboard: simplejson.NewFromAny(map[string]interface{}{
+				"id":     dashInDB.Id,
+				"uid":    dashInDB.Uid,
+				"title":  title,
+				"panels": panels,
+			}),
+			OrgId:     sc.user.OrgId,
+			UserId:    sc.user.UserId,
+			FolderId:  sc.folder.Id,
+			Overwrite: true,
+		})
+		require.NoError(t, err)
+		return dash
+	}
+	cpuPanel := func(id int) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"gridPos": map[string]interface{}{"h": 8, "w": 12, "x": 0, "y": id * 8},
+			"title":   "CPU",
+			"type":    "graph",
+			"targets": []interface{}{map[string]interface{}{"expr": "rate(cpu_seconds_total[5m])"}},
+		}
+	}
+	dedupe := func(t *testing.T, sc scenarioContext, cmd dedupeLibraryPanelsCommand) LibraryPanelDedupeResultDTO {
+		t.Helper()
+
+		resp := sc.service.dedupeHandler(sc.reqContext, cmd)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelDedupeResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin deduplicates panels in a dry run, it should report identical panels without changes",
+		func(t *testing.T, sc scenarioContext) {
+			first := saveDashboardWithPanels(t, sc, "First", cpuPanel(1), map[string]interface{}{"id": 2, "title": "Memory", "type": "graph"})
+			second := saveDashboardWithPanels(t, sc, "Second", cpuPanel(3))
+
+			result := dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id, DryRun: true})
+			require.True(t, result.DryRun)
+			require.Len(t, result.Groups, 1)
+			require.Equal(t, "CPU", result.Groups[0].Name)
+			require.Equal(t, 2, result.Groups[0].Panels)
+			require.Equal(t, []string{first.Uid, second.Uid}, result.Groups[0].DashboardUIDs)
+			require.Empty(t, result.Groups[0].LibraryPanelUID)
+
+			require.Empty(t, dedupe(t, sc, dedupeLibraryPanelsCommand{MinPanels: 3, DryRun: true}).Groups)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deduplicates panels, it should replace identical panels with a library panel",
+		func(t *testing.T, sc scenarioContext) {
+			first := saveDashboardWithPanels(t, sc, "First", cpuPanel(1), map[string]interface{}{"id": 2, "title": "Memory", "type": "graph"})
+			second := saveDashboardWithPanels(t, sc, "Second", cpuPanel(3))
+
+			result := dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id})
+			require.Len(t, result.Groups, 1)
+			uid := result.Groups[0].LibraryPanelUID
+			require.NotEmpty(t, uid)
+
+			panel, err := sc.service.getLibraryPanel(sc.reqContext, uid)
+			require.NoError(t, err)
+			require.Equal(t, "CPU", panel.Name)
+			require.Equal(t, sc.folder.Id, panel.FolderID)
+			var model map[string]interface{}
+			err = json.Unmarshal(panel.Model, &model)
+			require.NoError(t, err)
+			require.Nil(t, model["id"])
+			require.Nil(t, model["gridPos"])
+
+			dash, err := sc.sqlStore.GetDashboard(first.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			require.Equal(t, uid, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Get("uid").MustString())
+			require.Equal(t, 1, dash.Data.Get("panels").GetIndex(0).Get("id").MustInt())
+			require.Equal(t, "Memory", dash.Data.Get("panels").GetIndex(1).Get("title").MustString())
+			dash, err = sc.sqlStore.GetDashboard(second.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			require.Equal(t, 8, dash.Data.Get("panels").GetIndex(0).Get("gridPos").Get("h").MustInt())
+
+			connected, err := sc.service.getConnectedDashboards(sc.reqContext, uid)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []int64{first.Id, second.Id}, connected)
+
+			// the promoted panels are library panels now and aren't promoted again
+			require.Empty(t, dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id}).Groups)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deduplicates panels, it should replace identical panels in collapsed rows",
+		func(t *testing.T, sc scenarioContext) {
+			first := saveDashboardWithPanels(t, sc, "First", cpuPanel(1))
+			second := saveDashboardWithPanels(t, sc, "Second", map[string]interface{}{
+				"id": 2, "type": "row", "collapsed": true, "panels": []interface{}{cpuPanel(3)},
+			})
+
+			result := dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id})
+			require.Len(t, result.Groups, 1)
+			require.Equal(t, 2, result.Groups[0].Panels)
+			require.Equal(t, []string{first.Uid, second.Uid}, result.Groups[0].DashboardUIDs)
+			uid := result.Groups[0].LibraryPanelUID
+
+			dash, err := sc.sqlStore.GetDashboard(second.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			rowPanel := dash.Data.Get("panels").GetIndex(0).Get("panels").GetIndex(0)
+			require.Equal(t, uid, rowPanel.Get("libraryPanel").Get("uid").MustString())
+			require.Equal(t, 3, rowPanel.Get("id").MustInt())
+		})
+
+	scenarioWithLibraryPanel(t, "When a dashboard fails to save during deduplication, it should report it and continue",
+		func(t *testing.T, sc scenarioContext) {
+			broken := saveDashboardWithPanels(t, sc, "Broken", cpuPanel(1))
+			// a dashboard without a title can't be saved through the dashboard service
+			broken.Data.Set("title", "")
+			_, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+				Dashboard: broken.Data,
+				OrgId:     sc.user.OrgId,
+				UserId:    sc.user.UserId,
+				FolderId:  sc.folder.Id,
+				Overwrite: true,
+			})
+			require.NoError(t, err)
+			second := saveDashboardWithPanels(t, sc, "Second", cpuPanel(1))
+
+			result := dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id})
+			require.Len(t, result.Groups, 1)
+			uid := result.Groups[0].LibraryPanelUID
+			require.Len(t, result.Failures, 1)
+			require.Equal(t, broken.Uid, result.Failures[0].DashboardUID)
+			require.NotEmpty(t, result.Failures[0].Error)
+
+			dash, err := sc.sqlStore.GetDashboard(second.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			require.Equal(t, uid, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Get("uid").MustString())
+			dash, err = sc.sqlStore.GetDashboard(broken.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			require.Nil(t, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Interface())
+
+			// once the dashboard is fixed, running again reuses the library panel that was created
+			_ = saveDashboardWithPanels(t, sc, "Third", cpuPanel(1))
+			dash.Data.Set("title", "Fixed")
+			_, err = sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+				Dashboard: dash.Data,
+				OrgId:     sc.user.OrgId,
+				UserId:    sc.user.UserId,
+				FolderId:  sc.folder.Id,
+				Overwrite: true,
+			})
+			require.NoError(t, err)
+
+			result = dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id})
+			require.Len(t, result.Groups, 1)
+			require.Equal(t, uid, result.Groups[0].LibraryPanelUID)
+			require.Equal(t, "CPU", result.Groups[0].Name)
+			require.Empty(t, result.Failures)
+
+			connected, err := sc.service.getConnectedDashboards(sc.reqContext, uid)
+			require.NoError(t, err)
+			require.Len(t, connected, 3)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deduplicates panels, it should leave provisioned dashboards out",
+		func(t *testing.T, sc scenarioContext) {
+			first := saveDashboardWithPanels(t, sc, "First", cpuPanel(1))
+			second := saveDashboardWithPanels(t, sc, "Second", cpuPanel(1))
+			provisioned := saveDashboardWithPanels(t, sc, "Provisioned", cpuPanel(1))
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				_, err := session.Insert(&models.DashboardProvisioning{DashboardId: provisioned.Id, Name: "default", ExternalId: "provisioned.json"})
+				return err
+			})
+			require.NoError(t, err)
+
+			result := dedupe(t, sc, dedupeLibraryPanelsCommand{FolderID: sc.folder.Id})
+			require.Equal(t, 2, result.ScannedDashboards)
+			require.Len(t, result.Groups, 1)
+			require.Equal(t, []string{first.Uid, second.Uid}, result.Groups[0].DashboardUIDs)
+
+			dash, err := sc.sqlStore.GetDashboard(provisioned.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			require.Nil(t, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Interface())
+		})
+}
@@ -1,11 +1,16 @@
 package librarypanels
 
 import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 )
 
 func TestDeleteLibraryPanel(t *testing.T) {
@@ -41,4 +46,164 @@ func TestDeleteLibraryPanel(t *testing.T) {
 			resp = sc.service.deleteHandler(sc.reqContext)
 			require.Equal(t, 403, resp.Status())
 		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to delete several library panels, it should report the result for each of them",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Connected Panel")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			connected := validateAndUnMarshalResponse(t, resp)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": connected.Result.UID, ":dashboardId": "1"})
+			resp = sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			cmd := deleteLibraryPanelsCommand{
+				UIDs: []string{sc.initialResult.Result.UID, connected.Result.UID, "unknown"},
+			}
+			resp = sc.service.deleteManyHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+
+			var result struct {
+				Result []DeleteLibraryPanelResultDTO `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []DeleteLibraryPanelResultDTO{
+				{UID: sc.initialResult.Result.UID, Status: deleteStatusDeleted},
+				{UID: connected.Result.UID, Status: deleteStatusHasConnections},
+				{UID: "unknown", Status: deleteStatusNotFound},
+			}, result.Result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin force deletes a library panel that is connected, it should be disconnected and deleted",
+		func(t *testing.T, sc scenarioContext) {
+			dashboard := createDashboard(t, sc.sqlStore, sc.user, "Dash 1", 0)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": strconv.FormatInt(dashboard.Id, 10)})
+			resp := sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?force=true")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				DashboardUIDs []string `json:"dashboardUids"`
+			}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{dashboard.Uid}, result.DashboardUIDs)
+
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin force deletes a library panel that is used in a dashboard, it should unlink it so the dashboard can be saved again",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			dash.Data.Set("id", dashInDB.Id)
+			dash.Data.Set("uid", dashInDB.Uid)
+			dash.Data.Set("title", dashInDB.Title)
+			_, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+				Dashboard: dash.Data,
+				OrgId:     sc.user.OrgId,
+				UserId:    sc.user.UserId,
+				FolderId:  sc.folder.Id,
+				Overwrite: true,
+			})
+			require.NoError(t, err)
+			err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?force=true")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			saved, err := sc.sqlStore.GetDashboard(dashInDB.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			panel := saved.Data.Get("panels").GetIndex(0)
+			require.Nil(t, panel.Get("libraryPanel").Interface())
+			require.Equal(t, "A description", panel.Get("description").MustString())
+			err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, saved)
+			require.NoError(t, err)
+		})
+
+	scenarioWithLibraryPanel(t, "When a force delete of a library panel used in dashboards fails, it should leave the dashboards unchanged",
+		func(t *testing.T, sc scenarioContext) {
+			var dashboards []*models.Dashboard
+			for _, title := range []string{"Dash 1", "Dash 2"} {
+				created := createDashboard(t, sc.sqlStore, sc.user, title, sc.folder.Id)
+				dash := getDashboardWithLibraryPanel(sc, created.Id)
+				dash.Data.Set("id", created.Id)
+				dash.Data.Set("uid", created.Uid)
+				dash.Data.Set("title", created.Title)
+				saved, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+					Dashboard: dash.Data,
+					OrgId:     sc.user.OrgId,
+					UserId:    sc.user.UserId,
+					FolderId:  sc.folder.Id,
+					Overwrite: true,
+				})
+				require.NoError(t, err)
+				err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+				require.NoError(t, err)
+				dashboards = append(dashboards, saved)
+			}
+			// the delete itself fails after the dashboards were unlinked
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				_, err := session.Exec("UPDATE library_panel SET provisioned=? WHERE id=?", true, sc.initialResult.Result.ID)
+				return err
+			})
+			require.NoError(t, err)
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?force=true")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+
+			for _, dash := range dashboards {
+				saved, err := sc.sqlStore.GetDashboard(dash.Id, sc.user.OrgId, "", "")
+				require.NoError(t, err)
+				require.Equal(t, dash.Version, saved.Version)
+				panel := saved.Data.Get("panels").GetIndex(0)
+				require.Equal(t, sc.initialResult.Result.UID, panel.Get("libraryPanel").Get("uid").MustString())
+			}
+			resp = sc.service.getConnectedDashboardsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelDashboardsResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []int64{dashboards[0].Id, dashboards[1].Id}, result.Result)
+		})
+
+	scenarioWithLibraryPanel(t, "When a viewer force deletes a library panel that is connected, it should fail and keep the connection",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": "1"})
+			resp := sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?force=true")
+			require.NoError(t, err)
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 403, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_ADMIN
+			resp = sc.service.getConnectedDashboardsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelDashboardsResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []int64{1}, result.Result)
+		})
 }
@@ -0,0 +1,55 @@
+package librarypanels
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/response"
+)
+
+func TestLibraryPanelETag(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with the ETag from get, it should succeed and return the new ETag",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			etag := resp.(*response.NormalResponse).Header().Get("ETag")
+			require.Equal(t, `"`+sc.initialResult.Result.UID+`-1"`, etag)
+
+			sc.reqContext.Req.Header = http.Header{}
+			sc.reqContext.Req.Header.Set("If-Match", etag)
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed"})
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), result.Result.Version)
+			require.Equal(t, `"`+sc.initialResult.Result.UID+`-2"`, resp.(*response.NormalResponse).Header().Get("ETag"))
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed again"})
+			require.Equal(t, 412, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel without a version or If-Match header, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed"})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deletes a library panel with an outdated or foreign ETag, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			sc.reqContext.Req.Header = http.Header{}
+			sc.reqContext.Req.Header.Set("If-Match", `"`+sc.initialResult.Result.UID+`-2"`)
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 412, resp.Status())
+
+			sc.reqContext.Req.Header.Set("If-Match", `"other-uid-1"`)
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 412, resp.Status())
+
+			sc.reqContext.Req.Header.Set("If-Match", `"`+sc.initialResult.Result.UID+`-1"`)
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+}
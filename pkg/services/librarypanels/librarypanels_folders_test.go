@@ -0,0 +1,97 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type libraryPanelFolderStatsResult struct {
+	Result []LibraryPanelFolderStatsDTO `json:"result"`
+}
+
+func TestGetLibraryPanelFolderStats(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin gets the folder stats, it should count the library panels per folder and type",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommandWithModel(sc.folder.Id, "Graph", []byte(`{"type": "graph"}`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			command = getCreateCommand(0, "General Text")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.getFolderStatsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelFolderStatsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []LibraryPanelFolderStatsDTO{
+				{FolderID: 0, Total: 1, Types: map[string]int64{"text": 1}},
+				{FolderID: sc.folder.Id, Total: 2, Types: map[string]int64{"text": 1, "graph": 1}},
+			}, result.Result)
+		})
+}
+
+type libraryPanelFoldersResult struct {
+	Result []LibraryPanelFolderDTO `json:"result"`
+}
+
+func TestGetLibraryPanelFolders(t *testing.T) {
+	testScenario(t, "When a viewer gets the folders they can create library panels in, it should only return folders they can edit",
+		func(t *testing.T, sc scenarioContext) {
+			editable := createFolderWithACL(t, sc.sqlStore, "TeamA", sc.user, []folderACLItem{{models.ROLE_VIEWER, models.PERMISSION_EDIT}})
+			viewable := createFolderWithACL(t, sc.sqlStore, "TeamB", sc.user, []folderACLItem{{models.ROLE_VIEWER, models.PERMISSION_VIEW}})
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?permission=create&query=team")
+			require.NoError(t, err)
+			resp := sc.service.getFoldersHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelFoldersResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelFolderDTO{{ID: editable.Id, UID: editable.Uid, Title: "TeamA"}}, result.Result)
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?permission=view&query=team")
+			require.NoError(t, err)
+			sc.ctx.Req.Form = nil
+			resp = sc.service.getFoldersHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			result = libraryPanelFoldersResult{}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelFolderDTO{
+				{ID: editable.Id, UID: editable.Uid, Title: "TeamA"},
+				{ID: viewable.Id, UID: viewable.Uid, Title: "TeamB"},
+			}, result.Result)
+		})
+
+	testScenario(t, "When an editor gets the folders they can create library panels in, it should include the General folder",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?permission=create")
+			require.NoError(t, err)
+			resp := sc.service.getFoldersHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelFoldersResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, LibraryPanelFolderDTO{ID: 0, UID: "", Title: "General"}, result.Result[0])
+		})
+
+	testScenario(t, "When an user gets the folders for an unknown permission, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?permission=delete")
+			require.NoError(t, err)
+			resp := sc.service.getFoldersHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,148 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type libraryPanelFreezeWindowsResult struct {
+	Result []LibraryPanelFreezeWindowDTO `json:"result"`
+}
+
+func TestLibraryPanelFreezeWindows(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an editor changes a published library panel during a freeze window, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Incident",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Frozen", Version: 1})
+			require.Equal(t, 423, resp.Status())
+			require.Contains(t, string(resp.Body()), "Incident")
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 423, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_ADMIN
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Override", Version: 1})
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an editor moves a published library panel during a freeze window, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Incident",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			resp = sc.service.moveHandler(sc.reqContext, moveLibraryPanelsCommand{
+				UIDs:     []string{sc.initialResult.Result.UID},
+				FolderID: newFolder.Id,
+			})
+			require.Equal(t, 423, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an editor changes a library panel that isn't published during a freeze window, it should succeed",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Incident",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Not frozen", Version: 1})
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deletes a freeze window, changes should be allowed again",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Incident",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.getFreezeWindowsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelFreezeWindowsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result, 1)
+			require.True(t, result.Result[0].Active)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":id": "unknown"})
+			resp = sc.service.deleteFreezeWindowHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+			sc.reqContext.ReplaceAllParams(map[string]string{":id": strconv.FormatInt(result.Result[0].ID, 10)})
+			resp = sc.service.deleteFreezeWindowHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Thawed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a freeze window that ends before it starts, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createFreezeWindowHandler(sc.reqContext, createFreezeWindowCommand{
+				Name:   "Backwards",
+				Starts: time.Now().Add(time.Hour),
+				Ends:   time.Now(),
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When freeze windows are provisioned, they should replace provisioned freeze windows and not be deletable",
+		func(t *testing.T, sc scenarioContext) {
+			cmd := ProvisionFreezeWindowCommand{
+				OrgID:  sc.user.OrgId,
+				Name:   "End of quarter",
+				Starts: time.Now().Add(-time.Hour),
+				Ends:   time.Now().Add(time.Hour),
+			}
+			err := sc.service.ProvisionFreezeWindows(context.Background(), []ProvisionFreezeWindowCommand{cmd})
+			require.NoError(t, err)
+			err = sc.service.ProvisionFreezeWindows(context.Background(), []ProvisionFreezeWindowCommand{cmd})
+			require.NoError(t, err)
+
+			resp := sc.service.getFreezeWindowsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelFreezeWindowsResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result, 1)
+			require.True(t, result.Result[0].Provisioned)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":id": strconv.FormatInt(result.Result[0].ID, 10)})
+			resp = sc.service.deleteFreezeWindowHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/services/search"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 )
 
 func TestGetAllLibraryPanels(t *testing.T) {
@@ -1105,6 +1107,94 @@ func TestGetAllLibraryPanels(t *testing.T) {
 			require.Equal(t, int64(2), results.Result.LibraryPanels[1].Meta.ConnectedDashboards)
 		})
 
+	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels filtered by connected, it should only return library panels with or without connections",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel2")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			var result = validateAndUnMarshalResponse(t, resp)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": result.Result.UID, ":dashboardId": "1"})
+			resp = sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			for connected, name := range map[string]string{
+				"false": sc.initialResult.Result.Name,
+				"true":  "Text - Library Panel2",
+			} {
+				err := sc.reqContext.Req.ParseForm()
+				require.NoError(t, err)
+				sc.reqContext.Req.Form.Set("connected", connected)
+				resp = sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+
+				var results libraryPanelsSearch
+				err = json.Unmarshal(resp.Body(), &results)
+				require.NoError(t, err)
+				require.Equal(t, int64(1), results.Result.TotalCount)
+				require.Len(t, results.Result.LibraryPanels, 1)
+				require.Equal(t, name, results.Result.LibraryPanels[0].Name)
+			}
+
+			sc.reqContext.Req.Form.Set("connected", "maybe")
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels with a compound sort, it should sort by each field and break ties by uid",
+		func(t *testing.T, sc scenarioContext) {
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			command := getCreateCommand(newFolder.Id, sc.initialResult.Result.Name)
+			resp := sc.service.createHandler(sc.reqContext, command)
+			sameName := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommand(sc.folder.Id, "A - Library Panel")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			first := validateAndUnMarshalResponse(t, resp)
+
+			getSorted := func(t *testing.T, sort string, perPage string, page string) []libraryPanel {
+				t.Helper()
+
+				err := sc.reqContext.Req.ParseForm()
+				require.NoError(t, err)
+				sc.reqContext.Req.Form.Set("sort", sort)
+				sc.reqContext.Req.Form.Set("perPage", perPage)
+				sc.reqContext.Req.Form.Set("page", page)
+				resp := sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+				var results libraryPanelsSearch
+				err = json.Unmarshal(resp.Body(), &results)
+				require.NoError(t, err)
+				return results.Result.LibraryPanels
+			}
+
+			tied := []string{sc.initialResult.Result.UID, sameName.Result.UID}
+			if tied[1] < tied[0] {
+				tied[0], tied[1] = tied[1], tied[0]
+			}
+			panels := getSorted(t, "name:desc", "100", "1")
+			require.Len(t, panels, 3)
+			require.Equal(t, []string{tied[0], tied[1], first.Result.UID}, []string{panels[0].UID, panels[1].UID, panels[2].UID})
+			for page, uid := range tied {
+				panels = getSorted(t, "type,name:desc", "1", strconv.Itoa(page+1))
+				require.Len(t, panels, 1)
+				require.Equal(t, uid, panels[0].UID)
+			}
+
+			panels = getSorted(t, "folder:desc,name", "100", "1")
+			require.Len(t, panels, 3)
+			require.Equal(t, []string{first.Result.UID, sc.initialResult.Result.UID, sameName.Result.UID}, []string{panels[0].UID, panels[1].UID, panels[2].UID})
+
+			for _, sort := range []string{"size", "name:up", "name,name:desc"} {
+				sc.reqContext.Req.Form.Set("sort", sort)
+				resp = sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 400, resp.Status())
+			}
+			sc.reqContext.Req.Form.Set("sort", "updated:desc")
+			sc.reqContext.Req.Form.Set("continueToken", encodeContinueToken(first.Result.Name, first.Result.UID))
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+
 	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels in a different org, none should be returned",
 		func(t *testing.T, sc scenarioContext) {
 			resp := sc.service.getAllHandler(sc.reqContext)
@@ -1137,4 +1227,199 @@ func TestGetAllLibraryPanels(t *testing.T) {
 				t.Fatalf("Result mismatch (-want +got):\n%s", diff)
 			}
 		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels and searchString only exists in a model, it should only match when searching in models",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommandWithModel(sc.folder.Id, "Graph - Library Panel", []byte(`
+			{
+			  "id": 1,
+			  "title": "Graph - Library Panel",
+			  "type": "graph",
+			  "targets": [{"expr": "rate(http_requests_total[5m])"}]
+			}
+		`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			err := sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Add("searchString", "http_requests_total")
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), result.Result.TotalCount)
+
+			sc.reqContext.Req.Form.Add("searchIn", "model")
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			result = libraryPanelsSearch{}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, "Graph - Library Panel", result.Result.LibraryPanels[0].Name)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels within the search latency budget, it should return connected dashboards",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": "1"})
+			resp := sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			sc.service.Cfg.PanelLibrarySearchLatencyBudget = time.Hour
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result LibraryPanelSearchResult `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.False(t, result.Result.Partial)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, int64(1), result.Result.LibraryPanels[0].Meta.ConnectedDashboards)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to get all library panels and the search latency budget is spent, it should return a partial result",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": "1"})
+			resp := sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			sc.service.Cfg.PanelLibrarySearchLatencyBudget = time.Nanosecond
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result LibraryPanelSearchResult `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.True(t, result.Result.Partial)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, int64(0), result.Result.LibraryPanels[0].Meta.ConnectedDashboards)
+		})
+}
+
+func TestGetAllLibraryPanelsByDatasource(t *testing.T) {
+	testScenario(t, "When an admin tries to get all library panels using a datasource, it should match references by uid, name and default placeholder",
+		func(t *testing.T, sc scenarioContext) {
+			err := sqlstore.AddDataSource(&models.AddDataSourceCommand{
+				OrgId:     sc.user.OrgId,
+				Name:      "Prometheus",
+				Type:      "prometheus",
+				Access:    models.DS_ACCESS_PROXY,
+				IsDefault: true,
+				Uid:       "prometheus-uid",
+			})
+			require.NoError(t, err)
+
+			for name, model := range map[string]string{
+				"By Uid":        `{"type": "graph", "datasource": "prometheus-uid"}`,
+				"By Name":       `{"type": "graph", "datasource": "Prometheus"}`,
+				"By Default":    `{"type": "graph", "datasource": "default"}`,
+				"In A Target":   `{"type": "graph", "datasource": "-- Mixed --", "targets": [{"datasource": "Prometheus"}]}`,
+				"Other":         `{"type": "graph", "datasource": "Loki"}`,
+				"Similar Names": `{"type": "graph", "datasource": "Prometheus 2"}`,
+			} {
+				command := getCreateCommandWithModel(sc.folder.Id, name, []byte(model))
+				resp := sc.service.createHandler(sc.reqContext, command)
+				require.Equal(t, 200, resp.Status())
+			}
+
+			err = sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Add("datasourceUid", "prometheus-uid")
+			resp := sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			var result libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(4), result.Result.TotalCount)
+			var names []string
+			for _, panel := range result.Result.LibraryPanels {
+				names = append(names, panel.Name)
+			}
+			require.Equal(t, []string{"By Default", "By Name", "By Uid", "In A Target"}, names)
+		})
+}
+
+func TestGetAllLibraryPanelsWithContinueToken(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin pages through library panels with continueToken, it should return every library panel once",
+		func(t *testing.T, sc scenarioContext) {
+			for _, name := range []string{"A", "B", "C"} {
+				resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, name))
+				require.Equal(t, 200, resp.Status())
+			}
+
+			var names []string
+			continueToken := ""
+			for page := 0; page < 3; page++ {
+				err := sc.reqContext.Req.ParseForm()
+				require.NoError(t, err)
+				sc.reqContext.Req.Form.Set("perPage", "2")
+				sc.reqContext.Req.Form.Set("continueToken", continueToken)
+				resp := sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+				var result struct {
+					Result struct {
+						TotalCount    int64          `json:"totalCount"`
+						LibraryPanels []libraryPanel `json:"libraryPanels"`
+						ContinueToken string         `json:"continueToken"`
+					} `json:"result"`
+				}
+				err = json.Unmarshal(resp.Body(), &result)
+				require.NoError(t, err)
+				require.Equal(t, int64(4), result.Result.TotalCount)
+				for _, panel := range result.Result.LibraryPanels {
+					names = append(names, panel.Name)
+				}
+				continueToken = result.Result.ContinueToken
+				if continueToken == "" {
+					break
+				}
+			}
+
+			require.Equal(t, []string{"A", "B", "C", "Text - Library Panel"}, names)
+			require.Empty(t, continueToken)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets a full page of library panels with a sort, it shouldn't return a continueToken",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "A"))
+			require.Equal(t, 200, resp.Status())
+
+			getContinueToken := func(t *testing.T, sort string) string {
+				t.Helper()
+
+				err := sc.reqContext.Req.ParseForm()
+				require.NoError(t, err)
+				sc.reqContext.Req.Form.Set("perPage", "1")
+				sc.reqContext.Req.Form.Set("sort", sort)
+				resp := sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+				var result struct {
+					Result struct {
+						ContinueToken string `json:"continueToken"`
+					} `json:"result"`
+				}
+				err = json.Unmarshal(resp.Body(), &result)
+				require.NoError(t, err)
+				return result.Result.ContinueToken
+			}
+
+			require.NotEmpty(t, getContinueToken(t, ""))
+			require.Empty(t, getContinueToken(t, "updated:desc"))
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets library panels with an invalid continueToken, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			err := sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Add("continueToken", "not a token")
+			resp := sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
 }
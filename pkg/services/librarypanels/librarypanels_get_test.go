@@ -1,12 +1,15 @@
 package librarypanels
 
 import (
+	"encoding/json"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 )
 
 func TestGetLibraryPanel(t *testing.T) {
@@ -89,3 +92,59 @@ func TestGetLibraryPanel(t *testing.T) {
 			require.Equal(t, int64(2), result.Result.Meta.ConnectedDashboards)
 		})
 }
+
+func TestReplaceDefaultDatasource(t *testing.T) {
+	for _, tc := range []struct {
+		model    string
+		expected string
+	}{
+		{`{"datasource": "default", "targets": [{"refId": "A"}]}`, `{"datasource": "uid", "targets": [{"refId": "A"}]}`},
+		{`{"datasource": null, "targets": [{"refId": "A"}]}`, `{"datasource": "uid", "targets": [{"refId": "A"}]}`},
+		{`{"targets": [{"refId": "A", "datasource": "default"}]}`, `{"datasource": "uid", "targets": [{"refId": "A", "datasource": "uid"}]}`},
+		{`{"datasource": "Prometheus", "targets": [{"refId": "A", "datasource": null}]}`, `{"datasource": "Prometheus", "targets": [{"refId": "A", "datasource": null}]}`},
+		{`{"type": "text"}`, `{"type": "text"}`},
+	} {
+		model, err := replaceDefaultDatasource([]byte(tc.model), "uid")
+		require.NoError(t, err)
+		require.JSONEq(t, tc.expected, string(model), tc.model)
+	}
+}
+
+func TestGetLibraryPanelWithDefaultDatasource(t *testing.T) {
+	testScenario(t, "When an admin gets a library panel using the default datasource with resolveDefaultDatasource, it should return the default datasource uid",
+		func(t *testing.T, sc scenarioContext) {
+			err := sqlstore.AddDataSource(&models.AddDataSourceCommand{
+				OrgId:     sc.user.OrgId,
+				Name:      "Default Datasource",
+				Type:      "testdata",
+				Access:    models.DS_ACCESS_PROXY,
+				IsDefault: true,
+				Uid:       "default-ds-uid",
+			})
+			require.NoError(t, err)
+
+			command := getCreateCommandWithModel(sc.folder.Id, "Default Datasource Panel", []byte(`
+			{
+			  "datasource": "default",
+			  "targets": [{"refId": "A", "datasource": "default"}, {"refId": "B", "datasource": "other"}],
+			  "type": "graph"
+			}
+			`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			created := validateAndUnMarshalResponse(t, resp)
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?resolveDefaultDatasource=true")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "default-ds-uid", result.Result.Model["datasource"])
+
+			var targets []map[string]interface{}
+			raw, err := json.Marshal(result.Result.Model["targets"])
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(raw, &targets))
+			require.Equal(t, "default-ds-uid", targets[0]["datasource"])
+			require.Equal(t, "other", targets[1]["datasource"])
+		})
+}
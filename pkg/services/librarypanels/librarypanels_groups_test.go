@@ -0,0 +1,199 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type libraryPanelGroupResult struct {
+	Result LibraryPanelGroupDTO `json:"result"`
+}
+
+type libraryPanelGroupsResult struct {
+	Result []LibraryPanelGroupDTO `json:"result"`
+}
+
+func TestLibraryPanelGroups(t *testing.T) {
+	createGroup := func(t *testing.T, sc scenarioContext) (LibraryPanelGroupDTO, libraryPanelResult) {
+		t.Helper()
+
+		command := getCreateCommand(sc.folder.Id, "Variables")
+		resp := sc.service.createHandler(sc.reqContext, command)
+		variables := validateAndUnMarshalResponse(t, resp)
+
+		resp = sc.service.createGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+			Name: "Cluster overview",
+			UIDs: []string{sc.initialResult.Result.UID, variables.Result.UID},
+		})
+		require.Equal(t, 200, resp.Status())
+		var group libraryPanelGroupResult
+		err := json.Unmarshal(resp.Body(), &group)
+		require.NoError(t, err)
+		return group.Result, variables
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel group, it should be listed with its library panels",
+		func(t *testing.T, sc scenarioContext) {
+			group, variables := createGroup(t, sc)
+			require.Equal(t, "Cluster overview", group.Name)
+			require.Equal(t, []LibraryPanelGroupMemberDTO{
+				{UID: sc.initialResult.Result.UID, Name: sc.initialResult.Result.Name, Version: 1},
+				{UID: variables.Result.UID, Name: "Variables", Version: 1},
+			}, group.Panels)
+
+			resp := sc.service.getGroupsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var groups libraryPanelGroupsResult
+			err := json.Unmarshal(resp.Body(), &groups)
+			require.NoError(t, err)
+			require.Len(t, groups.Result, 1)
+			require.Equal(t, group.UID, groups.Result[0].UID)
+			require.Equal(t, group.Panels, groups.Result[0].Panels)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": group.UID})
+			resp = sc.service.deleteGroupHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getGroupHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": variables.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates an invalid library panel group, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+				Name: "Twice",
+				UIDs: []string{sc.initialResult.Result.UID, sc.initialResult.Result.UID},
+			})
+			require.Equal(t, 400, resp.Status())
+
+			resp = sc.service.createGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+				Name: "Unknown",
+				UIDs: []string{"unknown"},
+			})
+			require.Equal(t, 404, resp.Status())
+
+			resp = sc.service.getGroupsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var groups libraryPanelGroupsResult
+			err := json.Unmarshal(resp.Body(), &groups)
+			require.NoError(t, err)
+			require.Empty(t, groups.Result)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin publishes a library panel group, it should update all its library panels",
+		func(t *testing.T, sc scenarioContext) {
+			group, variables := createGroup(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": group.UID})
+			resp := sc.service.publishGroupHandler(sc.reqContext, publishLibraryPanelGroupCommand{
+				Panels: []publishLibraryPanelGroupPanel{
+					{UID: sc.initialResult.Result.UID, Version: 1, Model: []byte(`{"type": "text", "description": "Uses $cluster"}`)},
+					{UID: variables.Result.UID, Name: "Cluster variables", Version: 1},
+				},
+			})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), result.Result.Version)
+			require.Equal(t, "Uses $cluster", result.Result.Description)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": variables.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), result.Result.Version)
+			require.Equal(t, "Cluster variables", result.Result.Name)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin publishes a library panel group and one library panel fails, it should update none of them",
+		func(t *testing.T, sc scenarioContext) {
+			group, variables := createGroup(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": group.UID})
+			resp := sc.service.publishGroupHandler(sc.reqContext, publishLibraryPanelGroupCommand{
+				Panels: []publishLibraryPanelGroupPanel{
+					{UID: sc.initialResult.Result.UID, Name: "Renamed", Version: 1},
+					{UID: variables.Result.UID, Name: "Cluster variables", Version: 2},
+				},
+			})
+			require.Equal(t, 412, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(1), result.Result.Version)
+			require.Equal(t, sc.initialResult.Result.Name, result.Result.Name)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin publishes a library panel group without all its library panels, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			group, _ := createGroup(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": group.UID})
+			resp := sc.service.publishGroupHandler(sc.reqContext, publishLibraryPanelGroupCommand{
+				Panels: []publishLibraryPanelGroupPanel{
+					{UID: sc.initialResult.Result.UID, Name: "Renamed", Version: 1},
+				},
+			})
+			require.Equal(t, 400, resp.Status())
+
+			resp = sc.service.publishGroupHandler(sc.reqContext, publishLibraryPanelGroupCommand{
+				Panels: []publishLibraryPanelGroupPanel{
+					{UID: sc.initialResult.Result.UID, Name: "Renamed", Version: 1},
+					{UID: sc.initialResult.Result.UID, Name: "Renamed again", Version: 1},
+				},
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a user without access to a folder uses library panel groups, it should leave its library panels out",
+		func(t *testing.T, sc scenarioContext) {
+			restricted := createFolderWithACL(t, sc.sqlStore, "Restricted", sc.user, []folderACLItem{{models.ROLE_ADMIN, models.PERMISSION_EDIT}})
+			command := getCreateCommand(restricted.Id, "Secret")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			secret := validateAndUnMarshalResponse(t, resp)
+			resp = sc.service.createGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+				Name: "Cluster overview",
+				UIDs: []string{sc.initialResult.Result.UID, secret.Result.UID},
+			})
+			require.Equal(t, 200, resp.Status())
+			var group libraryPanelGroupResult
+			err := json.Unmarshal(resp.Body(), &group)
+			require.NoError(t, err)
+			require.Len(t, group.Result.Panels, 2)
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			visible := []LibraryPanelGroupMemberDTO{{UID: sc.initialResult.Result.UID, Name: sc.initialResult.Result.Name, Version: 1}}
+			resp = sc.service.getGroupsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var groups libraryPanelGroupsResult
+			err = json.Unmarshal(resp.Body(), &groups)
+			require.NoError(t, err)
+			require.Len(t, groups.Result, 1)
+			require.Equal(t, visible, groups.Result[0].Panels)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": group.Result.UID})
+			resp = sc.service.getGroupHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &group)
+			require.NoError(t, err)
+			require.Equal(t, visible, group.Result.Panels)
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			resp = sc.service.createGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+				Name: "Secrets",
+				UIDs: []string{secret.Result.UID},
+			})
+			require.Equal(t, 403, resp.Status())
+			resp = sc.service.updateGroupHandler(sc.reqContext, setLibraryPanelGroupCommand{
+				Name: "Cluster overview",
+				UIDs: []string{sc.initialResult.Result.UID, secret.Result.UID},
+			})
+			require.Equal(t, 403, resp.Status())
+		})
+}
@@ -0,0 +1,77 @@
+package librarypanels
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestLoadLibraryPanelsForDashboardWithStaleness(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin loads a dashboard with a staleness budget, it should serve cached library panels and refresh them in the background",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			sc.sqlStore.CacheService.Delete(hydrateCacheKey(dashInDB.Id))
+			load := func(query string) string {
+				t.Helper()
+
+				var err error
+				sc.ctx.Req.Request.URL, err = url.Parse("/?" + query)
+				require.NoError(t, err)
+				sc.ctx.Req.Form = nil
+				dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+				err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+				require.NoError(t, err)
+				return dash.Data.Get("panels").GetIndex(0).Get("title").MustString()
+			}
+			refreshed := func() bool {
+				refreshing := false
+				sc.service.hydrateRefreshing.Range(func(key, value interface{}) bool {
+					refreshing = true
+					return false
+				})
+				return !refreshing
+			}
+
+			require.Equal(t, "Text - Library Panel", load("maxStaleSeconds=60"))
+			err = sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				_, err := session.Exec("UPDATE library_panel SET model=? WHERE id=?", []byte(`{"title": "Updated", "type": "text"}`), sc.initialResult.Result.ID)
+				return err
+			})
+			require.NoError(t, err)
+
+			// the cached library panel is served while it's refreshed
+			require.Equal(t, "Text - Library Panel", load("maxStaleSeconds=60"))
+			require.Eventually(t, refreshed, time.Second, 10*time.Millisecond)
+			require.Equal(t, "Updated", load("maxStaleSeconds=60"))
+			require.Eventually(t, refreshed, time.Second, 10*time.Millisecond)
+
+			// without a staleness budget the library panel is always fetched
+			require.Equal(t, "Updated", load(""))
+		})
+
+	scenarioWithLibraryPanel(t, "When the library panels of a dashboard are fetched and cached, changing them should not change the cached ones",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			sc.sqlStore.CacheService.Delete(hydrateCacheKey(dashInDB.Id))
+
+			libraryPanels, _, err := sc.service.getLibraryPanelsForDashboard(sc.reqContext, dashInDB.Id, time.Minute)
+			require.NoError(t, err)
+			panel := libraryPanels[sc.initialResult.Result.UID]
+			panel.Version = 42
+			libraryPanels[sc.initialResult.Result.UID] = panel
+
+			cached, ok := sc.sqlStore.CacheService.Get(hydrateCacheKey(dashInDB.Id))
+			require.True(t, ok)
+			require.Equal(t, int64(1), cached.(hydratedLibraryPanels).libraryPanels[sc.initialResult.Result.UID].Version)
+		})
+}
@@ -0,0 +1,88 @@
+package librarypanels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+func getImportedDashboard(t *testing.T, uid string) *simplejson.Json {
+	t.Helper()
+
+	dashboard, err := simplejson.NewJson([]byte(`{
+		"__inputs": [
+			{
+				"name": "LIB_PANEL_CPU",
+				"label": "CPU",
+				"type": "librarypanel",
+				"uid": "` + uid + `",
+				"model": {"title": "CPU", "type": "graph", "description": "CPU usage"}
+			}
+		],
+		"title": "Imported",
+		"panels": [
+			{"id": 1, "libraryPanel": {"uid": "${LIB_PANEL_CPU}", "name": "CPU"}}
+		]
+	}`))
+	require.NoError(t, err)
+	return dashboard
+}
+
+func TestImportLibraryPanelsForDashboard(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a dashboard with a library panel input is imported without a value, it should create the library panel once",
+		func(t *testing.T, sc scenarioContext) {
+			dashboard := getImportedDashboard(t, "exported-uid")
+			inputs := []plugins.ImportDashboardInput{
+				{Type: "datasource", Name: "DS_PROMETHEUS", Value: "Prometheus"},
+				{Type: libraryPanelInputType, Name: "LIB_PANEL_CPU"},
+			}
+			resolved, err := sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, dashboard, inputs, sc.folder.Id)
+			require.NoError(t, err)
+			require.Len(t, resolved, 2)
+			require.Equal(t, inputs[0], resolved[0])
+			require.NotEmpty(t, resolved[1].Value)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": resolved[1].Value})
+			resp := sc.service.getHandler(sc.reqContext)
+			created := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "CPU", created.Result.Name)
+			require.Equal(t, "graph", created.Result.Type)
+			require.Equal(t, sc.folder.Id, created.Result.FolderID)
+
+			again, err := sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, dashboard, inputs, sc.folder.Id)
+			require.NoError(t, err)
+			require.Equal(t, resolved, again)
+		})
+
+	scenarioWithLibraryPanel(t, "When a dashboard with a library panel input is imported, it should map to an existing library panel",
+		func(t *testing.T, sc scenarioContext) {
+			dashboard := getImportedDashboard(t, sc.initialResult.Result.UID)
+			resolved, err := sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, dashboard, []plugins.ImportDashboardInput{
+				{Type: libraryPanelInputType, Name: "LIB_PANEL_CPU"},
+			}, sc.folder.Id)
+			require.NoError(t, err)
+			require.Equal(t, sc.initialResult.Result.UID, resolved[0].Value)
+
+			resolved, err = sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, getImportedDashboard(t, ""), []plugins.ImportDashboardInput{
+				{Type: libraryPanelInputType, Name: "LIB_PANEL_CPU", Value: sc.initialResult.Result.UID},
+			}, sc.folder.Id)
+			require.NoError(t, err)
+			require.Equal(t, sc.initialResult.Result.UID, resolved[0].Value)
+
+			_, err = sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, dashboard, []plugins.ImportDashboardInput{
+				{Type: libraryPanelInputType, Name: "LIB_PANEL_CPU", Value: "unknown"},
+			}, sc.folder.Id)
+			require.ErrorIs(t, err, errLibraryPanelNotFound)
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel input isn't declared in the dashboard, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			_, err := sc.service.ImportLibraryPanelsForDashboard(sc.reqContext, getImportedDashboard(t, ""), []plugins.ImportDashboardInput{
+				{Type: libraryPanelInputType, Name: "LIB_PANEL_MEMORY"},
+			}, sc.folder.Id)
+			require.ErrorIs(t, err, errLibraryPanelInvalidImportInput)
+		})
+}
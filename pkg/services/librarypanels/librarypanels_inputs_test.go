@@ -0,0 +1,139 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelWithInputsResult struct {
+	Result struct {
+		UID    string                 `json:"uid"`
+		Inputs []LibraryPanelInputDTO `json:"inputs"`
+	} `json:"result"`
+}
+
+type libraryPanelModelResult struct {
+	Result map[string]interface{} `json:"result"`
+}
+
+func TestLibraryPanelInputs(t *testing.T) {
+	model := []byte(`{
+		"title": "Pods in ${namespace}",
+		"type": "graph",
+		"datasource": "${datasource}",
+		"targets": [{"expr": "kube_pod_info{namespace=\"${namespace}\", cluster=\"$cluster\"}"}]
+	}`)
+	inputs := []LibraryPanelInputDTO{
+		{Name: "namespace", Label: "Namespace", Required: true},
+		{Name: "datasource", Type: libraryPanelInputTypeDatasource, Default: "Prometheus"},
+	}
+	createPanelWithInputs := func(t *testing.T, sc scenarioContext) libraryPanelWithInputsResult {
+		t.Helper()
+
+		command := getCreateCommandWithModel(sc.folder.Id, "Pods", model)
+		command.Inputs = inputs
+		resp := sc.service.createHandler(sc.reqContext, command)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelWithInputsResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel with inputs, it should return them in declaration order",
+		func(t *testing.T, sc scenarioContext) {
+			created := createPanelWithInputs(t, sc)
+			expected := []LibraryPanelInputDTO{
+				{Name: "namespace", Label: "Namespace", Type: libraryPanelInputTypeText, Required: true},
+				{Name: "datasource", Type: libraryPanelInputTypeDatasource, Default: "Prometheus"},
+			}
+			require.Equal(t, expected, created.Result.Inputs)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelWithInputsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, expected, result.Result.Inputs)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Pods by namespace", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, expected, result.Result.Inputs)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Inputs: []LibraryPanelInputDTO{}, Version: 2})
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Empty(t, result.Result.Inputs)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel with invalid inputs, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			for _, invalid := range [][]LibraryPanelInputDTO{
+				{{Name: "name-space"}},
+				{{Name: "namespace"}, {Name: "namespace"}},
+				{{Name: "namespace", Type: "number"}},
+			} {
+				command := getCreateCommandWithModel(sc.folder.Id, "Pods", model)
+				command.Inputs = invalid
+				resp := sc.service.createHandler(sc.reqContext, command)
+				require.Equal(t, 400, resp.Status())
+			}
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin renders the model of a library panel with inputs, it should replace them",
+		func(t *testing.T, sc scenarioContext) {
+			err := sqlstore.AddDataSource(&models.AddDataSourceCommand{
+				OrgId:  sc.user.OrgId,
+				Name:   "Prometheus",
+				Type:   "prometheus",
+				Access: models.DS_ACCESS_PROXY,
+				Uid:    "prometheus-uid",
+			})
+			require.NoError(t, err)
+			created := createPanelWithInputs(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+
+			resp := sc.service.renderModelHandler(sc.reqContext, renderLibraryPanelModelCommand{
+				Inputs: map[string]string{"namespace": "monitoring"},
+			})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelModelResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, "Prometheus", result.Result["datasource"])
+			require.Equal(t, `kube_pod_info{namespace="monitoring", cluster="$cluster"}`, result.Result["targets"].([]interface{})[0].(map[string]interface{})["expr"])
+
+			resp = sc.service.renderModelHandler(sc.reqContext, renderLibraryPanelModelCommand{
+				Inputs: map[string]string{"namespace": "monitoring", "datasource": "prometheus-uid"},
+			})
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin renders the model of a library panel with missing, unknown or invalid inputs, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			created := createPanelWithInputs(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+
+			resp := sc.service.renderModelHandler(sc.reqContext, renderLibraryPanelModelCommand{})
+			require.Equal(t, 400, resp.Status())
+
+			resp = sc.service.renderModelHandler(sc.reqContext, renderLibraryPanelModelCommand{
+				Inputs: map[string]string{"namespace": "monitoring", "cluster": "eu-west"},
+			})
+			require.Equal(t, 400, resp.Status())
+
+			resp = sc.service.renderModelHandler(sc.reqContext, renderLibraryPanelModelCommand{
+				Inputs: map[string]string{"namespace": "monitoring"},
+			})
+			require.Equal(t, 404, resp.Status())
+		})
+}
@@ -0,0 +1,89 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelIntegrityResult struct {
+	Result LibraryPanelIntegrityReportDTO `json:"result"`
+}
+
+func TestLibraryPanelIntegrity(t *testing.T) {
+	getIntegrity := func(t *testing.T, sc scenarioContext) LibraryPanelIntegrityReportDTO {
+		t.Helper()
+
+		resp := sc.service.getIntegrityHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelIntegrityResult
+		err := json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When a server admin checks integrity of healthy library panels, it should report no problems",
+		func(t *testing.T, sc scenarioContext) {
+			report := getIntegrity(t, sc)
+			require.True(t, report.Healthy)
+			require.Empty(t, report.DanglingConnections)
+			require.Empty(t, report.MissingFolders)
+			require.Empty(t, report.InvalidModels)
+			require.Empty(t, report.Collisions)
+		})
+
+	scenarioWithLibraryPanel(t, "When a server admin checks integrity of broken library panels, it should report the problems",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Broken")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			broken := validateAndUnMarshalResponse(t, resp)
+
+			err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				connection := libraryPanelDashboard{
+					LibraryPanelID: sc.initialResult.Result.ID,
+					DashboardID:    1000,
+					Created:        time.Now(),
+					CreatedBy:      sc.user.UserId,
+				}
+				if _, err := session.Insert(&connection); err != nil {
+					return err
+				}
+				if _, err := session.Exec("UPDATE library_panel SET model=?, folder_id=? WHERE id=?", []byte("{"), sc.folder.Id+1000, broken.Result.ID); err != nil {
+					return err
+				}
+				_, err := session.Insert(&LibraryPanel{
+					OrgID:     sc.user.OrgId + 1,
+					UID:       sc.initialResult.Result.UID,
+					Name:      sc.initialResult.Result.Name,
+					Type:      "text",
+					Model:     []byte("{}"),
+					Version:   1,
+					Created:   time.Now(),
+					CreatedBy: sc.user.UserId,
+					Updated:   time.Now(),
+					UpdatedBy: sc.user.UserId,
+				})
+				return err
+			})
+			require.NoError(t, err)
+
+			report := getIntegrity(t, sc)
+			require.False(t, report.Healthy)
+			require.Len(t, report.DanglingConnections, 1)
+			require.Equal(t, danglingReasonDashboardDeleted, report.DanglingConnections[0].Reason)
+			require.Equal(t, int64(1000), report.DanglingConnections[0].DashboardID)
+			require.Len(t, report.MissingFolders, 1)
+			require.Equal(t, broken.Result.UID, report.MissingFolders[0].UID)
+			require.Len(t, report.InvalidModels, 1)
+			require.Equal(t, broken.Result.UID, report.InvalidModels[0].UID)
+			require.NotEmpty(t, report.InvalidModels[0].Error)
+			require.Equal(t, []LibraryPanelIntegrityCollisionDTO{
+				{Field: integrityCollisionUID, Value: sc.initialResult.Result.UID, OrgIDs: []int64{sc.user.OrgId, sc.user.OrgId + 1}},
+				{Field: integrityCollisionName, Value: sc.initialResult.Result.Name, OrgIDs: []int64{sc.user.OrgId, sc.user.OrgId + 1}},
+			}, report.Collisions)
+		})
+}
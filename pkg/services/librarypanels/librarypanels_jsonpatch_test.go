@@ -0,0 +1,149 @@
+package librarypanels
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	model := `{"type": "stat", "targets": [{"refId": "A"}, {"refId": "B"}], "fieldConfig": {"defaults": {"thresholds": {"steps": [{"value": null}, {"value": 80}]}}}}`
+	testCases := []struct {
+		desc     string
+		patch    string
+		expected string
+		err      error
+	}{
+		{
+			desc:     "replace a threshold",
+			patch:    `[{"op": "test", "path": "/fieldConfig/defaults/thresholds/steps/1/value", "value": 80}, {"op": "replace", "path": "/fieldConfig/defaults/thresholds/steps/1/value", "value": 90}]`,
+			expected: `{"type": "stat", "targets": [{"refId": "A"}, {"refId": "B"}], "fieldConfig": {"defaults": {"thresholds": {"steps": [{"value": null}, {"value": 90}]}}}}`,
+		},
+		{
+			desc:     "add and remove array elements",
+			patch:    `[{"op": "add", "path": "/targets/-", "value": {"refId": "C"}}, {"op": "remove", "path": "/targets/0"}]`,
+			expected: `{"type": "stat", "targets": [{"refId": "B"}, {"refId": "C"}], "fieldConfig": {"defaults": {"thresholds": {"steps": [{"value": null}, {"value": 80}]}}}}`,
+		},
+		{
+			desc:     "move and copy",
+			patch:    `[{"op": "copy", "from": "/targets/0", "path": "/targets/2"}, {"op": "move", "from": "/fieldConfig/defaults", "path": "/defaults"}, {"op": "remove", "path": "/fieldConfig"}]`,
+			expected: `{"type": "stat", "targets": [{"refId": "A"}, {"refId": "B"}, {"refId": "A"}], "defaults": {"thresholds": {"steps": [{"value": null}, {"value": 80}]}}}`,
+		},
+		{
+			desc:     "escaped member names",
+			patch:    `[{"op": "add", "path": "/a~1b~0c", "value": null}]`,
+			expected: `{"type": "stat", "a/b~c": null, "targets": [{"refId": "A"}, {"refId": "B"}], "fieldConfig": {"defaults": {"thresholds": {"steps": [{"value": null}, {"value": 80}]}}}}`,
+		},
+		{
+			desc:  "failed test",
+			patch: `[{"op": "test", "path": "/type", "value": "graph"}, {"op": "replace", "path": "/type", "value": "table"}]`,
+			err:   errLibraryPanelJSONPatchTestFailed,
+		},
+		{
+			desc:  "missing member",
+			patch: `[{"op": "replace", "path": "/title", "value": "CPU"}]`,
+			err:   errLibraryPanelInvalidJSONPatch,
+		},
+		{
+			desc:  "array index out of bounds",
+			patch: `[{"op": "add", "path": "/targets/3", "value": {}}]`,
+			err:   errLibraryPanelInvalidJSONPatch,
+		},
+		{
+			desc:  "unknown operation",
+			patch: `[{"op": "merge", "path": "/type", "value": "table"}]`,
+			err:   errLibraryPanelInvalidJSONPatch,
+		},
+		{
+			desc:  "move into itself",
+			patch: `[{"op": "move", "from": "/fieldConfig", "path": "/fieldConfig/defaults/old"}]`,
+			err:   errLibraryPanelInvalidJSONPatch,
+		},
+		{
+			desc:  "model that isn't an object",
+			patch: `[{"op": "replace", "path": "", "value": []}]`,
+			err:   errLibraryPanelInvalidJSONPatch,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var operations []jsonPatchOperation
+			err := json.Unmarshal([]byte(tc.patch), &operations)
+			require.NoError(t, err)
+
+			patched, err := applyJSONPatch([]byte(model), operations)
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(patched))
+		})
+	}
+}
+
+func TestJSONPatchLibraryPanel(t *testing.T) {
+	setJSONPatch := func(sc scenarioContext, patch string) {
+		sc.reqContext.Req.Header = http.Header{}
+		sc.reqContext.Req.Header.Set("Content-Type", "application/json-patch+json")
+		sc.reqContext.Req.Request.Body = ioutil.NopCloser(bytes.NewBufferString(patch))
+		sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with a JSON Patch, it should change the model only",
+		func(t *testing.T, sc scenarioContext) {
+			setJSONPatch(sc, `[{"op": "replace", "path": "/description", "value": "An updated description"}]`)
+			require.True(t, isJSONPatchRequest(sc.reqContext))
+			resp := sc.service.handleJSONPatch(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), result.Result.Version)
+			require.Equal(t, sc.initialResult.Result.Name, result.Result.Name)
+			require.Equal(t, sc.initialResult.Result.FolderID, result.Result.FolderID)
+			require.Equal(t, "An updated description", result.Result.Description)
+			require.Equal(t, "An updated description", result.Result.Model["description"])
+			require.Equal(t, "text", result.Result.Model["type"])
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with a JSON Patch whose test fails, it should fail and not change it",
+		func(t *testing.T, sc scenarioContext) {
+			setJSONPatch(sc, `[{"op": "test", "path": "/type", "value": "graph"}, {"op": "remove", "path": "/description"}]`)
+			resp := sc.service.handleJSONPatch(sc.reqContext)
+			require.Equal(t, 409, resp.Status())
+
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(1), result.Result.Version)
+			require.Equal(t, "A description", result.Result.Model["description"])
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with an invalid JSON Patch, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			setJSONPatch(sc, `{"op": "remove", "path": "/description"}`)
+			resp := sc.service.handleJSONPatch(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+
+			setJSONPatch(sc, `[{"op": "replace", "path": "/type", "value": 1}]`)
+			resp = sc.service.handleJSONPatch(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with a JSON Patch for an old version, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			setJSONPatch(sc, `[{"op": "remove", "path": "/description"}]`)
+			sc.reqContext.Req.Header.Set("If-Match", libraryPanelETag(sc.initialResult.Result.UID, 2))
+			resp := sc.service.handleJSONPatch(sc.reqContext)
+			require.Equal(t, 412, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel with JSON, it should not be handled as a JSON Patch",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.Req.Header = http.Header{}
+			sc.reqContext.Req.Header.Set("Content-Type", "application/json; charset=utf-8")
+			require.False(t, isJSONPatchRequest(sc.reqContext))
+		})
+}
@@ -0,0 +1,127 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelWithLabels struct {
+	UID    string            `json:"uid"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+type libraryPanelWithLabelsResult struct {
+	Result libraryPanelWithLabels `json:"result"`
+}
+
+type libraryPanelsWithLabelsSearch struct {
+	Result struct {
+		TotalCount    int64                    `json:"totalCount"`
+		LibraryPanels []libraryPanelWithLabels `json:"libraryPanels"`
+	} `json:"result"`
+}
+
+func TestLibraryPanelLabels(t *testing.T) {
+	testScenario(t, "When an admin tries to create a library panel with labels, it should return the labels",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			command.Labels = map[string]string{"tier": "gold", "env": "prod"}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			var result libraryPanelWithLabelsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{"tier": "gold", "env": "prod"}, result.Result.Labels)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": result.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{"tier": "gold", "env": "prod"}, result.Result.Labels)
+		})
+
+	testScenario(t, "When an admin tries to create a library panel with an invalid label, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			command.Labels = map[string]string{"tier=": "gold"}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 400, resp.Status())
+		})
+
+	testScenario(t, "When an admin tries to get all library panels with a label selector, it should only return matching panels",
+		func(t *testing.T, sc scenarioContext) {
+			for name, labels := range map[string]map[string]string{
+				"Gold Prod": {"tier": "gold", "env": "prod"},
+				"Gold Dev":  {"tier": "gold", "env": "dev"},
+				"Silver":    {"tier": "silver"},
+			} {
+				command := getCreateCommand(sc.folder.Id, name)
+				command.Labels = labels
+				resp := sc.service.createHandler(sc.reqContext, command)
+				require.Equal(t, 200, resp.Status())
+			}
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?label=tier=gold,env!=dev")
+			require.NoError(t, err)
+			resp := sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			var result libraryPanelsWithLabelsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, "Gold Prod", result.Result.LibraryPanels[0].Name)
+		})
+
+	testScenario(t, "When an admin tries to get all library panels with an invalid label selector, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?label=tier")
+			require.NoError(t, err)
+			resp := sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+
+	testScenario(t, "When an org requires labels, creating or patching a library panel without them should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.setLabelPolicyHandler(sc.reqContext, setLabelPolicyCommand{RequiredLabels: []string{"team", "tier"}})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.getLabelPolicyHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var policy struct {
+				Result LabelPolicyDTO `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &policy)
+			require.NoError(t, err)
+			require.Equal(t, []string{"team", "tier"}, policy.Result.RequiredLabels)
+
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			command.Labels = map[string]string{"team": "a"}
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 400, resp.Status())
+
+			command.Labels = map[string]string{"team": "a", "tier": "gold"}
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelWithLabelsResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": result.Result.UID})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+				FolderID: sc.folder.Id,
+				Labels:   map[string]string{"team": "a"},
+				Version:  1,
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,101 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+type libraryPanelManifestResult struct {
+	Result LibraryPanelManifestDTO `json:"result"`
+}
+
+type libraryPanelManifestVerificationResult struct {
+	Result LibraryPanelManifestVerificationDTO `json:"result"`
+}
+
+func TestLibraryPanelManifest(t *testing.T) {
+	getDashboard := func(uids ...string) *simplejson.Json {
+		panels := make([]interface{}, 0, len(uids))
+		for i, uid := range uids {
+			panels = append(panels, map[string]interface{}{
+				"id": int64(i + 1),
+				"libraryPanel": map[string]interface{}{
+					"uid":  uid,
+					"name": "Library Panel",
+				},
+			})
+		}
+		return simplejson.NewFromAny(map[string]interface{}{"panels": panels})
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin creates a manifest for a dashboard, it should pin the referenced library panels",
+		func(t *testing.T, sc scenarioContext) {
+			uid := sc.initialResult.Result.UID
+			resp := sc.service.createManifestHandler(sc.reqContext, createManifestCommand{Dashboard: getDashboard(uid, uid)})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelManifestResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, uid, result.Result.LibraryPanels[0].UID)
+			require.Equal(t, int64(1), result.Result.LibraryPanels[0].Version)
+			require.Regexp(t, "^sha256:[0-9a-f]{64}$", result.Result.LibraryPanels[0].Hash)
+
+			resp = sc.service.verifyManifestHandler(sc.reqContext, verifyManifestCommand{
+				Dashboard:     getDashboard(uid),
+				LibraryPanels: result.Result.LibraryPanels,
+			})
+			require.Equal(t, 200, resp.Status())
+			var verification libraryPanelManifestVerificationResult
+			err = json.Unmarshal(resp.Body(), &verification)
+			require.NoError(t, err)
+			require.True(t, verification.Result.Valid)
+			require.Len(t, verification.Result.Mismatches, 0)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": uid})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Changed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.verifyManifestHandler(sc.reqContext, verifyManifestCommand{
+				Dashboard:     getDashboard(uid, "unknown"),
+				LibraryPanels: result.Result.LibraryPanels,
+			})
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &verification)
+			require.NoError(t, err)
+			require.False(t, verification.Result.Valid)
+			require.Equal(t, []LibraryPanelManifestMismatchDTO{
+				{UID: uid, Status: manifestStatusVersionMismatch, PinnedVersion: 1, Version: 2},
+				{UID: "unknown", Status: manifestStatusUnpinned},
+			}, verification.Result.Mismatches)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin verifies a manifest with a changed hash or missing library panel, it should report them",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.verifyManifestHandler(sc.reqContext, verifyManifestCommand{
+				LibraryPanels: []LibraryPanelManifestEntryDTO{
+					{UID: sc.initialResult.Result.UID, Version: 1, Hash: "sha256:changed"},
+					{UID: "unknown", Version: 3},
+				},
+			})
+			require.Equal(t, 200, resp.Status())
+			var verification libraryPanelManifestVerificationResult
+			err := json.Unmarshal(resp.Body(), &verification)
+			require.NoError(t, err)
+			require.False(t, verification.Result.Valid)
+			require.Equal(t, []LibraryPanelManifestMismatchDTO{
+				{UID: sc.initialResult.Result.UID, Status: manifestStatusHashMismatch, PinnedVersion: 1, Version: 1},
+				{UID: "unknown", Status: manifestStatusNotFound, PinnedVersion: 3},
+			}, verification.Result.Mismatches)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a manifest for a dashboard referencing a missing library panel, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createManifestHandler(sc.reqContext, createManifestCommand{Dashboard: getDashboard("unknown")})
+			require.Equal(t, 404, resp.Status())
+		})
+}
@@ -0,0 +1,39 @@
+package librarypanels
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func getCounterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	require.NoError(t, counter.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestLibraryPanelMetrics(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When library panels are patched and searched, it should count the operations by status",
+		func(t *testing.T, sc scenarioContext) {
+			patched := getCounterValue(t, libraryPanelOperations.WithLabelValues(operationPatch, "200"))
+			notFound := getCounterValue(t, libraryPanelOperations.WithLabelValues(operationPatch, "404"))
+			searched := getCounterValue(t, libraryPanelOperations.WithLabelValues(operationSearch, "200"))
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "unknown"})
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 404, resp.Status())
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			require.Equal(t, patched+1, getCounterValue(t, libraryPanelOperations.WithLabelValues(operationPatch, "200")))
+			require.Equal(t, notFound+1, getCounterValue(t, libraryPanelOperations.WithLabelValues(operationPatch, "404")))
+			require.Equal(t, searched+1, getCounterValue(t, libraryPanelOperations.WithLabelValues(operationSearch, "200")))
+		})
+}
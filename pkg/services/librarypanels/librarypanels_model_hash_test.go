@@ -0,0 +1,50 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelModelHashResult struct {
+	Result struct {
+		Model     json.RawMessage `json:"model"`
+		ModelHash string          `json:"modelHash"`
+	} `json:"result"`
+}
+
+func TestLibraryPanelModelHash(t *testing.T) {
+	getModelHash := func(t *testing.T, body []byte) libraryPanelModelHashResult {
+		t.Helper()
+
+		var result libraryPanelModelHashResult
+		err := json.Unmarshal(body, &result)
+		require.NoError(t, err)
+		return result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin writes a library panel, it should update the model hash only when the model changes",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			created := getModelHash(t, resp.Body())
+			require.Regexp(t, "^sha256:[0-9a-f]{64}$", created.Result.ModelHash)
+			require.Equal(t, hashModel(created.Result.Model), created.Result.ModelHash)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Version: 1})
+			require.Equal(t, 200, resp.Status())
+			require.Equal(t, created.Result.ModelHash, getModelHash(t, resp.Body()).Result.ModelHash)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 2})
+			require.Equal(t, 200, resp.Status())
+			renamed := getModelHash(t, resp.Body())
+			require.NotEqual(t, created.Result.ModelHash, renamed.Result.ModelHash)
+			require.Equal(t, hashModel(renamed.Result.Model), renamed.Result.ModelHash)
+
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			require.Equal(t, renamed.Result.ModelHash, getModelHash(t, resp.Body()).Result.ModelHash)
+		})
+}
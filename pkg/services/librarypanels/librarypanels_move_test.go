@@ -0,0 +1,102 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestMoveLibraryPanels(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin tries to move several library panels to another folder, it should move them and bump their versions",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Another Panel")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			another := validateAndUnMarshalResponse(t, resp)
+
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			cmd := moveLibraryPanelsCommand{
+				UIDs:     []string{sc.initialResult.Result.UID, another.Result.UID},
+				FolderID: newFolder.Id,
+			}
+			resp = sc.service.moveHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+
+			var result struct {
+				Result []MoveLibraryPanelResultDTO `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []MoveLibraryPanelResultDTO{
+				{UID: sc.initialResult.Result.UID, FolderID: newFolder.Id, Version: 2},
+				{UID: another.Result.UID, FolderID: newFolder.Id, Version: 2},
+			}, result.Result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": another.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			moved := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, newFolder.Id, moved.Result.FolderID)
+			require.Equal(t, int64(2), moved.Result.Version)
+			require.Equal(t, another.Result.Model, moved.Result.Model)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to move library panels and one of them does not exist, it should not move any of them",
+		func(t *testing.T, sc scenarioContext) {
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			cmd := moveLibraryPanelsCommand{
+				UIDs:     []string{sc.initialResult.Result.UID, "unknown"},
+				FolderID: newFolder.Id,
+			}
+			resp := sc.service.moveHandler(sc.reqContext, cmd)
+			require.Equal(t, 404, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, sc.folder.Id, result.Result.FolderID)
+			require.Equal(t, int64(1), result.Result.Version)
+		})
+
+	scenarioWithLibraryPanel(t, "When an editor tries to move library panels to a folder they can't edit, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{
+				{models.ROLE_EDITOR, models.PERMISSION_VIEW},
+			})
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			cmd := moveLibraryPanelsCommand{
+				UIDs:     []string{sc.initialResult.Result.UID},
+				FolderID: newFolder.Id,
+			}
+			resp := sc.service.moveHandler(sc.reqContext, cmd)
+			require.Equal(t, 403, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a dashboard is moved with its library panels, it should only move the library panels no other dashboard uses",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Shared Panel")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			shared := validateAndUnMarshalResponse(t, resp)
+
+			dash := createDashboard(t, sc.sqlStore, sc.user, "Moved Dashboard", sc.folder.Id)
+			other := createDashboard(t, sc.sqlStore, sc.user, "Other Dashboard", sc.folder.Id)
+			require.NoError(t, sc.service.connectDashboard(sc.reqContext, sc.initialResult.Result.UID, dash.Id))
+			require.NoError(t, sc.service.connectDashboard(sc.reqContext, shared.Result.UID, dash.Id))
+			require.NoError(t, sc.service.connectDashboard(sc.reqContext, shared.Result.UID, other.Id))
+
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			dash.FolderId = newFolder.Id
+			moved, err := sc.service.MoveLibraryPanelsWithDashboard(sc.reqContext, dash, sc.folder.Id)
+			require.NoError(t, err)
+			require.Equal(t, []MoveLibraryPanelResultDTO{
+				{UID: sc.initialResult.Result.UID, FolderID: newFolder.Id, Version: 2},
+			}, moved)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": shared.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			notMoved := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, sc.folder.Id, notMoved.Result.FolderID)
+			require.Equal(t, int64(1), notMoved.Result.Version)
+		})
+}
@@ -0,0 +1,147 @@
+package librarypanels
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestApplyConnectionOverrides(t *testing.T) {
+	model := `{"title": "CPU", "type": "stat", "repeat": "host", "fieldConfig": {"defaults": {"unit": "percent", "thresholds": {"steps": [{"value": null}, {"value": 80}]}}}}`
+	testCases := []struct {
+		desc      string
+		overrides string
+		expected  string
+		err       error
+	}{
+		{
+			desc:      "merge nested objects",
+			overrides: `{"title": "CPU (prod)", "fieldConfig": {"defaults": {"thresholds": {"steps": [{"value": null}, {"value": 90}]}}}}`,
+			expected:  `{"title": "CPU (prod)", "type": "stat", "repeat": "host", "fieldConfig": {"defaults": {"unit": "percent", "thresholds": {"steps": [{"value": null}, {"value": 90}]}}}}`,
+		},
+		{
+			desc:      "null removes a property",
+			overrides: `{"repeat": null}`,
+			expected:  `{"title": "CPU", "type": "stat", "fieldConfig": {"defaults": {"unit": "percent", "thresholds": {"steps": [{"value": null}, {"value": 80}]}}}}`,
+		},
+		{
+			desc:      "unsupported property",
+			overrides: `{"type": "table"}`,
+			err:       errLibraryPanelInvalidOverrides,
+		},
+		{
+			desc:      "not an object",
+			overrides: `["title"]`,
+			err:       errLibraryPanelInvalidOverrides,
+		},
+		{
+			desc:      "too large",
+			overrides: `{"description": "` + strings.Repeat("a", maxConnectionOverridesSize) + `"}`,
+			err:       errLibraryPanelInvalidOverrides,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			overrides, err := validateConnectionOverrides([]byte(tc.overrides))
+			if tc.err != nil {
+				require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+				return
+			}
+			require.NoError(t, err)
+
+			merged, err := applyConnectionOverrides([]byte(model), overrides)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(merged))
+		})
+	}
+}
+
+func getDashboardWithLibraryPanel(sc scenarioContext, id int64) models.Dashboard {
+	return models.Dashboard{
+		Id: id,
+		Data: simplejson.NewFromAny(map[string]interface{}{
+			"panels": []interface{}{
+				map[string]interface{}{
+					"id":      int64(1),
+					"gridPos": map[string]interface{}{"h": 6, "w": 6, "x": 0, "y": 0},
+					"libraryPanel": map[string]interface{}{
+						"uid":  sc.initialResult.Result.UID,
+						"name": sc.initialResult.Result.Name,
+					},
+				},
+			},
+		}),
+	}
+}
+
+func TestConnectionOverrides(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin sets overrides on a connection, it should merge them when the dashboard is loaded and keep them when it is saved",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.setConnectionOverridesHandler(sc.reqContext, setConnectionOverridesCommand{
+				Overrides: []byte(`{"title": "Text (prod)", "repeat": "host"}`),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			// saving the dashboard recreates its connections
+			err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			panel := dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, "Text (prod)", panel.Get("title").MustString())
+			require.Equal(t, "host", panel.Get("repeat").MustString())
+			require.Equal(t, "A description", panel.Get("description").MustString())
+			require.Equal(t, sc.initialResult.Result.Name, panel.Get("libraryPanel").Get("name").MustString())
+			require.Equal(t, "Text (prod)", panel.Get("libraryPanel").Get("overrides").Get("title").MustString())
+
+			result := getConnections(t, sc, "")
+			require.Len(t, result.Connections, 1)
+			require.JSONEq(t, `{"title": "Text (prod)", "repeat": "host"}`, string(result.Connections[0].Overrides))
+
+			resp = sc.service.setConnectionOverridesHandler(sc.reqContext, setConnectionOverridesCommand{})
+			require.Equal(t, 200, resp.Status())
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			panel = dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, "Text - Library Panel", panel.Get("title").MustString())
+			require.Nil(t, panel.Get("libraryPanel").Get("overrides").Interface())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin sets invalid overrides or overrides on a missing connection, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.setConnectionOverridesHandler(sc.reqContext, setConnectionOverridesCommand{
+				Overrides: []byte(`{"title": "Text (prod)"}`),
+			})
+			require.Equal(t, 404, resp.Status())
+
+			resp = sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.setConnectionOverridesHandler(sc.reqContext, setConnectionOverridesCommand{
+				Overrides: []byte(`{"targets": []}`),
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+}
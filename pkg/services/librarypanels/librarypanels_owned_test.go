@@ -0,0 +1,50 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestGetOwnedLibraryPanels(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin gets owned library panels, it should only return library panels they created",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.SignedInUser.UserId = 2
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "Someone else's"))
+			require.Equal(t, 200, resp.Status())
+			sc.reqContext.SignedInUser.UserId = 1
+
+			resp = sc.service.getOwnedHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelsSearch
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, sc.initialResult.Result.UID, result.Result.LibraryPanels[0].UID)
+
+			err = sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Set("userId", "2")
+			resp = sc.service.getOwnedHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, "Someone else's", result.Result.LibraryPanels[0].Name)
+		})
+
+	scenarioWithLibraryPanel(t, "When an editor gets library panels owned by another user, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?userId=2")
+			require.NoError(t, err)
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_EDITOR
+			resp := sc.service.getOwnedHandler(sc.reqContext)
+			require.Equal(t, 403, resp.Status())
+		})
+}
@@ -0,0 +1,272 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+)
+
+type libraryPanelPackResult struct {
+	Result LibraryPanelPackDTO `json:"result"`
+}
+
+type libraryPanelPackInstallResult struct {
+	Result LibraryPanelPackInstallResultDTO `json:"result"`
+}
+
+func TestLibraryPanelPacks(t *testing.T) {
+	exportPack := func(t *testing.T, sc scenarioContext) LibraryPanelPackDTO {
+		t.Helper()
+
+		dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+		dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+		dash.Data.Set("id", dashInDB.Id)
+		dash.Data.Set("uid", dashInDB.Uid)
+		dash.Data.Set("title", dashInDB.Title)
+		_, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+			Dashboard: dash.Data,
+			OrgId:     sc.user.OrgId,
+			UserId:    sc.user.UserId,
+			FolderId:  sc.folder.Id,
+			Overwrite: true,
+		})
+		require.NoError(t, err)
+
+		resp := sc.service.exportPackHandler(sc.reqContext, exportPackCommand{Name: "Starter kit", DashboardUIDs: []string{dashInDB.Uid}})
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelPackResult
+		err = json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin exports a pack with pinned versions, it should contain the library panels at those versions",
+		func(t *testing.T, sc scenarioContext) {
+			dashboardUID := exportPack(t, sc).Dashboards[0].Dashboard.Get("uid").MustString()
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+				FolderID: -1,
+				Name:     "Renamed",
+				Model:    []byte(`{"type": "text", "description": "Version 2"}`),
+				Version:  1,
+			})
+			require.Equal(t, 200, resp.Status())
+
+			for version, expected := range map[int64]string{1: "A description", 2: "Version 2"} {
+				resp = sc.service.exportPackHandler(sc.reqContext, exportPackCommand{
+					DashboardUIDs: []string{dashboardUID},
+					Versions:      map[string]int64{sc.initialResult.Result.UID: version},
+				})
+				require.Equal(t, 200, resp.Status())
+				var result libraryPanelPackResult
+				err := json.Unmarshal(resp.Body(), &result)
+				require.NoError(t, err)
+				require.Len(t, result.Result.LibraryPanels, 1)
+				var model map[string]interface{}
+				err = json.Unmarshal(result.Result.LibraryPanels[0].Model, &model)
+				require.NoError(t, err)
+				require.Equal(t, expected, model["description"])
+			}
+
+			resp = sc.service.exportPackHandler(sc.reqContext, exportPackCommand{
+				DashboardUIDs: []string{dashboardUID},
+				Versions:      map[string]int64{sc.initialResult.Result.UID: 3},
+			})
+			require.Equal(t, 404, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin exports a pack, it should contain the dashboards, library panels and folders",
+		func(t *testing.T, sc scenarioContext) {
+			pack := exportPack(t, sc)
+			require.Equal(t, "Starter kit", pack.Name)
+			require.Equal(t, []LibraryPanelPackFolderDTO{{UID: sc.folder.Uid, Title: sc.folder.Title}}, pack.Folders)
+			require.Len(t, pack.LibraryPanels, 1)
+			require.Equal(t, sc.initialResult.Result.UID, pack.LibraryPanels[0].UID)
+			require.Equal(t, sc.folder.Uid, pack.LibraryPanels[0].FolderUID)
+			require.Len(t, pack.Dashboards, 1)
+			require.Equal(t, sc.folder.Uid, pack.Dashboards[0].FolderUID)
+			require.Nil(t, pack.Dashboards[0].Dashboard.Get("id").Interface())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin exports a pack with a redaction profile, it should strip internal details",
+		func(t *testing.T, sc scenarioContext) {
+			dashboardUID := exportPack(t, sc).Dashboards[0].Dashboard.Get("uid").MustString()
+
+			resp := sc.service.exportPackHandler(sc.reqContext, exportPackCommand{
+				DashboardUIDs:      []string{dashboardUID},
+				IncludeConnections: true,
+				RedactionProfile:   "partner",
+			})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelPackResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Empty(t, result.Result.Connections)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			var model map[string]interface{}
+			err = json.Unmarshal(result.Result.LibraryPanels[0].Model, &model)
+			require.NoError(t, err)
+			require.NotContains(t, model, "description")
+			require.Equal(t, "${DS_GDEV-TESTDATA}", model["datasource"])
+
+			resp = sc.service.exportPackHandler(sc.reqContext, exportPackCommand{
+				DashboardUIDs:    []string{dashboardUID},
+				RedactionProfile: "everything",
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin installs a pack into a remapped folder, it should create the library panels and connect the dashboards",
+		func(t *testing.T, sc scenarioContext) {
+			pack := exportPack(t, sc)
+			pack.Dashboards[0].Dashboard.Del("uid")
+			target, err := dashboards.NewFolderService(sc.user.OrgId, &sc.user, sc.sqlStore).CreateFolder("Team", "team")
+			require.NoError(t, err)
+
+			cmd := installPackCommand{Pack: pack, FolderUIDs: map[string]string{sc.folder.Uid: target.Uid}}
+			resp := sc.service.installPackHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelPackInstallResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{sc.folder.Uid: target.Uid}, result.Result.FolderUIDs)
+			installedUID := result.Result.LibraryPanelUIDs[sc.initialResult.Result.UID]
+			require.NotEqual(t, sc.initialResult.Result.UID, installedUID)
+			require.Len(t, result.Result.DashboardUIDs, 1)
+
+			installed, err := sc.service.getLibraryPanel(sc.reqContext, installedUID)
+			require.NoError(t, err)
+			require.Equal(t, target.Id, installed.FolderID)
+			dash, err := sc.sqlStore.GetDashboard(0, sc.user.OrgId, result.Result.DashboardUIDs[0], "")
+			require.NoError(t, err)
+			require.Equal(t, target.Id, dash.FolderId)
+			require.Equal(t, installedUID, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Get("uid").MustString())
+			connected, err := sc.service.getConnectedDashboards(sc.reqContext, installedUID)
+			require.NoError(t, err)
+			require.Equal(t, []int64{dash.Id}, connected)
+
+			// installing the pack again reuses the installed library panel
+			cmd.Overwrite = true
+			resp = sc.service.installPackHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+			var again libraryPanelPackInstallResult
+			err = json.Unmarshal(resp.Body(), &again)
+			require.NoError(t, err)
+			require.Equal(t, installedUID, again.Result.LibraryPanelUIDs[sc.initialResult.Result.UID])
+			dash, err = sc.sqlStore.GetDashboard(0, sc.user.OrgId, again.Result.DashboardUIDs[0], "")
+			require.NoError(t, err)
+			require.Equal(t, installedUID, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Get("uid").MustString())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin installs a pack with remapped library panels, it should reference the existing library panels",
+		func(t *testing.T, sc scenarioContext) {
+			pack := exportPack(t, sc)
+			pack.Dashboards[0].Dashboard.Del("uid")
+			pack.LibraryPanels = nil
+
+			resp := sc.service.installPackHandler(sc.reqContext, installPackCommand{
+				Pack:             pack,
+				LibraryPanelUIDs: map[string]string{sc.initialResult.Result.UID: sc.initialResult.Result.UID},
+				Overwrite:        true,
+			})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelPackInstallResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, map[string]string{sc.folder.Uid: sc.folder.Uid}, result.Result.FolderUIDs)
+			require.Equal(t, sc.initialResult.Result.UID, result.Result.LibraryPanelUIDs[sc.initialResult.Result.UID])
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin exports and installs a pack with connections, it should restore the connections to existing dashboards",
+		func(t *testing.T, sc scenarioContext) {
+			other := createDashboard(t, sc.sqlStore, sc.user, "Other", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, other.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			pack := exportPack(t, sc)
+			require.Empty(t, pack.Connections)
+
+			resp := sc.service.exportPackHandler(sc.reqContext, exportPackCommand{
+				DashboardUIDs:      []string{pack.Dashboards[0].Dashboard.Get("uid").MustString()},
+				IncludeConnections: true,
+			})
+			require.Equal(t, 200, resp.Status())
+			var exported libraryPanelPackResult
+			err = json.Unmarshal(resp.Body(), &exported)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelPackConnectionDTO{
+				{DashboardUID: other.Uid, LibraryPanelUID: sc.initialResult.Result.UID},
+			}, exported.Result.Connections)
+
+			pack.Dashboards = nil
+			pack.Connections = append(exported.Result.Connections, LibraryPanelPackConnectionDTO{
+				DashboardUID: "missing", LibraryPanelUID: sc.initialResult.Result.UID,
+			})
+			target, err := dashboards.NewFolderService(sc.user.OrgId, &sc.user, sc.sqlStore).CreateFolder("Team", "team")
+			require.NoError(t, err)
+			resp = sc.service.installPackHandler(sc.reqContext, installPackCommand{Pack: pack, FolderUIDs: map[string]string{sc.folder.Uid: target.Uid}})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelPackInstallResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, 1, result.Result.Connections)
+			require.Equal(t, 1, result.Result.SkippedConnections)
+
+			connected, err := sc.service.getConnectedDashboards(sc.reqContext, result.Result.LibraryPanelUIDs[sc.initialResult.Result.UID])
+			require.NoError(t, err)
+			require.Equal(t, []int64{other.Id}, connected)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin installs a pack that refers to library panels it doesn't contain, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			dashboard := simplejson.NewFromAny(map[string]interface{}{
+				"title": "Dash",
+				"panels": []interface{}{
+					map[string]interface{}{"id": 1, "libraryPanel": map[string]interface{}{"uid": "unknown"}},
+				},
+			})
+			resp := sc.service.installPackHandler(sc.reqContext, installPackCommand{
+				Pack: LibraryPanelPackDTO{Dashboards: []LibraryPanelPackDashboardDTO{{Dashboard: dashboard}}},
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+}
+
+func TestRedactPack(t *testing.T) {
+	element := LibraryPanelPackElementDTO{
+		Model: []byte(`{"type": "graph", "description": "Internal", "datasource": "prod-prometheus",
+			"targets": [{"datasource": {"type": "prometheus", "uid": "P1809F7CD0C75ACF3"}}, {"datasource": "default"}]}`),
+		Labels: map[string]string{"team": "a"},
+	}
+	err := redactLibraryPanel(&element, packRedaction{DatasourceUIDs: true, Owners: true})
+	require.NoError(t, err)
+	require.Nil(t, element.Labels)
+	require.JSONEq(t, `{"type": "graph", "description": "Internal",
+		"targets": [{"datasource": {"type": "prometheus"}}, {"datasource": "default"}]}`, string(element.Model))
+
+	dash := simplejson.NewFromAny(map[string]interface{}{
+		"description": "Internal",
+		"panels": []interface{}{
+			map[string]interface{}{"type": "row", "panels": []interface{}{
+				map[string]interface{}{"description": "Internal", "datasource": "prod-prometheus"},
+			}},
+		},
+		"templating": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{"name": "host", "datasource": "${DS_PROMETHEUS}", "description": "Internal"},
+			},
+		},
+	})
+	redactDashboard(dash, packRedaction{Descriptions: true})
+	dashJSON, err := dash.MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"panels": [{"type": "row", "panels": [{"datasource": "prod-prometheus"}]}],
+		"templating": {"list": [{"name": "host", "datasource": "${DS_PROMETHEUS}"}]}
+	}`, string(dashJSON))
+}
@@ -1,10 +1,15 @@
 package librarypanels
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 )
 
 func TestPatchLibraryPanel(t *testing.T) {
@@ -63,6 +68,8 @@ func TestPatchLibraryPanel(t *testing.T) {
 					Version: 2,
 					Meta: LibraryPanelDTOMeta{
 						CanEdit:             true,
+						FolderName:          "NewFolder",
+						FolderUID:           newFolder.Uid,
 						ConnectedDashboards: 2,
 						Created:             sc.initialResult.Result.Meta.Created,
 						Updated:             result.Result.Meta.Updated,
@@ -96,6 +103,8 @@ func TestPatchLibraryPanel(t *testing.T) {
 			require.Equal(t, 200, resp.Status())
 			var result = validateAndUnMarshalResponse(t, resp)
 			sc.initialResult.Result.FolderID = newFolder.Id
+			sc.initialResult.Result.Meta.FolderName = "NewFolder"
+			sc.initialResult.Result.Meta.FolderUID = newFolder.Uid
 			sc.initialResult.Result.Meta.CreatedBy.Name = UserInDbName
 			sc.initialResult.Result.Meta.CreatedBy.AvatarUrl = UserInDbAvatar
 			sc.initialResult.Result.Version = 2
@@ -269,4 +278,56 @@ func TestPatchLibraryPanel(t *testing.T) {
 			resp = sc.service.patchHandler(sc.reqContext, cmd)
 			require.Equal(t, 412, resp.Status())
 		})
+
+	scenarioWithLibraryPanel(t, "When an admin tries to patch a library panel model with an old version number, it should return the current version and a diff",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Version: 1, Model: []byte(`{"type": "text", "description": "Theirs"}`)})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Version: 1, Model: []byte(`{"type": "text", "description": "Mine"}`)})
+			require.Equal(t, 412, resp.Status())
+			var result struct {
+				Message string                 `json:"message"`
+				Version int64                  `json:"version"`
+				Diff    map[string]interface{} `json:"diff"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(2), result.Version)
+			require.Equal(t, []interface{}{"Theirs", "Mine"}, result.Diff["description"])
+		})
+
+	scenarioWithLibraryPanel(t, "When a viewer tries to patch a library panel with an old version number, it should fail without returning a diff",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Version: 1, Model: []byte(`{"type": "text", "description": "Secret"}`)})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Version: 1, Model: []byte(`{}`)})
+			require.Equal(t, 403, resp.Status())
+			require.NotContains(t, string(resp.Body()), "Secret")
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel twice, it should record every version in the history",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "First", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Second", Version: 2})
+			require.Equal(t, 200, resp.Status())
+
+			var versions []libraryPanelVersion
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				sql := "SELECT * FROM library_panel_version WHERE librarypanel_id=? ORDER BY version"
+				return session.SQL(sql, sc.initialResult.Result.ID).Find(&versions)
+			})
+			require.NoError(t, err)
+			require.Len(t, versions, 3)
+			for i, version := range versions {
+				require.Equal(t, int64(i+1), version.Version)
+			}
+			require.Equal(t, "Second", versions[2].Name)
+		})
 }
@@ -483,3 +483,91 @@ func TestLibraryPanelPermissions(t *testing.T) {
 			})
 	}
 }
+
+type libraryPanelACLResult struct {
+	Result []LibraryPanelACLItemDTO `json:"result"`
+}
+
+func TestLibraryPanelACL(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a viewer is granted edit on a library panel, they should be able to patch it without edit on the folder",
+		func(t *testing.T, sc scenarioContext) {
+			folder := createFolderWithACL(t, sc.sqlStore, "ReadOnly", sc.user, []folderACLItem{{models.ROLE_VIEWER, models.PERMISSION_VIEW}})
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(folder.Id, "Shared Panel"))
+			created := validateAndUnMarshalResponse(t, resp)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 403, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_ADMIN
+			resp = sc.service.setPermissionsHandler(sc.reqContext, setLibraryPanelPermissionsCommand{
+				Items: []LibraryPanelACLItemDTO{{UserID: sc.user.UserId, Permission: models.PERMISSION_EDIT}},
+			})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getPermissionsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelACLResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelACLItemDTO{
+				{UserID: sc.user.UserId, Permission: models.PERMISSION_EDIT, PermissionName: "Edit"},
+			}, result.Result)
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.setPermissionsHandler(sc.reqContext, setLibraryPanelPermissionsCommand{})
+			require.Equal(t, 403, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin sets a permission without a user or team, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.setPermissionsHandler(sc.reqContext, setLibraryPanelPermissionsCommand{
+				Items: []LibraryPanelACLItemDTO{{Permission: models.PERMISSION_EDIT}},
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+}
+
+func TestLibraryPanelSharedWithTeam(t *testing.T) {
+	testScenario(t, "When a library panel is shared with a team, its members should find it without view on the folder",
+		func(t *testing.T, sc scenarioContext) {
+			folder := createFolderWithACL(t, sc.sqlStore, "Private", sc.user, []folderACLItem{{models.ROLE_ADMIN, models.PERMISSION_EDIT}})
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(folder.Id, "Team Panel"))
+			created := validateAndUnMarshalResponse(t, resp)
+			team, err := sc.sqlStore.CreateTeam("Team", "", sc.user.OrgId)
+			require.NoError(t, err)
+			err = sc.sqlStore.AddTeamMember(sc.user.UserId, sc.user.OrgId, team.Id, false, 0)
+			require.NoError(t, err)
+
+			search := func() libraryPanelsSearch {
+				resp := sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+				var result libraryPanelsSearch
+				err := json.Unmarshal(resp.Body(), &result)
+				require.NoError(t, err)
+				return result
+			}
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			require.Len(t, search().Result.LibraryPanels, 0)
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_ADMIN
+			resp = sc.service.setPermissionsHandler(sc.reqContext, setLibraryPanelPermissionsCommand{
+				Items: []LibraryPanelACLItemDTO{{TeamID: team.Id, Permission: models.PERMISSION_VIEW}},
+			})
+			require.Equal(t, 200, resp.Status())
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			result := search()
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, created.Result.UID, result.Result.LibraryPanels[0].UID)
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+}
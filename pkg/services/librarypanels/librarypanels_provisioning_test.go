@@ -0,0 +1,95 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestProvisionLibraryPanel(t *testing.T) {
+	testScenario(t, "When a library panel is provisioned for the first time, it should be created in the folder",
+		func(t *testing.T, sc scenarioContext) {
+			cmd := ProvisionLibraryPanelCommand{
+				OrgID:     sc.user.OrgId,
+				FolderUID: sc.folder.Uid,
+				UID:       "provisioned-panel",
+				Name:      "Provisioned Panel",
+				Model:     []byte(`{"type": "text", "description": "A description"}`),
+			}
+			err := sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "provisioned-panel"})
+			resp := sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, sc.folder.Id, result.Result.FolderID)
+			require.Equal(t, "Provisioned Panel", result.Result.Name)
+			require.Equal(t, "text", result.Result.Type)
+			require.Equal(t, int64(1), result.Result.Version)
+		})
+
+	testScenario(t, "When a provisioned library panel changes, it should be updated and its version bumped",
+		func(t *testing.T, sc scenarioContext) {
+			cmd := ProvisionLibraryPanelCommand{
+				OrgID: sc.user.OrgId,
+				UID:   "provisioned-panel",
+				Name:  "Provisioned Panel",
+				Model: []byte(`{"type": "text"}`),
+			}
+			err := sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.NoError(t, err)
+
+			cmd.Model = []byte(`{"type": "graph"}`)
+			err = sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.NoError(t, err)
+			// provisioning an unchanged panel should not bump the version
+			err = sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "provisioned-panel"})
+			resp := sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(0), result.Result.FolderID)
+			require.Equal(t, "graph", result.Result.Type)
+			require.Equal(t, int64(2), result.Result.Version)
+		})
+
+	testScenario(t, "When a library panel is provisioned into a folder that does not exist, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			cmd := ProvisionLibraryPanelCommand{
+				OrgID:     sc.user.OrgId,
+				FolderUID: "unknown-folder",
+				UID:       "provisioned-panel",
+				Name:      "Provisioned Panel",
+				Model:     []byte(`{"type": "text"}`),
+			}
+			err := sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.ErrorIs(t, err, models.ErrFolderNotFound)
+		})
+	testScenario(t, "When an admin tries to patch or delete a provisioned library panel, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			cmd := ProvisionLibraryPanelCommand{
+				OrgID: sc.user.OrgId,
+				UID:   "provisioned-panel",
+				Name:  "Provisioned Panel",
+				Model: []byte(`{"type": "text"}`),
+			}
+			err := sc.service.ProvisionLibraryPanel(context.Background(), cmd)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "provisioned-panel"})
+			resp := sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.True(t, result.Result.Meta.Provisioned)
+
+			patchCmd := patchLibraryPanelCommand{FolderID: -1, Name: "Changed Name", Version: 1}
+			resp = sc.service.patchHandler(sc.reqContext, patchCmd)
+			require.Equal(t, 400, resp.Status())
+
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
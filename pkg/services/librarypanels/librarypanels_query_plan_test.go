@@ -0,0 +1,148 @@
+package librarypanels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// To run these tests, start one of the databases in devenv/docker/blocks and run
+// GRAFANA_TEST_DB=mysql go test -run TestLibraryPanelQueryPlans ./pkg/services/librarypanels
+// or GRAFANA_TEST_DB=postgres. Query plans on SQLite are not checked.
+func TestLibraryPanelQueryPlans(t *testing.T) {
+	if !sqlstore.IsTestDbMySQL() && !sqlstore.IsTestDbPostgres() {
+		t.Skip()
+	}
+
+	queries := []struct {
+		desc  string
+		query searchLibraryPanelsQuery
+		// fullScan exempts a search that is expected to scan library_panel in full, it's still explained so it
+		// stays valid SQL.
+		fullScan bool
+	}{
+		{desc: "search", query: searchLibraryPanelsQuery{perPage: 100, page: 1}},
+		{desc: "search with search string", query: searchLibraryPanelsQuery{perPage: 100, page: 1, searchString: "Panel"}},
+		{desc: "search in models", query: searchLibraryPanelsQuery{perPage: 100, page: 1, searchString: "rate(", searchIn: searchInModel}},
+		{desc: "search by datasource", query: searchLibraryPanelsQuery{perPage: 100, page: 1, datasourceRefs: []string{"uid", "name"}}},
+		{desc: "search with panel filter", query: searchLibraryPanelsQuery{perPage: 100, page: 1, panelFilter: "text,graph"}},
+		{desc: "search with folder filter", query: searchLibraryPanelsQuery{perPage: 100, page: 1, folderFilter: "0,1"}},
+		{desc: "search with label selector", query: searchLibraryPanelsQuery{perPage: 100, page: 1, labelSelector: "team=a,env!=dev"}},
+		{desc: "search with tags", query: searchLibraryPanelsQuery{perPage: 100, page: 1, tags: []string{"prod", "team-a"}}},
+		{desc: "search excluding a panel", query: searchLibraryPanelsQuery{perPage: 100, page: 1, excludeUID: "uid"}},
+		{desc: "search connected", query: searchLibraryPanelsQuery{perPage: 100, page: 1, connected: "true"}},
+		{desc: "search not connected", query: searchLibraryPanelsQuery{perPage: 100, page: 1, connected: "false"}},
+		{desc: "search not connected since", query: searchLibraryPanelsQuery{perPage: 100, page: 1, notConnectedSince: "30d"}},
+		{desc: "search not updated since", query: searchLibraryPanelsQuery{perPage: 100, page: 1, notUpdatedSince: "90d"}},
+		{desc: "search not viewed since", query: searchLibraryPanelsQuery{perPage: 100, page: 1, notViewedSince: "30d"}},
+		{desc: "search by creator", query: searchLibraryPanelsQuery{perPage: 100, page: 1, createdBy: 1}},
+		{desc: "search sorted descending", query: searchLibraryPanelsQuery{perPage: 100, page: 1, sortDirection: search.SortAlphaDesc.Name}},
+		{desc: "search sorted by folder and update", query: searchLibraryPanelsQuery{perPage: 100, page: 1, sort: "folder:asc,updated:desc"}},
+		{desc: "search sorted by type and creation", query: searchLibraryPanelsQuery{perPage: 100, page: 1, sort: "type,created:desc"}},
+		{desc: "search sorted by last use", query: searchLibraryPanelsQuery{perPage: 100, page: 1, sort: "lastconnected:desc,lastviewed"}},
+		{desc: "search with continueToken", query: searchLibraryPanelsQuery{perPage: 100, continueToken: encodeContinueToken("Panel", "uid")}},
+		// searching all orgs is a server admin tool that pages through every library panel, which is a full scan
+		{desc: "search all orgs", query: searchLibraryPanelsQuery{perPage: 100, page: 1, allOrgs: true}, fullScan: true},
+	}
+
+	scenarioWithLibraryPanel(t, "When searching or hydrating library panels, library_panel should never be scanned in full",
+		func(t *testing.T, sc scenarioContext) {
+			createQueryPlanFixtures(t, sc)
+
+			for _, role := range []models.RoleType{models.ROLE_ADMIN, models.ROLE_EDITOR} {
+				user := *sc.reqContext.SignedInUser
+				user.OrgRole = role
+				for _, q := range queries {
+					builder, countBuilder, err := sc.service.buildSearchLibraryPanelsSQL(&user, q.query, selectLibrayPanelDTOForSearch)
+					require.NoError(t, err)
+					if q.fullScan {
+						explain(t, sc, builder.GetSQLString(), builder.GetParams()...)
+						explain(t, sc, countBuilder.GetSQLString(), countBuilder.GetParams()...)
+						continue
+					}
+					requireNoFullScan(t, sc, string(role)+" "+q.desc, builder.GetSQLString(), builder.GetParams()...)
+					requireNoFullScan(t, sc, string(role)+" "+q.desc+" count", countBuilder.GetSQLString(), countBuilder.GetParams()...)
+				}
+			}
+
+			requireNoFullScan(t, sc, "hydrate by uid", sqlStatmentLibraryPanelByUID, sc.initialResult.Result.UID, sc.user.OrgId)
+			requireNoFullScan(t, sc, "hydrate for dashboard", sqlStatmentLibraryPanelsForDashboard, int64(1), int64(1))
+		})
+}
+
+// createQueryPlanFixtures spreads library panels over many orgs so that the planner has a reason to use the indices
+// on library_panel.
+func createQueryPlanFixtures(t *testing.T, sc scenarioContext) {
+	t.Helper()
+
+	orgID := sc.reqContext.SignedInUser.OrgId
+	t.Cleanup(func() {
+		sc.reqContext.SignedInUser.OrgId = orgID
+	})
+	for org := int64(2); org <= 25; org++ {
+		sc.reqContext.SignedInUser.OrgId = org
+		for i := 0; i < 8; i++ {
+			command := getCreateCommand(0, "Panel "+strings.Repeat("x", i))
+			command.Labels = map[string]string{"team": "a"}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+		}
+	}
+
+	err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+		for _, table := range []string{"library_panel", "library_panel_label", "library_panel_tag", "library_panel_datasource", "library_panel_dashboard", "library_panel_view"} {
+			if _, err := session.Exec("ANALYZE " + analyzeTableKeyword() + table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func analyzeTableKeyword() string {
+	if sqlstore.IsTestDbMySQL() {
+		return "TABLE "
+	}
+	return ""
+}
+
+// explain runs EXPLAIN on a query and returns the plan.
+func explain(t *testing.T, sc scenarioContext, sql string, params ...interface{}) []map[string][]byte {
+	t.Helper()
+
+	var plan []map[string][]byte
+	err := sc.sqlStore.WithTransactionalDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+		if sqlstore.IsTestDbPostgres() {
+			// tiny tables are cheaper to scan, so tell the planner to only scan when there's no usable index
+			if _, err := session.Exec("SET LOCAL enable_seqscan = off"); err != nil {
+				return err
+			}
+		}
+		var err error
+		plan, err = session.Query(append([]interface{}{"EXPLAIN " + sql}, params...)...)
+		return err
+	})
+	require.NoError(t, err)
+	return plan
+}
+
+// requireNoFullScan runs EXPLAIN on a query and fails if the plan scans library_panel in full.
+func requireNoFullScan(t *testing.T, sc scenarioContext, desc string, sql string, params ...interface{}) {
+	t.Helper()
+
+	for _, row := range explain(t, sc, sql, params...) {
+		if sqlstore.IsTestDbMySQL() {
+			require.False(t, string(row["table"]) == "lp" && string(row["type"]) == "ALL",
+				"%s: full scan of library_panel in plan %v", desc, row)
+			continue
+		}
+		require.NotContains(t, string(row["QUERY PLAN"]), "Seq Scan on library_panel lp",
+			"%s: full scan of library_panel", desc)
+	}
+}
@@ -0,0 +1,128 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestLibraryPanelQuota(t *testing.T) {
+	enableQuota := func(sc scenarioContext, orgLimit int64, userLimit int64) {
+		sc.service.Cfg.Quota = setting.QuotaSettings{
+			Enabled: true,
+			Org:     &setting.OrgQuota{LibraryPanel: orgLimit},
+			User:    &setting.UserQuota{LibraryPanel: userLimit},
+			Global:  &setting.GlobalQuota{LibraryPanel: -1},
+		}
+		sc.service.QuotaService = &quota.QuotaService{Cfg: sc.service.Cfg}
+		sc.reqContext.IsSignedIn = true
+		sc.reqContext.Logger = log.New("test")
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel and the org quota is reached, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, 1, -1)
+			command := getCreateCommand(sc.folder.Id, "Over quota")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 403, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel and the org quota isn't reached, it should succeed",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, 2, -1)
+			command := getCreateCommand(sc.folder.Id, "Within quota")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			command = getCreateCommand(sc.folder.Id, "Over quota")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 403, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel and the user quota is reached, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, -1, 1)
+			command := getCreateCommand(sc.folder.Id, "Over quota")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 403, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deletes a library panel, it should no longer count towards the org quota",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, 1, -1)
+			query := models.GetOrgQuotaByTargetQuery{OrgId: sc.reqContext.OrgId, Target: sqlstore.LIBRARY_PANEL_TARGET, IsPanelLibraryEnabled: true}
+			err := sqlstore.GetOrgQuotaByTarget(&query)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), query.Result.Used)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			err = sqlstore.GetOrgQuotaByTarget(&query)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), query.Result.Used)
+
+			command := getCreateCommand(sc.folder.Id, "Within quota")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin deletes a library panel, it should no longer count towards the user quota",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, -1, 1)
+			query := models.GetUserQuotaByTargetQuery{UserId: sc.reqContext.UserId, Target: sqlstore.LIBRARY_PANEL_TARGET, IsPanelLibraryEnabled: true}
+			err := sqlstore.GetUserQuotaByTarget(&query)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), query.Result.Used)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			err = sqlstore.GetUserQuotaByTarget(&query)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), query.Result.Used)
+
+			command := getCreateCommand(sc.folder.Id, "Within quota")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When the quota is checked in the transaction of an insert, it should count the inserted library panel",
+		func(t *testing.T, sc scenarioContext) {
+			enableQuota(sc, 2, -1)
+			err := sc.service.SQLStore.WithTransactionalDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				limitReached, err := sc.service.QuotaService.QuotaReachedWithSession(sc.reqContext, session, sqlstore.LIBRARY_PANEL_TARGET)
+				require.NoError(t, err)
+				require.False(t, limitReached)
+
+				_, err = session.Insert(&LibraryPanel{
+					OrgID:     sc.reqContext.OrgId,
+					FolderID:  sc.folder.Id,
+					UID:       "in-transaction",
+					Name:      "In transaction",
+					Model:     []byte("{}"),
+					Created:   time.Now(),
+					Updated:   time.Now(),
+					CreatedBy: sc.reqContext.UserId,
+					UpdatedBy: sc.reqContext.UserId,
+				})
+				require.NoError(t, err)
+
+				limitReached, err = sc.service.QuotaService.QuotaReachedWithSession(sc.reqContext, session, sqlstore.LIBRARY_PANEL_TARGET)
+				require.NoError(t, err)
+				require.True(t, limitReached)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+}
@@ -0,0 +1,99 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelRecommendationsResult struct {
+	Result []LibraryPanelRecommendationDTO `json:"result"`
+}
+
+func TestLibraryPanelRecommendations(t *testing.T) {
+	getRecommendations := func(t *testing.T, sc scenarioContext, recommendationType string) []LibraryPanelRecommendationDTO {
+		t.Helper()
+
+		var err error
+		sc.ctx.Req.Request.URL, err = url.Parse("/?type=" + recommendationType)
+		require.NoError(t, err)
+		sc.ctx.Req.Form = nil
+		resp := sc.service.getRecommendationsHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelRecommendationsResult
+		err = json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin gets cleanup recommendations, it should recommend deleting unused library panels",
+		func(t *testing.T, sc scenarioContext) {
+			recommendations := getRecommendations(t, sc, "")
+			require.Len(t, recommendations, 1)
+			require.Equal(t, recommendationActionDelete, recommendations[0].Action)
+			require.Equal(t, sc.initialResult.Result.UID, recommendations[0].UID)
+			require.Equal(t, 0.7, recommendations[0].Score)
+			require.Equal(t, []string{"not used by any dashboard", "never rendered"}, recommendations[0].Reasons)
+
+			err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				return recordRender(session, sc.initialResult.Result.ID)
+			})
+			require.NoError(t, err)
+			recommendations = getRecommendations(t, sc, "cleanup")
+			require.Len(t, recommendations, 1)
+			require.Equal(t, int64(1), recommendations[0].RenderCount)
+			require.Equal(t, []string{"not used by any dashboard"}, recommendations[0].Reasons)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets cleanup recommendations, it should recommend unlinking stale library panels used by a single dashboard",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			require.Empty(t, getRecommendations(t, sc, "cleanup"))
+
+			err = sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				_, err := session.Exec("UPDATE library_panel SET updated=? WHERE id=?", time.Now().Add(-2*staleAfter), sc.initialResult.Result.ID)
+				return err
+			})
+			require.NoError(t, err)
+			recommendations := getRecommendations(t, sc, "cleanup")
+			require.Len(t, recommendations, 1)
+			require.Equal(t, recommendationActionUnlink, recommendations[0].Action)
+			require.Equal(t, 0.5, recommendations[0].Score)
+			require.Equal(t, int64(1), recommendations[0].ConnectedDashboards)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets consolidate recommendations, it should recommend merging similar library panels into the most used one",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panels")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			similar := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommand(sc.folder.Id, "Memory usage")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			recommendations := getRecommendations(t, sc, "consolidate")
+			require.Len(t, recommendations, 1)
+			require.Equal(t, recommendationActionMerge, recommendations[0].Action)
+			require.Equal(t, similar.Result.UID, recommendations[0].UID)
+			require.Equal(t, sc.initialResult.Result.UID, recommendations[0].MergeInto)
+			require.Less(t, recommendations[0].Score, 0.8)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets recommendations of an unknown type, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?type=unused")
+			require.NoError(t, err)
+			sc.ctx.Req.Form = nil
+			resp := sc.service.getRecommendationsHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,89 @@
+package librarypanels
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rendering"
+)
+
+type testRenderService struct {
+	renderProvider func(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error)
+}
+
+func (s *testRenderService) IsAvailable() bool {
+	return true
+}
+
+func (s *testRenderService) Render(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error) {
+	return s.renderProvider(ctx, opts)
+}
+
+func (s *testRenderService) RenderErrorImage(err error) (*rendering.RenderResult, error) {
+	return nil, err
+}
+
+func (s *testRenderService) GetRenderUser(key string) (*rendering.RenderUser, bool) {
+	return nil, false
+}
+
+func TestRenderLibraryPanel(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin renders a library panel, it should be rendered in an ephemeral dashboard",
+		func(t *testing.T, sc scenarioContext) {
+			imagePath := filepath.Join(t.TempDir(), "image.png")
+			err := ioutil.WriteFile(imagePath, []byte("png"), 0600)
+			require.NoError(t, err)
+
+			var renderedPath string
+			sc.service.RenderService = &testRenderService{
+				renderProvider: func(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error) {
+					renderedPath = opts.Path
+					require.Equal(t, 1000, opts.Width)
+
+					uid := strings.Split(strings.TrimPrefix(opts.Path, "d-solo/"), "/")[0]
+					query := models.GetDashboardQuery{Uid: uid, OrgId: sc.user.OrgId}
+					err := bus.Dispatch(&query)
+					require.NoError(t, err)
+					require.Equal(t, sc.folder.Id, query.Result.FolderId)
+					require.Equal(t, "text", query.Result.Data.Get("panels").GetIndex(0).Get("type").MustString())
+					require.Equal(t, "production", query.Result.Data.Get("templating").Get("list").GetIndex(0).Get("query").MustString())
+
+					return &rendering.RenderResult{FilePath: imagePath}, nil
+				},
+			}
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?width=1000&from=now-1h&var-env=production")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.renderHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			require.Equal(t, []byte("png"), resp.Body())
+
+			require.True(t, strings.HasPrefix(renderedPath, "d-solo/"))
+			require.Contains(t, renderedPath, "panelId=1")
+			require.Contains(t, renderedPath, "from=now-1h")
+			require.Contains(t, renderedPath, "var-env=production")
+
+			uid := strings.Split(strings.TrimPrefix(renderedPath, "d-solo/"), "/")[0]
+			err = bus.Dispatch(&models.GetDashboardQuery{Uid: uid, OrgId: sc.user.OrgId})
+			require.ErrorIs(t, err, models.ErrDashboardNotFound)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin renders a library panel with invalid parameters, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?width=wide")
+			require.NoError(t, err)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.renderHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,114 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type libraryPanelReorgResult struct {
+	Result LibraryPanelReorgResultDTO `json:"result"`
+}
+
+type libraryPanelReorgsResult struct {
+	Result []LibraryPanelReorgDTO `json:"result"`
+}
+
+func TestReorgLibraryPanels(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin reorgs library panels, it should rewrite folders, owners and team permissions and roll back",
+		func(t *testing.T, sc scenarioContext) {
+			oldTeam, err := sc.sqlStore.CreateTeam("Old team", "", sc.user.OrgId)
+			require.NoError(t, err)
+			newTeam, err := sc.sqlStore.CreateTeam("New team", "", sc.user.OrgId)
+			require.NoError(t, err)
+			newOwner, err := sc.sqlStore.CreateUser(context.Background(), models.CreateUserCommand{Login: "new-owner", Email: "new.owner@test.com"})
+			require.NoError(t, err)
+			newFolder := createFolderWithACL(t, sc.sqlStore, "NewFolder", sc.user, []folderACLItem{})
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.setPermissionsHandler(sc.reqContext, setLibraryPanelPermissionsCommand{
+				Items: []LibraryPanelACLItemDTO{{TeamID: oldTeam.Id, Permission: models.PERMISSION_EDIT}},
+			})
+			require.Equal(t, 200, resp.Status())
+
+			cmd := reorgLibraryPanelsCommand{
+				Teams:   []LibraryPanelReorgMappingDTO{{From: oldTeam.Id, To: newTeam.Id}},
+				Folders: []LibraryPanelReorgMappingDTO{{From: sc.folder.Id, To: newFolder.Id}},
+				Owners:  []LibraryPanelReorgMappingDTO{{From: sc.user.UserId, To: newOwner.Id}},
+				DryRun:  true,
+			}
+			resp = sc.service.reorgHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelReorgResult
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, rewriteStatusRewritten, result.Result.LibraryPanels[0].Status)
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, sc.folder.Id, validateAndUnMarshalResponse(t, resp).Result.FolderID)
+
+			cmd.DryRun = false
+			resp = sc.service.reorgHandler(sc.reqContext, cmd)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.NotZero(t, result.Result.ID)
+			require.Equal(t, []LibraryPanelReorgStateDTO{{
+				UID:       sc.initialResult.Result.UID,
+				FolderID:  sc.folder.Id,
+				CreatedBy: sc.user.UserId,
+				Permissions: []LibraryPanelACLItemDTO{
+					{TeamID: oldTeam.Id, Permission: models.PERMISSION_EDIT, PermissionName: "Edit"},
+				},
+			}}, result.Result.Rollback.LibraryPanels)
+			resp = sc.service.getHandler(sc.reqContext)
+			reorged := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, newFolder.Id, reorged.Result.FolderID)
+			require.Equal(t, newOwner.Id, reorged.Result.Meta.CreatedBy.ID)
+			resp = sc.service.getPermissionsHandler(sc.reqContext)
+			var permissions libraryPanelACLResult
+			err = json.Unmarshal(resp.Body(), &permissions)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelACLItemDTO{
+				{TeamID: newTeam.Id, Permission: models.PERMISSION_EDIT, PermissionName: "Edit"},
+			}, permissions.Result)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":id": "999"})
+			resp = sc.service.getReorgRollbackHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+			resp = sc.service.rollbackReorgHandler(sc.reqContext, rollbackReorgCommand{
+				ReorgID:       result.Result.Rollback.ReorgID,
+				LibraryPanels: result.Result.Rollback.LibraryPanels,
+			})
+			require.Equal(t, 200, resp.Status())
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			restored := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, sc.folder.Id, restored.Result.FolderID)
+			require.Equal(t, sc.user.UserId, restored.Result.Meta.CreatedBy.ID)
+
+			resp = sc.service.getReorgsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var reorgs libraryPanelReorgsResult
+			err = json.Unmarshal(resp.Body(), &reorgs)
+			require.NoError(t, err)
+			require.Len(t, reorgs.Result, 2)
+			require.Equal(t, result.Result.ID, reorgs.Result[0].RollbackOf)
+			require.Equal(t, int64(1), reorgs.Result[1].Changed)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin reorgs library panels to a team that doesn't exist, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.reorgHandler(sc.reqContext, reorgLibraryPanelsCommand{
+				Teams: []LibraryPanelReorgMappingDTO{{From: 1, To: 999}},
+			})
+			require.Equal(t, 400, resp.Status())
+			resp = sc.service.reorgHandler(sc.reqContext, reorgLibraryPanelsCommand{
+				Folders: []LibraryPanelReorgMappingDTO{{From: sc.folder.Id, To: sc.folder.Id}},
+			})
+			require.Equal(t, 400, resp.Status())
+		})
+}
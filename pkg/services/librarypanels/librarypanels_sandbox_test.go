@@ -0,0 +1,65 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestLibraryPanelSandbox(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an editor creates a sandbox, it should get a connected dashboard that is deleted once it expires",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.createSandboxHandler(sc.reqContext, createLibraryPanelSandboxCommand{})
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result LibraryPanelSandboxDTO `json:"result"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+
+			query := models.GetDashboardQuery{Uid: result.Result.DashboardUID, OrgId: sc.user.OrgId}
+			err = bus.Dispatch(&query)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), query.Result.FolderId)
+			require.Equal(t, sc.initialResult.Result.UID, query.Result.Data.Get("panels").GetIndex(0).Get("libraryPanel").Get("uid").MustString())
+
+			resp = sc.service.getConnectedDashboardsHandler(sc.reqContext)
+			var connected libraryPanelDashboardsResult
+			err = json.Unmarshal(resp.Body(), &connected)
+			require.NoError(t, err)
+			require.Equal(t, []int64{query.Result.Id}, connected.Result)
+
+			deleted, err := sc.service.deleteExpiredSandboxes(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, 0, deleted)
+
+			err = sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+				_, err := session.Exec("UPDATE library_panel_sandbox SET expires=0")
+				return err
+			})
+			require.NoError(t, err)
+			deleted, err = sc.service.deleteExpiredSandboxes(sc.ctx.Req.Context())
+			require.NoError(t, err)
+			require.Equal(t, 1, deleted)
+
+			err = bus.Dispatch(&models.GetDashboardQuery{Uid: result.Result.DashboardUID, OrgId: sc.user.OrgId})
+			require.ErrorIs(t, err, models.ErrDashboardNotFound)
+			resp = sc.service.getConnectedDashboardsHandler(sc.reqContext)
+			err = json.Unmarshal(resp.Body(), &connected)
+			require.NoError(t, err)
+			require.Empty(t, connected.Result)
+		})
+
+	scenarioWithLibraryPanel(t, "When an editor creates a sandbox that lives too long, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.createSandboxHandler(sc.reqContext, createLibraryPanelSandboxCommand{SecondsToLive: maxSandboxSecondsToLive + 1})
+			require.Equal(t, 400, resp.Status())
+		})
+}
@@ -0,0 +1,110 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelFindingsResult struct {
+	Result []LibraryPanelFindingDTO `json:"result"`
+}
+
+type failingContentScanner struct{}
+
+func (s failingContentScanner) Name() string {
+	return "failing"
+}
+
+func (s failingContentScanner) Scan(ctx context.Context, panel ScannedLibraryPanel) ([]ContentFinding, error) {
+	return nil, errors.New("scanner unavailable")
+}
+
+func getLibraryPanelFindings(t *testing.T, sc scenarioContext) []LibraryPanelFindingDTO {
+	t.Helper()
+
+	resp := sc.service.getLibraryPanelFindingsHandler(sc.reqContext)
+	require.Equal(t, 200, resp.Status())
+	var result libraryPanelFindingsResult
+	err := json.Unmarshal(resp.Body(), &result)
+	require.NoError(t, err)
+	return result.Result
+}
+
+func TestLibraryPanelContentScanners(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a library panel is saved, content scanners should run and replace their findings",
+		func(t *testing.T, sc scenarioContext) {
+			scanner, err := NewRegexContentScanner("compliance", []RegexContentRule{
+				{Name: "raw-sql", Severity: "high", Message: "Raw SQL in query", Pattern: `(?i)select \*`},
+			})
+			require.NoError(t, err)
+			sc.service.RegisterContentScanner(scanner)
+			sc.service.RegisterContentScanner(failingContentScanner{})
+
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommandWithModel(sc.folder.Id, "Raw SQL", []byte(`
+				{
+				  "title": "Raw SQL",
+				  "type": "table",
+				  "targets": [{"rawSql": "SELECT * FROM events"}]
+				}
+			`)))
+			created := validateAndUnMarshalResponse(t, resp)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": created.Result.UID})
+			findings := getLibraryPanelFindings(t, sc)
+			require.Len(t, findings, 1)
+			require.Equal(t, "compliance", findings[0].Scanner)
+			require.Equal(t, "raw-sql", findings[0].Rule)
+			require.Equal(t, "high", findings[0].Severity)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{
+				FolderID: -1,
+				Model:    []byte(`{"title": "Raw SQL", "type": "table", "targets": [{"rawSql": "SELECT time, value FROM events"}]}`),
+				Version:  1,
+			})
+			require.Equal(t, 200, resp.Status())
+			require.Len(t, getLibraryPanelFindings(t, sc), 0)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin scans all library panels, it should store findings for existing library panels",
+		func(t *testing.T, sc scenarioContext) {
+			scanner, err := NewRegexContentScanner("datasources", []RegexContentRule{
+				{Name: "testdata", Severity: "low", Pattern: `DS_GDEV-TESTDATA`},
+			})
+			require.NoError(t, err)
+			sc.service.RegisterContentScanner(scanner)
+
+			resp := sc.service.scanHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result LibraryPanelScanResultDTO `json:"result"`
+			}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, LibraryPanelScanResultDTO{Scanned: 1, Findings: 1}, result.Result)
+
+			err = sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Set("severity", "low")
+			resp = sc.service.getFindingsHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var findings libraryPanelFindingsResult
+			err = json.Unmarshal(resp.Body(), &findings)
+			require.NoError(t, err)
+			require.Len(t, findings.Result, 1)
+			require.Equal(t, sc.initialResult.Result.UID, findings.Result[0].UID)
+
+			sc.reqContext.Req.Form.Set("severity", "high")
+			resp = sc.service.getFindingsHandler(sc.reqContext)
+			err = json.Unmarshal(resp.Body(), &findings)
+			require.NoError(t, err)
+			require.Len(t, findings.Result, 0)
+		})
+
+	t.Run("When a regex pack has an invalid pattern, it should fail", func(t *testing.T) {
+		_, err := NewRegexContentScanner("invalid", []RegexContentRule{{Name: "broken", Pattern: `(`}})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,66 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchLibraryPanelsInAllOrgs(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a server admin searches library panels in all orgs, it should return library panels of all orgs with their org names",
+		func(t *testing.T, sc scenarioContext) {
+			org, err := sc.sqlStore.CreateOrgWithMember("Other org", sc.user.UserId)
+			require.NoError(t, err)
+			sc.reqContext.SignedInUser.OrgId = org.Id
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(0, "Other org's"))
+			require.Equal(t, 200, resp.Status())
+			sc.reqContext.SignedInUser.OrgId = sc.user.OrgId
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?orgId=all")
+			require.NoError(t, err)
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 403, resp.Status())
+
+			sc.reqContext.SignedInUser.IsGrafanaAdmin = true
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(2), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 2)
+			require.Equal(t, "Other org's", result.Result.LibraryPanels[0].Name)
+			require.Equal(t, org.Id, result.Result.LibraryPanels[0].OrgID)
+			require.Equal(t, "Other org", result.Result.LibraryPanels[0].Meta.OrgName)
+		})
+
+	scenarioWithLibraryPanel(t, "When a server admin pages through library panels in all orgs, it should page with page instead of continueToken",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "A"))
+			require.Equal(t, 200, resp.Status())
+			sc.reqContext.SignedInUser.IsGrafanaAdmin = true
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?orgId=all&perPage=1")
+			require.NoError(t, err)
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				Result struct {
+					LibraryPanels []libraryPanel `json:"libraryPanels"`
+					ContinueToken string         `json:"continueToken"`
+				} `json:"result"`
+			}
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Empty(t, result.Result.ContinueToken)
+
+			continueToken := encodeContinueToken(result.Result.LibraryPanels[0].Name, result.Result.LibraryPanels[0].UID)
+			sc.reqContext.Req.Form.Set("continueToken", continueToken)
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
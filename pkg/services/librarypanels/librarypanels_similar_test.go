@@ -0,0 +1,80 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelSimilarResult struct {
+	Result []LibraryPanelSimilarDTO `json:"result"`
+}
+
+type libraryPanelCreateSimilarResult struct {
+	Result  LibraryPanelDTO          `json:"result"`
+	Similar []LibraryPanelSimilarDTO `json:"similar"`
+}
+
+func TestNameSimilarity(t *testing.T) {
+	require.Equal(t, float64(1), nameSimilarity("CPU usage", " cpu USAGE"))
+	require.GreaterOrEqual(t, nameSimilarity("CPU usage", "CPU usages"), similarNameThreshold)
+	require.GreaterOrEqual(t, nameSimilarity("Requests per second", "Requests / second"), similarNameThreshold)
+	require.Less(t, nameSimilarity("CPU usage", "Memory usage"), similarNameThreshold)
+	require.Equal(t, float64(0), nameSimilarity("CPU usage", "---"))
+}
+
+func TestGetSimilarLibraryPanels(t *testing.T) {
+	getSimilar := func(t *testing.T, sc scenarioContext, name string) []LibraryPanelSimilarDTO {
+		t.Helper()
+
+		var err error
+		sc.ctx.Req.Request.URL, err = url.Parse("/?name=" + url.QueryEscape(name))
+		require.NoError(t, err)
+		sc.ctx.Req.Form = nil
+		resp := sc.service.getSimilarHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelSimilarResult
+		err = json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin checks a name that is similar to a library panel, it should suggest the library panel",
+		func(t *testing.T, sc scenarioContext) {
+			similar := getSimilar(t, sc, "text library panel")
+			require.Len(t, similar, 1)
+			require.Equal(t, sc.initialResult.Result.UID, similar[0].UID)
+			require.Equal(t, sc.folder.Title, similar[0].FolderName)
+			require.Equal(t, float64(1), getSimilar(t, sc, "TEXT - library panel")[0].Similarity)
+
+			require.Empty(t, getSimilar(t, sc, "Memory usage"))
+			require.Empty(t, getSimilar(t, sc, ""))
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel with a similar name, it should be created and suggest the similar library panel",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panels")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			created := validateAndUnMarshalResponse(t, resp)
+			var result libraryPanelCreateSimilarResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Len(t, result.Similar, 1)
+			require.Equal(t, sc.initialResult.Result.UID, result.Similar[0].UID)
+
+			command = getCreateCommand(sc.folder.Id, "Memory usage")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			var unique libraryPanelCreateSimilarResult
+			err = json.Unmarshal(resp.Body(), &unique)
+			require.NoError(t, err)
+			require.Empty(t, unique.Similar)
+
+			similar := getSimilar(t, sc, "Text - Library Panel")
+			require.Len(t, similar, 2)
+			require.Equal(t, sc.initialResult.Result.UID, similar[0].UID)
+			require.Equal(t, created.Result.UID, similar[1].UID)
+		})
+}
@@ -0,0 +1,122 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelSmokeRendersResult struct {
+	Result []LibraryPanelSmokeRenderDTO `json:"result"`
+}
+
+func TestSmokeRenderLibraryPanels(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When published library panels are smoke rendered, it should report the ones that failed",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Broken")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			broken := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommand(sc.folder.Id, "Unpublished")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			for _, uid := range []string{sc.initialResult.Result.UID, broken.Result.UID} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": uid})
+				resp = sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+				require.Equal(t, 200, resp.Status())
+			}
+
+			sc.service.Cfg.PanelLibrarySmokeRenderFrom = "now-6h"
+			sc.service.Cfg.PanelLibrarySmokeRenderTo = "now"
+			rendered := make([]string, 0)
+			sc.service.RenderService = &testRenderService{
+				renderProvider: func(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error) {
+					require.Equal(t, models.ROLE_ADMIN, opts.OrgRole)
+					require.Contains(t, opts.Path, "from=now-6h")
+
+					uid := strings.Split(strings.TrimPrefix(opts.Path, "d-solo/"), "/")[0]
+					query := models.GetDashboardQuery{Uid: uid, OrgId: sc.user.OrgId}
+					err := bus.Dispatch(&query)
+					require.NoError(t, err)
+					title := query.Result.Data.Get("title").MustString()
+					rendered = append(rendered, title)
+					if strings.HasPrefix(title, "Broken") {
+						return nil, errors.New("panel plugin not found")
+					}
+					return &rendering.RenderResult{}, nil
+				},
+			}
+
+			result, err := sc.service.smokeRenderLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, LibraryPanelSmokeRenderResultDTO{Rendered: 2, Failed: 1}, result)
+			require.Len(t, rendered, 2)
+
+			resp = sc.service.getBrokenHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var report libraryPanelSmokeRendersResult
+			err = json.Unmarshal(resp.Body(), &report)
+			require.NoError(t, err)
+			require.Len(t, report.Result, 1)
+			require.Equal(t, broken.Result.UID, report.Result[0].UID)
+			require.Equal(t, int64(1), report.Result[0].Version)
+			require.False(t, report.Result[0].Passed)
+			require.Equal(t, "panel plugin not found", report.Result[0].Error)
+
+			sc.service.RenderService = &testRenderService{
+				renderProvider: func(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error) {
+					return &rendering.RenderResult{}, nil
+				},
+			}
+			result, err = sc.service.smokeRenderLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, LibraryPanelSmokeRenderResultDTO{Rendered: 2}, result)
+
+			resp = sc.service.getBrokenHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &report)
+			require.NoError(t, err)
+			require.Empty(t, report.Result)
+		})
+
+	scenarioWithLibraryPanel(t, "When published library panels are smoke rendered with a test datasource, it should render them against it",
+		func(t *testing.T, sc scenarioContext) {
+			err := sqlstore.AddDataSource(&models.AddDataSourceCommand{
+				OrgId:  sc.user.OrgId,
+				Name:   "TestData",
+				Type:   "testdata",
+				Access: models.DS_ACCESS_PROXY,
+				Uid:    "testdata-uid",
+			})
+			require.NoError(t, err)
+			sc.service.Cfg.PanelLibrarySmokeRenderDatasource = "TestData"
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.publishHandler(sc.reqContext, publishLibraryPanelCommand{})
+			require.Equal(t, 200, resp.Status())
+
+			var datasource string
+			sc.service.RenderService = &testRenderService{
+				renderProvider: func(ctx context.Context, opts rendering.Opts) (*rendering.RenderResult, error) {
+					uid := strings.Split(strings.TrimPrefix(opts.Path, "d-solo/"), "/")[0]
+					query := models.GetDashboardQuery{Uid: uid, OrgId: sc.user.OrgId}
+					err := bus.Dispatch(&query)
+					require.NoError(t, err)
+					datasource = query.Result.Data.Get("panels").GetIndex(0).Get("datasource").MustString()
+					return &rendering.RenderResult{}, nil
+				},
+			}
+
+			result, err := sc.service.smokeRenderLibraryPanels(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, LibraryPanelSmokeRenderResultDTO{Rendered: 1}, result)
+			require.Equal(t, "TestData", datasource)
+		})
+}
@@ -0,0 +1,111 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelStaleResult struct {
+	Result []LibraryPanelStaleDTO `json:"result"`
+}
+
+func TestStaleLibraryPanels(t *testing.T) {
+	getStale := func(t *testing.T, sc scenarioContext, window string) []LibraryPanelStaleDTO {
+		t.Helper()
+
+		var err error
+		sc.ctx.Req.Request.URL, err = url.Parse("/?notUpdatedSince=" + window)
+		require.NoError(t, err)
+		sc.ctx.Req.Form = nil
+		resp := sc.service.getStaleHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelStaleResult
+		err = json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+	backdate := func(t *testing.T, sc scenarioContext, column string, age time.Duration) {
+		t.Helper()
+
+		err := sc.sqlStore.WithDbSession(sc.ctx.Req.Context(), func(session *sqlstore.DBSession) error {
+			_, err := session.Exec("UPDATE library_panel SET "+column+"=? WHERE id=?", time.Now().Add(-age), sc.initialResult.Result.ID)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin gets stale library panels, it should return library panels not updated or not connected within the window",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel2")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			connected := validateAndUnMarshalResponse(t, resp)
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": connected.Result.UID, ":dashboardId": strconv.FormatInt(dashInDB.Id, 10)})
+			resp = sc.service.connectHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			backdate(t, sc, "created", 150*24*time.Hour)
+
+			stale := getStale(t, sc, "")
+			require.Len(t, stale, 1)
+			require.Equal(t, sc.initialResult.Result.UID, stale[0].UID)
+			require.False(t, stale[0].NotUpdated)
+			require.True(t, stale[0].NotConnected)
+			require.Nil(t, stale[0].LastConnected)
+			require.Equal(t, int64(1), stale[0].UpdatedBy.ID)
+			require.Equal(t, UserInDbName, stale[0].UpdatedBy.Name)
+
+			backdate(t, sc, "updated", 100*24*time.Hour)
+			stale = getStale(t, sc, "90d")
+			require.Len(t, stale, 1)
+			require.True(t, stale[0].NotUpdated)
+			require.True(t, stale[0].NotConnected)
+			require.Empty(t, getStale(t, sc, "200d"))
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets stale library panels, it should not return library panels created within the window",
+		func(t *testing.T, sc scenarioContext) {
+			require.Empty(t, getStale(t, sc, "90d"))
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets stale library panels with an invalid window, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			for _, window := range []string{"soon", "-5d", "0d"} {
+				var err error
+				sc.ctx.Req.Request.URL, err = url.Parse("/?notUpdatedSince=" + window)
+				require.NoError(t, err)
+				sc.ctx.Req.Form = nil
+				resp := sc.service.getStaleHandler(sc.reqContext)
+				require.Equal(t, 400, resp.Status())
+			}
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin searches library panels not updated since a window, it should only return older library panels",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel2")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+			backdate(t, sc, "updated", 100*24*time.Hour)
+
+			err := sc.reqContext.Req.ParseForm()
+			require.NoError(t, err)
+			sc.reqContext.Req.Form.Set("notUpdatedSince", "90d")
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var results libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &results)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), results.Result.TotalCount)
+			require.Equal(t, sc.initialResult.Result.UID, results.Result.LibraryPanels[0].UID)
+
+			sc.reqContext.Req.Form.Set("notUpdatedSince", "soon")
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
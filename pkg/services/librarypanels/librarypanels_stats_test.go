@@ -0,0 +1,109 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelTopStatsResult struct {
+	Result LibraryPanelTopStatsDTO `json:"result"`
+}
+
+func TestLibraryPanelTopStats(t *testing.T) {
+	getTopStats := func(t *testing.T, sc scenarioContext, query string) LibraryPanelTopStatsDTO {
+		t.Helper()
+
+		var err error
+		sc.ctx.Req.Request.URL, err = url.Parse("/?" + query)
+		require.NoError(t, err)
+		sc.ctx.Req.Form = nil
+		resp := sc.service.getTopStatsHandler(sc.reqContext)
+		require.Equal(t, 200, resp.Status())
+		var result libraryPanelTopStatsResult
+		err = json.Unmarshal(resp.Body(), &result)
+		require.NoError(t, err)
+		return result.Result
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin gets the most used library panels, it should rank them by connections and by views with a type breakdown",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommandWithModel(sc.folder.Id, "Graph - Library Panel", []byte(`{"title": "Graph - Library Panel", "type": "graph"}`))
+			resp := sc.service.createHandler(sc.reqContext, command)
+			graph := validateAndUnMarshalResponse(t, resp)
+			command = getCreateCommand(sc.folder.Id, "Unused")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			for _, dashboardID := range []string{"1", "2"} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID, ":dashboardId": dashboardID})
+				resp = sc.service.connectHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+			}
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				today := time.Now().UTC().Format(viewsDayLayout)
+				return addViews(session, libraryPanelViewKey{panelID: graph.Result.ID, day: today}, 3)
+			})
+			require.NoError(t, err)
+
+			stats := getTopStats(t, sc, "")
+			require.Len(t, stats.ByConnections, 1)
+			require.Equal(t, sc.initialResult.Result.UID, stats.ByConnections[0].UID)
+			require.Equal(t, int64(2), stats.ByConnections[0].ConnectedDashboards)
+			require.Len(t, stats.ByViews, 1)
+			require.Equal(t, graph.Result.UID, stats.ByViews[0].UID)
+			require.Equal(t, int64(3), stats.ByViews[0].ViewsLast30Days)
+			require.Equal(t, []LibraryPanelTypeStatsDTO{
+				{Type: "text", Count: 2, ConnectedDashboards: 2},
+				{Type: "graph", Count: 1, ViewsLast30Days: 3},
+			}, stats.Types)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin gets the most used library panels with a limit, it should return at most that many",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel2")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			second := validateAndUnMarshalResponse(t, resp)
+			for _, uid := range []string{sc.initialResult.Result.UID, second.Result.UID} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": uid, ":dashboardId": "1"})
+				resp = sc.service.connectHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+			}
+
+			require.Len(t, getTopStats(t, sc, "").ByConnections, 2)
+			stats := getTopStats(t, sc, "limit=1")
+			require.Len(t, stats.ByConnections, 1)
+			expected := sc.initialResult.Result.UID
+			if second.Result.UID < expected {
+				expected = second.Result.UID
+			}
+			require.Equal(t, expected, stats.ByConnections[0].UID)
+		})
+
+	scenarioWithLibraryPanel(t, "When a viewer gets the most used library panels, it should leave out folders they can't view",
+		func(t *testing.T, sc scenarioContext) {
+			restricted := createFolderWithACL(t, sc.sqlStore, "Restricted", sc.user, []folderACLItem{{models.ROLE_ADMIN, models.PERMISSION_EDIT}})
+			command := getCreateCommand(restricted.Id, "Secret")
+			resp := sc.service.createHandler(sc.reqContext, command)
+			secret := validateAndUnMarshalResponse(t, resp)
+			for _, uid := range []string{sc.initialResult.Result.UID, secret.Result.UID} {
+				sc.reqContext.ReplaceAllParams(map[string]string{":uid": uid, ":dashboardId": "1"})
+				resp = sc.service.connectHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+			}
+			require.Len(t, getTopStats(t, sc, "").ByConnections, 2)
+
+			sc.reqContext.SignedInUser.OrgRole = models.ROLE_VIEWER
+			stats := getTopStats(t, sc, "")
+			require.Len(t, stats.ByConnections, 1)
+			require.Equal(t, sc.initialResult.Result.UID, stats.ByConnections[0].UID)
+			require.Equal(t, []LibraryPanelTypeStatsDTO{{Type: "text", Count: 1, ConnectedDashboards: 1}}, stats.Types)
+		})
+}
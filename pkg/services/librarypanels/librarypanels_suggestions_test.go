@@ -0,0 +1,105 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestSuggestLibraryPanelsForDashboard(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin saves a dashboard with panels identical to a library panel, it should suggest the library panel",
+		func(t *testing.T, sc scenarioContext) {
+			dash := models.Dashboard{
+				Id: 1,
+				Data: simplejson.NewFromAny(map[string]interface{}{
+					"panels": []interface{}{
+						map[string]interface{}{
+							"id":          int64(1),
+							"gridPos":     map[string]interface{}{"h": 6, "w": 6, "x": 0, "y": 0},
+							"datasource":  "${DS_GDEV-TESTDATA}",
+							"title":       sc.initialResult.Result.Name,
+							"type":        "text",
+							"description": "A description",
+						},
+						map[string]interface{}{
+							"id":          int64(2),
+							"gridPos":     map[string]interface{}{"h": 6, "w": 6, "x": 6, "y": 0},
+							"datasource":  "${DS_GDEV-TESTDATA}",
+							"title":       "Another title",
+							"type":        "text",
+							"description": "A description",
+						},
+						map[string]interface{}{
+							"id":      int64(3),
+							"gridPos": map[string]interface{}{"h": 6, "w": 6, "x": 12, "y": 0},
+							"libraryPanel": map[string]interface{}{
+								"uid":  sc.initialResult.Result.UID,
+								"name": sc.initialResult.Result.Name,
+							},
+						},
+					},
+				}),
+			}
+
+			suggestions, err := sc.service.SuggestLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			require.Equal(t, []LibraryPanelSuggestionDTO{
+				{
+					PanelID:          1,
+					PanelTitle:       sc.initialResult.Result.Name,
+					LibraryPanelUID:  sc.initialResult.Result.UID,
+					LibraryPanelName: sc.initialResult.Result.Name,
+					FolderID:         sc.folder.Id,
+				},
+			}, suggestions)
+			// the dashboard isn't changed
+			require.Nil(t, dash.Data.Get("panels").GetIndex(0).Get("libraryPanel").Interface())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin saves a dashboard without panels matching a library panel, it should suggest nothing",
+		func(t *testing.T, sc scenarioContext) {
+			dash := getDashboardWithLibraryPanel(sc, 1)
+			suggestions, err := sc.service.SuggestLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			require.Empty(t, suggestions)
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel has no stored normalized hash, it should still be suggested",
+		func(t *testing.T, sc scenarioContext) {
+			var stored LibraryPanel
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				if _, err := session.SQL("SELECT * FROM library_panel WHERE id=?", sc.initialResult.Result.ID).Get(&stored); err != nil {
+					return err
+				}
+				_, err := session.Exec("UPDATE library_panel SET normalized_hash=NULL WHERE id=?", sc.initialResult.Result.ID)
+				return err
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, stored.NormalizedHash)
+
+			dash := models.Dashboard{
+				Id: 1,
+				Data: simplejson.NewFromAny(map[string]interface{}{
+					"panels": []interface{}{
+						map[string]interface{}{
+							"id":          int64(1),
+							"gridPos":     map[string]interface{}{"h": 6, "w": 6, "x": 0, "y": 0},
+							"datasource":  "${DS_GDEV-TESTDATA}",
+							"title":       sc.initialResult.Result.Name,
+							"type":        "text",
+							"description": "A description",
+						},
+					},
+				}),
+			}
+			suggestions, err := sc.service.SuggestLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			require.Len(t, suggestions, 1)
+			require.Equal(t, sc.initialResult.Result.UID, suggestions[0].LibraryPanelUID)
+		})
+}
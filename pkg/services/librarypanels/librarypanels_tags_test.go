@@ -0,0 +1,108 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelWithTags struct {
+	UID  string   `json:"uid"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type libraryPanelWithTagsResult struct {
+	Result libraryPanelWithTags `json:"result"`
+}
+
+type libraryPanelsWithTagsSearch struct {
+	Result struct {
+		TotalCount    int64                  `json:"totalCount"`
+		LibraryPanels []libraryPanelWithTags `json:"libraryPanels"`
+	} `json:"result"`
+}
+
+func TestLibraryPanelTags(t *testing.T) {
+	testScenario(t, "When an admin tries to create a library panel with tags, it should return the normalized tags",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			command.Tags = []string{"prod", " team-a ", "", "prod"}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			var result libraryPanelWithTagsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{"prod", "team-a"}, result.Result.Tags)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": result.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{"prod", "team-a"}, result.Result.Tags)
+		})
+
+	testScenario(t, "When an admin tries to create a library panel with a tag that is too long, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			command.Tags = []string{strings.Repeat("x", 51)}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin patches a library panel, it should only replace the tags if they are given",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Tags: []string{"a", "b"}, Version: 1})
+			require.Equal(t, 200, resp.Status())
+			var result libraryPanelWithTagsResult
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{"a", "b"}, result.Result.Tags)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 2})
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{"a", "b"}, result.Result.Tags)
+
+			resp = sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Tags: []string{}, Version: 3})
+			require.Equal(t, 200, resp.Status())
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{}, result.Result.Tags)
+		})
+
+	testScenario(t, "When an admin tries to get all library panels with tag filters, it should only return panels with all tags",
+		func(t *testing.T, sc scenarioContext) {
+			for name, tags := range map[string][]string{
+				"Prod Team A": {"prod", "team-a"},
+				"Prod Team B": {"prod", "team-b"},
+				"Dev Team A":  {"dev", "team-a"},
+			} {
+				command := getCreateCommand(sc.folder.Id, name)
+				command.Tags = tags
+				resp := sc.service.createHandler(sc.reqContext, command)
+				require.Equal(t, 200, resp.Status())
+			}
+
+			var err error
+			sc.ctx.Req.Request.URL, err = url.Parse("/?tag=prod&tag=team-a")
+			require.NoError(t, err)
+			resp := sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			var result libraryPanelsWithTagsSearch
+			err = json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), result.Result.TotalCount)
+			require.Len(t, result.Result.LibraryPanels, 1)
+			require.Equal(t, "Prod Team A", result.Result.LibraryPanels[0].Name)
+			require.Equal(t, []string{"prod", "team-a"}, result.Result.LibraryPanels[0].Tags)
+		})
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	dboards "github.com/grafana/grafana/pkg/dashboards"
+	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/dashboards"
@@ -97,6 +98,7 @@ func TestLoadLibraryPanelsForDashboard(t *testing.T) {
 								"folderName":          "ScenarioFolder",
 								"folderUid":           sc.folder.Uid,
 								"connectedDashboards": int64(1),
+								"provisioned":         false,
 								"created":             sc.initialResult.Result.Meta.Created,
 								"updated":             sc.initialResult.Result.Meta.Updated,
 								"createdBy": map[string]interface{}{
@@ -700,16 +702,20 @@ type libraryPanelDashboardsResult struct {
 	Result []int64 `json:"result"`
 }
 
-func overrideLibraryPanelServiceInRegistry(cfg *setting.Cfg) LibraryPanelService {
-	lps := LibraryPanelService{
+func overrideLibraryPanelServiceInRegistry(cfg *setting.Cfg) *LibraryPanelService {
+	lps := &LibraryPanelService{
 		SQLStore: nil,
 		Cfg:      cfg,
+		log:      log.New("librarypanels"),
 	}
 
 	overrideServiceFunc := func(d registry.Descriptor) (*registry.Descriptor, bool) {
+		if d.Name != "LibraryPanelService" {
+			return nil, false
+		}
 		descriptor := registry.Descriptor{
 			Name:         "LibraryPanelService",
-			Instance:     &lps,
+			Instance:     lps,
 			InitPriority: 0,
 		}
 
@@ -897,7 +903,7 @@ func testScenario(t *testing.T, desc string, fn func(t *testing.T, sc scenarioCo
 		sc := scenarioContext{
 			user:     user,
 			ctx:      &ctx,
-			service:  &service,
+			service:  service,
 			sqlStore: sqlStore,
 			reqContext: &models.ReqContext{
 				Context:      &ctx,
@@ -0,0 +1,101 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type libraryPanelTokenResult struct {
+	Result LibraryPanelTokenDTO `json:"result"`
+}
+
+type libraryPanelTokensResult struct {
+	Result []LibraryPanelTokenDTO `json:"result"`
+}
+
+type libraryPanelEmbedResult struct {
+	Result LibraryPanelEmbedDTO `json:"result"`
+}
+
+func TestLibraryPanelTokens(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin creates a token for a library panel, the token should grant access to that panel until revoked",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.createTokenHandler(sc.reqContext, createLibraryPanelTokenCommand{Name: "wiki", SecondsToLive: 3600})
+			require.Equal(t, 200, resp.Status())
+			var token libraryPanelTokenResult
+			err := json.Unmarshal(resp.Body(), &token)
+			require.NoError(t, err)
+			require.Equal(t, "wiki", token.Result.Name)
+			require.NotEmpty(t, token.Result.Key)
+			require.NotNil(t, token.Result.Expiration)
+
+			resp = sc.service.getTokensHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var tokens libraryPanelTokensResult
+			err = json.Unmarshal(resp.Body(), &tokens)
+			require.NoError(t, err)
+			require.Len(t, tokens.Result, 1)
+			require.Equal(t, token.Result.ID, tokens.Result[0].ID)
+			require.Empty(t, tokens.Result[0].Key)
+
+			sc.reqContext.Req.Header = http.Header{}
+			sc.reqContext.Req.Header.Set("Authorization", "Bearer "+token.Result.Key)
+			resp = sc.service.embedHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var embed libraryPanelEmbedResult
+			err = json.Unmarshal(resp.Body(), &embed)
+			require.NoError(t, err)
+			require.Equal(t, sc.initialResult.Result.UID, embed.Result.UID)
+			require.Equal(t, "text", embed.Result.Type)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":     sc.initialResult.Result.UID,
+				":tokenId": strconv.FormatInt(token.Result.ID, 10),
+			})
+			resp = sc.service.revokeTokenHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.embedHandler(sc.reqContext)
+			require.Equal(t, 401, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a token with a negative time to live, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.createTokenHandler(sc.reqContext, createLibraryPanelTokenCommand{Name: "wiki", SecondsToLive: -1})
+			require.Equal(t, 400, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel is requested with an invalid token, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.Req.Header = http.Header{}
+			sc.reqContext.Req.Header.Set("Authorization", "Bearer invalid")
+			resp := sc.service.embedHandler(sc.reqContext)
+			require.Equal(t, 401, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel is requested with a token in the query string, it should only succeed if that's enabled",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.createTokenHandler(sc.reqContext, createLibraryPanelTokenCommand{Name: "wiki"})
+			require.Equal(t, 200, resp.Status())
+			var token libraryPanelTokenResult
+			err := json.Unmarshal(resp.Body(), &token)
+			require.NoError(t, err)
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?token=" + url.QueryEscape(token.Result.Key))
+			require.NoError(t, err)
+			resp = sc.service.embedHandler(sc.reqContext)
+			require.Equal(t, 401, resp.Status())
+
+			sc.service.Cfg.PanelLibraryEmbedTokenInQuery = true
+			resp = sc.service.embedHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+}
@@ -0,0 +1,57 @@
+package librarypanels
+
+import (
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func useMockTracer(t *testing.T) *mocktracer.MockTracer {
+	t.Helper()
+
+	tracer := mocktracer.New()
+	origTracer := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(origTracer)
+	})
+	return tracer
+}
+
+func TestLibraryPanelTracing(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When library panels are fetched and searched, it should trace the queries with org id, uid and result counts",
+		func(t *testing.T, sc scenarioContext) {
+			tracer := useMockTracer(t)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			spans := tracer.FinishedSpans()
+			require.Len(t, spans, 2)
+			require.Equal(t, "librarypanels.getLibraryPanel", spans[0].OperationName)
+			require.Equal(t, sc.user.OrgId, spans[0].Tag("orgId"))
+			require.Equal(t, sc.initialResult.Result.UID, spans[0].Tag("uid"))
+			require.Equal(t, "librarypanels.getAllLibraryPanels", spans[1].OperationName)
+			require.Equal(t, 1, spans[1].Tag("resultCount"))
+			require.Equal(t, int64(1), spans[1].Tag("totalCount"))
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel write fails, it should mark its span as failed",
+		func(t *testing.T, sc scenarioContext) {
+			tracer := useMockTracer(t)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "unknown"})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 404, resp.Status())
+
+			spans := tracer.FinishedSpans()
+			require.Len(t, spans, 1)
+			require.Equal(t, "librarypanels.patchLibraryPanel", spans[0].OperationName)
+			require.Equal(t, true, spans[0].Tag("error"))
+		})
+}
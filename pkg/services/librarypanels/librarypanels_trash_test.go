@@ -0,0 +1,162 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+type libraryPanelTrashResult struct {
+	Result []TrashedLibraryPanelDTO `json:"result"`
+}
+
+func TestLibraryPanelTrash(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When an admin deletes a library panel, it should be moved to the trash and be restorable",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.getHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var search libraryPanelsSearch
+			err := json.Unmarshal(resp.Body(), &search)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), search.Result.TotalCount)
+
+			resp = sc.service.getTrashHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var trash libraryPanelTrashResult
+			err = json.Unmarshal(resp.Body(), &trash)
+			require.NoError(t, err)
+			require.Len(t, trash.Result, 1)
+			require.Equal(t, sc.initialResult.Result.UID, trash.Result[0].UID)
+			require.Equal(t, sc.folder.Id, trash.Result[0].FolderID)
+			require.Equal(t, "Text - Library Panel", trash.Result[0].Name)
+			require.False(t, trash.Result[0].DeletedAt.IsZero())
+
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+
+			resp = sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, sc.initialResult.Result.UID, result.Result.UID)
+			require.Equal(t, sc.initialResult.Result.Version, result.Result.Version)
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin purges a library panel from the trash, it should be gone for good",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.purgeHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.purgeHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+			resp = sc.service.getTrashHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var trash libraryPanelTrashResult
+			err := json.Unmarshal(resp.Body(), &trash)
+			require.NoError(t, err)
+			require.Len(t, trash.Result, 0)
+
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin creates a library panel with the name of a library panel in the trash, it should succeed and restore only once the name is free",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			command := getCreateCommand(sc.folder.Id, "Text - Library Panel")
+			resp = sc.service.createHandler(sc.reqContext, command)
+			recreated := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "Text - Library Panel", recreated.Result.Name)
+
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": recreated.Result.UID})
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getTrashHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var trash libraryPanelTrashResult
+			err := json.Unmarshal(resp.Body(), &trash)
+			require.NoError(t, err)
+			require.Len(t, trash.Result, 2)
+			for _, trashed := range trash.Result {
+				require.Equal(t, "Text - Library Panel", trashed.Name)
+			}
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "Text - Library Panel", result.Result.Name)
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel is provisioned with the uid of a library panel in the trash, it should fail and keep it restorable",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+
+			err := sc.service.ProvisionLibraryPanel(context.Background(), ProvisionLibraryPanelCommand{
+				OrgID:     sc.user.OrgId,
+				FolderUID: sc.folder.Uid,
+				UID:       sc.initialResult.Result.UID,
+				Name:      "Provisioned Panel",
+				Model:     []byte(`{"type": "graph"}`),
+			})
+			require.ErrorIs(t, err, errLibraryPanelUIDAlreadyExists)
+
+			resp = sc.service.restoreHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			resp = sc.service.getHandler(sc.reqContext)
+			var result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, "Text - Library Panel", result.Result.Name)
+			require.Equal(t, "text", result.Result.Type)
+		})
+
+	scenarioWithLibraryPanel(t, "When the library panels of a folder are deleted, it should delete the rows that belong to them",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.patchHandler(sc.reqContext, patchLibraryPanelCommand{FolderID: -1, Name: "Renamed", Version: 1})
+			require.Equal(t, 200, resp.Status())
+			sc.service.recordViews(sc.initialResult.Result.ID)
+			_, err := sc.service.flushViews(context.Background())
+			require.NoError(t, err)
+
+			err = sc.service.DeleteLibraryPanelsInFolder(sc.reqContext, sc.folder.Uid)
+			require.NoError(t, err)
+
+			err = sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				for _, table := range libraryPanelDependentTables {
+					count, err := session.Table(table).Where("librarypanel_id=?", sc.initialResult.Result.ID).Count()
+					if err != nil {
+						return err
+					}
+					require.Zero(t, count, table)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		})
+}
@@ -0,0 +1,83 @@
+package librarypanels
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateULID(t *testing.T) {
+	entropy := bytes.Repeat([]byte{0xff}, 10)
+	ulid, err := generateULID(time.Unix(0, 0), bytes.NewReader(entropy))
+	require.NoError(t, err)
+	require.Equal(t, "0000000000ZZZZZZZZZZZZZZZZ", ulid)
+
+	ulid, err = generateULID(time.Unix(1469918176, 385*int64(time.Millisecond)), bytes.NewReader(make([]byte, 10)))
+	require.NoError(t, err)
+	require.Equal(t, "01ARYZ6S410000000000000000", ulid)
+
+	earlier, err := generateULID(time.Unix(1000, 0), bytes.NewReader(entropy))
+	require.NoError(t, err)
+	later, err := generateULID(time.Unix(1001, 0), bytes.NewReader(make([]byte, 10)))
+	require.NoError(t, err)
+	require.Less(t, earlier, later)
+
+	_, err = generateULID(time.Now(), bytes.NewReader(nil))
+	require.Error(t, err)
+}
+
+func TestValidateUIDStrategy(t *testing.T) {
+	require.NoError(t, validateUIDStrategy("", ""))
+	require.NoError(t, validateUIDStrategy(uidStrategyShortID, ""))
+	require.NoError(t, validateUIDStrategy(uidStrategyULID, ""))
+	require.NoError(t, validateUIDStrategy(uidStrategyPrefixed, "eu-west"))
+	require.Error(t, validateUIDStrategy(uidStrategyPrefixed, ""))
+	require.Error(t, validateUIDStrategy(uidStrategyPrefixed, "eu west"))
+	require.Error(t, validateUIDStrategy(uidStrategyPrefixed, strings.Repeat("a", maxUIDPrefixLength+1)))
+	require.Error(t, validateUIDStrategy("uuid", ""))
+}
+
+func TestValidateUID(t *testing.T) {
+	for _, uid := range []string{"d5yBkRmMz", "01ARYZ6S410000000000000000", "eu-west-d5yBkRmMz", "cpu_usage"} {
+		require.NoError(t, validateUID(uid), uid)
+	}
+	for _, uid := range []string{"", "cpu usage", "cpu/usage", strings.Repeat("a", maxUIDLength+1)} {
+		require.ErrorIs(t, validateUID(uid), errLibraryPanelInvalidUID, uid)
+	}
+}
+
+func TestCreateLibraryPanelUIDStrategy(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When the uid strategy is ulid, it should create library panels with a ULID",
+		func(t *testing.T, sc scenarioContext) {
+			sc.service.Cfg.PanelLibraryUIDStrategy = uidStrategyULID
+
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "ULID Panel"))
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Regexp(t, `^[0-9A-HJKMNP-TV-Z]{26}$`, result.Result.UID)
+		})
+
+	scenarioWithLibraryPanel(t, "When the uid strategy is prefixed, it should create library panels with a prefixed uid",
+		func(t *testing.T, sc scenarioContext) {
+			sc.service.Cfg.PanelLibraryUIDStrategy = uidStrategyPrefixed
+			sc.service.Cfg.PanelLibraryUIDPrefix = "eu-west"
+
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "Prefixed Panel"))
+			result := validateAndUnMarshalResponse(t, resp)
+			require.True(t, strings.HasPrefix(result.Result.UID, "eu-west-"), result.Result.UID)
+			require.NoError(t, validateUID(result.Result.UID))
+		})
+
+	scenarioWithLibraryPanel(t, "When a provisioned library panel has an invalid uid, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			err := ValidateProvisionLibraryPanelCommand(ProvisionLibraryPanelCommand{
+				OrgID: sc.user.OrgId,
+				UID:   "cpu usage",
+				Name:  "CPU",
+				Model: []byte(`{"type": "graph"}`),
+			})
+			require.ErrorIs(t, err, errLibraryPanelInvalidUID)
+		})
+}
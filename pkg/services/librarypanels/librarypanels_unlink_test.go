@@ -0,0 +1,83 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestUnlinkLibraryPanel(t *testing.T) {
+	saveDashboardWithLibraryPanel := func(t *testing.T, sc scenarioContext) *models.Dashboard {
+		t.Helper()
+
+		dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+		dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+		dash.Data.Set("id", dashInDB.Id)
+		dash.Data.Set("uid", dashInDB.Uid)
+		dash.Data.Set("title", dashInDB.Title)
+		_, err := sc.sqlStore.SaveDashboard(models.SaveDashboardCommand{
+			Dashboard: dash.Data,
+			OrgId:     sc.user.OrgId,
+			UserId:    sc.user.UserId,
+			FolderId:  sc.folder.Id,
+			Overwrite: true,
+		})
+		require.NoError(t, err)
+		err = sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+		require.NoError(t, err)
+		return dashInDB
+	}
+
+	scenarioWithLibraryPanel(t, "When an admin unlinks a library panel, it should copy the model into connected dashboards and allow deletion",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := saveDashboardWithLibraryPanel(t, sc)
+			sc.reqContext.ReplaceAllParams(map[string]string{
+				":uid":         sc.initialResult.Result.UID,
+				":dashboardId": strconv.FormatInt(dashInDB.Id, 10),
+			})
+			resp := sc.service.setConnectionOverridesHandler(sc.reqContext, setConnectionOverridesCommand{
+				Overrides: []byte(`{"title": "Text (prod)"}`),
+			})
+			require.Equal(t, 200, resp.Status())
+
+			resp = sc.service.unlinkHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var result struct {
+				DashboardUIDs []string `json:"dashboardUids"`
+			}
+			err := json.Unmarshal(resp.Body(), &result)
+			require.NoError(t, err)
+			require.Equal(t, []string{dashInDB.Uid}, result.DashboardUIDs)
+
+			dash, err := sc.sqlStore.GetDashboard(dashInDB.Id, sc.user.OrgId, "", "")
+			require.NoError(t, err)
+			panel := dash.Data.Get("panels").GetIndex(0)
+			require.Equal(t, int64(1), panel.Get("id").MustInt64())
+			require.Equal(t, 6, panel.Get("gridPos").Get("w").MustInt())
+			require.Equal(t, "Text (prod)", panel.Get("title").MustString())
+			require.Equal(t, "A description", panel.Get("description").MustString())
+			require.Nil(t, panel.Get("libraryPanel").Interface())
+			require.Empty(t, getConnections(t, sc, "").Connections)
+
+			resp = sc.service.deleteHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin unlinks a library panel that isn't connected, it should succeed without changes",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.unlinkHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+		})
+
+	scenarioWithLibraryPanel(t, "When an admin unlinks a library panel that doesn't exist, it should fail",
+		func(t *testing.T, sc scenarioContext) {
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": "unknown"})
+			resp := sc.service.unlinkHandler(sc.reqContext)
+			require.Equal(t, 404, resp.Status())
+		})
+}
@@ -0,0 +1,49 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/usagestats"
+)
+
+type testUsageStats struct {
+	metrics map[string]usagestats.MetricFunc
+}
+
+func (s *testUsageStats) GetUsageReport(ctx context.Context) (usagestats.UsageReport, error) {
+	return usagestats.UsageReport{}, nil
+}
+
+func (s *testUsageStats) RegisterMetric(name string, fn usagestats.MetricFunc) {
+	s.metrics[name] = fn
+}
+
+func TestLibraryPanelUsageMetrics(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When usage stats are collected, they should count library panels and the features they use",
+		func(t *testing.T, sc scenarioContext) {
+			command := getCreateCommandWithModel(sc.folder.Id, "Graph", []byte(`{"type": "graph"}`))
+			command.Tags = []string{"team-a"}
+			resp := sc.service.createHandler(sc.reqContext, command)
+			require.Equal(t, 200, resp.Status())
+
+			usageStats := &testUsageStats{metrics: make(map[string]usagestats.MetricFunc)}
+			sc.service.registerUsageMetrics(usageStats)
+			require.Len(t, usageStats.metrics, len(sc.service.usageMetrics()))
+
+			for name, expected := range map[string]int64{
+				"stats.library_panels.count":             2,
+				"stats.library_panels.provisioned.count": 0,
+				"stats.library_panels.types.count":       2,
+				"stats.library_panels.max_version":       1,
+				"stats.library_panels.tagged.count":      1,
+				"stats.library_panels.trashed.count":     0,
+			} {
+				value, err := usageStats.metrics[name]()
+				require.NoError(t, err)
+				require.Equal(t, expected, value, name)
+			}
+		})
+}
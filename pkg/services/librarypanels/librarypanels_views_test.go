@@ -0,0 +1,139 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestLibraryPanelViews(t *testing.T) {
+	scenarioWithLibraryPanel(t, "When a dashboard with a library panel is viewed, it should count the view once the views are flushed",
+		func(t *testing.T, sc scenarioContext) {
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+				err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+				require.NoError(t, err)
+			}
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(0), result.Result.Meta.ViewsLast30Days)
+
+			flushed, err := sc.service.flushViews(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(2), flushed)
+			resp = sc.service.getHandler(sc.reqContext)
+			result = validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(2), result.Result.Meta.ViewsLast30Days)
+
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			flushed, err = sc.service.flushViews(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(1), flushed)
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 200, resp.Status())
+			var results libraryPanelsSearch
+			err = json.Unmarshal(resp.Body(), &results)
+			require.NoError(t, err)
+			require.Len(t, results.Result.LibraryPanels, 1)
+			require.Equal(t, int64(3), results.Result.LibraryPanels[0].Meta.ViewsLast30Days)
+		})
+
+	scenarioWithLibraryPanel(t, "When library panel views are older than 30 days, they should not be counted and should be deleted on flush",
+		func(t *testing.T, sc scenarioContext) {
+			old := libraryPanelViewKey{panelID: sc.initialResult.Result.ID, day: time.Now().UTC().AddDate(0, 0, -31).Format(viewsDayLayout)}
+			recent := libraryPanelViewKey{panelID: sc.initialResult.Result.ID, day: time.Now().UTC().AddDate(0, 0, -29).Format(viewsDayLayout)}
+			err := sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				if err := addViews(session, old, 5); err != nil {
+					return err
+				}
+				if err := addViews(session, recent, 3); err != nil {
+					return err
+				}
+				return addViews(session, recent, 1)
+			})
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp := sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Equal(t, int64(4), result.Result.Meta.ViewsLast30Days)
+
+			_, err = sc.service.flushViews(context.Background())
+			require.NoError(t, err)
+			var days []struct {
+				Day string `xorm:"day"`
+			}
+			err = sc.sqlStore.WithDbSession(context.Background(), func(session *sqlstore.DBSession) error {
+				return session.SQL("SELECT day FROM library_panel_view").Find(&days)
+			})
+			require.NoError(t, err)
+			require.Len(t, days, 1)
+			require.Equal(t, recent.day, days[0].Day)
+		})
+
+	scenarioWithLibraryPanel(t, "When a library panel is connected and viewed, it should get last used times once they are flushed",
+		func(t *testing.T, sc scenarioContext) {
+			resp := sc.service.createHandler(sc.reqContext, getCreateCommand(sc.folder.Id, "Unused Panel"))
+			require.Equal(t, 200, resp.Status())
+
+			dashInDB := createDashboard(t, sc.sqlStore, sc.user, "Dash", sc.folder.Id)
+			dash := getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err := sc.service.ConnectLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+			dash = getDashboardWithLibraryPanel(sc, dashInDB.Id)
+			err = sc.service.LoadLibraryPanelsForDashboard(sc.reqContext, &dash)
+			require.NoError(t, err)
+
+			sc.reqContext.ReplaceAllParams(map[string]string{":uid": sc.initialResult.Result.UID})
+			resp = sc.service.getHandler(sc.reqContext)
+			result := validateAndUnMarshalResponse(t, resp)
+			require.Nil(t, result.Result.Meta.LastConnectedAt)
+			require.Nil(t, result.Result.Meta.LastViewedAt)
+
+			err = sc.service.flushLastUsed(context.Background())
+			require.NoError(t, err)
+			resp = sc.service.getHandler(sc.reqContext)
+			result = validateAndUnMarshalResponse(t, resp)
+			require.NotNil(t, result.Result.Meta.LastConnectedAt)
+			require.NotNil(t, result.Result.Meta.LastViewedAt)
+
+			search := func(rawQuery string) []string {
+				sc.ctx.Req.Request.URL, err = url.Parse("/?" + rawQuery)
+				require.NoError(t, err)
+				sc.ctx.Req.Form = nil
+				resp := sc.service.getAllHandler(sc.reqContext)
+				require.Equal(t, 200, resp.Status())
+				var results libraryPanelsSearch
+				err = json.Unmarshal(resp.Body(), &results)
+				require.NoError(t, err)
+				names := make([]string, 0, len(results.Result.LibraryPanels))
+				for _, panel := range results.Result.LibraryPanels {
+					names = append(names, panel.Name)
+				}
+				return names
+			}
+			require.Equal(t, []string{"Unused Panel"}, search("notViewedSince=1d"))
+			require.Equal(t, []string{"Unused Panel"}, search("notConnectedSince=1d"))
+			require.Equal(t, []string{"Text - Library Panel", "Unused Panel"}, search("sort=lastViewed:desc,name"))
+
+			sc.ctx.Req.Request.URL, err = url.Parse("/?notViewedSince=soon")
+			require.NoError(t, err)
+			sc.ctx.Req.Form = nil
+			resp = sc.service.getAllHandler(sc.reqContext)
+			require.Equal(t, 400, resp.Status())
+		})
+}
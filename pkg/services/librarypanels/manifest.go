@@ -0,0 +1,136 @@
+package librarypanels
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+const (
+	manifestStatusNotFound        = "not-found"
+	manifestStatusVersionMismatch = "version-mismatch"
+	manifestStatusHashMismatch    = "hash-mismatch"
+	manifestStatusUnpinned        = "unpinned"
+)
+
+// LibraryPanelManifestEntryDTO pins a library panel referenced by a dashboard to a version and a hash of its model.
+type LibraryPanelManifestEntryDTO struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+	Hash    string `json:"hash"`
+}
+
+// LibraryPanelManifestDTO is the manifest of all library panels referenced by a dashboard.
+type LibraryPanelManifestDTO struct {
+	LibraryPanels []LibraryPanelManifestEntryDTO `json:"libraryPanels"`
+}
+
+// LibraryPanelManifestMismatchDTO is a library panel that doesn't match its pinned version in a manifest.
+type LibraryPanelManifestMismatchDTO struct {
+	UID           string `json:"uid"`
+	Status        string `json:"status"`
+	PinnedVersion int64  `json:"pinnedVersion"`
+	Version       int64  `json:"version"`
+}
+
+// LibraryPanelManifestVerificationDTO is the result of verifying a manifest.
+type LibraryPanelManifestVerificationDTO struct {
+	Valid      bool                              `json:"valid"`
+	Mismatches []LibraryPanelManifestMismatchDTO `json:"mismatches"`
+}
+
+func hashLibraryPanelModel(panel LibraryPanelDTO) string {
+	return hashModel(panel.Model)
+}
+
+// getLibraryPanelUIDsForDashboard gets the uids of the library panels referenced by dashboard JSON, in the order
+// they're first referenced.
+func getLibraryPanelUIDsForDashboard(dashboard *simplejson.Json) ([]string, error) {
+	uids := make([]string, 0)
+	if dashboard == nil {
+		return uids, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, panel := range dashboard.Get("panels").MustArray() {
+		libraryPanel := simplejson.NewFromAny(panel).Get("libraryPanel")
+		if libraryPanel.Interface() == nil {
+			continue
+		}
+
+		uid := libraryPanel.Get("uid").MustString()
+		if len(uid) == 0 {
+			return nil, errLibraryPanelHeaderUIDMissing
+		}
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		uids = append(uids, uid)
+	}
+
+	return uids, nil
+}
+
+// createManifest pins the library panels referenced by dashboard JSON to their current versions.
+func (lps *LibraryPanelService) createManifest(c *models.ReqContext, cmd createManifestCommand) (LibraryPanelManifestDTO, error) {
+	uids, err := getLibraryPanelUIDsForDashboard(cmd.Dashboard)
+	if err != nil {
+		return LibraryPanelManifestDTO{}, err
+	}
+
+	manifest := LibraryPanelManifestDTO{LibraryPanels: make([]LibraryPanelManifestEntryDTO, 0, len(uids))}
+	for _, uid := range uids {
+		panel, err := lps.getLibraryPanel(c, uid)
+		if err != nil {
+			return LibraryPanelManifestDTO{}, err
+		}
+		manifest.LibraryPanels = append(manifest.LibraryPanels, LibraryPanelManifestEntryDTO{
+			UID:     panel.UID,
+			Name:    panel.Name,
+			Version: panel.Version,
+			Hash:    hashLibraryPanelModel(panel),
+		})
+	}
+
+	return manifest, nil
+}
+
+// verifyManifest checks that the library panels pinned by a manifest still have the pinned versions and models.
+// If dashboard JSON is given, all library panels it references must be pinned as well.
+func (lps *LibraryPanelService) verifyManifest(c *models.ReqContext, cmd verifyManifestCommand) (LibraryPanelManifestVerificationDTO, error) {
+	uids, err := getLibraryPanelUIDsForDashboard(cmd.Dashboard)
+	if err != nil {
+		return LibraryPanelManifestVerificationDTO{}, err
+	}
+
+	mismatches := make([]LibraryPanelManifestMismatchDTO, 0)
+	pinned := make(map[string]bool)
+	for _, entry := range cmd.LibraryPanels {
+		pinned[entry.UID] = true
+		panel, err := lps.getLibraryPanel(c, entry.UID)
+		if errors.Is(err, errLibraryPanelNotFound) {
+			mismatches = append(mismatches, LibraryPanelManifestMismatchDTO{UID: entry.UID, Status: manifestStatusNotFound, PinnedVersion: entry.Version})
+			continue
+		}
+		if err != nil {
+			return LibraryPanelManifestVerificationDTO{}, err
+		}
+
+		switch {
+		case panel.Version != entry.Version:
+			mismatches = append(mismatches, LibraryPanelManifestMismatchDTO{UID: entry.UID, Status: manifestStatusVersionMismatch, PinnedVersion: entry.Version, Version: panel.Version})
+		case hashLibraryPanelModel(panel) != entry.Hash:
+			mismatches = append(mismatches, LibraryPanelManifestMismatchDTO{UID: entry.UID, Status: manifestStatusHashMismatch, PinnedVersion: entry.Version, Version: panel.Version})
+		}
+	}
+	for _, uid := range uids {
+		if !pinned[uid] {
+			mismatches = append(mismatches, LibraryPanelManifestMismatchDTO{UID: uid, Status: manifestStatusUnpinned})
+		}
+	}
+
+	return LibraryPanelManifestVerificationDTO{Valid: len(mismatches) == 0, Mismatches: mismatches}, nil
+}
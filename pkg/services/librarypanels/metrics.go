@@ -0,0 +1,57 @@
+package librarypanels
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	operationCreate = "create"
+	operationPatch  = "patch"
+	operationDelete = "delete"
+	operationSearch = "search"
+)
+
+var (
+	libraryPanelOperations        *prometheus.CounterVec
+	libraryPanelOperationDuration *prometheus.HistogramVec
+	libraryPanelSearchResults     prometheus.Histogram
+)
+
+func init() {
+	libraryPanelOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "library_panels_operations_total",
+		Help:      "Number of library panel creates, patches, deletes and searches by the status code they returned",
+		Namespace: "grafana",
+	}, []string{"operation", "status"})
+	libraryPanelOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "library_panels_operation_duration_seconds",
+		Help:      "Time library panel creates, patches, deletes and searches spent in the database",
+		Namespace: "grafana",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"operation"})
+	libraryPanelSearchResults = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:      "library_panels_search_results",
+		Help:      "Number of library panels returned by a library panel search page",
+		Namespace: "grafana",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 6),
+	})
+}
+
+// startOperation starts timing a library panel operation, the returned function records the status and duration of
+// the operation once it's done. The status is the status code the operation's error is returned with, so 400s and
+// 404s can be told apart from 500s.
+func startOperation(operation string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		status := "200"
+		if err != nil {
+			status = strconv.Itoa(toLibraryPanelError(err, "").Status())
+		}
+		libraryPanelOperations.WithLabelValues(operation, status).Inc()
+		libraryPanelOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
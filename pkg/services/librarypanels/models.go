@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
 )
 
 // LibraryPanel is the model for library panel definitions.
@@ -16,10 +18,17 @@ type LibraryPanel struct {
 	Type        string
 	Description string
 	Model       json.RawMessage
-	Version     int64
+	ModelHash   string `xorm:"model_hash"`
+	// NormalizedHash is the hash of the model without its placement, see normalizePanelModel.
+	NormalizedHash string `xorm:"normalized_hash"`
+	Version        int64
+	Provisioned    bool
 
-	Created time.Time
-	Updated time.Time
+	Created   time.Time
+	Updated   time.Time
+	DeletedAt *time.Time `xorm:"deleted_at"`
+	// TrashedName is the name of a Library Panel in the trash, see trashLibraryPanelSQL.
+	TrashedName string `xorm:"trashed_name"`
 
 	CreatedBy int64
 	UpdatedBy int64
@@ -35,10 +44,14 @@ type LibraryPanelWithMeta struct {
 	Type        string
 	Description string
 	Model       json.RawMessage
+	ModelHash   string `xorm:"model_hash"`
 	Version     int64
+	Provisioned bool
 
-	Created time.Time
-	Updated time.Time
+	Created         time.Time
+	Updated         time.Time
+	LastConnectedAt *time.Time `xorm:"last_connected_at"`
+	LastViewedAt    *time.Time `xorm:"last_viewed_at"`
 
 	CanEdit             bool
 	FolderName          string
@@ -54,16 +67,20 @@ type LibraryPanelWithMeta struct {
 
 // LibraryPanelDTO is the frontend DTO for library panels.
 type LibraryPanelDTO struct {
-	ID          int64               `json:"id"`
-	OrgID       int64               `json:"orgId"`
-	FolderID    int64               `json:"folderId"`
-	UID         string              `json:"uid"`
-	Name        string              `json:"name"`
-	Type        string              `json:"type"`
-	Description string              `json:"description"`
-	Model       json.RawMessage     `json:"model"`
-	Version     int64               `json:"version"`
-	Meta        LibraryPanelDTOMeta `json:"meta"`
+	ID          int64                  `json:"id"`
+	OrgID       int64                  `json:"orgId"`
+	FolderID    int64                  `json:"folderId"`
+	UID         string                 `json:"uid"`
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Description string                 `json:"description"`
+	Model       json.RawMessage        `json:"model"`
+	ModelHash   string                 `json:"modelHash"`
+	Version     int64                  `json:"version"`
+	Labels      map[string]string      `json:"labels"`
+	Tags        []string               `json:"tags"`
+	Inputs      []LibraryPanelInputDTO `json:"inputs"`
+	Meta        LibraryPanelDTOMeta    `json:"meta"`
 }
 
 // LibraryPanelSearchResult is the search result for library panels.
@@ -72,6 +89,60 @@ type LibraryPanelSearchResult struct {
 	LibraryPanels []LibraryPanelDTO `json:"libraryPanels"`
 	Page          int               `json:"page"`
 	PerPage       int               `json:"perPage"`
+	// Partial is true when connected dashboards were skipped because the search latency budget was spent.
+	Partial bool `json:"partial"`
+	// ContinueToken gets the next page when passed as continueToken, it's empty on the last page.
+	ContinueToken string `json:"continueToken"`
+}
+
+// DeleteLibraryPanelResultDTO is the outcome of deleting a single library panel in a bulk delete.
+type DeleteLibraryPanelResultDTO struct {
+	UID    string `json:"uid"`
+	Status string `json:"status"`
+}
+
+// MoveLibraryPanelResultDTO is the outcome of moving a single library panel in a bulk move. A library panel that
+// couldn't be moved with its dashboard has the error and the folder it stayed in.
+type MoveLibraryPanelResultDTO struct {
+	UID      string `json:"uid"`
+	FolderID int64  `json:"folderId"`
+	Version  int64  `json:"version"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TrashedLibraryPanelDTO is the DTO for library panels in the trash. It doesn't contain the panel model.
+type TrashedLibraryPanelDTO struct {
+	UID         string    `json:"uid"`
+	FolderID    int64     `json:"folderId"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	DeletedAt   time.Time `json:"deletedAt"`
+}
+
+// RewriteDatasourceResultDTO is the outcome of rewriting the datasource references of a single library panel.
+type RewriteDatasourceResultDTO struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+	Status  string `json:"status"`
+}
+
+// LibraryPanelConnectionDTO is the DTO for a dashboard connected to a library panel.
+type LibraryPanelConnectionDTO struct {
+	DashboardID    int64  `json:"dashboardId"`
+	DashboardUID   string `json:"dashboardUid"`
+	DashboardTitle string `json:"dashboardTitle"`
+	FolderID       int64  `json:"folderId"`
+	FolderUID      string `json:"folderUid"`
+	FolderTitle    string `json:"folderTitle"`
+	CanView        bool   `json:"canView"`
+	// Created and CreatedBy are when and by whom the library panel was connected to the dashboard.
+	Created   time.Time               `json:"created"`
+	CreatedBy LibraryPanelDTOMetaUser `json:"createdBy"`
+	Overrides json.RawMessage         `json:"overrides,omitempty"`
+	// AcceptedVersion is the version of the library panel the dashboard shows when updates require approval.
+	AcceptedVersion int64 `json:"acceptedVersion,omitempty"`
 }
 
 // LibraryPanelDTOMeta is the meta information for LibraryPanelDTO.
@@ -80,6 +151,17 @@ type LibraryPanelDTOMeta struct {
 	FolderName          string `json:"folderName"`
 	FolderUID           string `json:"folderUid"`
 	ConnectedDashboards int64  `json:"connectedDashboards"`
+	// ViewsLast30Days is the number of times dashboards with the library panel were viewed in the last 30 days.
+	ViewsLast30Days int64 `json:"viewsLast30Days"`
+	// LastConnectedAt and LastViewedAt are when the library panel was last connected to a dashboard and last
+	// viewed on one, they're written in the background and can lag behind by a minute.
+	LastConnectedAt *time.Time `json:"lastConnectedAt,omitempty"`
+	LastViewedAt    *time.Time `json:"lastViewedAt,omitempty"`
+	Provisioned     bool       `json:"provisioned"`
+	// MinGrafanaVersion is the lowest Grafana version that supports the features used by the model.
+	MinGrafanaVersion string `json:"minGrafanaVersion,omitempty"`
+	// OrgName is only set when searching library panels in all orgs.
+	OrgName string `json:"orgName,omitempty"`
 
 	Created time.Time `json:"created"`
 	Updated time.Time `json:"updated"`
@@ -100,12 +182,33 @@ type libraryPanelDashboard struct {
 	ID             int64 `xorm:"pk autoincr 'id'"`
 	LibraryPanelID int64 `xorm:"librarypanel_id"`
 	DashboardID    int64 `xorm:"dashboard_id"`
+	// Overrides is a JSON object merged into the library panel model on this dashboard only.
+	Overrides json.RawMessage
+	// AcceptedVersion and AcceptedModel are the version of the library panel the dashboard was last updated to
+	// when updates require approval, 0 follows the latest version. RejectedVersion is the last update rejected.
+	AcceptedVersion int64 `xorm:"accepted_version"`
+	AcceptedModel   json.RawMessage
+	RejectedVersion int64 `xorm:"rejected_version"`
+	// CanaryID is the canary update that holds the dashboard on the previous version, 0 if there's none.
+	CanaryID int64 `xorm:"canary_id"`
 
 	Created time.Time
 
 	CreatedBy int64
 }
 
+const (
+	deleteStatusDeleted        = "deleted"
+	deleteStatusNotFound       = "not-found"
+	deleteStatusHasConnections = "has-connections"
+	deleteStatusProvisioned    = "provisioned"
+	deleteStatusAccessDenied   = "access-denied"
+	deleteStatusFrozen         = "frozen"
+)
+
+// defaultDatasource is the placeholder used in panel models that reference the default datasource of an org.
+const defaultDatasource = "default"
+
 var (
 	// errLibraryPanelAlreadyExists is an error for when the user tries to add a library panel that already exists.
 	errLibraryPanelAlreadyExists = errors.New("library panel with that name already exists")
@@ -121,25 +224,313 @@ var (
 	ErrFolderHasConnectedLibraryPanels = errors.New("folder contains library panels that are linked to dashboards")
 	// errLibraryPanelVersionMismatch is an error for when a library panel has been changed by someone else.
 	errLibraryPanelVersionMismatch = errors.New("the library panel has been changed by someone else")
+	// errLibraryPanelInvalidPermission is an error for when a library panel permission has no single user or team, or isn't View or Edit.
+	errLibraryPanelInvalidPermission = errors.New("permissions must have either a userId or a teamId and be View (1) or Edit (2)")
+	// errLibraryPanelAccessDenied is an error for when access control doesn't allow an action on a library panel.
+	errLibraryPanelAccessDenied = errors.New("access denied to library panel")
+	// errLibraryPanelVersionRequired is an error for when an user changes a library panel without a version or If-Match header.
+	errLibraryPanelVersionRequired = errors.New("version or If-Match header is required")
 	// errLibraryPanelHasConnectedDashboards is an error for when an user deletes a library panel that is connected to library panels.
 	errLibraryPanelHasConnectedDashboards = errors.New("the library panel is linked to dashboards")
+	// errLibraryPanelProvisioned is an error for when an user tries to change or delete a provisioned library panel.
+	errLibraryPanelProvisioned = errors.New("cannot change or delete a provisioned library panel")
+	// errLibraryPanelInvalidLabel is an error for when a label name or value contains invalid characters.
+	errLibraryPanelInvalidLabel = errors.New("label names must not be empty or contain '=', '!' or ',' and label values must not contain ','")
+	// errLibraryPanelInvalidTag is an error for when a library panel tag is too long.
+	errLibraryPanelInvalidTag = errors.New("tags must not be longer than 50 characters")
+	// errLibraryPanelInvalidLabelSelector is an error for when a label selector can't be parsed.
+	errLibraryPanelInvalidLabelSelector = errors.New("invalid label selector, expected a comma separated list of name=value or name!=value")
+	// errLibraryPanelMissingRequiredLabels is an error for when a library panel lacks labels required by the org's label policy.
+	errLibraryPanelMissingRequiredLabels = errors.New("library panel is missing labels required by the organization's label policy")
+	// errLibraryPanelTokenNotFound is an error for when a library panel token can't be found.
+	errLibraryPanelTokenNotFound = errors.New("library panel token could not be found")
+	// errLibraryPanelTokenInvalid is an error for when a library panel token doesn't match any token.
+	errLibraryPanelTokenInvalid = errors.New("invalid library panel token")
+	// errLibraryPanelTokenExpired is an error for when a library panel token has expired.
+	errLibraryPanelTokenExpired = errors.New("library panel token has expired")
+	// errLibraryPanelTokenInvalidSecondsToLive is an error for when a library panel token is created with a negative time to live.
+	errLibraryPanelTokenInvalidSecondsToLive = errors.New("secondsToLive must not be negative")
+	// errLibraryPanelSandboxInvalidSecondsToLive is an error for when a sandbox is created with a time to live that is negative or too long.
+	errLibraryPanelSandboxInvalidSecondsToLive = errors.New("secondsToLive must be between 0 and 604800")
+	// errLibraryPanelInvalidContinueToken is an error for when a search is continued with a token that can't be parsed.
+	errLibraryPanelInvalidContinueToken = errors.New("invalid continueToken")
+	// errLibraryPanelInvalidFolderPermission is an error for when folders are listed for an unknown permission.
+	errLibraryPanelInvalidFolderPermission = errors.New("permission must be either view or create")
+	// errLibraryPanelInvalidScreenshotURL is an error for when a library panel is published with a screenshot URL that isn't an absolute http(s) URL.
+	errLibraryPanelInvalidScreenshotURL = errors.New("screenshotUrl must be an absolute http or https URL")
+	// errLibraryPanelNotPublished is an error for when an user unpublishes a library panel that isn't in the catalog.
+	errLibraryPanelNotPublished = errors.New("library panel is not published to the catalog")
+	// errLibraryPanelInvalidModel is an error for when a library panel model has a type or description that isn't a string.
+	errLibraryPanelInvalidModel = errors.New("library panel model type and description must be strings")
+	// errLibraryPanelQuotaReached is an error for when an user creates a library panel and the org or global quota is reached.
+	errLibraryPanelQuotaReached = errors.New("library panel quota reached")
+	// errLibraryPanelNotOrphaned is an error for when a library panel was connected or updated after it was selected for archiving.
+	errLibraryPanelNotOrphaned = errors.New("library panel was connected or updated since it was selected for archiving")
+	// errLibraryPanelFrozen is an error for when an user changes or deletes a published library panel during a freeze window.
+	errLibraryPanelFrozen = errors.New("published library panels can't be changed or deleted during a freeze window")
+	// errLibraryPanelInvalidFreezeWindow is an error for when a freeze window has no name or doesn't end after it starts.
+	errLibraryPanelInvalidFreezeWindow = errors.New("freeze windows must have a name and end after they start")
+	// errLibraryPanelFreezeWindowNotFound is an error for when a freeze window can't be found.
+	errLibraryPanelFreezeWindowNotFound = errors.New("freeze window could not be found")
+	// errLibraryPanelFreezeWindowProvisioned is an error for when an user tries to delete a provisioned freeze window.
+	errLibraryPanelFreezeWindowProvisioned = errors.New("cannot delete a provisioned freeze window")
+	// errLibraryPanelInvalidReorgMapping is an error for when a reorg maps something twice, to itself or to a team, folder or user that doesn't exist.
+	errLibraryPanelInvalidReorgMapping = errors.New("reorg mappings must map each team, folder or owner once to an existing team, folder or user")
+	// errLibraryPanelReorgNotFound is an error for when a reorg can't be found.
+	errLibraryPanelReorgNotFound = errors.New("reorg could not be found")
+	// errLibraryPanelInvalidConnectionSort is an error for when connections are sorted by something else than title or updated.
+	errLibraryPanelInvalidConnectionSort = errors.New("connections can only be sorted by alpha-asc, alpha-desc, updated-asc or updated-desc")
+	// errLibraryPanelInvalidImportInput is an error for when a library panel input of an imported dashboard isn't declared or has no model.
+	errLibraryPanelInvalidImportInput = errors.New("library panel inputs must be declared in __inputs with a model")
+	// errLibraryPanelInvalidJSONPatch is an error for when a JSON Patch can't be parsed or applied to a library panel model.
+	errLibraryPanelInvalidJSONPatch = errors.New("invalid JSON Patch")
+	// errLibraryPanelJSONPatchTestFailed is an error for when a test operation of a JSON Patch doesn't match the library panel model.
+	errLibraryPanelJSONPatchTestFailed = errors.New("JSON Patch test operation failed")
+	// errLibraryPanelInvalidGroup is an error for when a library panel group has no name or no library panels, or has a library panel twice.
+	errLibraryPanelInvalidGroup = errors.New("library panel groups must have a name and at least one library panel, each at most once")
+	// errLibraryPanelGroupAlreadyExists is an error for when a library panel group is given the name of another group.
+	errLibraryPanelGroupAlreadyExists = errors.New("library panel group with that name already exists")
+	// errLibraryPanelGroupNotFound is an error for when a library panel group can't be found.
+	errLibraryPanelGroupNotFound = errors.New("library panel group could not be found")
+	// errLibraryPanelGroupIncomplete is an error for when a library panel group is published without updating each of its library panels exactly once.
+	errLibraryPanelGroupIncomplete = errors.New("publishing a library panel group must update each of its library panels exactly once")
+	// errLibraryPanelInvalidOverrides is an error for when the overrides of a library panel connection aren't a small JSON object with supported keys.
+	errLibraryPanelInvalidOverrides = errors.New("invalid library panel connection overrides")
+	// errLibraryPanelNoPendingUpdate is an error for when a library panel update is accepted or rejected for a dashboard that has none pending.
+	errLibraryPanelNoPendingUpdate = errors.New("library panel has no pending update for the dashboard")
+	// errLibraryPanelInvalidInputs is an error for when the inputs declared by a library panel are invalid.
+	errLibraryPanelInvalidInputs = errors.New("library panel inputs must have unique names of letters, digits and underscores and a text or datasource type")
+	// errLibraryPanelInvalidInputValues is an error for when a library panel model is rendered with unknown or missing inputs.
+	errLibraryPanelInvalidInputValues = errors.New("invalid library panel input values")
+	// errLibraryPanelInvalidPack is an error for when a pack refers to folders or library panels it doesn't contain.
+	errLibraryPanelInvalidPack = errors.New("pack must contain or remap every folder and library panel it refers to")
+	// errLibraryPanelInvalidRecommendationType is an error for when recommendations are requested for an unknown type.
+	errLibraryPanelInvalidRecommendationType = errors.New("recommendation type must be cleanup or consolidate")
+	// errLibraryPanelInvalidConnectedFilter is an error for when library panels are searched by a connected filter that isn't a boolean.
+	errLibraryPanelInvalidConnectedFilter = errors.New("connected must be either true or false")
+	// errLibraryPanelInvalidStaleWindow is an error for when stale library panels are searched with a time window that can't be parsed.
+	errLibraryPanelInvalidStaleWindow = errors.New("time window must be a positive duration such as 90d or 12w")
+	// errLibraryPanelCanaryInProgress is an error for when the model of a library panel is updated while a canary update of it soaks.
+	errLibraryPanelCanaryInProgress = errors.New("the library panel has a canary update in progress")
+	// errLibraryPanelCanaryNotFound is an error for when a library panel has never had a canary update.
+	errLibraryPanelCanaryNotFound = errors.New("library panel has no canary update")
+	// errLibraryPanelInvalidArchivePolicy is an error for when an archive policy has an unknown action or no days.
+	errLibraryPanelInvalidArchivePolicy = errors.New("archive policy must have a positive number of days and an action of trash or folder")
+	// errLibraryPanelInvalidAuditExportFormat is an error for when the audit log is exported in an unknown format.
+	errLibraryPanelInvalidAuditExportFormat = errors.New("audit log export format must be jsonl or cef")
+	// errLibraryPanelInvalidAuditTimeRange is an error for when the audit log is exported with to before from.
+	errLibraryPanelInvalidAuditTimeRange = errors.New("audit log export time range must have from before to")
+	// errLibraryPanelVersionNotFound is an error for when a version of a library panel isn't in its history.
+	errLibraryPanelVersionNotFound = errors.New("library panel version could not be found")
+	// errLibraryPanelInvalidSort is an error for when a search is sorted by an unknown field or direction.
+	errLibraryPanelInvalidSort = errors.New("sort must be a list of name, type, created, updated or folder, each with an optional :asc or :desc")
+	// errLibraryPanelSortWithContinueToken is an error for when a search with a continueToken is sorted by sort.
+	errLibraryPanelSortWithContinueToken = errors.New("continueToken can't be combined with sort, use page instead")
+	// errLibraryPanelAllOrgsWithContinueToken is an error for when a search with a continueToken is in all orgs.
+	errLibraryPanelAllOrgsWithContinueToken = errors.New("continueToken can't be combined with orgId=all, use page instead")
+	// errLibraryPanelInvalidUID is an error for when a library panel uid is empty, too long or has invalid characters.
+	errLibraryPanelInvalidUID = errors.New("library panel uid must be 1 to 40 letters, digits, - or _")
+	// errLibraryPanelUIDAlreadyExists is an error for when a library panel uid is taken by another library panel, which
+	// can be in the trash.
+	errLibraryPanelUIDAlreadyExists = errors.New("library panel with that uid already exists or is in the trash")
+	// errLibraryPanelInvalidRedactionProfile is an error for when a pack is exported with an unknown redaction profile.
+	errLibraryPanelInvalidRedactionProfile = errors.New("redaction profile must be partner or support")
 )
 
 // Commands
 
 // createLibraryPanelCommand is the command for adding a LibraryPanel
 type createLibraryPanelCommand struct {
-	FolderID int64           `json:"folderId"`
-	Name     string          `json:"name"`
-	Model    json.RawMessage `json:"model"`
+	FolderID int64                  `json:"folderId"`
+	Name     string                 `json:"name"`
+	Model    json.RawMessage        `json:"model"`
+	Labels   map[string]string      `json:"labels"`
+	Tags     []string               `json:"tags"`
+	Inputs   []LibraryPanelInputDTO `json:"inputs"`
 }
 
 // patchLibraryPanelCommand is the command for patching a LibraryPanel
 type patchLibraryPanelCommand struct {
-	FolderID int64           `json:"folderId" binding:"Default(-1)"`
-	Name     string          `json:"name"`
-	Model    json.RawMessage `json:"model"`
-	Version  int64           `json:"version" binding:"Required"`
+	FolderID int64                  `json:"folderId" binding:"Default(-1)"`
+	Name     string                 `json:"name"`
+	Model    json.RawMessage        `json:"model"`
+	Labels   map[string]string      `json:"labels"`
+	Tags     []string               `json:"tags"`
+	Inputs   []LibraryPanelInputDTO `json:"inputs"`
+	// Version is required unless the request has an If-Match header.
+	Version int64 `json:"version"`
+	// Canary applies a model update to a sample of the connected dashboards first, see startCanary.
+	Canary bool `json:"canary"`
+}
+
+// cloneLibraryPanelCommand is the command for copying a LibraryPanel into a new LibraryPanel
+type cloneLibraryPanelCommand struct {
+	FolderID int64  `json:"folderId" binding:"Default(-1)"`
+	Name     string `json:"name"`
+}
+
+// deleteLibraryPanelsCommand is the command for deleting several LibraryPanels at once
+type deleteLibraryPanelsCommand struct {
+	UIDs []string `json:"uids" binding:"Required"`
+}
+
+// moveLibraryPanelsCommand is the command for moving several LibraryPanels to another folder
+type moveLibraryPanelsCommand struct {
+	UIDs     []string `json:"uids" binding:"Required"`
+	FolderID int64    `json:"folderId"`
+}
+
+// createLibraryPanelTokenCommand is the command for creating a token granting read access to a LibraryPanel
+type createLibraryPanelTokenCommand struct {
+	Name          string `json:"name" binding:"Required"`
+	SecondsToLive int64  `json:"secondsToLive"`
+}
+
+// setLibraryPanelPermissionsCommand is the command for replacing the permissions on a LibraryPanel
+type setLibraryPanelPermissionsCommand struct {
+	Items []LibraryPanelACLItemDTO `json:"items"`
+}
+
+// rewriteDatasourceCommand is the command for replacing references to one datasource in all LibraryPanels
+type rewriteDatasourceCommand struct {
+	FromUID string `json:"fromUid" binding:"Required"`
+	ToUID   string `json:"toUid" binding:"Required"`
+	DryRun  bool   `json:"dryRun"`
+}
+
+// createLibraryPanelSandboxCommand is the command for creating a temporary dashboard to try out a LibraryPanel
+type createLibraryPanelSandboxCommand struct {
+	SecondsToLive int64 `json:"secondsToLive"`
+}
+
+// publishLibraryPanelCommand is the command for publishing a LibraryPanel to the catalog
+type publishLibraryPanelCommand struct {
+	Readme        string `json:"readme"`
+	ScreenshotURL string `json:"screenshotUrl"`
+}
+
+// setLabelPolicyCommand is the command for setting the labels an org requires on library panels
+type setLabelPolicyCommand struct {
+	RequiredLabels []string `json:"requiredLabels"`
+}
+
+// createFreezeWindowCommand is the command for declaring a freeze window
+type createFreezeWindowCommand struct {
+	Name   string    `json:"name" binding:"Required"`
+	Reason string    `json:"reason"`
+	Starts time.Time `json:"starts" binding:"Required"`
+	Ends   time.Time `json:"ends" binding:"Required"`
+}
+
+// createManifestCommand is the command for pinning the LibraryPanels referenced by a dashboard
+type createManifestCommand struct {
+	Dashboard *simplejson.Json `json:"dashboard" binding:"Required"`
+}
+
+// verifyManifestCommand is the command for verifying that pinned LibraryPanels haven't changed
+type verifyManifestCommand struct {
+	Dashboard     *simplejson.Json               `json:"dashboard"`
+	LibraryPanels []LibraryPanelManifestEntryDTO `json:"libraryPanels"`
+}
+
+// reorgLibraryPanelsCommand is the command for moving LibraryPanels to new teams, folders and owners after a re-org
+type reorgLibraryPanelsCommand struct {
+	Teams   []LibraryPanelReorgMappingDTO `json:"teams"`
+	Folders []LibraryPanelReorgMappingDTO `json:"folders"`
+	Owners  []LibraryPanelReorgMappingDTO `json:"owners"`
+	DryRun  bool                          `json:"dryRun"`
+}
+
+// rollbackReorgCommand is the command for restoring LibraryPanels from the rollback file of a reorg
+type rollbackReorgCommand struct {
+	ReorgID       int64                       `json:"reorgId"`
+	LibraryPanels []LibraryPanelReorgStateDTO `json:"libraryPanels"`
+}
+
+// setLibraryPanelGroupCommand is the command for creating or changing a group of LibraryPanels that are published together
+type setLibraryPanelGroupCommand struct {
+	Name string   `json:"name"`
+	UIDs []string `json:"uids"`
+}
+
+// publishLibraryPanelGroupCommand is the command for updating all LibraryPanels of a group at once
+type publishLibraryPanelGroupCommand struct {
+	Panels []publishLibraryPanelGroupPanel `json:"panels"`
+}
+
+// publishLibraryPanelGroupPanel is the change to a LibraryPanel of a group that is published
+type publishLibraryPanelGroupPanel struct {
+	UID     string          `json:"uid"`
+	Name    string          `json:"name"`
+	Model   json.RawMessage `json:"model"`
+	Version int64           `json:"version"`
+}
+
+// renderLibraryPanelModelCommand is the command for rendering the model of a LibraryPanel with input values.
+type renderLibraryPanelModelCommand struct {
+	Inputs map[string]string `json:"inputs"`
+}
+
+// setConnectionOverridesCommand is the command for setting the overrides of a LibraryPanel connection.
+type setConnectionOverridesCommand struct {
+	Overrides json.RawMessage `json:"overrides"`
+}
+
+// exportPackCommand is the command for exporting Dashboards with their LibraryPanels as a pack.
+type exportPackCommand struct {
+	Name               string   `json:"name"`
+	DashboardUIDs      []string `json:"dashboardUids"`
+	IncludeConnections bool     `json:"includeConnections"`
+	// Versions pins library panels to a version from their history by uid, other library panels are exported at
+	// their latest version.
+	Versions map[string]int64 `json:"versions"`
+	// RedactionProfile strips internal details from the pack, partner or support, see redactionProfiles. Redact
+	// strips more on top of the profile.
+	RedactionProfile string        `json:"redactionProfile"`
+	Redact           packRedaction `json:"redact"`
+}
+
+// installPackCommand is the command for installing a pack. FolderUIDs and LibraryPanelUIDs map uids in the pack to
+// existing folders and LibraryPanels.
+type installPackCommand struct {
+	Pack             LibraryPanelPackDTO `json:"pack"`
+	FolderUIDs       map[string]string   `json:"folderUids"`
+	LibraryPanelUIDs map[string]string   `json:"libraryPanelUids"`
+	Overwrite        bool                `json:"overwrite"`
+}
+
+// dedupeLibraryPanelsCommand is the command for promoting identical panels of an org's dashboards to LibraryPanels.
+type dedupeLibraryPanelsCommand struct {
+	FolderID  int64 `json:"folderId"`
+	MinPanels int   `json:"minPanels"`
+	DryRun    bool  `json:"dryRun"`
+}
+
+// setArchivePolicyCommand is the command for setting the archive policy of an org.
+type setArchivePolicyCommand struct {
+	Enabled bool   `json:"enabled"`
+	Action  string `json:"action"`
+	Days    int64  `json:"days"`
+	// FolderID is the folder the folder action moves library panels to, an Archive folder is used when it's 0.
+	FolderID int64 `json:"folderId"`
+}
+
+// ProvisionFreezeWindowCommand is the command for declaring a freeze window from provisioning.
+type ProvisionFreezeWindowCommand struct {
+	OrgID  int64
+	Name   string
+	Reason string
+	Starts time.Time
+	Ends   time.Time
+}
+
+// ProvisionLibraryPanelCommand is the command for creating or updating a LibraryPanel from provisioning.
+type ProvisionLibraryPanelCommand struct {
+	OrgID     int64
+	FolderUID string
+	UID       string
+	Name      string
+	Model     json.RawMessage
 }
 
 // searchLibraryPanelsQuery is the query used for searching for LibraryPanels
@@ -151,4 +542,29 @@ type searchLibraryPanelsQuery struct {
 	panelFilter   string
 	excludeUID    string
 	folderFilter  string
+	labelSelector string
+	tags          []string
+	searchIn      string
+	datasourceUID string
+	// continueToken replaces page with keyset pagination, it's the ContinueToken of the previous page.
+	continueToken string
+	// datasourceRefs are the ways a panel model can reference the datasource in datasourceUID.
+	datasourceRefs []string
+	// createdBy limits the search to library panels created by a user.
+	createdBy int64
+	// allOrgs searches library panels in all orgs, only server admins can search all orgs.
+	allOrgs bool
+	// connected limits the search to library panels with ("true") or without ("false") connected dashboards.
+	connected string
+	// notUpdatedSince limits the search to library panels not updated within a time window, e.g. 90d.
+	notUpdatedSince string
+	// notConnectedSince and notViewedSince limit the search to library panels not connected to a dashboard or not
+	// viewed within a time window, e.g. 30d. Library panels that were never connected or viewed are included.
+	notConnectedSince string
+	notViewedSince    string
+	// sort is a compound sort expression such as folder:asc,updated:desc, it replaces sortDirection.
+	sort string
 }
+
+// searchInModel makes the search string also match the model of library panels, e.g. queries or field names.
+const searchInModel = "model"
@@ -0,0 +1,154 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// maxConnectionOverridesSize is the maximum size in bytes of the overrides of a connection.
+const maxConnectionOverridesSize = 4096
+
+// connectionOverrideKeys are the properties of a library panel model that a connection can override.
+var connectionOverrideKeys = map[string]bool{
+	"title":           true,
+	"description":     true,
+	"thresholds":      true,
+	"fieldConfig":     true,
+	"repeat":          true,
+	"repeatDirection": true,
+	"maxPerRow":       true,
+}
+
+// validateConnectionOverrides checks that overrides are a small JSON object that only has supported keys. Empty
+// or null overrides are returned as nil, which removes them from a connection.
+func validateConnectionOverrides(overrides json.RawMessage) (json.RawMessage, error) {
+	if len(overrides) == 0 || string(overrides) == "null" {
+		return nil, nil
+	}
+	if len(overrides) > maxConnectionOverridesSize {
+		return nil, fmt.Errorf("%w: overrides must be at most %d bytes", errLibraryPanelInvalidOverrides, maxConnectionOverridesSize)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(overrides, &keys); err != nil || keys == nil {
+		return nil, fmt.Errorf("%w: overrides must be a JSON object", errLibraryPanelInvalidOverrides)
+	}
+	for key := range keys {
+		if !connectionOverrideKeys[key] {
+			return nil, fmt.Errorf("%w: %q can't be overridden", errLibraryPanelInvalidOverrides, key)
+		}
+	}
+
+	return overrides, nil
+}
+
+// mergeJSON applies an RFC 7396 JSON Merge Patch to a value decoded from JSON. Objects are merged recursively, a
+// null member removes the member and any other value replaces the target.
+func mergeJSON(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{}, len(patchObject))
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeJSON(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// applyConnectionOverrides merges the overrides of a connection into a library panel model.
+func applyConnectionOverrides(model json.RawMessage, overrides json.RawMessage) (json.RawMessage, error) {
+	if len(overrides) == 0 {
+		return model, nil
+	}
+
+	var doc, patch interface{}
+	if err := json.Unmarshal(model, &doc); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overrides, &patch); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeJSON(doc, patch))
+}
+
+// getConnectionsForDashboard gets the connections of a dashboard by library panel id.
+func getConnectionsForDashboard(session *sqlstore.DBSession, dashboardID int64) (map[int64]libraryPanelDashboard, error) {
+	var connections []libraryPanelDashboard
+	if err := session.SQL("SELECT * FROM library_panel_dashboard WHERE dashboard_id=?", dashboardID).Find(&connections); err != nil {
+		return nil, err
+	}
+
+	byLibraryPanelID := make(map[int64]libraryPanelDashboard, len(connections))
+	for _, connection := range connections {
+		byLibraryPanelID[connection.LibraryPanelID] = connection
+	}
+
+	return byLibraryPanelID, nil
+}
+
+// getConnectionsForDashboard gets the connections of a Dashboard by Library Panel id.
+func (lps *LibraryPanelService) getConnectionsForDashboard(ctx context.Context, dashboardID int64) (map[int64]libraryPanelDashboard, error) {
+	var connections map[int64]libraryPanelDashboard
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		var err error
+		connections, err = getConnectionsForDashboard(session, dashboardID)
+		return err
+	})
+
+	return connections, err
+}
+
+// setConnectionOverrides sets the overrides of the connection between a Library Panel and a Dashboard. Overrides
+// belong to the dashboard, so the signed in user must be allowed to edit it.
+func (lps *LibraryPanelService) setConnectionOverrides(c *models.ReqContext, uid string, dashboardID int64, overrides json.RawMessage) error {
+	overrides, err := validateConnectionOverrides(overrides)
+	if err != nil {
+		return err
+	}
+
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := requireDashboardEdit(c.SignedInUser, dashboardID); err != nil {
+			return err
+		}
+
+		result, err := session.Exec("UPDATE library_panel_dashboard SET overrides=? WHERE librarypanel_id=? AND dashboard_id=?",
+			jsonColumn(overrides), panel.ID, dashboardID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected != 1 {
+			return errLibraryPanelDashboardNotFound
+		}
+
+		return nil
+	})
+}
+
+// jsonColumn is the value stored in a nullable JSON column, NULL for no JSON.
+func jsonColumn(value json.RawMessage) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+
+	return string(value)
+}
@@ -0,0 +1,410 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/guardian"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// LibraryPanelPackDTO is a pack of dashboards together with the library panels and folders they need, so a set of
+// dashboards can be installed in another org or instance in one call.
+type LibraryPanelPackDTO struct {
+	Name          string                         `json:"name"`
+	Folders       []LibraryPanelPackFolderDTO    `json:"folders"`
+	LibraryPanels []LibraryPanelPackElementDTO   `json:"libraryPanels"`
+	Dashboards    []LibraryPanelPackDashboardDTO `json:"dashboards"`
+	// Connections are the dashboards that use the library panels of the pack, including dashboards that aren't in it.
+	Connections []LibraryPanelPackConnectionDTO `json:"connections,omitempty"`
+}
+
+// LibraryPanelPackFolderDTO is a folder in a pack.
+type LibraryPanelPackFolderDTO struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// LibraryPanelPackElementDTO is a library panel in a pack, an empty folder uid is the General folder.
+type LibraryPanelPackElementDTO struct {
+	UID       string                 `json:"uid"`
+	Name      string                 `json:"name"`
+	FolderUID string                 `json:"folderUid"`
+	Model     json.RawMessage        `json:"model"`
+	Labels    map[string]string      `json:"labels,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+	Inputs    []LibraryPanelInputDTO `json:"inputs,omitempty"`
+}
+
+// LibraryPanelPackDashboardDTO is a dashboard in a pack, an empty folder uid is the General folder.
+type LibraryPanelPackDashboardDTO struct {
+	FolderUID string           `json:"folderUid"`
+	Dashboard *simplejson.Json `json:"dashboard"`
+}
+
+// LibraryPanelPackConnectionDTO is a dashboard that uses a library panel of a pack.
+type LibraryPanelPackConnectionDTO struct {
+	DashboardUID    string `json:"dashboardUid"`
+	LibraryPanelUID string `json:"libraryPanelUid"`
+}
+
+// LibraryPanelPackInstallResultDTO maps the folder and library panel uids of a pack to the uids they were installed
+// as, and lists the uids of the installed dashboards.
+type LibraryPanelPackInstallResultDTO struct {
+	FolderUIDs       map[string]string `json:"folderUids"`
+	LibraryPanelUIDs map[string]string `json:"libraryPanelUids"`
+	DashboardUIDs    []string          `json:"dashboardUids"`
+	// Connections are the connections of the pack that were restored, SkippedConnections the ones to dashboards that
+	// don't exist.
+	Connections        int `json:"connections"`
+	SkippedConnections int `json:"skippedConnections"`
+}
+
+// getViewableConnectedDashboardUIDs gets the uids of the dashboards connected to a Library Panel that the user can
+// view.
+func getViewableConnectedDashboardUIDs(session *sqlstore.DBSession, user *models.SignedInUser, panelID int64) ([]string, error) {
+	var dashboards []struct {
+		UID string `xorm:"uid"`
+	}
+	builder := sqlstore.SQLBuilder{}
+	builder.Write("SELECT dashboard.uid FROM library_panel_dashboard lpd")
+	builder.Write(" INNER JOIN dashboard AS dashboard on lpd.dashboard_id = dashboard.id")
+	builder.Write(` WHERE lpd.librarypanel_id=?`, panelID)
+	if user.OrgRole != models.ROLE_ADMIN {
+		builder.WriteDashboardPermissionFilter(user, models.PERMISSION_VIEW)
+	}
+	builder.Write(" ORDER BY dashboard.uid")
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&dashboards); err != nil {
+		return nil, err
+	}
+
+	uids := make([]string, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		uids = append(uids, dashboard.UID)
+	}
+
+	return uids, nil
+}
+
+// visitLibraryPanelReferences calls visit with the libraryPanel of every panel, including panels in collapsed rows.
+func visitLibraryPanelReferences(panels []interface{}, visit func(libraryPanel map[string]interface{})) {
+	for _, element := range panels {
+		panel, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rowPanels, ok := panel["panels"].([]interface{}); ok {
+			visitLibraryPanelReferences(rowPanels, visit)
+		}
+		if libraryPanel, ok := panel["libraryPanel"].(map[string]interface{}); ok {
+			visit(libraryPanel)
+		}
+	}
+}
+
+// exportPack creates a pack of the Dashboards with dashboardUIDs, the Library Panels they reference and the folders
+// of both. The signed in user must be allowed to view all of them. With includeConnections the pack also has the
+// connections of its Library Panels to the dashboards the user can view, unless owners are redacted.
+func (lps *LibraryPanelService) exportPack(c *models.ReqContext, cmd exportPackCommand) (LibraryPanelPackDTO, error) {
+	redaction, err := getPackRedaction(cmd)
+	if err != nil {
+		return LibraryPanelPackDTO{}, err
+	}
+	pack := LibraryPanelPackDTO{
+		Name:          cmd.Name,
+		Folders:       make([]LibraryPanelPackFolderDTO, 0),
+		LibraryPanels: make([]LibraryPanelPackElementDTO, 0),
+		Dashboards:    make([]LibraryPanelPackDashboardDTO, 0, len(cmd.DashboardUIDs)),
+	}
+	folderService := dashboards.NewFolderService(c.SignedInUser.OrgId, c.SignedInUser, lps.SQLStore)
+	folderUIDs := make(map[int64]string)
+	addFolder := func(folderID int64) (string, error) {
+		if isGeneralFolder(folderID) {
+			return "", nil
+		}
+		if uid, ok := folderUIDs[folderID]; ok {
+			return uid, nil
+		}
+		folder, err := folderService.GetFolderByID(folderID)
+		if err != nil {
+			return "", err
+		}
+		folderUIDs[folderID] = folder.Uid
+		pack.Folders = append(pack.Folders, LibraryPanelPackFolderDTO{UID: folder.Uid, Title: folder.Title})
+		return folder.Uid, nil
+	}
+
+	exported := make(map[string]bool)
+	for _, dashboardUID := range cmd.DashboardUIDs {
+		dash, err := lps.SQLStore.GetDashboard(0, c.SignedInUser.OrgId, dashboardUID, "")
+		if err != nil {
+			return LibraryPanelPackDTO{}, err
+		}
+		if dash.IsFolder {
+			return LibraryPanelPackDTO{}, models.ErrDashboardNotFound
+		}
+		canView, err := guardian.New(dash.Id, c.SignedInUser.OrgId, c.SignedInUser).CanView()
+		if err != nil {
+			return LibraryPanelPackDTO{}, err
+		}
+		if !canView {
+			return LibraryPanelPackDTO{}, models.ErrDashboardNotFound
+		}
+		folderUID, err := addFolder(dash.FolderId)
+		if err != nil {
+			return LibraryPanelPackDTO{}, err
+		}
+
+		uids, err := getLibraryPanelUIDsForDashboard(dash.Data)
+		if err != nil {
+			return LibraryPanelPackDTO{}, err
+		}
+		for _, uid := range uids {
+			if exported[uid] {
+				continue
+			}
+			panel, err := lps.getLibraryPanel(c, uid)
+			if err != nil {
+				return LibraryPanelPackDTO{}, err
+			}
+			panelFolderUID, err := addFolder(panel.FolderID)
+			if err != nil {
+				return LibraryPanelPackDTO{}, err
+			}
+			exported[uid] = true
+			name, model := panel.Name, panel.Model
+			if version, ok := cmd.Versions[uid]; ok {
+				err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+					var err error
+					name, model, err = getLibraryPanelVersion(session, panel, version)
+					return err
+				})
+				if err != nil {
+					return LibraryPanelPackDTO{}, err
+				}
+			}
+			if cmd.IncludeConnections && !redaction.Owners {
+				var dashboardUIDs []string
+				err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+					var err error
+					dashboardUIDs, err = getViewableConnectedDashboardUIDs(session, c.SignedInUser, panel.ID)
+					return err
+				})
+				if err != nil {
+					return LibraryPanelPackDTO{}, err
+				}
+				for _, dashboardUID := range dashboardUIDs {
+					pack.Connections = append(pack.Connections, LibraryPanelPackConnectionDTO{DashboardUID: dashboardUID, LibraryPanelUID: panel.UID})
+				}
+			}
+			element := LibraryPanelPackElementDTO{
+				UID:       panel.UID,
+				Name:      name,
+				FolderUID: panelFolderUID,
+				Model:     model,
+				Labels:    panel.Labels,
+				Tags:      panel.Tags,
+				Inputs:    panel.Inputs,
+			}
+			if err := redactLibraryPanel(&element, redaction); err != nil {
+				return LibraryPanelPackDTO{}, err
+			}
+			pack.LibraryPanels = append(pack.LibraryPanels, element)
+		}
+
+		dash.Data.Del("id")
+		dash.Data.Del("version")
+		redactDashboard(dash.Data, redaction)
+		pack.Dashboards = append(pack.Dashboards, LibraryPanelPackDashboardDTO{FolderUID: folderUID, Dashboard: dash.Data})
+	}
+
+	return pack, nil
+}
+
+// validatePack checks that every folder and library panel a pack refers to is in the pack or remapped.
+func validatePack(cmd installPackCommand) error {
+	folders := map[string]bool{"": true}
+	for _, folder := range cmd.Pack.Folders {
+		if folder.UID == "" || folder.Title == "" || folders[folder.UID] {
+			return errLibraryPanelInvalidPack
+		}
+		folders[folder.UID] = true
+	}
+	libraryPanels := make(map[string]bool)
+	for _, panel := range cmd.Pack.LibraryPanels {
+		if panel.UID == "" || libraryPanels[panel.UID] || !folders[panel.FolderUID] {
+			return errLibraryPanelInvalidPack
+		}
+		libraryPanels[panel.UID] = true
+	}
+	for uid := range cmd.LibraryPanelUIDs {
+		libraryPanels[uid] = true
+	}
+	for _, dashboard := range cmd.Pack.Dashboards {
+		if dashboard.Dashboard == nil || !folders[dashboard.FolderUID] {
+			return errLibraryPanelInvalidPack
+		}
+		valid := true
+		visitLibraryPanelReferences(dashboard.Dashboard.Get("panels").MustArray(), func(libraryPanel map[string]interface{}) {
+			uid, _ := libraryPanel["uid"].(string)
+			valid = valid && libraryPanels[uid]
+		})
+		if !valid {
+			return errLibraryPanelInvalidPack
+		}
+	}
+	for _, connection := range cmd.Pack.Connections {
+		if connection.DashboardUID == "" || !libraryPanels[connection.LibraryPanelUID] {
+			return errLibraryPanelInvalidPack
+		}
+	}
+
+	return nil
+}
+
+// installPack installs a pack as the signed in user. Folders and library panels of the pack that are remapped use
+// the existing folder or library panel, other folders are created unless the org already has one with the same uid
+// and other library panels are created unless the folder already has one with the same name. The library panel
+// references of the dashboards are rewritten to the installed library panels before the dashboards are saved.
+// Connections of the pack are restored for the dashboards the org has, so they keep protecting the installed library
+// panels from deletion, and skipped for the others.
+func (lps *LibraryPanelService) installPack(c *models.ReqContext, cmd installPackCommand) (LibraryPanelPackInstallResultDTO, error) {
+	if err := validatePack(cmd); err != nil {
+		return LibraryPanelPackInstallResultDTO{}, err
+	}
+
+	result := LibraryPanelPackInstallResultDTO{
+		FolderUIDs:       make(map[string]string, len(cmd.Pack.Folders)),
+		LibraryPanelUIDs: make(map[string]string, len(cmd.Pack.LibraryPanels)+len(cmd.LibraryPanelUIDs)),
+		DashboardUIDs:    make([]string, 0, len(cmd.Pack.Dashboards)),
+	}
+	folderService := dashboards.NewFolderService(c.SignedInUser.OrgId, c.SignedInUser, lps.SQLStore)
+	folderIDs := map[string]int64{"": 0}
+	for _, packFolder := range cmd.Pack.Folders {
+		var folder *models.Folder
+		var err error
+		if target, ok := cmd.FolderUIDs[packFolder.UID]; ok {
+			if target == "" {
+				folderIDs[packFolder.UID] = 0
+				result.FolderUIDs[packFolder.UID] = ""
+				continue
+			}
+			folder, err = folderService.GetFolderByUID(target)
+		} else {
+			folder, err = folderService.GetFolderByUID(packFolder.UID)
+			if errors.Is(err, models.ErrFolderNotFound) {
+				folder, err = folderService.CreateFolder(packFolder.Title, packFolder.UID)
+			}
+		}
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		folderIDs[packFolder.UID] = folder.Id
+		result.FolderUIDs[packFolder.UID] = folder.Uid
+	}
+
+	libraryPanelNames := make(map[string]string)
+	for packUID, target := range cmd.LibraryPanelUIDs {
+		panel, err := lps.getLibraryPanel(c, target)
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		result.LibraryPanelUIDs[packUID] = panel.UID
+		libraryPanelNames[packUID] = panel.Name
+	}
+	for _, packPanel := range cmd.Pack.LibraryPanels {
+		if _, ok := result.LibraryPanelUIDs[packPanel.UID]; ok {
+			continue
+		}
+		folderID := folderIDs[packPanel.FolderUID]
+
+		// installing the same pack again maps to the library panels created by the first install
+		var existing []LibraryPanel
+		err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+			sql := "SELECT * FROM library_panel WHERE org_id=? AND folder_id=? AND name=? AND deleted_at IS NULL"
+			return session.SQL(sql, c.SignedInUser.OrgId, folderID, packPanel.Name).Find(&existing)
+		})
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		var panel LibraryPanelDTO
+		if len(existing) > 0 {
+			panel, err = lps.getLibraryPanel(c, existing[0].UID)
+		} else {
+			panel, err = lps.createLibraryPanel(c, createLibraryPanelCommand{
+				FolderID: folderID,
+				Name:     packPanel.Name,
+				Model:    packPanel.Model,
+				Labels:   packPanel.Labels,
+				Tags:     packPanel.Tags,
+				Inputs:   packPanel.Inputs,
+			})
+		}
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		result.LibraryPanelUIDs[packPanel.UID] = panel.UID
+		libraryPanelNames[packPanel.UID] = panel.Name
+	}
+
+	dashboardService := dashboards.NewService(lps.SQLStore)
+	for _, packDashboard := range cmd.Pack.Dashboards {
+		// the pack is copied so that installing it again maps the library panels it references again
+		raw, err := packDashboard.Dashboard.Encode()
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		data, err := simplejson.NewJson(raw)
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		data.Del("id")
+		panels := data.Get("panels").MustArray()
+		visitLibraryPanelReferences(panels, func(libraryPanel map[string]interface{}) {
+			uid, _ := libraryPanel["uid"].(string)
+			libraryPanel["uid"] = result.LibraryPanelUIDs[uid]
+			libraryPanel["name"] = libraryPanelNames[uid]
+		})
+		if panels != nil {
+			data.Set("panels", panels)
+		}
+
+		dash := models.NewDashboardFromJson(data)
+		dash.OrgId = c.SignedInUser.OrgId
+		dash.FolderId = folderIDs[packDashboard.FolderUID]
+		saved, err := dashboardService.SaveDashboard(&dashboards.SaveDashboardDTO{
+			OrgId:     c.SignedInUser.OrgId,
+			User:      c.SignedInUser,
+			Dashboard: dash,
+			Message:   "Installed from pack " + cmd.Pack.Name,
+			Overwrite: cmd.Overwrite,
+		}, false)
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		if err := lps.ConnectLibraryPanelsForDashboard(c, saved); err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		result.DashboardUIDs = append(result.DashboardUIDs, saved.Uid)
+	}
+
+	for _, connection := range cmd.Pack.Connections {
+		dash, err := lps.SQLStore.GetDashboard(0, c.SignedInUser.OrgId, connection.DashboardUID, "")
+		if errors.Is(err, models.ErrDashboardNotFound) {
+			result.SkippedConnections++
+			continue
+		}
+		if err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		if err := lps.connectDashboard(c, result.LibraryPanelUIDs[connection.LibraryPanelUID], dash.Id); err != nil {
+			return LibraryPanelPackInstallResultDTO{}, err
+		}
+		result.Connections++
+	}
+
+	return result, nil
+}
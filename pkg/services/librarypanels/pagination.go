@@ -0,0 +1,140 @@
+package librarypanels
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// searchCursor is the position of the last library panel of a search result page, library panels are ordered by
+// name and then uid so the position is unique.
+type searchCursor struct {
+	Name string `json:"name"`
+	UID  string `json:"uid"`
+}
+
+func encodeContinueToken(name string, uid string) string {
+	token, _ := json.Marshal(searchCursor{Name: name, UID: uid})
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func parseContinueToken(continueToken string) (*searchCursor, error) {
+	if continueToken == "" {
+		return nil, nil
+	}
+
+	token, err := base64.RawURLEncoding.DecodeString(continueToken)
+	if err != nil {
+		return nil, errLibraryPanelInvalidContinueToken
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(token, &cursor); err != nil || cursor.UID == "" {
+		return nil, errLibraryPanelInvalidContinueToken
+	}
+
+	return &cursor, nil
+}
+
+// canContinueSearch returns true if the next page of a search can be requested with a continueToken. The cursor
+// only follows the order by name, and a uid is only unique within an org, so searches sorted by sort or in all orgs
+// are paged with page.
+func canContinueSearch(query searchLibraryPanelsQuery) bool {
+	return strings.TrimSpace(query.sort) == "" && !query.allOrgs
+}
+
+func writeCursorSQL(query searchLibraryPanelsQuery, cursor *searchCursor, builder *sqlstore.SQLBuilder) {
+	if cursor == nil {
+		return
+	}
+
+	operator := ">"
+	if query.sortDirection == search.SortAlphaDesc.Name {
+		operator = "<"
+	}
+	builder.Write(" AND (lp.name "+operator+" ? OR (lp.name = ? AND lp.uid "+operator+" ?))", cursor.Name, cursor.Name, cursor.UID)
+}
+
+// searchSortColumns are the fields search results can be sorted by and the positions of their columns in
+// selectLibrayPanelDTOForSearch followed by folder_name and folder_uid. Library panels that were never connected or
+// viewed sort by a NULL time, which databases put first or last depending on the dialect.
+var searchSortColumns = map[string]int{
+	"name":          1,
+	"type":          6,
+	"created":       10,
+	"updated":       12,
+	"lastconnected": 16,
+	"lastviewed":    17,
+	"folder":        24,
+}
+
+// searchSortUIDColumn is the position of the uid column, which is unique within an org.
+const searchSortUIDColumn = 5
+
+// searchSortField is a field of a compound sort expression.
+type searchSortField struct {
+	column int
+	desc   bool
+}
+
+// parseSearchSort parses a compound sort expression such as folder:asc,updated:desc. Fields are sorted ascending
+// when they have no direction, and each field can be used once.
+func parseSearchSort(sort string) ([]searchSortField, error) {
+	if strings.TrimSpace(sort) == "" {
+		return nil, nil
+	}
+
+	fields := make([]searchSortField, 0)
+	used := make(map[string]bool)
+	for _, expression := range strings.Split(sort, ",") {
+		parts := strings.SplitN(strings.TrimSpace(expression), ":", 2)
+		name := strings.ToLower(parts[0])
+		column, ok := searchSortColumns[name]
+		if !ok || used[name] {
+			return nil, errLibraryPanelInvalidSort
+		}
+		used[name] = true
+
+		field := searchSortField{column: column}
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				field.desc = true
+			default:
+				return nil, errLibraryPanelInvalidSort
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// writeOrderBySQL orders search results by a compound sort expression, or by name in sortDirection without one.
+// The uid is always the last column so library panels with the same values keep their order across pages.
+func writeOrderBySQL(query searchLibraryPanelsQuery, sortFields []searchSortField, builder *sqlstore.SQLBuilder) {
+	if len(sortFields) == 0 {
+		// uid breaks ties between library panels with the same name in different folders
+		if query.sortDirection == search.SortAlphaDesc.Name {
+			builder.Write(" ORDER BY 1 DESC, 5 DESC")
+		} else {
+			builder.Write(" ORDER BY 1 ASC, 5 ASC")
+		}
+		return
+	}
+
+	columns := make([]string, 0, len(sortFields)+1)
+	for _, field := range sortFields {
+		direction := " ASC"
+		if field.desc {
+			direction = " DESC"
+		}
+		columns = append(columns, strconv.Itoa(field.column)+direction)
+	}
+	columns = append(columns, strconv.Itoa(searchSortUIDColumn)+" ASC")
+	builder.Write(" ORDER BY " + strings.Join(columns, ", "))
+}
@@ -0,0 +1,164 @@
+package librarypanels
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelACLItem is the model for permissions granted to users or teams on a single library panel. They are
+// layered on top of the permissions of the folder the library panel is in.
+type libraryPanelACLItem struct {
+	ID             int64                 `xorm:"pk autoincr 'id'"`
+	OrgID          int64                 `xorm:"org_id"`
+	LibraryPanelID int64                 `xorm:"librarypanel_id"`
+	UserID         int64                 `xorm:"user_id"`
+	TeamID         int64                 `xorm:"team_id"`
+	Permission     models.PermissionType `xorm:"permission"`
+
+	Created time.Time
+	Updated time.Time
+}
+
+func (libraryPanelACLItem) TableName() string {
+	return "library_panel_acl"
+}
+
+// LibraryPanelACLItemDTO is the DTO for a permission on a library panel, either UserID or TeamID is set.
+type LibraryPanelACLItemDTO struct {
+	UserID         int64                 `json:"userId"`
+	TeamID         int64                 `json:"teamId"`
+	Permission     models.PermissionType `json:"permission"`
+	PermissionName string                `json:"permissionName"`
+}
+
+func validateACLItems(items []LibraryPanelACLItemDTO) error {
+	for _, item := range items {
+		if (item.UserID == 0) == (item.TeamID == 0) {
+			return errLibraryPanelInvalidPermission
+		}
+		if item.Permission != models.PERMISSION_VIEW && item.Permission != models.PERMISSION_EDIT {
+			return errLibraryPanelInvalidPermission
+		}
+	}
+
+	return nil
+}
+
+// hasLibraryPanelPermission checks if the permissions on a library panel grant the user at least permission,
+// directly or through one of their teams.
+func hasLibraryPanelPermission(session *sqlstore.DBSession, user *models.SignedInUser, panelID int64, permission models.PermissionType) (bool, error) {
+	var counts []struct {
+		Count int64 `xorm:"count"`
+	}
+	sql := `SELECT COUNT(*) AS count FROM library_panel_acl AS lpa
+LEFT JOIN team_member AS tm ON tm.team_id = lpa.team_id
+WHERE lpa.librarypanel_id=? AND lpa.permission >= ? AND (lpa.user_id=? OR tm.user_id=?)`
+	if err := session.SQL(sql, panelID, permission, user.UserId, user.UserId).Find(&counts); err != nil {
+		return false, err
+	}
+
+	return len(counts) > 0 && counts[0].Count > 0, nil
+}
+
+// writeSharedWithUserSQL limits a query to library panels shared with the user or one of their teams.
+func writeSharedWithUserSQL(user *models.SignedInUser, builder *sqlstore.SQLBuilder) {
+	builder.Write(` AND EXISTS (SELECT 1 FROM library_panel_acl AS lpa
+LEFT JOIN team_member AS tm ON tm.team_id = lpa.team_id
+WHERE lpa.librarypanel_id = lp.id AND lpa.permission >= ? AND (lpa.user_id = ? OR tm.user_id = ?))`, models.PERMISSION_VIEW, user.UserId, user.UserId)
+}
+
+// requirePermissionsOnLibraryPanel checks the permissions on the folder a library panel is in, and falls back to
+// the permissions on the library panel itself when the folder denies access.
+func (lps *LibraryPanelService) requirePermissionsOnLibraryPanel(session *sqlstore.DBSession, user *models.SignedInUser, panelID int64, folderID int64) error {
+	err := lps.requirePermissionsOnFolder(user, folderID)
+	if !errors.Is(err, models.ErrFolderAccessDenied) {
+		return err
+	}
+
+	canEdit, aclErr := hasLibraryPanelPermission(session, user, panelID, models.PERMISSION_EDIT)
+	if aclErr != nil {
+		return aclErr
+	}
+	if !canEdit {
+		return err
+	}
+
+	return nil
+}
+
+// getLibraryPanelPermissions gets the permissions on a Library Panel.
+func (lps *LibraryPanelService) getLibraryPanelPermissions(c *models.ReqContext, uid string) ([]LibraryPanelACLItemDTO, error) {
+	dtos := make([]LibraryPanelACLItemDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+
+		var items []libraryPanelACLItem
+		if err := session.SQL("SELECT * FROM library_panel_acl WHERE librarypanel_id=? ORDER BY id", panel.ID).Find(&items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			dtos = append(dtos, LibraryPanelACLItemDTO{
+				UserID:         item.UserID,
+				TeamID:         item.TeamID,
+				Permission:     item.Permission,
+				PermissionName: item.Permission.String(),
+			})
+		}
+
+		return nil
+	})
+
+	return dtos, err
+}
+
+// setLibraryPanelPermissions replaces the permissions on a Library Panel, only users that can edit the folder the
+// library panel is in can change them.
+func (lps *LibraryPanelService) setLibraryPanelPermissions(c *models.ReqContext, uid string, cmd setLibraryPanelPermissionsCommand) error {
+	if err := validateACLItems(cmd.Items); err != nil {
+		return err
+	}
+
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+
+		return replaceLibraryPanelACL(session, panel.OrgID, panel.ID, cmd.Items)
+	})
+}
+
+// replaceLibraryPanelACL replaces the permissions on a library panel with items.
+func replaceLibraryPanelACL(session *sqlstore.DBSession, orgID int64, panelID int64, items []LibraryPanelACLItemDTO) error {
+	if _, err := session.Exec("DELETE FROM library_panel_acl WHERE librarypanel_id=?", panelID); err != nil {
+		return err
+	}
+	for _, item := range items {
+		aclItem := libraryPanelACLItem{
+			OrgID:          orgID,
+			LibraryPanelID: panelID,
+			UserID:         item.UserID,
+			TeamID:         item.TeamID,
+			Permission:     item.Permission,
+			Created:        time.Now(),
+			Updated:        time.Now(),
+		}
+		if _, err := session.Insert(&aclItem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
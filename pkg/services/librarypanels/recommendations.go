@@ -0,0 +1,277 @@
+package librarypanels
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	recommendationTypeCleanup     = "cleanup"
+	recommendationTypeConsolidate = "consolidate"
+
+	recommendationActionDelete = "delete"
+	recommendationActionUnlink = "unlink"
+	recommendationActionMerge  = "merge"
+
+	// maxRecommendations is the number of recommendations returned, highest score first.
+	maxRecommendations = 50
+	// minCleanupScore is the score from which a library panel is recommended for cleanup.
+	minCleanupScore = 0.3
+	// staleAfter is the time without updates or renders after which a library panel is fully stale.
+	staleAfter = 180 * 24 * time.Hour
+)
+
+// LibraryPanelRecommendationDTO is a suggestion to clean up or consolidate a library panel, with the reasons for it.
+type LibraryPanelRecommendationDTO struct {
+	Action              string     `json:"action"`
+	UID                 string     `json:"uid"`
+	Name                string     `json:"name"`
+	FolderUID           string     `json:"folderUid"`
+	Score               float64    `json:"score"`
+	Reasons             []string   `json:"reasons"`
+	ConnectedDashboards int64      `json:"connectedDashboards"`
+	RenderCount         int64      `json:"renderCount"`
+	LastRendered        *time.Time `json:"lastRendered,omitempty"`
+	Updated             time.Time  `json:"updated"`
+	// MergeInto is the uid of the library panel to merge into.
+	MergeInto string `json:"mergeInto,omitempty"`
+}
+
+// libraryPanelRenderUsage is how often a library panel was rendered as an image or embedded with a token.
+type libraryPanelRenderUsage struct {
+	ID           int64      `xorm:"id"`
+	RenderCount  int64      `xorm:"render_count"`
+	LastRendered *time.Time `xorm:"last_rendered"`
+}
+
+type recommendationCandidate struct {
+	panel       LibraryPanelWithMeta
+	connections int64
+	usage       libraryPanelRenderUsage
+}
+
+func recordRender(session *sqlstore.DBSession, panelID int64) error {
+	_, err := session.Exec("UPDATE library_panel SET render_count=render_count+1, last_rendered=? WHERE id=?", time.Now(), panelID)
+	return err
+}
+
+// recordRenderForLibraryPanel records a render of a Library Panel. Failing to record it doesn't fail the render.
+func (lps *LibraryPanelService) recordRenderForLibraryPanel(c *models.ReqContext, panelID int64) {
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return recordRender(session, panelID)
+	})
+	if err != nil {
+		lps.log.Warn("Failed to record library panel render", "id", panelID, "error", err)
+	}
+}
+
+func getRenderUsage(session *sqlstore.DBSession, panelIDs ...int64) (map[int64]libraryPanelRenderUsage, error) {
+	usage := make(map[int64]libraryPanelRenderUsage)
+	if len(panelIDs) == 0 {
+		return usage, nil
+	}
+
+	params := make([]interface{}, 0, len(panelIDs))
+	for _, id := range panelIDs {
+		params = append(params, id)
+	}
+	var rows []libraryPanelRenderUsage
+	sql := "SELECT id, render_count, last_rendered FROM library_panel WHERE id IN (?" + strings.Repeat(",?", len(panelIDs)-1) + ")"
+	if err := session.SQL(sql, params...).Find(&rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		usage[row.ID] = row
+	}
+
+	return usage, nil
+}
+
+// lastUsed is the last time a library panel was updated or rendered.
+func (candidate recommendationCandidate) lastUsed() time.Time {
+	if candidate.usage.LastRendered != nil && candidate.usage.LastRendered.After(candidate.panel.Updated) {
+		return *candidate.usage.LastRendered
+	}
+
+	return candidate.panel.Updated
+}
+
+func (candidate recommendationCandidate) recommendation(action string, score float64, reasons []string) LibraryPanelRecommendationDTO {
+	return LibraryPanelRecommendationDTO{
+		Action:              action,
+		UID:                 candidate.panel.UID,
+		Name:                candidate.panel.Name,
+		FolderUID:           candidate.panel.FolderUID,
+		Score:               math.Round(score*100) / 100,
+		Reasons:             reasons,
+		ConnectedDashboards: candidate.connections,
+		RenderCount:         candidate.usage.RenderCount,
+		LastRendered:        candidate.usage.LastRendered,
+		Updated:             candidate.panel.Updated,
+	}
+}
+
+// cleanupRecommendation scores a library panel for cleanup. Library panels that no dashboard uses are recommended
+// for deletion and library panels that a single dashboard uses for unlinking, higher scores for panels that are
+// neither rendered nor updated.
+func cleanupRecommendation(candidate recommendationCandidate, now time.Time) (LibraryPanelRecommendationDTO, bool) {
+	var action string
+	var score float64
+	var reasons []string
+	switch candidate.connections {
+	case 0:
+		action = recommendationActionDelete
+		score += 0.5
+		reasons = append(reasons, "not used by any dashboard")
+	case 1:
+		action = recommendationActionUnlink
+		reasons = append(reasons, "used by a single dashboard, so it isn't shared")
+	default:
+		return LibraryPanelRecommendationDTO{}, false
+	}
+
+	if candidate.usage.RenderCount == 0 {
+		score += 0.2
+		reasons = append(reasons, "never rendered")
+	} else if candidate.usage.LastRendered != nil && now.Sub(*candidate.usage.LastRendered) > staleAfter/2 {
+		score += 0.1
+		reasons = append(reasons, fmt.Sprintf("not rendered in %d days", int(now.Sub(*candidate.usage.LastRendered).Hours()/24)))
+	}
+	idle := now.Sub(candidate.lastUsed())
+	score += 0.3 * math.Min(idle.Hours()/staleAfter.Hours(), 1)
+	if idle > staleAfter/2 {
+		reasons = append(reasons, fmt.Sprintf("not updated or rendered in %d days", int(idle.Hours()/24)))
+	}
+	if score < minCleanupScore {
+		return LibraryPanelRecommendationDTO{}, false
+	}
+
+	return candidate.recommendation(action, score, reasons), true
+}
+
+// preferredForMerge returns whether a is kept over b when they're merged: the one used by more dashboards, then the
+// one rendered more often, then the oldest.
+func preferredForMerge(a, b recommendationCandidate) bool {
+	if a.connections != b.connections {
+		return a.connections > b.connections
+	}
+	if a.usage.RenderCount != b.usage.RenderCount {
+		return a.usage.RenderCount > b.usage.RenderCount
+	}
+
+	return a.panel.ID < b.panel.ID
+}
+
+// consolidateRecommendations recommends merging library panels with identical models, and library panels of the same
+// type with similar names, into the one that is used most.
+func consolidateRecommendations(candidates []recommendationCandidate) []LibraryPanelRecommendationDTO {
+	best := make(map[int64]LibraryPanelRecommendationDTO)
+	consider := func(source, target recommendationCandidate, score float64, reason string) {
+		if existing, ok := best[source.panel.ID]; ok && existing.Score >= score {
+			return
+		}
+		recommendation := source.recommendation(recommendationActionMerge, score, []string{reason})
+		recommendation.MergeInto = target.panel.UID
+		if source.connections > 0 {
+			recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf("%d dashboards need to be updated", source.connections))
+		}
+		best[source.panel.ID] = recommendation
+	}
+
+	for i := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			a, b := candidates[i], candidates[j]
+			if !preferredForMerge(a, b) {
+				a, b = b, a
+			}
+			if storedModelHash(a.panel.ModelHash, a.panel.Model) == storedModelHash(b.panel.ModelHash, b.panel.Model) {
+				consider(b, a, 1, fmt.Sprintf("has the same model as %q", a.panel.Name))
+				continue
+			}
+			if a.panel.Type != b.panel.Type {
+				continue
+			}
+			if similarity := nameSimilarity(a.panel.Name, b.panel.Name); similarity >= similarNameThreshold {
+				consider(b, a, 0.8*similarity, fmt.Sprintf("has the same type as %q and a name that is %d%% similar", a.panel.Name, int(similarity*100)))
+			}
+		}
+	}
+
+	recommendations := make([]LibraryPanelRecommendationDTO, 0, len(best))
+	for _, candidate := range candidates {
+		if recommendation, ok := best[candidate.panel.ID]; ok {
+			recommendations = append(recommendations, recommendation)
+		}
+	}
+
+	return recommendations
+}
+
+// getRecommendations gets scored cleanup or consolidation recommendations for the Library Panels the signed in user
+// can view, highest score first.
+func (lps *LibraryPanelService) getRecommendations(c *models.ReqContext, recommendationType string) ([]LibraryPanelRecommendationDTO, error) {
+	if recommendationType == "" {
+		recommendationType = recommendationTypeCleanup
+	}
+	if recommendationType != recommendationTypeCleanup && recommendationType != recommendationTypeConsolidate {
+		return nil, errLibraryPanelInvalidRecommendationType
+	}
+
+	query := searchLibraryPanelsQuery{perPage: maxSimilarNameCandidates, page: 1}
+	builder, _, err := lps.buildSearchLibraryPanelsSQL(c.SignedInUser, query, selectLibrayPanelDTOForSearch)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []recommendationCandidate
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var libraryPanels []LibraryPanelWithMeta
+		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanels); err != nil {
+			return err
+		}
+		panelIDs := make([]int64, 0, len(libraryPanels))
+		for _, panel := range libraryPanels {
+			panelIDs = append(panelIDs, panel.ID)
+		}
+		counts, err := getConnectedDashboardCounts(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		usage, err := getRenderUsage(session, panelIDs...)
+		if err != nil {
+			return err
+		}
+		for _, panel := range libraryPanels {
+			candidates = append(candidates, recommendationCandidate{panel: panel, connections: counts[panel.ID], usage: usage[panel.ID]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]LibraryPanelRecommendationDTO, 0)
+	if recommendationType == recommendationTypeConsolidate {
+		recommendations = consolidateRecommendations(candidates)
+	} else {
+		now := time.Now()
+		for _, candidate := range candidates {
+			if recommendation, ok := cleanupRecommendation(candidate, now); ok {
+				recommendations = append(recommendations, recommendation)
+			}
+		}
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	if len(recommendations) > maxRecommendations {
+		recommendations = recommendations[:maxRecommendations]
+	}
+
+	return recommendations, nil
+}
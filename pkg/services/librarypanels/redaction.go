@@ -0,0 +1,131 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// packRedaction is what's stripped from a pack when it's exported, so it can be shared outside the org.
+type packRedaction struct {
+	// Descriptions strips the descriptions of library panels, dashboards and panels.
+	Descriptions bool `json:"descriptions"`
+	// DatasourceUIDs strips the datasource references of panels and their targets. Inputs such as ${DS_PROMETHEUS}
+	// and the default datasource are kept, they don't identify a datasource of the org.
+	DatasourceUIDs bool `json:"datasourceUids"`
+	// Owners strips the labels of library panels and the connections to dashboards outside the pack.
+	Owners bool `json:"owners"`
+}
+
+// redactionProfiles are the named sets of redactions an export can use.
+var redactionProfiles = map[string]packRedaction{
+	// partner strips everything internal from packs shared with other organizations.
+	"partner": {Descriptions: true, DatasourceUIDs: true, Owners: true},
+	// support keeps descriptions, which help to understand what a panel is for when debugging it.
+	"support": {DatasourceUIDs: true, Owners: true},
+}
+
+// getPackRedaction gets the redactions of an export, the redactions of its profile combined with the ones set on
+// the command.
+func getPackRedaction(cmd exportPackCommand) (packRedaction, error) {
+	redaction := cmd.Redact
+	if cmd.RedactionProfile == "" {
+		return redaction, nil
+	}
+
+	profile, ok := redactionProfiles[cmd.RedactionProfile]
+	if !ok {
+		return packRedaction{}, errLibraryPanelInvalidRedactionProfile
+	}
+	redaction.Descriptions = redaction.Descriptions || profile.Descriptions
+	redaction.DatasourceUIDs = redaction.DatasourceUIDs || profile.DatasourceUIDs
+	redaction.Owners = redaction.Owners || profile.Owners
+
+	return redaction, nil
+}
+
+// redactDatasource strips a datasource reference by uid or name, references by object lose their uid.
+func redactDatasource(container map[string]interface{}) {
+	switch datasource := container["datasource"].(type) {
+	case string:
+		if datasource != defaultDatasource && !strings.HasPrefix(datasource, "${") {
+			delete(container, "datasource")
+		}
+	case map[string]interface{}:
+		if uid, ok := datasource["uid"].(string); ok && !strings.HasPrefix(uid, "${") {
+			delete(datasource, "uid")
+		}
+	}
+}
+
+// redactPanel applies redactions to a panel and its targets, and to the panels of a row.
+func redactPanel(panel map[string]interface{}, redaction packRedaction) {
+	if redaction.Descriptions {
+		delete(panel, "description")
+	}
+	if redaction.DatasourceUIDs {
+		redactDatasource(panel)
+		if targets, ok := panel["targets"].([]interface{}); ok {
+			for _, t := range targets {
+				if target, ok := t.(map[string]interface{}); ok {
+					redactDatasource(target)
+				}
+			}
+		}
+	}
+	if rowPanels, ok := panel["panels"].([]interface{}); ok {
+		for _, p := range rowPanels {
+			if rowPanel, ok := p.(map[string]interface{}); ok {
+				redactPanel(rowPanel, redaction)
+			}
+		}
+	}
+}
+
+// redactLibraryPanel applies redactions to a library panel of a pack.
+func redactLibraryPanel(element *LibraryPanelPackElementDTO, redaction packRedaction) error {
+	if redaction.Owners {
+		element.Labels = nil
+	}
+	if !redaction.Descriptions && !redaction.DatasourceUIDs {
+		return nil
+	}
+
+	var model map[string]interface{}
+	if err := json.Unmarshal(element.Model, &model); err != nil {
+		return err
+	}
+	redactPanel(model, redaction)
+	redacted, err := json.Marshal(&model)
+	if err != nil {
+		return err
+	}
+	element.Model = redacted
+
+	return nil
+}
+
+// redactDashboard applies redactions to a dashboard of a pack, its panels and its template variables.
+func redactDashboard(dash *simplejson.Json, redaction packRedaction) {
+	if redaction.Descriptions {
+		dash.Del("description")
+	}
+	for _, p := range dash.Get("panels").MustArray() {
+		if panel, ok := p.(map[string]interface{}); ok {
+			redactPanel(panel, redaction)
+		}
+	}
+	for _, v := range dash.GetPath("templating", "list").MustArray() {
+		variable, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if redaction.Descriptions {
+			delete(variable, "description")
+		}
+		if redaction.DatasourceUIDs {
+			redactDatasource(variable)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package librarypanels
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rendering"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// ephemeralPanelID is the id of the library panel in the dashboard it's wrapped in for rendering.
+const ephemeralPanelID = 1
+
+// renderOpts are the options for rendering a library panel, read from the query string of the request.
+type renderOpts struct {
+	width     int
+	height    int
+	timeout   time.Duration
+	scale     float64
+	timezone  string
+	from      string
+	to        string
+	variables map[string]string
+}
+
+func parseRenderOpts(u *url.URL) (renderOpts, error) {
+	queryReader, err := util.NewURLQueryReader(u)
+	if err != nil {
+		return renderOpts{}, err
+	}
+
+	width, err := strconv.Atoi(queryReader.Get("width", "800"))
+	if err != nil {
+		return renderOpts{}, fmt.Errorf("cannot parse width as int: %w", err)
+	}
+	height, err := strconv.Atoi(queryReader.Get("height", "400"))
+	if err != nil {
+		return renderOpts{}, fmt.Errorf("cannot parse height as int: %w", err)
+	}
+	timeout, err := strconv.Atoi(queryReader.Get("timeout", "60"))
+	if err != nil {
+		return renderOpts{}, fmt.Errorf("cannot parse timeout as int: %w", err)
+	}
+	scale, err := strconv.ParseFloat(queryReader.Get("scale", "1"), 64)
+	if err != nil {
+		return renderOpts{}, fmt.Errorf("cannot parse scale as float: %w", err)
+	}
+
+	opts := renderOpts{
+		width:     width,
+		height:    height,
+		timeout:   time.Duration(timeout) * time.Second,
+		scale:     scale,
+		timezone:  queryReader.Get("tz", ""),
+		from:      queryReader.Get("from", "now-6h"),
+		to:        queryReader.Get("to", "now"),
+		variables: make(map[string]string),
+	}
+	for name, value := range u.Query() {
+		if strings.HasPrefix(name, "var-") && len(value) > 0 {
+			opts.variables[strings.TrimPrefix(name, "var-")] = value[0]
+		}
+	}
+
+	return opts, nil
+}
+
+// getEphemeralDashboard wraps a library panel model in a dashboard with a textbox variable for each variable
+// in opts, so that the panel can be rendered on its own.
+func getEphemeralDashboard(uid string, panel LibraryPanelDTO, opts renderOpts) (*simplejson.Json, error) {
+	model, err := simplejson.NewJson(panel.Model)
+	if err != nil {
+		return nil, err
+	}
+	model.Set("id", ephemeralPanelID)
+	model.Set("gridPos", map[string]interface{}{"x": 0, "y": 0, "w": 24, "h": 8})
+
+	names := make([]string, 0, len(opts.variables))
+	for name := range opts.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	variables := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		value := opts.variables[name]
+		variables = append(variables, map[string]interface{}{
+			"type":    "textbox",
+			"name":    name,
+			"query":   value,
+			"hide":    2,
+			"current": map[string]interface{}{"text": value, "value": value},
+		})
+	}
+
+	dash := simplejson.New()
+	dash.Set("uid", uid)
+	dash.Set("title", fmt.Sprintf("%s (%s)", panel.Name, uid))
+	dash.Set("panels", []interface{}{model.Interface()})
+	dash.Set("templating", map[string]interface{}{"list": variables})
+	dash.Set("time", map[string]interface{}{"from": opts.from, "to": opts.to})
+
+	return dash, nil
+}
+
+// renderLibraryPanel renders a Library Panel to an image.
+func (lps *LibraryPanelService) renderLibraryPanel(c *models.ReqContext, uid string, opts renderOpts) ([]byte, error) {
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := lps.resolveDefaultDatasource(c, &panel); err != nil {
+		return nil, err
+	}
+
+	headers := http.Header{}
+	acceptLanguageHeader := c.Req.Header.Values("Accept-Language")
+	if len(acceptLanguageHeader) > 0 {
+		headers["Accept-Language"] = acceptLanguageHeader
+	}
+
+	result, err := lps.renderEphemeral(c.Req.Context(), c.SignedInUser, panel, opts, headers)
+	if err != nil {
+		return nil, err
+	}
+	lps.recordRenderForLibraryPanel(c, panel.ID)
+
+	// nolint:gosec
+	// We can ignore the gosec G304 warning on this one because the file path comes from the renderer
+	return ioutil.ReadFile(result.FilePath)
+}
+
+// renderEphemeral renders a library panel as user by wrapping it in an ephemeral dashboard, which is removed again
+// once rendering is done.
+func (lps *LibraryPanelService) renderEphemeral(ctx context.Context, user *models.SignedInUser, panel LibraryPanelDTO, opts renderOpts, headers http.Header) (*rendering.RenderResult, error) {
+	dashUID := util.GenerateShortUID()
+	dashJSON, err := getEphemeralDashboard(dashUID, panel, opts)
+	if err != nil {
+		return nil, err
+	}
+	dash, err := lps.SQLStore.SaveDashboard(models.SaveDashboardCommand{
+		Dashboard: dashJSON,
+		OrgId:     user.OrgId,
+		UserId:    user.UserId,
+		FolderId:  panel.FolderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := bus.Dispatch(&models.DeleteDashboardCommand{Id: dash.Id, OrgId: dash.OrgId}); err != nil {
+			lps.log.Error("Failed to delete dashboard used for rendering library panel", "uid", panel.UID, "error", err)
+		}
+	}()
+
+	query := url.Values{}
+	query.Set("panelId", strconv.Itoa(ephemeralPanelID))
+	query.Set("from", opts.from)
+	query.Set("to", opts.to)
+	for name, value := range opts.variables {
+		query.Set("var-"+name, value)
+	}
+
+	return lps.RenderService.Render(ctx, rendering.Opts{
+		Width:             opts.width,
+		Height:            opts.height,
+		Timeout:           opts.timeout,
+		OrgId:             user.OrgId,
+		UserId:            user.UserId,
+		OrgRole:           user.OrgRole,
+		Path:              fmt.Sprintf("d-solo/%s/%s?%s", dash.Uid, dash.Slug, query.Encode()),
+		Timezone:          opts.timezone,
+		ConcurrentLimit:   lps.Cfg.RendererConcurrentRequestLimit,
+		DeviceScaleFactor: opts.scale,
+		Headers:           headers,
+	})
+}
@@ -0,0 +1,435 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const reorgStatusNotFound = "not-found"
+
+// libraryPanelReorg is the model for the audit trail of reorgs. Each reorg keeps the mapping it applied and the
+// rollback file that restores the library panels it changed.
+type libraryPanelReorg struct {
+	ID         int64  `xorm:"pk autoincr 'id'"`
+	OrgID      int64  `xorm:"org_id"`
+	RollbackOf int64  `xorm:"rollback_of"`
+	Mapping    string `xorm:"mapping"`
+	Rollback   string `xorm:"rollback"`
+	Changed    int64  `xorm:"changed"`
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// LibraryPanelReorgMappingDTO maps an old team, folder or owner to a new one by id.
+type LibraryPanelReorgMappingDTO struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// LibraryPanelReorgStateDTO is the folder, owner and permissions of a library panel.
+type LibraryPanelReorgStateDTO struct {
+	UID         string                   `json:"uid"`
+	FolderID    int64                    `json:"folderId"`
+	CreatedBy   int64                    `json:"createdBy"`
+	Permissions []LibraryPanelACLItemDTO `json:"permissions"`
+}
+
+// LibraryPanelReorgRollbackDTO is the rollback file of a reorg, it holds the state the library panels changed by
+// the reorg had before it.
+type LibraryPanelReorgRollbackDTO struct {
+	ReorgID       int64                       `json:"reorgId"`
+	LibraryPanels []LibraryPanelReorgStateDTO `json:"libraryPanels"`
+}
+
+// LibraryPanelReorgChangeDTO is the outcome of a reorg for a single library panel.
+type LibraryPanelReorgChangeDTO struct {
+	UID     string `json:"uid"`
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+	Status  string `json:"status"`
+}
+
+// LibraryPanelReorgResultDTO is the outcome of a reorg or of rolling one back.
+type LibraryPanelReorgResultDTO struct {
+	ID            int64                        `json:"id"`
+	DryRun        bool                         `json:"dryRun"`
+	LibraryPanels []LibraryPanelReorgChangeDTO `json:"libraryPanels"`
+	Rollback      LibraryPanelReorgRollbackDTO `json:"rollback"`
+}
+
+// LibraryPanelReorgDTO is an entry in the audit trail of reorgs.
+type LibraryPanelReorgDTO struct {
+	ID         int64           `json:"id"`
+	RollbackOf int64           `json:"rollbackOf"`
+	Mapping    json.RawMessage `json:"mapping"`
+	Changed    int64           `json:"changed"`
+	Created    time.Time       `json:"created"`
+	CreatedBy  int64           `json:"createdBy"`
+}
+
+// validateReorgMapping checks that each id is mapped at most once and not to itself. Only folder mappings can use
+// 0, the General folder.
+func validateReorgMapping(mappings []LibraryPanelReorgMappingDTO, allowZero bool) (map[int64]int64, error) {
+	byFrom := make(map[int64]int64, len(mappings))
+	for _, mapping := range mappings {
+		if _, ok := byFrom[mapping.From]; ok || mapping.From == mapping.To || mapping.From < 0 || mapping.To < 0 {
+			return nil, errLibraryPanelInvalidReorgMapping
+		}
+		if !allowZero && (mapping.From == 0 || mapping.To == 0) {
+			return nil, errLibraryPanelInvalidReorgMapping
+		}
+		byFrom[mapping.From] = mapping.To
+	}
+
+	return byFrom, nil
+}
+
+func reorgTargets(mapping map[int64]int64) []int64 {
+	targets := make([]int64, 0, len(mapping))
+	for _, to := range mapping {
+		targets = append(targets, to)
+	}
+
+	return targets
+}
+
+// requireReorgTargets checks that all ids other than 0 exist, sql must select the ids that exist and end with the
+// id column the ids are matched against.
+func requireReorgTargets(session *sqlstore.DBSession, ids []int64, sql string, params ...interface{}) error {
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		params = append(params, id)
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		ID int64 `xorm:"id"`
+	}
+	if err := session.SQL(sql+" IN (?"+strings.Repeat(",?", len(seen)-1)+")", params...).Find(&rows); err != nil {
+		return err
+	}
+	if len(rows) != len(seen) {
+		return errLibraryPanelInvalidReorgMapping
+	}
+
+	return nil
+}
+
+// getLibraryPanelReorgStates gets the library panels of an org that aren't deleted, and their states keyed by
+// library panel id.
+func getLibraryPanelReorgStates(session *sqlstore.DBSession, orgID int64) ([]LibraryPanel, map[int64]LibraryPanelReorgStateDTO, error) {
+	var panels []LibraryPanel
+	if err := session.SQL("SELECT * FROM library_panel WHERE org_id=? AND deleted_at IS NULL ORDER BY name", orgID).Find(&panels); err != nil {
+		return nil, nil, err
+	}
+	var items []libraryPanelACLItem
+	if err := session.SQL("SELECT * FROM library_panel_acl WHERE org_id=? ORDER BY id", orgID).Find(&items); err != nil {
+		return nil, nil, err
+	}
+
+	states := make(map[int64]LibraryPanelReorgStateDTO, len(panels))
+	for _, panel := range panels {
+		states[panel.ID] = LibraryPanelReorgStateDTO{
+			UID:         panel.UID,
+			FolderID:    panel.FolderID,
+			CreatedBy:   panel.CreatedBy,
+			Permissions: make([]LibraryPanelACLItemDTO, 0),
+		}
+	}
+	for _, item := range items {
+		state, ok := states[item.LibraryPanelID]
+		if !ok {
+			continue
+		}
+		state.Permissions = append(state.Permissions, LibraryPanelACLItemDTO{
+			UserID:         item.UserID,
+			TeamID:         item.TeamID,
+			Permission:     item.Permission,
+			PermissionName: item.Permission.String(),
+		})
+		states[item.LibraryPanelID] = state
+	}
+
+	return panels, states, nil
+}
+
+// reorgLibraryPanelState applies the mappings to the state of a library panel and reports if anything changed. A
+// team mapped to a team that already has permissions keeps the highest permission of the two.
+func reorgLibraryPanelState(state LibraryPanelReorgStateDTO, teams, folders, owners map[int64]int64) (LibraryPanelReorgStateDTO, bool) {
+	changed := false
+	next := LibraryPanelReorgStateDTO{
+		UID:         state.UID,
+		FolderID:    state.FolderID,
+		CreatedBy:   state.CreatedBy,
+		Permissions: make([]LibraryPanelACLItemDTO, 0, len(state.Permissions)),
+	}
+	if to, ok := folders[state.FolderID]; ok {
+		next.FolderID = to
+		changed = true
+	}
+	if to, ok := owners[state.CreatedBy]; ok {
+		next.CreatedBy = to
+		changed = true
+	}
+
+	byTeam := make(map[int64]int)
+	for _, item := range state.Permissions {
+		if to, ok := teams[item.TeamID]; ok {
+			item.TeamID = to
+			changed = true
+		}
+		if item.TeamID != 0 {
+			if i, ok := byTeam[item.TeamID]; ok {
+				if item.Permission > next.Permissions[i].Permission {
+					next.Permissions[i] = item
+				}
+				continue
+			}
+			byTeam[item.TeamID] = len(next.Permissions)
+		}
+		next.Permissions = append(next.Permissions, item)
+	}
+
+	return next, changed
+}
+
+// setLibraryPanelReorgState moves a library panel to the folder and owner of state and replaces its permissions.
+func setLibraryPanelReorgState(session *sqlstore.DBSession, panel LibraryPanel, state LibraryPanelReorgStateDTO, userID int64) error {
+	libraryPanel := LibraryPanel{
+		FolderID:  state.FolderID,
+		CreatedBy: state.CreatedBy,
+		Version:   panel.Version + 1,
+		Updated:   time.Now(),
+		UpdatedBy: userID,
+	}
+	if _, err := session.ID(panel.ID).Cols("folder_id", "created_by", "version", "updated", "updated_by").Update(&libraryPanel); err != nil {
+		return err
+	}
+	if err := recordLibraryPanelVersion(session, panel.ID, userID); err != nil {
+		return err
+	}
+
+	return replaceLibraryPanelACL(session, panel.OrgID, panel.ID, state.Permissions)
+}
+
+func insertLibraryPanelReorg(session *sqlstore.DBSession, user *models.SignedInUser, rollbackOf int64, mapping interface{}, rollback LibraryPanelReorgRollbackDTO) (int64, error) {
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return 0, err
+	}
+	rollbackJSON, err := json.Marshal(rollback)
+	if err != nil {
+		return 0, err
+	}
+
+	reorg := libraryPanelReorg{
+		OrgID:      user.OrgId,
+		RollbackOf: rollbackOf,
+		Mapping:    string(mappingJSON),
+		Rollback:   string(rollbackJSON),
+		Changed:    int64(len(rollback.LibraryPanels)),
+		Created:    time.Now(),
+		CreatedBy:  user.UserId,
+	}
+	if _, err := session.Insert(&reorg); err != nil {
+		return 0, err
+	}
+
+	return reorg.ID, nil
+}
+
+// reorgLibraryPanels moves the Library Panels of the signed in user's org to new teams, folders and owners after a
+// re-org. Provisioned Library Panels are skipped, and nothing is changed if cmd.DryRun is set. Otherwise the reorg
+// is added to the audit trail together with a rollback file for the Library Panels it changed.
+func (lps *LibraryPanelService) reorgLibraryPanels(c *models.ReqContext, cmd reorgLibraryPanelsCommand) (LibraryPanelReorgResultDTO, error) {
+	teams, err := validateReorgMapping(cmd.Teams, false)
+	if err != nil {
+		return LibraryPanelReorgResultDTO{}, err
+	}
+	folders, err := validateReorgMapping(cmd.Folders, true)
+	if err != nil {
+		return LibraryPanelReorgResultDTO{}, err
+	}
+	owners, err := validateReorgMapping(cmd.Owners, false)
+	if err != nil {
+		return LibraryPanelReorgResultDTO{}, err
+	}
+
+	result := LibraryPanelReorgResultDTO{
+		DryRun:        cmd.DryRun,
+		LibraryPanels: make([]LibraryPanelReorgChangeDTO, 0),
+		Rollback:      LibraryPanelReorgRollbackDTO{LibraryPanels: make([]LibraryPanelReorgStateDTO, 0)},
+	}
+	err = lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		orgID := c.SignedInUser.OrgId
+		if err := requireReorgTargets(session, reorgTargets(teams), "SELECT id FROM team WHERE org_id=? AND id", orgID); err != nil {
+			return err
+		}
+		folderSQL := "SELECT id FROM dashboard WHERE org_id=? AND is_folder=" + lps.SQLStore.Dialect.BooleanStr(true) + " AND id"
+		if err := requireReorgTargets(session, reorgTargets(folders), folderSQL, orgID); err != nil {
+			return err
+		}
+		if err := requireReorgTargets(session, reorgTargets(owners), "SELECT id FROM "+lps.SQLStore.Dialect.Quote("user")+" WHERE id"); err != nil {
+			return err
+		}
+
+		panels, states, err := getLibraryPanelReorgStates(session, orgID)
+		if err != nil {
+			return err
+		}
+		for _, panel := range panels {
+			next, changed := reorgLibraryPanelState(states[panel.ID], teams, folders, owners)
+			if !changed {
+				continue
+			}
+			if panel.Provisioned {
+				result.LibraryPanels = append(result.LibraryPanels, LibraryPanelReorgChangeDTO{UID: panel.UID, Name: panel.Name, Version: panel.Version, Status: rewriteStatusProvisioned})
+				continue
+			}
+			result.LibraryPanels = append(result.LibraryPanels, LibraryPanelReorgChangeDTO{UID: panel.UID, Name: panel.Name, Version: panel.Version + 1, Status: rewriteStatusRewritten})
+			result.Rollback.LibraryPanels = append(result.Rollback.LibraryPanels, states[panel.ID])
+			if cmd.DryRun {
+				continue
+			}
+			if err := setLibraryPanelReorgState(session, panel, next, c.SignedInUser.UserId); err != nil {
+				return err
+			}
+		}
+		if cmd.DryRun {
+			return nil
+		}
+
+		result.ID, err = insertLibraryPanelReorg(session, c.SignedInUser, 0, cmd, result.Rollback)
+		result.Rollback.ReorgID = result.ID
+		return err
+	})
+	if err != nil {
+		return LibraryPanelReorgResultDTO{}, err
+	}
+
+	if !cmd.DryRun {
+		lps.log.Info("Reorganized library panels", "orgId", c.SignedInUser.OrgId, "reorg", result.ID, "changed", len(result.Rollback.LibraryPanels), "userId", c.SignedInUser.UserId)
+	}
+	return result, nil
+}
+
+// rollbackReorg restores the folder, owner and permissions of Library Panels from the rollback file of a reorg.
+// Library Panels that were deleted since are skipped. The rollback is added to the audit trail with a rollback
+// file of its own.
+func (lps *LibraryPanelService) rollbackReorg(c *models.ReqContext, cmd rollbackReorgCommand) (LibraryPanelReorgResultDTO, error) {
+	folderIDs := make([]int64, 0, len(cmd.LibraryPanels))
+	for _, state := range cmd.LibraryPanels {
+		if err := validateACLItems(state.Permissions); err != nil {
+			return LibraryPanelReorgResultDTO{}, err
+		}
+		folderIDs = append(folderIDs, state.FolderID)
+	}
+
+	result := LibraryPanelReorgResultDTO{
+		LibraryPanels: make([]LibraryPanelReorgChangeDTO, 0),
+		Rollback:      LibraryPanelReorgRollbackDTO{LibraryPanels: make([]LibraryPanelReorgStateDTO, 0)},
+	}
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		orgID := c.SignedInUser.OrgId
+		if cmd.ReorgID != 0 {
+			var reorgs []libraryPanelReorg
+			if err := session.SQL("SELECT * FROM library_panel_reorg WHERE id=? AND org_id=?", cmd.ReorgID, orgID).Find(&reorgs); err != nil {
+				return err
+			}
+			if len(reorgs) == 0 {
+				return errLibraryPanelReorgNotFound
+			}
+		}
+		folderSQL := "SELECT id FROM dashboard WHERE org_id=? AND is_folder=" + lps.SQLStore.Dialect.BooleanStr(true) + " AND id"
+		if err := requireReorgTargets(session, folderIDs, folderSQL, orgID); err != nil {
+			return err
+		}
+
+		panels, states, err := getLibraryPanelReorgStates(session, orgID)
+		if err != nil {
+			return err
+		}
+		byUID := make(map[string]LibraryPanel, len(panels))
+		for _, panel := range panels {
+			byUID[panel.UID] = panel
+		}
+		for _, state := range cmd.LibraryPanels {
+			panel, ok := byUID[state.UID]
+			if !ok {
+				result.LibraryPanels = append(result.LibraryPanels, LibraryPanelReorgChangeDTO{UID: state.UID, Status: reorgStatusNotFound})
+				continue
+			}
+			result.LibraryPanels = append(result.LibraryPanels, LibraryPanelReorgChangeDTO{UID: panel.UID, Name: panel.Name, Version: panel.Version + 1, Status: rewriteStatusRewritten})
+			result.Rollback.LibraryPanels = append(result.Rollback.LibraryPanels, states[panel.ID])
+			if err := setLibraryPanelReorgState(session, panel, state, c.SignedInUser.UserId); err != nil {
+				return err
+			}
+		}
+
+		result.ID, err = insertLibraryPanelReorg(session, c.SignedInUser, cmd.ReorgID, struct{}{}, result.Rollback)
+		result.Rollback.ReorgID = result.ID
+		return err
+	})
+	if err != nil {
+		return LibraryPanelReorgResultDTO{}, err
+	}
+
+	lps.log.Info("Rolled back library panel reorg", "orgId", c.SignedInUser.OrgId, "reorg", cmd.ReorgID, "rollback", result.ID, "changed", len(result.Rollback.LibraryPanels), "userId", c.SignedInUser.UserId)
+	return result, nil
+}
+
+// getReorgs gets the audit trail of reorgs of the signed in user's org, most recent first.
+func (lps *LibraryPanelService) getReorgs(c *models.ReqContext) ([]LibraryPanelReorgDTO, error) {
+	dtos := make([]LibraryPanelReorgDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var reorgs []libraryPanelReorg
+		if err := session.SQL("SELECT * FROM library_panel_reorg WHERE org_id=? ORDER BY id DESC", c.SignedInUser.OrgId).Find(&reorgs); err != nil {
+			return err
+		}
+		for _, reorg := range reorgs {
+			dtos = append(dtos, LibraryPanelReorgDTO{
+				ID:         reorg.ID,
+				RollbackOf: reorg.RollbackOf,
+				Mapping:    json.RawMessage(reorg.Mapping),
+				Changed:    reorg.Changed,
+				Created:    reorg.Created,
+				CreatedBy:  reorg.CreatedBy,
+			})
+		}
+		return nil
+	})
+
+	return dtos, err
+}
+
+// getReorgRollback gets the rollback file of a reorg of the signed in user's org.
+func (lps *LibraryPanelService) getReorgRollback(c *models.ReqContext, id int64) (LibraryPanelReorgRollbackDTO, error) {
+	var rollback LibraryPanelReorgRollbackDTO
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var reorgs []libraryPanelReorg
+		if err := session.SQL("SELECT * FROM library_panel_reorg WHERE id=? AND org_id=?", id, c.SignedInUser.OrgId).Find(&reorgs); err != nil {
+			return err
+		}
+		if len(reorgs) == 0 {
+			return errLibraryPanelReorgNotFound
+		}
+		if err := json.Unmarshal([]byte(reorgs[0].Rollback), &rollback); err != nil {
+			return err
+		}
+		rollback.ReorgID = reorgs[0].ID
+		return nil
+	})
+
+	return rollback, err
+}
@@ -0,0 +1,152 @@
+package librarypanels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	// defaultSandboxSecondsToLive is how long a sandbox dashboard lives when no time to live is given.
+	defaultSandboxSecondsToLive = 60 * 60
+	// maxSandboxSecondsToLive is the longest a sandbox dashboard can live.
+	maxSandboxSecondsToLive = 7 * 24 * 60 * 60
+	// sandboxDashboardTag is the tag set on sandbox dashboards.
+	sandboxDashboardTag = "library-panel-sandbox"
+)
+
+// libraryPanelSandbox is the model for temporary dashboards created to try out a library panel.
+type libraryPanelSandbox struct {
+	ID             int64 `xorm:"pk autoincr 'id'"`
+	OrgID          int64 `xorm:"org_id"`
+	LibraryPanelID int64 `xorm:"librarypanel_id"`
+	DashboardID    int64 `xorm:"dashboard_id"`
+	Expires        int64
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// LibraryPanelSandboxDTO is the frontend DTO for library panel sandbox dashboards.
+type LibraryPanelSandboxDTO struct {
+	DashboardUID string    `json:"dashboardUid"`
+	URL          string    `json:"url"`
+	Expiration   time.Time `json:"expiration"`
+}
+
+// getSandboxDashboard wraps a reference to a library panel in a dashboard.
+func getSandboxDashboard(uid string, panel LibraryPanelDTO) *simplejson.Json {
+	dash := simplejson.New()
+	dash.Set("uid", uid)
+	dash.Set("title", fmt.Sprintf("Sandbox: %s (%s)", panel.Name, uid))
+	dash.Set("tags", []interface{}{sandboxDashboardTag})
+	dash.Set("panels", []interface{}{
+		map[string]interface{}{
+			"id":      ephemeralPanelID,
+			"gridPos": map[string]interface{}{"x": 0, "y": 0, "w": 24, "h": 8},
+			"libraryPanel": map[string]interface{}{
+				"uid":  panel.UID,
+				"name": panel.Name,
+			},
+		},
+	})
+
+	return dash
+}
+
+// createSandbox creates a dashboard in the General folder that is connected to a Library Panel and deleted again
+// once it expires.
+func (lps *LibraryPanelService) createSandbox(c *models.ReqContext, uid string, cmd createLibraryPanelSandboxCommand) (LibraryPanelSandboxDTO, error) {
+	if cmd.SecondsToLive < 0 || cmd.SecondsToLive > maxSandboxSecondsToLive {
+		return LibraryPanelSandboxDTO{}, errLibraryPanelSandboxInvalidSecondsToLive
+	}
+	if cmd.SecondsToLive == 0 {
+		cmd.SecondsToLive = defaultSandboxSecondsToLive
+	}
+
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return LibraryPanelSandboxDTO{}, err
+	}
+
+	dash, err := lps.SQLStore.SaveDashboard(models.SaveDashboardCommand{
+		Dashboard: getSandboxDashboard(util.GenerateShortUID(), panel),
+		OrgId:     c.SignedInUser.OrgId,
+		UserId:    c.SignedInUser.UserId,
+	})
+	if err != nil {
+		return LibraryPanelSandboxDTO{}, err
+	}
+
+	sandbox := libraryPanelSandbox{
+		OrgID:          c.SignedInUser.OrgId,
+		LibraryPanelID: panel.ID,
+		DashboardID:    dash.Id,
+		Expires:        time.Now().Unix() + cmd.SecondsToLive,
+		Created:        time.Now(),
+		CreatedBy:      c.SignedInUser.UserId,
+	}
+	err = lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		// the sandbox is connected directly, trying out a panel doesn't require permissions to change it
+		connection := libraryPanelDashboard{
+			DashboardID:    dash.Id,
+			LibraryPanelID: panel.ID,
+			Created:        time.Now(),
+			CreatedBy:      c.SignedInUser.UserId,
+		}
+		if _, err := session.Insert(&connection); err != nil {
+			return err
+		}
+		_, err := session.Insert(&sandbox)
+		return err
+	})
+	if err != nil {
+		if err := bus.Dispatch(&models.DeleteDashboardCommand{Id: dash.Id, OrgId: dash.OrgId}); err != nil {
+			lps.log.Error("Failed to delete sandbox dashboard", "uid", dash.Uid, "error", err)
+		}
+		return LibraryPanelSandboxDTO{}, err
+	}
+
+	return LibraryPanelSandboxDTO{
+		DashboardUID: dash.Uid,
+		URL:          models.GetDashboardUrl(dash.Uid, dash.Slug),
+		Expiration:   time.Unix(sandbox.Expires, 0),
+	}, nil
+}
+
+// deleteExpiredSandboxes deletes all sandbox dashboards that have expired, together with their connections.
+func (lps *LibraryPanelService) deleteExpiredSandboxes(ctx context.Context) (int, error) {
+	var sandboxes []libraryPanelSandbox
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		return session.SQL("SELECT * FROM library_panel_sandbox WHERE expires <= ?", time.Now().Unix()).Find(&sandboxes)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sandbox := range sandboxes {
+		err := bus.Dispatch(&models.DeleteDashboardCommand{Id: sandbox.DashboardID, OrgId: sandbox.OrgID})
+		if err != nil && !errors.Is(err, models.ErrDashboardNotFound) {
+			return 0, err
+		}
+		err = lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+			if _, err := session.Exec("DELETE FROM library_panel_dashboard WHERE dashboard_id=?", sandbox.DashboardID); err != nil {
+				return err
+			}
+			_, err := session.Exec("DELETE FROM library_panel_sandbox WHERE id=?", sandbox.ID)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sandboxes), nil
+}
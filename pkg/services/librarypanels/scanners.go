@@ -0,0 +1,278 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ContentScanner scans the model of library panels for content that needs a compliance review, such as query
+// text matching a regex pack or flagged by an external service.
+type ContentScanner interface {
+	// Name identifies the scanner, findings are replaced per scanner every time a library panel is scanned.
+	Name() string
+	// Scan returns the findings for a library panel, an empty slice if there are none.
+	Scan(ctx context.Context, panel ScannedLibraryPanel) ([]ContentFinding, error)
+}
+
+// ScannedLibraryPanel is the library panel handed to content scanners.
+type ScannedLibraryPanel struct {
+	OrgID int64
+	UID   string
+	Name  string
+	Type  string
+	Model json.RawMessage
+}
+
+// ContentFinding is something a content scanner found in a library panel.
+type ContentFinding struct {
+	Rule     string
+	Severity string
+	Message  string
+}
+
+// libraryPanelFinding is the model for content scanner findings on a library panel.
+type libraryPanelFinding struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	OrgID          int64  `xorm:"org_id"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Scanner        string `xorm:"scanner"`
+	Rule           string `xorm:"rule"`
+	Severity       string `xorm:"severity"`
+	Message        string `xorm:"message"`
+
+	Created time.Time
+}
+
+// LibraryPanelFindingDTO is the DTO for a content scanner finding on a library panel.
+type LibraryPanelFindingDTO struct {
+	UID      string    `json:"uid"`
+	Name     string    `json:"name"`
+	Scanner  string    `json:"scanner"`
+	Rule     string    `json:"rule"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Created  time.Time `json:"created"`
+}
+
+// LibraryPanelScanResultDTO is the outcome of scanning all library panels of an org.
+type LibraryPanelScanResultDTO struct {
+	Scanned  int `json:"scanned"`
+	Findings int `json:"findings"`
+	Failed   int `json:"failed"`
+}
+
+// RegexContentRule flags library panels whose model matches Pattern.
+type RegexContentRule struct {
+	Name     string
+	Severity string
+	Message  string
+	Pattern  string
+}
+
+type regexContentScanner struct {
+	name     string
+	rules    []RegexContentRule
+	patterns []*regexp.Regexp
+}
+
+// NewRegexContentScanner returns a ContentScanner that runs a pack of regex rules over the JSON model of library
+// panels.
+func NewRegexContentScanner(name string, rules []RegexContentRule) (ContentScanner, error) {
+	scanner := &regexContentScanner{name: name, rules: rules, patterns: make([]*regexp.Regexp, 0, len(rules))}
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for rule %q: %w", rule.Name, err)
+		}
+		scanner.patterns = append(scanner.patterns, pattern)
+	}
+
+	return scanner, nil
+}
+
+func (s *regexContentScanner) Name() string {
+	return s.name
+}
+
+func (s *regexContentScanner) Scan(ctx context.Context, panel ScannedLibraryPanel) ([]ContentFinding, error) {
+	findings := make([]ContentFinding, 0)
+	for i, pattern := range s.patterns {
+		if !pattern.Match(panel.Model) {
+			continue
+		}
+		message := s.rules[i].Message
+		if message == "" {
+			message = fmt.Sprintf("model matches %q", s.rules[i].Pattern)
+		}
+		findings = append(findings, ContentFinding{Rule: s.rules[i].Name, Severity: s.rules[i].Severity, Message: message})
+	}
+
+	return findings, nil
+}
+
+// RegisterContentScanner registers a content scanner that runs whenever a library panel is saved and when all
+// library panels of an org are scanned on demand.
+func (lps *LibraryPanelService) RegisterContentScanner(scanner ContentScanner) {
+	lps.scannersMu.Lock()
+	defer lps.scannersMu.Unlock()
+
+	lps.scanners = append(lps.scanners, scanner)
+}
+
+func (lps *LibraryPanelService) getContentScanners() []ContentScanner {
+	lps.scannersMu.RLock()
+	defer lps.scannersMu.RUnlock()
+
+	return append([]ContentScanner(nil), lps.scanners...)
+}
+
+// scanLibraryPanel runs all content scanners on a library panel and replaces the findings of the scanners that
+// succeeded. It returns the number of findings and of scanners that failed.
+func (lps *LibraryPanelService) scanLibraryPanel(ctx context.Context, panelID int64, panel ScannedLibraryPanel) (int, int, error) {
+	scanners := lps.getContentScanners()
+	if len(scanners) == 0 {
+		return 0, 0, nil
+	}
+
+	found, failed := 0, 0
+	findings := make(map[string][]ContentFinding, len(scanners))
+	for _, scanner := range scanners {
+		result, err := scanner.Scan(ctx, panel)
+		if err != nil {
+			lps.log.Warn("Content scanner failed to scan library panel", "scanner", scanner.Name(), "uid", panel.UID, "error", err)
+			failed++
+			continue
+		}
+		findings[scanner.Name()] = result
+		found += len(result)
+	}
+
+	err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+		for scanner, result := range findings {
+			if _, err := session.Exec("DELETE FROM library_panel_finding WHERE librarypanel_id=? AND scanner=?", panelID, scanner); err != nil {
+				return err
+			}
+			for _, finding := range result {
+				libraryPanelFinding := libraryPanelFinding{
+					OrgID:          panel.OrgID,
+					LibraryPanelID: panelID,
+					Scanner:        scanner,
+					Rule:           finding.Rule,
+					Severity:       finding.Severity,
+					Message:        finding.Message,
+					Created:        time.Now(),
+				}
+				if _, err := session.Insert(&libraryPanelFinding); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	return found, failed, err
+}
+
+// scanSavedLibraryPanel scans a library panel that was just saved. Saving doesn't fail when scanning does.
+func (lps *LibraryPanelService) scanSavedLibraryPanel(ctx context.Context, dto LibraryPanelDTO) {
+	panel := ScannedLibraryPanel{OrgID: dto.OrgID, UID: dto.UID, Name: dto.Name, Type: dto.Type, Model: dto.Model}
+	if _, _, err := lps.scanLibraryPanel(ctx, dto.ID, panel); err != nil {
+		lps.log.Error("Failed to store content scanner findings", "uid", dto.UID, "error", err)
+	}
+}
+
+// scanLibraryPanels runs all content scanners on the Library Panels of the signed in user's org.
+func (lps *LibraryPanelService) scanLibraryPanels(c *models.ReqContext) (LibraryPanelScanResultDTO, error) {
+	var panels []LibraryPanel
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return session.SQL("SELECT * FROM library_panel WHERE org_id=? AND deleted_at IS NULL", c.SignedInUser.OrgId).Find(&panels)
+	})
+	if err != nil {
+		return LibraryPanelScanResultDTO{}, err
+	}
+
+	var result LibraryPanelScanResultDTO
+	for _, panel := range panels {
+		found, failed, err := lps.scanLibraryPanel(c.Context.Req.Context(), panel.ID, ScannedLibraryPanel{
+			OrgID: panel.OrgID,
+			UID:   panel.UID,
+			Name:  panel.Name,
+			Type:  panel.Type,
+			Model: panel.Model,
+		})
+		if err != nil {
+			return LibraryPanelScanResultDTO{}, err
+		}
+		result.Scanned++
+		result.Findings += found
+		result.Failed += failed
+	}
+
+	return result, nil
+}
+
+// getFindings gets the content scanner findings on the Library Panels of the signed in user's org, optionally
+// limited to a scanner and a severity.
+func (lps *LibraryPanelService) getFindings(c *models.ReqContext, scanner string, severity string) ([]LibraryPanelFindingDTO, error) {
+	builder := sqlstore.SQLBuilder{}
+	builder.Write(`SELECT lp.uid, lp.name, lpf.scanner, lpf.rule, lpf.severity, lpf.message, lpf.created
+FROM library_panel_finding AS lpf
+INNER JOIN library_panel AS lp ON lp.id = lpf.librarypanel_id`)
+	builder.Write(" WHERE lpf.org_id=? AND lp.deleted_at IS NULL", c.SignedInUser.OrgId)
+	if scanner != "" {
+		builder.Write(" AND lpf.scanner=?", scanner)
+	}
+	if severity != "" {
+		builder.Write(" AND lpf.severity=?", severity)
+	}
+	builder.Write(" ORDER BY lp.name ASC, lpf.id ASC")
+
+	return lps.findFindings(c, builder)
+}
+
+// getFindingsForLibraryPanel gets the content scanner findings on a Library Panel.
+func (lps *LibraryPanelService) getFindingsForLibraryPanel(c *models.ReqContext, uid string) ([]LibraryPanelFindingDTO, error) {
+	panel, err := lps.getLibraryPanel(c, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := sqlstore.SQLBuilder{}
+	builder.Write(`SELECT lp.uid, lp.name, lpf.scanner, lpf.rule, lpf.severity, lpf.message, lpf.created
+FROM library_panel_finding AS lpf
+INNER JOIN library_panel AS lp ON lp.id = lpf.librarypanel_id`)
+	builder.Write(" WHERE lpf.librarypanel_id=?", panel.ID)
+	builder.Write(" ORDER BY lpf.id ASC")
+
+	return lps.findFindings(c, builder)
+}
+
+func (lps *LibraryPanelService) findFindings(c *models.ReqContext, builder sqlstore.SQLBuilder) ([]LibraryPanelFindingDTO, error) {
+	findings := make([]LibraryPanelFindingDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var rows []struct {
+			UID      string    `xorm:"uid"`
+			Name     string    `xorm:"name"`
+			Scanner  string    `xorm:"scanner"`
+			Rule     string    `xorm:"rule"`
+			Severity string    `xorm:"severity"`
+			Message  string    `xorm:"message"`
+			Created  time.Time `xorm:"created"`
+		}
+		if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			findings = append(findings, LibraryPanelFindingDTO(row))
+		}
+		return nil
+	})
+
+	return findings, err
+}
@@ -0,0 +1,106 @@
+package librarypanels
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	// similarNameThreshold is the trigram similarity from which library panel names are considered similar.
+	similarNameThreshold = 0.5
+	// maxSimilarLibraryPanels is the number of similarly named library panels that are suggested.
+	maxSimilarLibraryPanels = 5
+	// maxSimilarNameCandidates is the number of library panels compared with a name.
+	maxSimilarNameCandidates = 1000
+)
+
+// LibraryPanelSimilarDTO is a library panel with a name similar to the name of a new library panel.
+type LibraryPanelSimilarDTO struct {
+	UID        string  `json:"uid"`
+	Name       string  `json:"name"`
+	FolderUID  string  `json:"folderUid"`
+	FolderName string  `json:"folderName"`
+	Similarity float64 `json:"similarity"`
+}
+
+// nameTrigrams returns the trigrams of the lower cased words of a name, each word padded like pg_trgm does.
+func nameTrigrams(name string) map[string]bool {
+	trigrams := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			trigrams[string(padded[i:i+3])] = true
+		}
+	}
+
+	return trigrams
+}
+
+// nameSimilarity returns the share of trigrams two names have in common, 1 for names that only differ in case.
+func nameSimilarity(a, b string) float64 {
+	if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+		return 1
+	}
+
+	trigramsA, trigramsB := nameTrigrams(a), nameTrigrams(b)
+	if len(trigramsA) == 0 || len(trigramsB) == 0 {
+		return 0
+	}
+	shared := 0
+	for trigram := range trigramsA {
+		if trigramsB[trigram] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(trigramsA)+len(trigramsB)-shared)
+}
+
+// getSimilarLibraryPanels gets the Library Panels the signed in user can view with names similar to name, most
+// similar first. The Library Panel with excludeUID is left out.
+func (lps *LibraryPanelService) getSimilarLibraryPanels(c *models.ReqContext, name string, excludeUID string) ([]LibraryPanelSimilarDTO, error) {
+	similar := make([]LibraryPanelSimilarDTO, 0)
+	if strings.TrimSpace(name) == "" {
+		return similar, nil
+	}
+
+	query := searchLibraryPanelsQuery{perPage: maxSimilarNameCandidates, page: 1, excludeUID: excludeUID}
+	builder, _, err := lps.buildSearchLibraryPanelsSQL(c.SignedInUser, query, selectLibrayPanelDTOForSearch)
+	if err != nil {
+		return nil, err
+	}
+	var libraryPanels []LibraryPanelWithMeta
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		return session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&libraryPanels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, panel := range libraryPanels {
+		similarity := nameSimilarity(name, panel.Name)
+		if similarity < similarNameThreshold {
+			continue
+		}
+		similar = append(similar, LibraryPanelSimilarDTO{
+			UID:        panel.UID,
+			Name:       panel.Name,
+			FolderUID:  panel.FolderUID,
+			FolderName: panel.FolderName,
+			Similarity: similarity,
+		})
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Similarity > similar[j].Similarity
+	})
+	if len(similar) > maxSimilarLibraryPanels {
+		similar = similar[:maxSimilarLibraryPanels]
+	}
+
+	return similar, nil
+}
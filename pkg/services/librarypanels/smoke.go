@@ -0,0 +1,184 @@
+package librarypanels
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelSmokeRender is the model for the last smoke render of a library panel published to the catalog.
+type libraryPanelSmokeRender struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	OrgID          int64  `xorm:"org_id"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Version        int64  `xorm:"'version'"`
+	Passed         bool   `xorm:"passed"`
+	Error          string `xorm:"error"`
+
+	Rendered time.Time
+}
+
+// LibraryPanelSmokeRenderDTO is the DTO for the last smoke render of a library panel.
+type LibraryPanelSmokeRenderDTO struct {
+	UID      string    `json:"uid"`
+	Name     string    `json:"name"`
+	Version  int64     `json:"version"`
+	Passed   bool      `json:"passed"`
+	Error    string    `json:"error"`
+	Rendered time.Time `json:"rendered"`
+}
+
+// LibraryPanelSmokeRenderResultDTO is the outcome of smoke rendering the library panels published to the catalog.
+type LibraryPanelSmokeRenderResultDTO struct {
+	Rendered int `json:"rendered"`
+	Failed   int `json:"failed"`
+}
+
+// replaceDatasources replaces the datasource of a panel and of its targets that have one.
+func replaceDatasources(model json.RawMessage, datasource string) (json.RawMessage, error) {
+	var panel map[string]interface{}
+	if err := json.Unmarshal(model, &panel); err != nil {
+		return nil, err
+	}
+
+	panel["datasource"] = datasource
+	if targets, ok := panel["targets"].([]interface{}); ok {
+		for _, t := range targets {
+			target, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := target["datasource"]; ok {
+				target["datasource"] = datasource
+			}
+		}
+	}
+
+	return json.Marshal(&panel)
+}
+
+// smokeRenderLibraryPanels renders every library panel published to the catalog of any org and records whether it
+// rendered. Panels are rendered as an org admin against the smoke render datasource, if the org has one.
+func (lps *LibraryPanelService) smokeRenderLibraryPanels(ctx context.Context) (LibraryPanelSmokeRenderResultDTO, error) {
+	var panels []LibraryPanel
+	var datasources []struct {
+		OrgID int64 `xorm:"org_id"`
+	}
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		sql := `SELECT lp.* FROM library_panel AS lp
+INNER JOIN library_panel_catalog_entry AS lpce ON lpce.librarypanel_id = lp.id
+WHERE lp.deleted_at IS NULL
+ORDER BY lp.id ASC`
+		if err := session.SQL(sql).Find(&panels); err != nil {
+			return err
+		}
+		if lps.Cfg.PanelLibrarySmokeRenderDatasource == "" {
+			return nil
+		}
+		return session.SQL("SELECT org_id FROM data_source WHERE name=?", lps.Cfg.PanelLibrarySmokeRenderDatasource).Find(&datasources)
+	})
+	if err != nil {
+		return LibraryPanelSmokeRenderResultDTO{}, err
+	}
+	hasDatasource := make(map[int64]bool, len(datasources))
+	for _, datasource := range datasources {
+		hasDatasource[datasource.OrgID] = true
+	}
+
+	opts := lps.smokeRenderOpts()
+	var result LibraryPanelSmokeRenderResultDTO
+	for _, panel := range panels {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		smokeRender := libraryPanelSmokeRender{
+			OrgID:          panel.OrgID,
+			LibraryPanelID: panel.ID,
+			Version:        panel.Version,
+			Passed:         true,
+		}
+		if err := lps.smokeRenderLibraryPanel(ctx, panel, hasDatasource[panel.OrgID], opts); err != nil {
+			lps.log.Debug("Library panel failed to smoke render", "uid", panel.UID, "error", err)
+			smokeRender.Passed = false
+			smokeRender.Error = err.Error()
+			result.Failed++
+		}
+		smokeRender.Rendered = time.Now()
+		result.Rendered++
+
+		err := lps.SQLStore.WithTransactionalDbSession(ctx, func(session *sqlstore.DBSession) error {
+			if _, err := session.Exec("DELETE FROM library_panel_smoke_render WHERE librarypanel_id=?", panel.ID); err != nil {
+				return err
+			}
+			_, err := session.Insert(&smokeRender)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// smokeRenderOpts are the options library panels are smoke rendered with.
+func (lps *LibraryPanelService) smokeRenderOpts() renderOpts {
+	return renderOpts{
+		width:     800,
+		height:    400,
+		timeout:   time.Minute,
+		scale:     1,
+		from:      lps.Cfg.PanelLibrarySmokeRenderFrom,
+		to:        lps.Cfg.PanelLibrarySmokeRenderTo,
+		variables: map[string]string{},
+	}
+}
+
+func (lps *LibraryPanelService) smokeRenderLibraryPanel(ctx context.Context, panel LibraryPanel, replaceDatasource bool, opts renderOpts) error {
+	model := panel.Model
+	if replaceDatasource {
+		replaced, err := replaceDatasources(model, lps.Cfg.PanelLibrarySmokeRenderDatasource)
+		if err != nil {
+			return err
+		}
+		model = replaced
+	}
+
+	user := &models.SignedInUser{OrgId: panel.OrgID, OrgRole: models.ROLE_ADMIN}
+	dto := LibraryPanelDTO{OrgID: panel.OrgID, FolderID: panel.FolderID, UID: panel.UID, Name: panel.Name, Model: model}
+	_, err := lps.renderEphemeral(ctx, user, dto, opts, nil)
+	return err
+}
+
+// getBrokenLibraryPanels gets the Library Panels of the signed in user's org that failed their last smoke render.
+func (lps *LibraryPanelService) getBrokenLibraryPanels(c *models.ReqContext) ([]LibraryPanelSmokeRenderDTO, error) {
+	broken := make([]LibraryPanelSmokeRenderDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var rows []struct {
+			UID      string    `xorm:"uid"`
+			Name     string    `xorm:"name"`
+			Version  int64     `xorm:"'version'"`
+			Passed   bool      `xorm:"passed"`
+			Error    string    `xorm:"error"`
+			Rendered time.Time `xorm:"rendered"`
+		}
+		sql := `SELECT lp.uid, lp.name, lpsr.version, lpsr.passed, lpsr.error, lpsr.rendered
+FROM library_panel_smoke_render AS lpsr
+INNER JOIN library_panel AS lp ON lp.id = lpsr.librarypanel_id
+WHERE lpsr.org_id=? AND lpsr.passed=` + lps.SQLStore.Dialect.BooleanStr(false) + ` AND lp.deleted_at IS NULL
+ORDER BY lp.name ASC`
+		if err := session.SQL(sql, c.SignedInUser.OrgId).Find(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			broken = append(broken, LibraryPanelSmokeRenderDTO(row))
+		}
+		return nil
+	})
+
+	return broken, err
+}
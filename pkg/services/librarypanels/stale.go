@@ -0,0 +1,148 @@
+package librarypanels
+
+import (
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/components/gtime"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// defaultStaleWindow is the time window of the stale library panels report when none is given.
+const defaultStaleWindow = "90d"
+
+// LibraryPanelStaleDTO is the DTO for a library panel that wasn't updated or connected to a dashboard recently.
+type LibraryPanelStaleDTO struct {
+	UID                 string                  `json:"uid"`
+	Name                string                  `json:"name"`
+	FolderID            int64                   `json:"folderId"`
+	NotUpdated          bool                    `json:"notUpdated"`
+	NotConnected        bool                    `json:"notConnected"`
+	ConnectedDashboards int64                   `json:"connectedDashboards"`
+	LastConnected       *time.Time              `json:"lastConnected,omitempty"`
+	Updated             time.Time               `json:"updated"`
+	UpdatedBy           LibraryPanelDTOMetaUser `json:"updatedBy"`
+}
+
+// parseStaleWindow parses a time window such as 90d or 12w and returns the time it starts at.
+func parseStaleWindow(window string, now time.Time) (time.Time, error) {
+	duration, err := gtime.ParseDuration(strings.TrimSpace(window))
+	if err != nil || duration <= 0 {
+		return time.Time{}, errLibraryPanelInvalidStaleWindow
+	}
+
+	return now.Add(-duration), nil
+}
+
+// writeNotUpdatedSinceSQL limits the search to library panels that weren't updated within a time window.
+func writeNotUpdatedSinceSQL(notUpdatedSince *time.Time, builder *sqlstore.SQLBuilder) {
+	if notUpdatedSince != nil {
+		builder.Write(" AND lp.updated < ?", *notUpdatedSince)
+	}
+}
+
+// writeNotUsedSinceSQL limits the search to library panels that weren't connected to a dashboard or weren't viewed
+// within a time window, library panels without a last connected or last viewed time never were.
+func writeNotUsedSinceSQL(notConnectedSince *time.Time, notViewedSince *time.Time, builder *sqlstore.SQLBuilder) {
+	if notConnectedSince != nil {
+		builder.Write(" AND (lp.last_connected_at IS NULL OR lp.last_connected_at < ?)", *notConnectedSince)
+	}
+	if notViewedSince != nil {
+		builder.Write(" AND (lp.last_viewed_at IS NULL OR lp.last_viewed_at < ?)", *notViewedSince)
+	}
+}
+
+// getStaleLibraryPanels gets the Library Panels of the signed in user's org that weren't updated or weren't
+// connected to a dashboard within a time window, least recently updated first. Library Panels created within the
+// window aren't reported as not connected. Only Library Panels in folders the user can view are returned.
+func (lps *LibraryPanelService) getStaleLibraryPanels(c *models.ReqContext, window string) ([]LibraryPanelStaleDTO, error) {
+	if window == "" {
+		window = defaultStaleWindow
+	}
+	since, err := parseStaleWindow(window, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	stale := make([]LibraryPanelStaleDTO, 0)
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var panels []struct {
+			ID             int64     `xorm:"id"`
+			UID            string    `xorm:"uid"`
+			Name           string    `xorm:"name"`
+			FolderID       int64     `xorm:"folder_id"`
+			Created        time.Time `xorm:"created"`
+			Updated        time.Time `xorm:"updated"`
+			UpdatedBy      int64     `xorm:"updated_by"`
+			UpdatedByName  string    `xorm:"updated_by_name"`
+			UpdatedByEmail string    `xorm:"updated_by_email"`
+		}
+		sql := `SELECT lp.id, lp.uid, lp.name, lp.folder_id, lp.created, lp.updated, lp.updated_by, u.login AS updated_by_name, u.email AS updated_by_email
+FROM library_panel AS lp
+	LEFT JOIN user AS u ON lp.updated_by = u.id
+WHERE lp.org_id=? AND lp.deleted_at IS NULL
+	AND (lp.updated < ? OR (lp.created < ? AND NOT EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id AND lpd.created >= ?)))
+ORDER BY lp.updated ASC, lp.uid ASC`
+		if err := session.SQL(sql, c.SignedInUser.OrgId, since, since, since).Find(&panels); err != nil {
+			return err
+		}
+		if len(panels) == 0 {
+			return nil
+		}
+
+		var connections []struct {
+			LibraryPanelID int64     `xorm:"librarypanel_id"`
+			Created        time.Time `xorm:"created"`
+		}
+		sql = `SELECT lpd.librarypanel_id, lpd.created FROM library_panel_dashboard AS lpd
+INNER JOIN library_panel AS lp ON lp.id = lpd.librarypanel_id
+WHERE lp.org_id=? AND lp.deleted_at IS NULL`
+		if err := session.SQL(sql, c.SignedInUser.OrgId).Find(&connections); err != nil {
+			return err
+		}
+		connected := make(map[int64]int64)
+		lastConnected := make(map[int64]time.Time)
+		for _, connection := range connections {
+			connected[connection.LibraryPanelID]++
+			if connection.Created.After(lastConnected[connection.LibraryPanelID]) {
+				lastConnected[connection.LibraryPanelID] = connection.Created
+			}
+		}
+
+		canView := lps.folderViewChecker(c.SignedInUser)
+		for _, panel := range panels {
+			allowed, err := canView(panel.FolderID)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				continue
+			}
+
+			dto := LibraryPanelStaleDTO{
+				UID:                 panel.UID,
+				Name:                panel.Name,
+				FolderID:            panel.FolderID,
+				NotUpdated:          panel.Updated.Before(since),
+				ConnectedDashboards: connected[panel.ID],
+				Updated:             panel.Updated,
+				UpdatedBy: LibraryPanelDTOMetaUser{
+					ID:        panel.UpdatedBy,
+					Name:      panel.UpdatedByName,
+					AvatarUrl: dtos.GetGravatarUrl(panel.UpdatedByEmail),
+				},
+			}
+			if last, ok := lastConnected[panel.ID]; ok {
+				dto.LastConnected = &last
+			}
+			dto.NotConnected = panel.Created.Before(since) && (dto.LastConnected == nil || dto.LastConnected.Before(since))
+			stale = append(stale, dto)
+		}
+
+		return nil
+	})
+
+	return stale, err
+}
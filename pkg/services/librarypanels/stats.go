@@ -0,0 +1,168 @@
+package librarypanels
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	defaultTopStatsLimit = 10
+	maxTopStatsLimit     = 100
+)
+
+// LibraryPanelTopStatsEntryDTO is a library panel in the most used library panels of an org.
+type LibraryPanelTopStatsEntryDTO struct {
+	UID                 string `json:"uid"`
+	Name                string `json:"name"`
+	Type                string `json:"type"`
+	FolderID            int64  `json:"folderId"`
+	ConnectedDashboards int64  `json:"connectedDashboards"`
+	ViewsLast30Days     int64  `json:"viewsLast30Days"`
+}
+
+// LibraryPanelTypeStatsDTO is the number of library panels of a panel type and how much they're used.
+type LibraryPanelTypeStatsDTO struct {
+	Type                string `json:"type"`
+	Count               int64  `json:"count"`
+	ConnectedDashboards int64  `json:"connectedDashboards"`
+	ViewsLast30Days     int64  `json:"viewsLast30Days"`
+}
+
+// LibraryPanelTopStatsDTO is the most used library panels of an org by connected dashboards and by recent views,
+// with the usage of all library panels broken down by panel type.
+type LibraryPanelTopStatsDTO struct {
+	ByConnections []LibraryPanelTopStatsEntryDTO `json:"byConnections"`
+	ByViews       []LibraryPanelTopStatsEntryDTO `json:"byViews"`
+	Types         []LibraryPanelTypeStatsDTO     `json:"types"`
+}
+
+// topStatsConnectionsSQL is the number of dashboards connected to each Library Panel as count.
+const topStatsConnectionsSQL = "SELECT librarypanel_id, COUNT(*) AS count FROM library_panel_dashboard GROUP BY librarypanel_id"
+
+// topStatsViewsSQL is the number of views of each Library Panel since a day as count.
+const topStatsViewsSQL = "SELECT librarypanel_id, SUM(views) AS count FROM library_panel_view WHERE day >= ? GROUP BY librarypanel_id"
+
+// writeTopStatsFromSQL writes the FROM and WHERE clauses of the top stats queries after joins, which can have
+// params. Library Panels are joined with their folder as dashboard and, for users that aren't org admins, restricted
+// to folders the user can view.
+func writeTopStatsFromSQL(user *models.SignedInUser, builder *sqlstore.SQLBuilder, joins string, params ...interface{}) {
+	builder.Write(" FROM library_panel AS lp"+joins+
+		" LEFT JOIN dashboard AS dashboard ON dashboard.id = lp.folder_id AND lp.folder_id <> 0"+
+		" WHERE lp.org_id=? AND lp.deleted_at IS NULL", append(params, user.OrgId)...)
+	if user.OrgRole != models.ROLE_ADMIN {
+		builder.Write(" AND (lp.folder_id = 0 OR (dashboard.id IS NOT NULL")
+		builder.WriteDashboardPermissionFilter(user, models.PERMISSION_VIEW)
+		builder.Write("))")
+	}
+}
+
+// getTopStatsEntries gets the limit Library Panels with the highest count in usageSQL, most used first and by uid
+// when the count is the same. Only Library Panels with a count above zero are returned, with both their connected
+// dashboards and their views.
+func (lps *LibraryPanelService) getTopStatsEntries(session *sqlstore.DBSession, user *models.SignedInUser, limit int,
+	usageSQL string, params ...interface{}) ([]LibraryPanelTopStatsEntryDTO, error) {
+	var panels []struct {
+		ID       int64  `xorm:"id"`
+		UID      string `xorm:"uid"`
+		Name     string `xorm:"name"`
+		Type     string `xorm:"type"`
+		FolderID int64  `xorm:"folder_id"`
+	}
+	builder := sqlstore.SQLBuilder{}
+	builder.Write("SELECT lp.id, lp.uid, lp.name, lp.type, lp.folder_id")
+	writeTopStatsFromSQL(user, &builder, " INNER JOIN ("+usageSQL+") AS used ON used.librarypanel_id = lp.id", params...)
+	builder.Write(" AND used.count > 0 ORDER BY used.count DESC, lp.uid ASC" + lps.SQLStore.Dialect.Limit(int64(limit)))
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&panels); err != nil {
+		return nil, err
+	}
+
+	panelIDs := make([]int64, 0, len(panels))
+	for _, panel := range panels {
+		panelIDs = append(panelIDs, panel.ID)
+	}
+	connections, err := getConnectedDashboardCounts(session, panelIDs...)
+	if err != nil {
+		return nil, err
+	}
+	views, err := getViewsLast30Days(session, panelIDs...)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LibraryPanelTopStatsEntryDTO, 0, len(panels))
+	for _, panel := range panels {
+		entries = append(entries, LibraryPanelTopStatsEntryDTO{
+			UID:                 panel.UID,
+			Name:                panel.Name,
+			Type:                panel.Type,
+			FolderID:            panel.FolderID,
+			ConnectedDashboards: connections[panel.ID],
+			ViewsLast30Days:     views[panel.ID],
+		})
+	}
+
+	return entries, nil
+}
+
+// getTypeStats gets the number of Library Panels of each panel type and how much they're used, most common first.
+func getTypeStats(session *sqlstore.DBSession, user *models.SignedInUser, oldest string) ([]LibraryPanelTypeStatsDTO, error) {
+	var rows []struct {
+		Type                string `xorm:"type"`
+		Count               int64  `xorm:"panel_count"`
+		ConnectedDashboards int64  `xorm:"connected_dashboards"`
+		Views               int64  `xorm:"view_count"`
+	}
+	builder := sqlstore.SQLBuilder{}
+	builder.Write("SELECT lp.type, COUNT(*) AS panel_count, COALESCE(SUM(connections.count), 0) AS connected_dashboards, " +
+		"COALESCE(SUM(views.count), 0) AS view_count")
+	writeTopStatsFromSQL(user, &builder, " LEFT JOIN ("+topStatsConnectionsSQL+") AS connections ON connections.librarypanel_id = lp.id"+
+		" LEFT JOIN ("+topStatsViewsSQL+") AS views ON views.librarypanel_id = lp.id", oldest)
+	builder.Write(" GROUP BY lp.type ORDER BY panel_count DESC, lp.type ASC")
+	if err := session.SQL(builder.GetSQLString(), builder.GetParams()...).Find(&rows); err != nil {
+		return nil, err
+	}
+
+	types := make([]LibraryPanelTypeStatsDTO, 0, len(rows))
+	for _, row := range rows {
+		types = append(types, LibraryPanelTypeStatsDTO{
+			Type:                row.Type,
+			Count:               row.Count,
+			ConnectedDashboards: row.ConnectedDashboards,
+			ViewsLast30Days:     row.Views,
+		})
+	}
+
+	return types, nil
+}
+
+// getTopStats gets the limit most used Library Panels of the signed in user's org by connected dashboards and by
+// views in the last 30 days. Only Library Panels in folders the user can view are counted. The usage is grouped and
+// ranked in the database, only the top Library Panels are loaded.
+func (lps *LibraryPanelService) getTopStats(c *models.ReqContext, limit int) (LibraryPanelTopStatsDTO, error) {
+	if limit <= 0 {
+		limit = defaultTopStatsLimit
+	}
+	if limit > maxTopStatsLimit {
+		limit = maxTopStatsLimit
+	}
+
+	var stats LibraryPanelTopStatsDTO
+	oldest := time.Now().UTC().AddDate(0, 0, -(viewsWindowDays - 1)).Format(viewsDayLayout)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var err error
+		if stats.ByConnections, err = lps.getTopStatsEntries(session, c.SignedInUser, limit, topStatsConnectionsSQL); err != nil {
+			return err
+		}
+		if stats.ByViews, err = lps.getTopStatsEntries(session, c.SignedInUser, limit, topStatsViewsSQL, oldest); err != nil {
+			return err
+		}
+		stats.Types, err = getTypeStats(session, c.SignedInUser, oldest)
+		return err
+	})
+	if err != nil {
+		return LibraryPanelTopStatsDTO{}, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,116 @@
+package librarypanels
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// LibraryPanelSuggestionDTO is the DTO for a dashboard panel that is identical to an existing library panel.
+type LibraryPanelSuggestionDTO struct {
+	PanelID          int64  `json:"panelId"`
+	PanelTitle       string `json:"panelTitle"`
+	LibraryPanelUID  string `json:"libraryPanelUid"`
+	LibraryPanelName string `json:"libraryPanelName"`
+	FolderID         int64  `json:"folderId"`
+}
+
+// SuggestLibraryPanelsForDashboard finds the top level panels of a dashboard that are identical, except for their
+// placement, to a Library Panel the signed in user can view. The dashboard isn't changed, the suggestions are meant
+// to nudge users towards reusing the Library Panel.
+func (lps *LibraryPanelService) SuggestLibraryPanelsForDashboard(c *models.ReqContext, dash *models.Dashboard) ([]LibraryPanelSuggestionDTO, error) {
+	suggestions := make([]LibraryPanelSuggestionDTO, 0)
+	if !lps.IsEnabled() {
+		return suggestions, nil
+	}
+
+	type dashboardPanel struct {
+		id    int64
+		title string
+	}
+	byHash := make(map[string][]dashboardPanel)
+	var hashes []interface{}
+	var types []interface{}
+	seenTypes := make(map[string]bool)
+	for _, panel := range dash.Data.Get("panels").MustArray() {
+		panelAsMap, ok := panel.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		_, hash, err := normalizePanelModel(panelAsMap)
+		if err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			continue
+		}
+		if _, ok := byHash[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		panelType, _ := panelAsMap["type"].(string)
+		if !seenTypes[panelType] {
+			seenTypes[panelType] = true
+			types = append(types, panelType)
+		}
+		panelAsJSON := simplejson.NewFromAny(panelAsMap)
+		byHash[hash] = append(byHash[hash], dashboardPanel{
+			id:    panelAsJSON.Get("id").MustInt64(),
+			title: panelAsJSON.Get("title").MustString(),
+		})
+	}
+	if len(hashes) == 0 {
+		return suggestions, nil
+	}
+
+	// Library Panels that weren't written since normalized_hash was added have no stored hash, it's computed for
+	// them instead
+	var libraryPanels []LibraryPanel
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		sql := "SELECT * FROM library_panel WHERE org_id=? AND deleted_at IS NULL AND (normalized_hash IN (?" +
+			strings.Repeat(",?", len(hashes)-1) + ") OR (normalized_hash IS NULL AND type IN (?" +
+			strings.Repeat(",?", len(types)-1) + "))) ORDER BY name"
+		params := []interface{}{c.SignedInUser.OrgId}
+		params = append(params, hashes...)
+		params = append(params, types...)
+		return session.SQL(sql, params...).Find(&libraryPanels)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	canView := lps.folderViewChecker(c.SignedInUser)
+	suggested := make(map[string]bool)
+	for _, libraryPanel := range libraryPanels {
+		hash := libraryPanel.NormalizedHash
+		if hash == "" {
+			if hash, err = normalizedModelHash(libraryPanel.Model); err != nil {
+				continue
+			}
+		}
+		if len(byHash[hash]) == 0 || suggested[hash] {
+			continue
+		}
+		allowed, err := canView(libraryPanel.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+
+		suggested[hash] = true
+		for _, panel := range byHash[hash] {
+			suggestions = append(suggestions, LibraryPanelSuggestionDTO{
+				PanelID:          panel.id,
+				PanelTitle:       panel.title,
+				LibraryPanelUID:  libraryPanel.UID,
+				LibraryPanelName: libraryPanel.Name,
+				FolderID:         libraryPanel.FolderID,
+			})
+		}
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,83 @@
+package librarypanels
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelTag is the model for library panel tags.
+type libraryPanelTag struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	Term           string `xorm:"term"`
+}
+
+// maxTagLength is the length of the term column in library_panel_tag.
+const maxTagLength = 50
+
+// normalizeTags trims tags, drops empty and duplicate tags and sorts the rest.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if len(tag) > maxTagLength {
+			return nil, errLibraryPanelInvalidTag
+		}
+		if len(tag) == 0 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+
+	return normalized, nil
+}
+
+func writeTagFilterSQL(tags []string, builder *sqlstore.SQLBuilder) {
+	for _, tag := range tags {
+		builder.Write(" AND EXISTS (SELECT 1 FROM library_panel_tag AS lpt WHERE lpt.librarypanel_id = lp.id AND lpt.term=?)", tag)
+	}
+}
+
+func getTagsForLibraryPanels(session *sqlstore.DBSession, panelIDs ...int64) (map[int64][]string, error) {
+	tagsByPanel := make(map[int64][]string)
+	if len(panelIDs) == 0 {
+		return tagsByPanel, nil
+	}
+
+	params := make([]interface{}, 0, len(panelIDs))
+	for _, id := range panelIDs {
+		params = append(params, id)
+	}
+	var tags []libraryPanelTag
+	sql := "SELECT * FROM library_panel_tag WHERE librarypanel_id IN (?" + strings.Repeat(",?", len(panelIDs)-1) + ") ORDER BY term"
+	if err := session.SQL(sql, params...).Find(&tags); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		tagsByPanel[tag.LibraryPanelID] = append(tagsByPanel[tag.LibraryPanelID], tag.Term)
+	}
+
+	return tagsByPanel, nil
+}
+
+func setTagsForLibraryPanel(session *sqlstore.DBSession, panelID int64, tags []string) error {
+	if _, err := session.Exec("DELETE FROM library_panel_tag WHERE librarypanel_id=?", panelID); err != nil {
+		return err
+	}
+	for _, term := range tags {
+		tag := libraryPanelTag{
+			LibraryPanelID: panelID,
+			Term:           term,
+		}
+		if _, err := session.Insert(&tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/components/apikeygen"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// libraryPanelToken is the model for tokens granting read access to a single library panel.
+type libraryPanelToken struct {
+	ID             int64  `xorm:"pk autoincr 'id'"`
+	OrgID          int64  `xorm:"org_id"`
+	LibraryPanelID int64  `xorm:"librarypanel_id"`
+	UID            string `xorm:"uid"`
+	Name           string `xorm:"name"`
+	Key            string `xorm:"key"`
+	Expires        *int64
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// LibraryPanelTokenDTO is the frontend DTO for library panel tokens.
+type LibraryPanelTokenDTO struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Created    time.Time  `json:"created"`
+	// Key is only returned when the token is created.
+	Key string `json:"key,omitempty"`
+}
+
+// LibraryPanelEmbedDTO is the DTO returned to holders of a library panel token.
+type LibraryPanelEmbedDTO struct {
+	UID   string          `json:"uid"`
+	Name  string          `json:"name"`
+	Type  string          `json:"type"`
+	Model json.RawMessage `json:"model"`
+}
+
+func toLibraryPanelTokenDTO(token libraryPanelToken) LibraryPanelTokenDTO {
+	dto := LibraryPanelTokenDTO{
+		ID:      token.ID,
+		Name:    token.Name,
+		Created: token.Created,
+	}
+	if token.Expires != nil {
+		expiration := time.Unix(*token.Expires, 0)
+		dto.Expiration = &expiration
+	}
+
+	return dto
+}
+
+// getPanelForTokens gets a Library Panel the signed in user is allowed to manage tokens for.
+func (lps *LibraryPanelService) getPanelForTokens(session *sqlstore.DBSession, user *models.SignedInUser, uid string) (LibraryPanelWithMeta, error) {
+	panel, err := getLibraryPanel(session, uid, user.OrgId)
+	if err != nil {
+		return LibraryPanelWithMeta{}, err
+	}
+	if err := lps.requirePermissionsOnFolder(user, panel.FolderID); err != nil {
+		return LibraryPanelWithMeta{}, err
+	}
+
+	return panel, nil
+}
+
+// createToken creates a token granting read access to a single Library Panel.
+func (lps *LibraryPanelService) createToken(c *models.ReqContext, uid string, cmd createLibraryPanelTokenCommand) (LibraryPanelTokenDTO, error) {
+	if cmd.SecondsToLive < 0 {
+		return LibraryPanelTokenDTO{}, errLibraryPanelTokenInvalidSecondsToLive
+	}
+
+	var dto LibraryPanelTokenDTO
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := lps.getPanelForTokens(session, c.SignedInUser, uid)
+		if err != nil {
+			return err
+		}
+
+		token := libraryPanelToken{
+			OrgID:          c.SignedInUser.OrgId,
+			LibraryPanelID: panel.ID,
+			UID:            util.GenerateShortUID(),
+			Name:           cmd.Name,
+			Created:        time.Now(),
+			CreatedBy:      c.SignedInUser.UserId,
+		}
+		if cmd.SecondsToLive > 0 {
+			expires := time.Now().Add(time.Second * time.Duration(cmd.SecondsToLive)).Unix()
+			token.Expires = &expires
+		}
+		newKey, err := apikeygen.New(token.OrgID, token.UID)
+		if err != nil {
+			return err
+		}
+		token.Key = newKey.HashedKey
+		if _, err := session.Insert(&token); err != nil {
+			return err
+		}
+
+		dto = toLibraryPanelTokenDTO(token)
+		dto.Key = newKey.ClientSecret
+		return nil
+	})
+
+	return dto, err
+}
+
+// getTokens gets all tokens for a Library Panel.
+func (lps *LibraryPanelService) getTokens(c *models.ReqContext, uid string) ([]LibraryPanelTokenDTO, error) {
+	result := make([]LibraryPanelTokenDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := lps.getPanelForTokens(session, c.SignedInUser, uid)
+		if err != nil {
+			return err
+		}
+
+		var tokens []libraryPanelToken
+		if err := session.SQL("SELECT * FROM library_panel_token WHERE librarypanel_id=? ORDER BY created", panel.ID).Find(&tokens); err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			result = append(result, toLibraryPanelTokenDTO(token))
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// revokeToken deletes a token for a Library Panel.
+func (lps *LibraryPanelService) revokeToken(c *models.ReqContext, uid string, tokenID int64) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := lps.getPanelForTokens(session, c.SignedInUser, uid)
+		if err != nil {
+			return err
+		}
+
+		result, err := session.Exec("DELETE FROM library_panel_token WHERE id=? AND librarypanel_id=?", tokenID, panel.ID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected != 1 {
+			return errLibraryPanelTokenNotFound
+		}
+
+		return nil
+	})
+}
+
+// getEmbedToken gets the library panel token of an embed request from its Authorization header. The token query
+// parameter is only read when embed_token_in_query is enabled, since tokens in URLs leak through logs and referrers.
+func (lps *LibraryPanelService) getEmbedToken(c *models.ReqContext) string {
+	parts := strings.SplitN(c.Req.Header.Get("Authorization"), " ", 2)
+	if len(parts) == 2 && parts[0] == "Bearer" {
+		return parts[1]
+	}
+	if lps.Cfg.PanelLibraryEmbedTokenInQuery {
+		return c.Query("token")
+	}
+
+	return ""
+}
+
+// getLibraryPanelByToken gets the Library Panel a token grants access to, with the default datasource resolved.
+func (lps *LibraryPanelService) getLibraryPanelByToken(c *models.ReqContext, key string) (LibraryPanelEmbedDTO, error) {
+	decoded, err := apikeygen.Decode(key)
+	if err != nil {
+		return LibraryPanelEmbedDTO{}, errLibraryPanelTokenInvalid
+	}
+
+	var dto LibraryPanelEmbedDTO
+	err = lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var tokens []libraryPanelToken
+		if err := session.SQL("SELECT * FROM library_panel_token WHERE org_id=? AND uid=?", decoded.OrgId, decoded.Name).Find(&tokens); err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return errLibraryPanelTokenInvalid
+		}
+		token := tokens[0]
+		if valid, err := apikeygen.IsValid(decoded, token.Key); err != nil {
+			return err
+		} else if !valid {
+			return errLibraryPanelTokenInvalid
+		}
+		if token.Expires != nil && *token.Expires <= time.Now().Unix() {
+			return errLibraryPanelTokenExpired
+		}
+
+		var panels []LibraryPanel
+		if err := session.SQL("SELECT * FROM library_panel WHERE id=? AND deleted_at IS NULL", token.LibraryPanelID).Find(&panels); err != nil {
+			return err
+		}
+		if len(panels) == 0 {
+			return errLibraryPanelTokenInvalid
+		}
+		panel := panels[0]
+
+		model := panel.Model
+		datasourceUID, err := getDefaultDatasourceUID(session, token.OrgID)
+		if err != nil {
+			return err
+		}
+		if len(datasourceUID) > 0 {
+			if model, err = replaceDefaultDatasource(model, datasourceUID); err != nil {
+				return err
+			}
+		}
+
+		dto = LibraryPanelEmbedDTO{
+			UID:   panel.UID,
+			Name:  panel.Name,
+			Type:  panel.Type,
+			Model: model,
+		}
+		return recordRender(session, panel.ID)
+	})
+
+	return dto, err
+}
@@ -0,0 +1,26 @@
+package librarypanels
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	tlog "github.com/opentracing/opentracing-go/log"
+)
+
+// startSpan starts a tracing span for a library panel database operation. The span is a child of the span in ctx,
+// so slow queries show up under the span of the HTTP request they were made for.
+func startSpan(ctx context.Context, operation string, orgID int64) (opentracing.Span, context.Context) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "librarypanels."+operation)
+	span.SetTag("orgId", orgID)
+	return span, ctx
+}
+
+// finishSpan finishes a span started by startSpan, marking it as failed when the operation returned an error.
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(tlog.Error(err))
+	}
+	span.Finish()
+}
@@ -0,0 +1,146 @@
+package librarypanels
+
+import (
+	"errors"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// trashLibraryPanelSQL moves a Library Panel to the trash. Its name is kept in trashed_name and replaced by
+// trashedName, so the name is free to use in its folder again while the Library Panel is in the trash. The arguments
+// are the time it's deleted at and its trashedName.
+const trashLibraryPanelSQL = "UPDATE library_panel SET deleted_at=?, trashed_name=name, name=?"
+
+// trashedName is the name of a Library Panel while it's in the trash, the uid keeps it unique.
+func trashedName(uid string) string {
+	return "trashed:" + uid
+}
+
+// nameBeforeTrash returns the name a Library Panel in the trash had, Library Panels that were moved to the trash
+// before trashed_name was added kept their name.
+func nameBeforeTrash(panel LibraryPanel) string {
+	if panel.TrashedName != "" {
+		return panel.TrashedName
+	}
+	return panel.Name
+}
+
+// getTrashedLibraryPanel gets a Library Panel that has been moved to the trash.
+func getTrashedLibraryPanel(session *sqlstore.DBSession, uid string, orgID int64) (LibraryPanel, error) {
+	var panels []LibraryPanel
+	sql := "SELECT * FROM library_panel WHERE uid=? AND org_id=? AND deleted_at IS NOT NULL"
+	if err := session.SQL(sql, uid, orgID).Find(&panels); err != nil {
+		return LibraryPanel{}, err
+	}
+	if len(panels) == 0 {
+		return LibraryPanel{}, errLibraryPanelNotFound
+	}
+
+	return panels[0], nil
+}
+
+// libraryPanelDependentTables are the tables with rows that belong to a Library Panel. The audit log is kept when a
+// Library Panel is deleted for good.
+var libraryPanelDependentTables = []string{
+	"library_panel_dashboard",
+	"library_panel_label",
+	"library_panel_datasource",
+	"library_panel_tag",
+	"library_panel_token",
+	"library_panel_catalog_entry",
+	"library_panel_acl",
+	"library_panel_finding",
+	"library_panel_smoke_render",
+	"library_panel_group_member",
+	"library_panel_input",
+	"library_panel_view",
+	"library_panel_version",
+	"library_panel_sandbox",
+	"library_panel_canary",
+}
+
+// purgeLibraryPanelRows permanently deletes a Library Panel and the rows that belong to it.
+func purgeLibraryPanelRows(session *sqlstore.DBSession, panelID int64) error {
+	for _, table := range libraryPanelDependentTables {
+		if _, err := session.Exec("DELETE FROM "+table+" WHERE librarypanel_id=?", panelID); err != nil {
+			return err
+		}
+	}
+	_, err := session.Exec("DELETE FROM library_panel WHERE id=?", panelID)
+	return err
+}
+
+// getTrash gets all Library Panels in the trash that the signed in user is allowed to restore or purge.
+func (lps *LibraryPanelService) getTrash(c *models.ReqContext) ([]TrashedLibraryPanelDTO, error) {
+	trash := make([]TrashedLibraryPanelDTO, 0)
+	err := lps.SQLStore.WithDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		var panels []LibraryPanel
+		sql := "SELECT * FROM library_panel WHERE org_id=? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC"
+		if err := session.SQL(sql, c.SignedInUser.OrgId).Find(&panels); err != nil {
+			return err
+		}
+		for _, panel := range panels {
+			if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+				if errors.Is(err, models.ErrFolderAccessDenied) {
+					continue
+				}
+				return err
+			}
+			trash = append(trash, TrashedLibraryPanelDTO{
+				UID:         panel.UID,
+				FolderID:    panel.FolderID,
+				Name:        nameBeforeTrash(panel),
+				Type:        panel.Type,
+				Description: panel.Description,
+				DeletedAt:   *panel.DeletedAt,
+			})
+		}
+		return nil
+	})
+
+	return trash, err
+}
+
+// restoreLibraryPanel moves a Library Panel out of the trash with the name it had, which fails when another Library
+// Panel in its folder took the name or, for Library Panels trashed before uids were unique, the uid in the meantime.
+func (lps *LibraryPanelService) restoreLibraryPanel(c *models.ReqContext, uid string) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getTrashedLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+		if _, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId); err == nil {
+			return errLibraryPanelUIDAlreadyExists
+		} else if !errors.Is(err, errLibraryPanelNotFound) {
+			return err
+		}
+
+		sql := "UPDATE library_panel SET deleted_at=NULL, name=?, trashed_name=NULL WHERE id=?"
+		if _, err := session.Exec(sql, nameBeforeTrash(panel), panel.ID); err != nil {
+			if lps.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return errLibraryPanelAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// purgeLibraryPanel permanently deletes a Library Panel from the trash.
+func (lps *LibraryPanelService) purgeLibraryPanel(c *models.ReqContext, uid string) error {
+	return lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getTrashedLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+
+		return purgeLibraryPanelRows(session, panel.ID)
+	})
+}
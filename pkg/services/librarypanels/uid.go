@@ -0,0 +1,94 @@
+package librarypanels
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	uidStrategyShortID  = "shortid"
+	uidStrategyULID     = "ulid"
+	uidStrategyPrefixed = "prefixed"
+
+	// maxUIDLength is the length of the uid column of library panels.
+	maxUIDLength = 40
+	// maxUIDPrefixLength leaves room for the short uid after the prefix and separator.
+	maxUIDPrefixLength = 20
+)
+
+// crockfordAlphabet is the base32 alphabet ULIDs are encoded with, it leaves out I, L, O and U.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// validateUIDStrategy validates the uid strategy and prefix of the panel library settings. An empty strategy is
+// the shortid strategy.
+func validateUIDStrategy(strategy string, prefix string) error {
+	switch strategy {
+	case "", uidStrategyShortID, uidStrategyULID:
+		return nil
+	case uidStrategyPrefixed:
+		if prefix == "" || len(prefix) > maxUIDPrefixLength || !util.IsValidShortUID(prefix) {
+			return fmt.Errorf("panel_library uid_prefix must be 1 to %d letters, digits, - or _ for the prefixed uid strategy", maxUIDPrefixLength)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown panel_library uid_strategy %q, expected %s, %s or %s", strategy, uidStrategyShortID,
+			uidStrategyULID, uidStrategyPrefixed)
+	}
+}
+
+// generateUID generates the uid of a new Library Panel with the uid strategy of the panel library settings.
+func (lps *LibraryPanelService) generateUID() (string, error) {
+	switch lps.Cfg.PanelLibraryUIDStrategy {
+	case uidStrategyULID:
+		return generateULID(time.Now(), rand.Reader)
+	case uidStrategyPrefixed:
+		return lps.Cfg.PanelLibraryUIDPrefix + "-" + util.GenerateShortUID(), nil
+	default:
+		return util.GenerateShortUID(), nil
+	}
+}
+
+// generateULID generates a ULID, a 48 bit millisecond timestamp followed by 80 random bits encoded as 26 characters
+// of Crockford's base32, so ULIDs sort by the time they were generated.
+func generateULID(now time.Time, entropy io.Reader) (string, error) {
+	var id [16]byte
+	ms := uint64(now.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := io.ReadFull(entropy, id[6:]); err != nil {
+		return "", err
+	}
+
+	// 128 bits are 26 characters of 5 bits with 2 leading zero bits, the first character only holds 3 bits
+	ulid := make([]byte, 26)
+	var buffer uint64
+	bits := uint(2)
+	pos := 0
+	for _, b := range id {
+		buffer = buffer<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			ulid[pos] = crockfordAlphabet[(buffer>>bits)&0x1f]
+			pos++
+		}
+	}
+
+	return string(ulid), nil
+}
+
+// validateUID validates the uid of a Library Panel that isn't generated, e.g. when it's provisioned. Short uids,
+// ULIDs and prefixed uids are all valid, whatever the uid strategy of this instance is.
+func validateUID(uid string) error {
+	if uid == "" || len(uid) > maxUIDLength || !util.IsValidShortUID(uid) {
+		return errLibraryPanelInvalidUID
+	}
+
+	return nil
+}
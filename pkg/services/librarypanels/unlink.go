@@ -0,0 +1,132 @@
+package librarypanels
+
+import (
+	"encoding/json"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// unlinkPanels replaces the panels, including those in collapsed rows, that reference the Library Panel with uid by
+// a copy of model that keeps their id and position. It returns the number of panels that were replaced.
+func unlinkPanels(panels []interface{}, uid string, model map[string]interface{}) int {
+	unlinked := 0
+	for i, element := range panels {
+		panel, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rowPanels, ok := panel["panels"].([]interface{}); ok {
+			unlinked += unlinkPanels(rowPanels, uid, model)
+		}
+		libraryPanel, ok := panel["libraryPanel"].(map[string]interface{})
+		if !ok || libraryPanel["uid"] != uid {
+			continue
+		}
+
+		copied := make(map[string]interface{}, len(model)+2)
+		for key, value := range model {
+			copied[key] = value
+		}
+		delete(copied, "libraryPanel")
+		if id, ok := panel["id"]; ok {
+			copied["id"] = id
+		}
+		if gridPos, ok := panel["gridPos"]; ok {
+			copied["gridPos"] = gridPos
+		}
+		panels[i] = copied
+		unlinked++
+	}
+
+	return unlinked
+}
+
+// unlinkLibraryPanel replaces the Library Panel with uid by a copy of its model in every connected Dashboard and
+// removes the connections. It returns the uids of the Dashboards that were changed.
+func (lps *LibraryPanelService) unlinkLibraryPanel(c *models.ReqContext, uid string) ([]string, error) {
+	var dashboardUIDs []string
+	err := lps.SQLStore.WithTransactionalDbSession(c.Context.Req.Context(), func(session *sqlstore.DBSession) error {
+		panel, err := getLibraryPanel(session, uid, c.SignedInUser.OrgId)
+		if err != nil {
+			return err
+		}
+		if err := lps.requirePermissionsOnFolder(c.SignedInUser, panel.FolderID); err != nil {
+			return err
+		}
+		dashboardUIDs, err = unlinkConnectedDashboards(session, c.SignedInUser, panel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboardUIDs, nil
+}
+
+// unlinkConnectedDashboards replaces a Library Panel by a copy of its model in every connected Dashboard and removes
+// the connections, so the Library Panel can be deleted without breaking any Dashboard. The copy keeps the version a
+// Dashboard accepted and its overrides. Every Dashboard is checked before any of them is changed, the Dashboards are
+// saved with session so they're rolled back together with the caller's transaction. It returns the uids of the
+// Dashboards that were changed.
+func unlinkConnectedDashboards(session *sqlstore.DBSession, user *models.SignedInUser, panel LibraryPanelWithMeta) ([]string, error) {
+	var connections []libraryPanelDashboard
+	if err := session.SQL("SELECT * FROM library_panel_dashboard WHERE librarypanel_id=?", panel.ID).Find(&connections); err != nil {
+		return nil, err
+	}
+	for _, connection := range connections {
+		if err := requireDashboardEdit(user, connection.DashboardID); err != nil {
+			return nil, err
+		}
+	}
+
+	dashboardUIDs := make([]string, 0, len(connections))
+	for _, connection := range connections {
+		model := panel.Model
+		if connection.AcceptedVersion != 0 && len(connection.AcceptedModel) > 0 {
+			model = connection.AcceptedModel
+		}
+		model, err := applyConnectionOverrides(model, connection.Overrides)
+		if err != nil {
+			return nil, err
+		}
+		var modelMap map[string]interface{}
+		if err := json.Unmarshal(model, &modelMap); err != nil {
+			return nil, err
+		}
+
+		var dash models.Dashboard
+		has, err := session.Where("id=? AND org_id=?", connection.DashboardID, user.OrgId).Get(&dash)
+		if err != nil {
+			return nil, err
+		}
+		if !has {
+			// the connection of a deleted Dashboard is removed with the others
+			continue
+		}
+		panels, ok := dash.Data.Get("panels").Interface().([]interface{})
+		if ok && unlinkPanels(panels, panel.UID, modelMap) > 0 {
+			dash.Data.Set("panels", panels)
+			dash.Data.Set("id", dash.Id)
+			dash.Data.Set("uid", dash.Uid)
+			err := sqlstore.SaveDashboardWithSession(session, &models.SaveDashboardCommand{
+				Dashboard: simplejson.NewFromAny(dash.Data.Interface()),
+				OrgId:     dash.OrgId,
+				UserId:    user.UserId,
+				FolderId:  dash.FolderId,
+				Overwrite: true,
+				Message:   "Unlinked library panel " + panel.Name,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		dashboardUIDs = append(dashboardUIDs, dash.Uid)
+	}
+	if _, err := session.Exec("DELETE FROM library_panel_dashboard WHERE librarypanel_id=?", panel.ID); err != nil {
+		return nil, err
+	}
+
+	return dashboardUIDs, nil
+}
@@ -0,0 +1,53 @@
+package librarypanels
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/infra/usagestats"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// usageMetrics are the anonymous usage stats reported for the Panel Library, they only contain counts across all orgs.
+func (lps *LibraryPanelService) usageMetrics() map[string]string {
+	return map[string]string{
+		"stats.library_panels.count":             "SELECT COUNT(*) AS count FROM library_panel WHERE deleted_at IS NULL",
+		"stats.library_panels.trashed.count":     "SELECT COUNT(*) AS count FROM library_panel WHERE deleted_at IS NOT NULL",
+		"stats.library_panels.provisioned.count": "SELECT COUNT(*) AS count FROM library_panel WHERE deleted_at IS NULL AND provisioned = " + lps.SQLStore.Dialect.BooleanStr(true),
+		"stats.library_panels.types.count":       "SELECT COUNT(DISTINCT type) AS count FROM library_panel WHERE deleted_at IS NULL",
+		"stats.library_panels.max_version":       "SELECT COALESCE(MAX(version), 0) AS count FROM library_panel WHERE deleted_at IS NULL",
+		"stats.library_panels.connected.count":   "SELECT COUNT(DISTINCT librarypanel_id) AS count FROM library_panel_dashboard",
+		"stats.library_panels.labeled.count":     "SELECT COUNT(DISTINCT librarypanel_id) AS count FROM library_panel_label",
+		"stats.library_panels.tagged.count":      "SELECT COUNT(DISTINCT librarypanel_id) AS count FROM library_panel_tag",
+		"stats.library_panels.published.count":   "SELECT COUNT(*) AS count FROM library_panel_catalog_entry",
+		"stats.library_panels.tokens.count":      "SELECT COUNT(*) AS count FROM library_panel_token",
+		"stats.library_panels.sandboxes.count":   "SELECT COUNT(*) AS count FROM library_panel_sandbox",
+	}
+}
+
+// registerUsageMetrics registers the Panel Library usage stats, they're only sent when reporting is enabled.
+func (lps *LibraryPanelService) registerUsageMetrics(usageStats usagestats.UsageStats) {
+	for name, sql := range lps.usageMetrics() {
+		sql := sql
+		usageStats.RegisterMetric(name, func() (interface{}, error) {
+			return lps.getUsageCount(context.Background(), sql)
+		})
+	}
+}
+
+func (lps *LibraryPanelService) getUsageCount(ctx context.Context, sql string) (int64, error) {
+	var count int64
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		var counts []struct {
+			Count int64 `xorm:"count"`
+		}
+		if err := session.SQL(sql).Find(&counts); err != nil {
+			return err
+		}
+		if len(counts) > 0 {
+			count = counts[0].Count
+		}
+		return nil
+	})
+
+	return count, err
+}
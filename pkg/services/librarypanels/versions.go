@@ -0,0 +1,72 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// libraryPanelVersion is the model for the name and model a library panel had at a version, so exports can be
+// pinned to a version after the library panel changed.
+type libraryPanelVersion struct {
+	ID             int64           `xorm:"pk autoincr 'id'"`
+	LibraryPanelID int64           `xorm:"librarypanel_id"`
+	Version        int64           `xorm:"'version'"`
+	Name           string          `xorm:"name"`
+	Model          json.RawMessage `xorm:"model"`
+
+	Created   time.Time
+	CreatedBy int64
+}
+
+// recordLibraryPanelVersion adds the current version of a Library Panel to its history. It's called after every
+// change that increases the version, a version that's already in the history is kept as it is.
+func recordLibraryPanelVersion(session *sqlstore.DBSession, panelID int64, userID int64) error {
+	var panels []LibraryPanel
+	if err := session.SQL("SELECT * FROM library_panel WHERE id=?", panelID).Find(&panels); err != nil {
+		return err
+	}
+	if len(panels) == 0 {
+		return errLibraryPanelNotFound
+	}
+	panel := panels[0]
+
+	var versions []libraryPanelVersion
+	sql := "SELECT * FROM library_panel_version WHERE librarypanel_id=? AND version=?"
+	if err := session.SQL(sql, panel.ID, panel.Version).Find(&versions); err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		return nil
+	}
+
+	_, err := session.Insert(&libraryPanelVersion{
+		LibraryPanelID: panel.ID,
+		Version:        panel.Version,
+		Name:           panel.Name,
+		Model:          panel.Model,
+		Created:        time.Now(),
+		CreatedBy:      userID,
+	})
+	return err
+}
+
+// getLibraryPanelVersion gets the name and model a Library Panel had at a version. The current version is always
+// available, older versions only if they were changed after the history was introduced.
+func getLibraryPanelVersion(session *sqlstore.DBSession, panel LibraryPanelDTO, version int64) (string, json.RawMessage, error) {
+	if version == panel.Version {
+		return panel.Name, panel.Model, nil
+	}
+
+	var versions []libraryPanelVersion
+	sql := "SELECT * FROM library_panel_version WHERE librarypanel_id=? AND version=?"
+	if err := session.SQL(sql, panel.ID, version).Find(&versions); err != nil {
+		return "", nil, err
+	}
+	if len(versions) == 0 {
+		return "", nil, errLibraryPanelVersionNotFound
+	}
+
+	return versions[0].Name, versions[0].Model, nil
+}
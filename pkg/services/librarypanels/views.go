@@ -0,0 +1,213 @@
+package librarypanels
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	// viewsWindowDays is the number of days views are counted and kept for.
+	viewsWindowDays = 30
+	viewsDayLayout  = "2006-01-02"
+)
+
+// libraryPanelViewKey is the library panel and UTC day views are counted for.
+type libraryPanelViewKey struct {
+	panelID int64
+	day     string
+}
+
+// libraryPanelLastUsed is when a library panel was last connected to a dashboard and last viewed on one.
+type libraryPanelLastUsed struct {
+	connected time.Time
+	viewed    time.Time
+}
+
+// recordViews records a view of each Library Panel of a dashboard. Views are counted in memory and written in
+// batches by flushViews, and the last viewed time by flushLastUsed, so viewing a dashboard doesn't write to the
+// database.
+func (lps *LibraryPanelService) recordViews(panelIDs ...int64) {
+	if len(panelIDs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	day := now.UTC().Format(viewsDayLayout)
+	lps.viewsMu.Lock()
+	defer lps.viewsMu.Unlock()
+	if lps.pendingViews == nil {
+		lps.pendingViews = make(map[libraryPanelViewKey]int64)
+	}
+	if lps.pendingLastUsed == nil {
+		lps.pendingLastUsed = make(map[int64]libraryPanelLastUsed)
+	}
+	for _, panelID := range panelIDs {
+		lps.pendingViews[libraryPanelViewKey{panelID: panelID, day: day}]++
+		lastUsed := lps.pendingLastUsed[panelID]
+		lastUsed.viewed = now
+		lps.pendingLastUsed[panelID] = lastUsed
+	}
+}
+
+// recordConnected records that Library Panels were connected to a dashboard. Like views, the time is kept in
+// memory and written by flushLastUsed.
+func (lps *LibraryPanelService) recordConnected(panelIDs ...int64) {
+	if len(panelIDs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	lps.viewsMu.Lock()
+	defer lps.viewsMu.Unlock()
+	if lps.pendingLastUsed == nil {
+		lps.pendingLastUsed = make(map[int64]libraryPanelLastUsed)
+	}
+	for _, panelID := range panelIDs {
+		lastUsed := lps.pendingLastUsed[panelID]
+		lastUsed.connected = now
+		lps.pendingLastUsed[panelID] = lastUsed
+	}
+}
+
+// flushLastUsed writes the last connected and last viewed times recorded since the last flush. A time is only
+// written when it's later than the stored one, as other instances flush their own times. Times that couldn't be
+// written are kept for the next flush.
+func (lps *LibraryPanelService) flushLastUsed(ctx context.Context) error {
+	lps.viewsMu.Lock()
+	pending := lps.pendingLastUsed
+	lps.pendingLastUsed = nil
+	lps.viewsMu.Unlock()
+
+	for panelID, lastUsed := range pending {
+		err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+			return setLastUsed(session, panelID, lastUsed)
+		})
+		if err != nil {
+			lps.viewsMu.Lock()
+			if lps.pendingLastUsed == nil {
+				lps.pendingLastUsed = make(map[int64]libraryPanelLastUsed)
+			}
+			for panelID, lastUsed := range pending {
+				current := lps.pendingLastUsed[panelID]
+				if lastUsed.connected.After(current.connected) {
+					current.connected = lastUsed.connected
+				}
+				if lastUsed.viewed.After(current.viewed) {
+					current.viewed = lastUsed.viewed
+				}
+				lps.pendingLastUsed[panelID] = current
+			}
+			lps.viewsMu.Unlock()
+			return err
+		}
+		delete(pending, panelID)
+	}
+
+	return nil
+}
+
+func setLastUsed(session *sqlstore.DBSession, panelID int64, lastUsed libraryPanelLastUsed) error {
+	if !lastUsed.connected.IsZero() {
+		sql := "UPDATE library_panel SET last_connected_at=? WHERE id=? AND (last_connected_at IS NULL OR last_connected_at < ?)"
+		if _, err := session.Exec(sql, lastUsed.connected, panelID, lastUsed.connected); err != nil {
+			return err
+		}
+	}
+	if !lastUsed.viewed.IsZero() {
+		sql := "UPDATE library_panel SET last_viewed_at=? WHERE id=? AND (last_viewed_at IS NULL OR last_viewed_at < ?)"
+		if _, err := session.Exec(sql, lastUsed.viewed, panelID, lastUsed.viewed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flushViews writes the views recorded since the last flush and deletes the counts of days outside the window.
+// Views that couldn't be written are kept for the next flush.
+func (lps *LibraryPanelService) flushViews(ctx context.Context) (int64, error) {
+	lps.viewsMu.Lock()
+	pending := lps.pendingViews
+	lps.pendingViews = nil
+	lps.viewsMu.Unlock()
+
+	var flushed int64
+	for key, views := range pending {
+		err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+			return addViews(session, key, views)
+		})
+		if err != nil {
+			lps.viewsMu.Lock()
+			if lps.pendingViews == nil {
+				lps.pendingViews = make(map[libraryPanelViewKey]int64)
+			}
+			for key, views := range pending {
+				lps.pendingViews[key] += views
+			}
+			lps.viewsMu.Unlock()
+			return flushed, err
+		}
+		delete(pending, key)
+		flushed += views
+	}
+
+	err := lps.SQLStore.WithDbSession(ctx, func(session *sqlstore.DBSession) error {
+		oldest := time.Now().UTC().AddDate(0, 0, -viewsWindowDays).Format(viewsDayLayout)
+		_, err := session.Exec("DELETE FROM library_panel_view WHERE day < ?", oldest)
+		return err
+	})
+
+	return flushed, err
+}
+
+// addViews adds views to the count of a Library Panel for a day. Another instance can insert the count between the
+// update and insert, so the update is retried when the insert fails.
+func addViews(session *sqlstore.DBSession, key libraryPanelViewKey, views int64) error {
+	sql := "UPDATE library_panel_view SET views=views+? WHERE librarypanel_id=? AND day=?"
+	result, err := session.Exec(sql, views, key.panelID, key.day)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := session.Exec("INSERT INTO library_panel_view (librarypanel_id, day, views) VALUES (?, ?, ?)", key.panelID, key.day, views); err == nil {
+		return nil
+	}
+	_, err = session.Exec(sql, views, key.panelID, key.day)
+	return err
+}
+
+// getViewsLast30Days gets the number of views of Library Panels in the last 30 days, including today.
+func getViewsLast30Days(session *sqlstore.DBSession, panelIDs ...int64) (map[int64]int64, error) {
+	views := make(map[int64]int64, len(panelIDs))
+	if len(panelIDs) == 0 {
+		return views, nil
+	}
+
+	var counts []struct {
+		LibraryPanelID int64 `xorm:"librarypanel_id"`
+		Views          int64 `xorm:"views"`
+	}
+	oldest := time.Now().UTC().AddDate(0, 0, -(viewsWindowDays - 1)).Format(viewsDayLayout)
+	sql := "SELECT librarypanel_id, SUM(views) AS views FROM library_panel_view WHERE day >= ? AND librarypanel_id IN (?" +
+		strings.Repeat(",?", len(panelIDs)-1) + ") GROUP BY librarypanel_id"
+	params := []interface{}{oldest}
+	for _, panelID := range panelIDs {
+		params = append(params, panelID)
+	}
+	if err := session.SQL(sql, params...).Find(&counts); err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		views[count.LibraryPanelID] = count.Views
+	}
+
+	return views, nil
+}
@@ -9,7 +9,9 @@ import (
 )
 
 func writePerPageSQL(query searchLibraryPanelsQuery, sqlStore *sqlstore.SQLStore, builder *sqlstore.SQLBuilder) {
-	if query.perPage != 0 {
+	if query.perPage != 0 && query.continueToken != "" {
+		builder.Write(sqlStore.Dialect.Limit(int64(query.perPage)))
+	} else if query.perPage != 0 {
 		offset := query.perPage * (query.page - 1)
 		builder.Write(sqlStore.Dialect.LimitOffset(int64(query.perPage), int64(offset)))
 	}
@@ -30,8 +32,24 @@ func writePanelFilterSQL(panelFilter []string, builder *sqlstore.SQLBuilder) {
 func writeSearchStringSQL(query searchLibraryPanelsQuery, sqlStore *sqlstore.SQLStore, builder *sqlstore.SQLBuilder) {
 	if len(strings.TrimSpace(query.searchString)) > 0 {
 		builder.Write(" AND (lp.name "+sqlStore.Dialect.LikeStr()+" ?", "%"+query.searchString+"%")
-		builder.Write(" OR lp.description "+sqlStore.Dialect.LikeStr()+" ?)", "%"+query.searchString+"%")
+		builder.Write(" OR lp.description "+sqlStore.Dialect.LikeStr()+" ?", "%"+query.searchString+"%")
+		if query.searchIn == searchInModel {
+			builder.Write(" OR lp.model "+sqlStore.Dialect.LikeStr()+" ?", "%"+query.searchString+"%")
+		}
+		builder.Write(")")
+	}
+}
+
+func writeDatasourceFilterSQL(query searchLibraryPanelsQuery, builder *sqlstore.SQLBuilder) {
+	if len(query.datasourceRefs) == 0 {
+		return
+	}
+
+	params := make([]interface{}, 0, len(query.datasourceRefs))
+	for _, ref := range query.datasourceRefs {
+		params = append(params, ref)
 	}
+	builder.Write(" AND EXISTS (SELECT 1 FROM library_panel_datasource AS lpds WHERE lpds.librarypanel_id = lp.id AND lpds.ref IN (?"+strings.Repeat(",?", len(params)-1)+"))", params...)
 }
 
 func writeExcludeSQL(query searchLibraryPanelsQuery, builder *sqlstore.SQLBuilder) {
@@ -40,6 +58,22 @@ func writeExcludeSQL(query searchLibraryPanelsQuery, builder *sqlstore.SQLBuilde
 	}
 }
 
+func writeCreatedBySQL(query searchLibraryPanelsQuery, builder *sqlstore.SQLBuilder) {
+	if query.createdBy > 0 {
+		builder.Write(" AND lp.created_by=?", query.createdBy)
+	}
+}
+
+// writeConnectedSQL limits the search to library panels with or without connected dashboards.
+func writeConnectedSQL(query searchLibraryPanelsQuery, builder *sqlstore.SQLBuilder) {
+	switch query.connected {
+	case "true":
+		builder.Write(" AND EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id)")
+	case "false":
+		builder.Write(" AND NOT EXISTS (SELECT 1 FROM library_panel_dashboard AS lpd WHERE lpd.librarypanel_id = lp.id)")
+	}
+}
+
 type FolderFilter struct {
 	includeGeneralFolder bool
 	folderIDs            []string
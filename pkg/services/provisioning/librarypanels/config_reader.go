@@ -0,0 +1,164 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"gopkg.in/yaml.v2"
+)
+
+type configReader struct {
+	log log.Logger
+}
+
+func (cr *configReader) readConfig(path string) ([]*configs, error) {
+	var libraryPanels []*configs
+
+	files, err := ioutil.ReadDir(path)
+	if os.IsNotExist(err) {
+		cr.log.Debug("no library panel provisioning directory, nothing to provision", "path", path)
+		return libraryPanels, nil
+	}
+	if err != nil {
+		cr.log.Error("can't read library panel provisioning files from directory", "path", path, "error", err)
+		return libraryPanels, nil
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".yaml") || strings.HasSuffix(file.Name(), ".yml") ||
+			strings.HasSuffix(file.Name(), ".json") {
+			cfg, err := cr.parseLibraryPanelConfig(path, file)
+			if err != nil {
+				return nil, err
+			}
+
+			if cfg != nil {
+				libraryPanels = append(libraryPanels, cfg)
+			}
+		}
+	}
+
+	if err := validateLibraryPanels(libraryPanels); err != nil {
+		return nil, err
+	}
+	if err := validateFreezeWindows(libraryPanels); err != nil {
+		return nil, err
+	}
+
+	return libraryPanels, nil
+}
+
+func (cr *configReader) parseLibraryPanelConfig(path string, file os.FileInfo) (*configs, error) {
+	filename, _ := filepath.Abs(filepath.Join(path, file.Name()))
+
+	// nolint:gosec
+	// We can ignore the gosec G304 warning on this one because `filename` comes from ps.Cfg.ProvisioningPath
+	yamlFile, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiVersion *configVersion
+	err = yaml.Unmarshal(yamlFile, &apiVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiVersion == nil || apiVersion.APIVersion < 1 {
+		return nil, fmt.Errorf("library panel provisioning file %q is missing a supported apiVersion", filename)
+	}
+
+	v1 := &configsV1{}
+	err = yaml.Unmarshal(yamlFile, v1)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := v1.mapToLibraryPanelsFromConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, panel := range cfg.LibraryPanels {
+		if len(panel.File) == 0 {
+			continue
+		}
+		if err := readModelFromFile(filepath.Dir(filename), panel); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+func readModelFromFile(path string, panel *libraryPanelFromConfig) error {
+	modelPath := panel.File
+	if !filepath.IsAbs(modelPath) {
+		modelPath = filepath.Join(path, modelPath)
+	}
+
+	// nolint:gosec
+	// We can ignore the gosec G304 warning on this one because `modelPath` comes from the provisioning config
+	model, err := ioutil.ReadFile(modelPath)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(model) {
+		return fmt.Errorf("library panel %q: file %q does not contain valid JSON", panel.UID, panel.File)
+	}
+
+	panel.Model = model
+	return nil
+}
+
+func validateLibraryPanels(cfgs []*configs) error {
+	uids := map[int64]map[string]bool{}
+	for _, cfg := range cfgs {
+		for index, panel := range cfg.LibraryPanels {
+			if panel.OrgID < 1 {
+				panel.OrgID = 1
+			}
+			if panel.UID == "" {
+				return fmt.Errorf("library panel item %d in configuration doesn't contain required field uid", index+1)
+			}
+			if panel.Name == "" {
+				return fmt.Errorf("library panel %q doesn't contain required field name", panel.UID)
+			}
+			if len(panel.Model) == 0 {
+				return fmt.Errorf("library panel %q doesn't contain a model or a file", panel.UID)
+			}
+			if uids[panel.OrgID] == nil {
+				uids[panel.OrgID] = map[string]bool{}
+			}
+			if uids[panel.OrgID][panel.UID] {
+				return fmt.Errorf("library panel %q is provisioned more than once in organization %d", panel.UID, panel.OrgID)
+			}
+			uids[panel.OrgID][panel.UID] = true
+		}
+	}
+
+	return nil
+}
+
+func validateFreezeWindows(cfgs []*configs) error {
+	for _, cfg := range cfgs {
+		for index, window := range cfg.FreezeWindows {
+			if window.OrgID < 1 {
+				window.OrgID = 1
+			}
+			if window.Name == "" {
+				return fmt.Errorf("freeze window item %d in configuration doesn't contain required field name", index+1)
+			}
+			if !window.Ends.After(window.Starts) {
+				return fmt.Errorf("freeze window %q must end after it starts", window.Name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	twoPanels    = "testdata/two-panels"
+	brokenYaml   = "testdata/broken-yaml"
+	missingName  = "testdata/missing-name"
+	duplicateUID = "testdata/duplicate-uid"
+	emptyFolder  = "testdata/does-not-exist"
+
+	freezeWindows       = "testdata/freeze-windows"
+	invalidFreezeWindow = "testdata/invalid-freeze-window"
+)
+
+func TestLibraryPanelsConfigReader(t *testing.T) {
+	t.Run("Can read library panels with inline and file models", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		cfgs, err := reader.readConfig(twoPanels)
+		require.NoError(t, err)
+		require.Len(t, cfgs, 1)
+
+		cfg := cfgs[0]
+		require.Equal(t, int64(5), cfg.UpdateIntervalSeconds)
+		require.Len(t, cfg.LibraryPanels, 2)
+
+		cpu := cfg.LibraryPanels[0]
+		require.Equal(t, "cpu-usage", cpu.UID)
+		require.Equal(t, int64(2), cpu.OrgID)
+		require.Equal(t, "infrastructure", cpu.FolderUID)
+		require.Equal(t, "CPU usage", cpu.Name)
+		var model map[string]interface{}
+		require.NoError(t, json.Unmarshal(cpu.Model, &model))
+		require.Equal(t, "graph", model["type"])
+
+		memory := cfg.LibraryPanels[1]
+		require.Equal(t, "memory-usage", memory.UID)
+		require.Equal(t, int64(1), memory.OrgID)
+		require.Equal(t, "", memory.FolderUID)
+		require.NoError(t, json.Unmarshal(memory.Model, &model))
+		require.Equal(t, "stat", model["type"])
+	})
+
+	t.Run("Broken yaml should return error", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		_, err := reader.readConfig(brokenYaml)
+		require.Error(t, err)
+	})
+
+	t.Run("Missing name should return error", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		_, err := reader.readConfig(missingName)
+		require.EqualError(t, err, `library panel "cpu-usage" doesn't contain required field name`)
+	})
+
+	t.Run("Duplicate uid should return error", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		_, err := reader.readConfig(duplicateUID)
+		require.EqualError(t, err, `library panel "cpu-usage" is provisioned more than once in organization 1`)
+	})
+
+	t.Run("Can read freeze windows", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		cfgs, err := reader.readConfig(freezeWindows)
+		require.NoError(t, err)
+		require.Len(t, cfgs, 1)
+		require.Len(t, cfgs[0].FreezeWindows, 1)
+
+		window := cfgs[0].FreezeWindows[0]
+		require.Equal(t, int64(2), window.OrgID)
+		require.Equal(t, "End of quarter", window.Name)
+		require.Equal(t, "Quarterly reporting", window.Reason)
+		require.Equal(t, time.Date(2021, 3, 25, 0, 0, 0, 0, time.UTC), window.Starts.UTC())
+		require.Equal(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), window.Ends.UTC())
+	})
+
+	t.Run("Freeze window that ends before it starts should return error", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		_, err := reader.readConfig(invalidFreezeWindow)
+		require.EqualError(t, err, `freeze window "Backwards" must end after it starts`)
+	})
+
+	t.Run("Skip invalid directory", func(t *testing.T) {
+		reader := &configReader{log: log.New("test logger")}
+		cfgs, err := reader.readConfig(emptyFolder)
+		require.NoError(t, err)
+		require.Len(t, cfgs, 0)
+	})
+}
@@ -0,0 +1,154 @@
+package librarypanels
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/librarypanels"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const defaultUpdateIntervalSeconds = 10
+
+// Store is the interface used by the provisioner to persist library panels.
+type Store interface {
+	ProvisionLibraryPanel(ctx context.Context, cmd librarypanels.ProvisionLibraryPanelCommand) error
+	ProvisionFreezeWindows(ctx context.Context, cmds []librarypanels.ProvisionFreezeWindowCommand) error
+}
+
+// LibraryPanelProvisioner is responsible for provisioning library panels based on
+// configuration read by the `configReader`.
+type LibraryPanelProvisioner struct {
+	log            log.Logger
+	cfgProvider    *configReader
+	store          Store
+	path           string
+	updateInterval time.Duration
+}
+
+// New returns a new LibraryPanelProvisioner reading config files from configDirectory.
+func New(configDirectory string, store Store) *LibraryPanelProvisioner {
+	logger := log.New("provisioning.librarypanels")
+	return &LibraryPanelProvisioner{
+		log:            logger,
+		cfgProvider:    &configReader{log: logger},
+		store:          store,
+		path:           configDirectory,
+		updateInterval: defaultUpdateIntervalSeconds * time.Second,
+	}
+}
+
+// Provision reads the config files and creates or updates the library panels in them. The freeze windows in
+// them replace all previously provisioned freeze windows.
+func (lp *LibraryPanelProvisioner) Provision(ctx context.Context) error {
+	cfgs, err := lp.cfgProvider.readConfig(lp.path)
+	if err != nil {
+		return err
+	}
+
+	updateInterval := int64(defaultUpdateIntervalSeconds)
+	freezeWindows := make([]librarypanels.ProvisionFreezeWindowCommand, 0)
+	for _, cfg := range cfgs {
+		if cfg.UpdateIntervalSeconds > 0 && cfg.UpdateIntervalSeconds < updateInterval {
+			updateInterval = cfg.UpdateIntervalSeconds
+		}
+		if err := lp.apply(ctx, cfg); err != nil {
+			return err
+		}
+		for _, window := range cfg.FreezeWindows {
+			freezeWindows = append(freezeWindows, librarypanels.ProvisionFreezeWindowCommand{
+				OrgID:  window.OrgID,
+				Name:   window.Name,
+				Reason: window.Reason,
+				Starts: window.Starts,
+				Ends:   window.Ends,
+			})
+		}
+	}
+	if err := lp.store.ProvisionFreezeWindows(ctx, freezeWindows); err != nil {
+		return errutil.Wrap("failed to provision freeze windows", err)
+	}
+	lp.updateInterval = time.Duration(updateInterval) * time.Second
+
+	return nil
+}
+
+// Validate reads the config files in configDirectory and validates the library panels in them the same way
+// Provision does, without touching the database.
+func Validate(configDirectory string) error {
+	if _, err := os.Stat(configDirectory); err != nil {
+		return err
+	}
+
+	cfgProvider := &configReader{log: log.New("provisioning.librarypanels")}
+	cfgs, err := cfgProvider.readConfig(configDirectory)
+	if err != nil {
+		return err
+	}
+
+	var invalid []string
+	for _, cfg := range cfgs {
+		for _, panel := range cfg.LibraryPanels {
+			cmd := librarypanels.ProvisionLibraryPanelCommand{
+				OrgID:     panel.OrgID,
+				FolderUID: panel.FolderUID,
+				UID:       panel.UID,
+				Name:      panel.Name,
+				Model:     panel.Model,
+			}
+			if err := librarypanels.ValidateProvisionLibraryPanelCommand(cmd); err != nil {
+				invalid = append(invalid, fmt.Sprintf("library panel %q: %s", panel.UID, err))
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid library panels:\n%s", strings.Join(invalid, "\n"))
+	}
+
+	return nil
+}
+
+// PollChanges periodically re-reads the config files and applies any changes until ctx is cancelled. It returns
+// right away when the config directory doesn't exist.
+func (lp *LibraryPanelProvisioner) PollChanges(ctx context.Context) {
+	if _, err := os.Stat(lp.path); os.IsNotExist(err) {
+		lp.log.Debug("no library panel provisioning directory, not polling for changes", "path", lp.path)
+		return
+	}
+
+	ticker := time.NewTicker(lp.updateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := lp.Provision(ctx); err != nil {
+				lp.log.Error("failed to provision library panels", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (lp *LibraryPanelProvisioner) apply(ctx context.Context, cfg *configs) error {
+	for _, panel := range cfg.LibraryPanels {
+		lp.log.Debug("provisioning library panel from configuration", "uid", panel.UID, "orgId", panel.OrgID)
+		cmd := librarypanels.ProvisionLibraryPanelCommand{
+			OrgID:     panel.OrgID,
+			FolderUID: panel.FolderUID,
+			UID:       panel.UID,
+			Name:      panel.Name,
+			Model:     panel.Model,
+		}
+		if err := lp.store.ProvisionLibraryPanel(ctx, cmd); err != nil {
+			return errutil.Wrapf(err, "failed to provision library panel %q", panel.UID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package librarypanels
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const invalidModel = "testdata/invalid-model"
+
+func TestValidate(t *testing.T) {
+	t.Run("Valid library panels should not return error", func(t *testing.T) {
+		err := Validate(twoPanels)
+		require.NoError(t, err)
+	})
+
+	t.Run("Invalid config should return error", func(t *testing.T) {
+		err := Validate(missingName)
+		require.EqualError(t, err, `library panel "cpu-usage" doesn't contain required field name`)
+	})
+
+	t.Run("Invalid model should return error", func(t *testing.T) {
+		err := Validate(invalidModel)
+		require.EqualError(t, err, "invalid library panels:\nlibrary panel \"cpu-usage\": library panel model type and description must be strings")
+	})
+
+	t.Run("Missing directory should return error", func(t *testing.T) {
+		err := Validate(emptyFolder)
+		require.Error(t, err)
+	})
+}
+
+func TestPollChanges(t *testing.T) {
+	t.Run("Missing directory should not poll", func(t *testing.T) {
+		provisioner := New(emptyFolder, nil)
+		done := make(chan struct{})
+		go func() {
+			provisioner.PollChanges(context.Background())
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected PollChanges to return for a missing directory")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package librarypanels
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/provisioning/values"
+)
+
+// configVersion is used to figure out which API version a config uses.
+type configVersion struct {
+	APIVersion int64 `json:"apiVersion" yaml:"apiVersion"`
+}
+
+// configs is a normalized data object for library panels config data. Any config version should be mappable
+// to this type.
+type configs struct {
+	UpdateIntervalSeconds int64
+	LibraryPanels         []*libraryPanelFromConfig
+	FreezeWindows         []*freezeWindowFromConfig
+}
+
+type libraryPanelFromConfig struct {
+	OrgID     int64
+	FolderUID string
+	UID       string
+	Name      string
+	File      string
+	Model     json.RawMessage
+}
+
+type freezeWindowFromConfig struct {
+	OrgID  int64
+	Name   string
+	Reason string
+	Starts time.Time
+	Ends   time.Time
+}
+
+type configsV1 struct {
+	UpdateIntervalSeconds values.Int64Value           `json:"updateIntervalSeconds" yaml:"updateIntervalSeconds"`
+	LibraryPanels         []*libraryPanelFromConfigV1 `json:"libraryPanels" yaml:"libraryPanels"`
+	FreezeWindows         []*freezeWindowFromConfigV1 `json:"freezeWindows" yaml:"freezeWindows"`
+}
+
+type libraryPanelFromConfigV1 struct {
+	OrgID     values.Int64Value  `json:"orgId" yaml:"orgId"`
+	FolderUID values.StringValue `json:"folderUid" yaml:"folderUid"`
+	UID       values.StringValue `json:"uid" yaml:"uid"`
+	Name      values.StringValue `json:"name" yaml:"name"`
+	File      values.StringValue `json:"file" yaml:"file"`
+	Model     values.JSONValue   `json:"model" yaml:"model"`
+}
+
+type freezeWindowFromConfigV1 struct {
+	OrgID  values.Int64Value  `json:"orgId" yaml:"orgId"`
+	Name   values.StringValue `json:"name" yaml:"name"`
+	Reason values.StringValue `json:"reason" yaml:"reason"`
+	Starts values.StringValue `json:"starts" yaml:"starts"`
+	Ends   values.StringValue `json:"ends" yaml:"ends"`
+}
+
+// mapToLibraryPanelsFromConfig maps config syntax to a normalized configs object. Every version
+// of the config syntax should have this function.
+func (cfg *configsV1) mapToLibraryPanelsFromConfig() (*configs, error) {
+	r := &configs{}
+	if cfg == nil {
+		return r, nil
+	}
+
+	r.UpdateIntervalSeconds = cfg.UpdateIntervalSeconds.Value()
+	for _, panel := range cfg.LibraryPanels {
+		var model json.RawMessage
+		if panel.Model.Value() != nil {
+			m, err := json.Marshal(panel.Model.Value())
+			if err != nil {
+				return nil, err
+			}
+			model = m
+		}
+
+		r.LibraryPanels = append(r.LibraryPanels, &libraryPanelFromConfig{
+			OrgID:     panel.OrgID.Value(),
+			FolderUID: panel.FolderUID.Value(),
+			UID:       panel.UID.Value(),
+			Name:      panel.Name.Value(),
+			File:      panel.File.Value(),
+			Model:     model,
+		})
+	}
+
+	for _, window := range cfg.FreezeWindows {
+		starts, err := time.Parse(time.RFC3339, window.Starts.Value())
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %q: starts must be an RFC 3339 timestamp", window.Name.Value())
+		}
+		ends, err := time.Parse(time.RFC3339, window.Ends.Value())
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %q: ends must be an RFC 3339 timestamp", window.Name.Value())
+		}
+
+		r.FreezeWindows = append(r.FreezeWindows, &freezeWindowFromConfig{
+			OrgID:  window.OrgID.Value(),
+			Name:   window.Name.Value(),
+			Reason: window.Reason.Value(),
+			Starts: starts,
+			Ends:   ends,
+		})
+	}
+
+	return r, nil
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	plugifaces "github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/registry"
+	lpservice "github.com/grafana/grafana/pkg/services/librarypanels"
 	"github.com/grafana/grafana/pkg/services/provisioning/dashboards"
 	"github.com/grafana/grafana/pkg/services/provisioning/datasources"
+	"github.com/grafana/grafana/pkg/services/provisioning/librarypanels"
 	"github.com/grafana/grafana/pkg/services/provisioning/notifiers"
 	"github.com/grafana/grafana/pkg/services/provisioning/plugins"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
@@ -24,6 +26,7 @@ type ProvisioningService interface {
 	ProvisionPlugins() error
 	ProvisionNotifications() error
 	ProvisionDashboards() error
+	ProvisionLibraryPanels() error
 	GetDashboardProvisionerResolvedPath(name string) string
 	GetAllowUIUpdatesFromConfig(name string) bool
 }
@@ -64,13 +67,15 @@ func newProvisioningServiceImpl(
 }
 
 type provisioningServiceImpl struct {
-	Cfg                     *setting.Cfg       `inject:""`
-	SQLStore                *sqlstore.SQLStore `inject:""`
-	PluginManager           plugifaces.Manager `inject:""`
+	Cfg                     *setting.Cfg                   `inject:""`
+	SQLStore                *sqlstore.SQLStore             `inject:""`
+	PluginManager           plugifaces.Manager             `inject:""`
+	LibraryPanelService     *lpservice.LibraryPanelService `inject:""`
 	log                     log.Logger
 	pollingCtxCancel        context.CancelFunc
 	newDashboardProvisioner dashboards.DashboardProvisionerFactory
 	dashboardProvisioner    dashboards.DashboardProvisioner
+	libraryPanelProvisioner *librarypanels.LibraryPanelProvisioner
 	provisionNotifiers      func(string) error
 	provisionDatasources    func(string) error
 	provisionPlugins        func(string, plugifaces.Manager) error
@@ -107,6 +112,15 @@ func (ps *provisioningServiceImpl) Run(ctx context.Context) error {
 		return err
 	}
 
+	err = ps.ProvisionLibraryPanels()
+	if err != nil {
+		ps.log.Error("Failed to provision library panels", "error", err)
+		return err
+	}
+	if ps.libraryPanelProvisioner != nil {
+		go ps.libraryPanelProvisioner.PollChanges(ctx)
+	}
+
 	for {
 		// Wait for unlock. This is tied to new dashboardProvisioner to be instantiated before we start polling.
 		ps.mutex.Lock()
@@ -170,6 +184,21 @@ func (ps *provisioningServiceImpl) ProvisionDashboards() error {
 	return nil
 }
 
+func (ps *provisioningServiceImpl) ProvisionLibraryPanels() error {
+	if ps.LibraryPanelService == nil || !ps.LibraryPanelService.IsEnabled() {
+		return nil
+	}
+
+	libraryPanelPath := filepath.Join(ps.Cfg.ProvisioningPath, "library-panels")
+	libraryPanelProvisioner := librarypanels.New(libraryPanelPath, ps.LibraryPanelService)
+	err := libraryPanelProvisioner.Provision(context.Background())
+	if err != nil {
+		return errutil.Wrap("Library panel provisioning error", err)
+	}
+	ps.libraryPanelProvisioner = libraryPanelProvisioner
+	return nil
+}
+
 func (ps *provisioningServiceImpl) GetDashboardProvisionerResolvedPath(name string) string {
 	return ps.dashboardProvisioner.GetProvisionerResolvedPath(name)
 }
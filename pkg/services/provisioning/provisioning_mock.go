@@ -8,6 +8,7 @@ type Calls struct {
 	ProvisionPlugins                    []interface{}
 	ProvisionNotifications              []interface{}
 	ProvisionDashboards                 []interface{}
+	ProvisionLibraryPanels              []interface{}
 	GetDashboardProvisionerResolvedPath []interface{}
 	GetAllowUIUpdatesFromConfig         []interface{}
 	Run                                 []interface{}
@@ -20,6 +21,7 @@ type ProvisioningServiceMock struct {
 	ProvisionPluginsFunc                    func() error
 	ProvisionNotificationsFunc              func() error
 	ProvisionDashboardsFunc                 func() error
+	ProvisionLibraryPanelsFunc              func() error
 	GetDashboardProvisionerResolvedPathFunc func(name string) string
 	GetAllowUIUpdatesFromConfigFunc         func(name string) bool
 	RunFunc                                 func(ctx context.Context) error
@@ -71,6 +73,14 @@ func (mock *ProvisioningServiceMock) ProvisionDashboards() error {
 	return nil
 }
 
+func (mock *ProvisioningServiceMock) ProvisionLibraryPanels() error {
+	mock.Calls.ProvisionLibraryPanels = append(mock.Calls.ProvisionLibraryPanels, nil)
+	if mock.ProvisionLibraryPanelsFunc != nil {
+		return mock.ProvisionLibraryPanelsFunc()
+	}
+	return nil
+}
+
 func (mock *ProvisioningServiceMock) GetDashboardProvisionerResolvedPath(name string) string {
 	mock.Calls.GetDashboardProvisionerResolvedPath = append(mock.Calls.GetDashboardProvisionerResolvedPath, name)
 	if mock.GetDashboardProvisionerResolvedPathFunc != nil {
@@ -6,6 +6,7 @@ import (
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
@@ -25,6 +26,18 @@ func (qs *QuotaService) Init() error {
 }
 
 func (qs *QuotaService) QuotaReached(c *models.ReqContext, target string) (bool, error) {
+	return qs.quotaReached(c, target, bus.Dispatch)
+}
+
+// QuotaReachedWithSession is like QuotaReached, but counts the used quota with sess. Use it inside the transaction
+// that inserts the counted row, so that concurrent inserts can't both pass the check.
+func (qs *QuotaService) QuotaReachedWithSession(c *models.ReqContext, sess *sqlstore.DBSession, target string) (bool, error) {
+	return qs.quotaReached(c, target, func(query bus.Msg) error {
+		return sqlstore.GetQuotaByTargetWithSession(sess, query)
+	})
+}
+
+func (qs *QuotaService) quotaReached(c *models.ReqContext, target string, dispatch func(bus.Msg) error) (bool, error) {
 	if !qs.Cfg.Quota.Enabled {
 		return false, nil
 	}
@@ -64,8 +77,8 @@ func (qs *QuotaService) QuotaReached(c *models.ReqContext, target string) (bool,
 				}
 				continue
 			}
-			query := models.GetGlobalQuotaByTargetQuery{Target: scope.Target, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled()}
-			if err := bus.Dispatch(&query); err != nil {
+			query := models.GetGlobalQuotaByTargetQuery{Target: scope.Target, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled(), IsPanelLibraryEnabled: qs.Cfg.IsPanelLibraryEnabled()}
+			if err := dispatch(&query); err != nil {
 				return true, err
 			}
 			if query.Result.Used >= scope.DefaultLimit {
@@ -75,8 +88,8 @@ func (qs *QuotaService) QuotaReached(c *models.ReqContext, target string) (bool,
 			if !c.IsSignedIn {
 				continue
 			}
-			query := models.GetOrgQuotaByTargetQuery{OrgId: c.OrgId, Target: scope.Target, Default: scope.DefaultLimit, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled()}
-			if err := bus.Dispatch(&query); err != nil {
+			query := models.GetOrgQuotaByTargetQuery{OrgId: c.OrgId, Target: scope.Target, Default: scope.DefaultLimit, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled(), IsPanelLibraryEnabled: qs.Cfg.IsPanelLibraryEnabled()}
+			if err := dispatch(&query); err != nil {
 				return true, err
 			}
 			if query.Result.Limit < 0 {
@@ -93,8 +106,8 @@ func (qs *QuotaService) QuotaReached(c *models.ReqContext, target string) (bool,
 			if !c.IsSignedIn || c.UserId == 0 {
 				continue
 			}
-			query := models.GetUserQuotaByTargetQuery{UserId: c.UserId, Target: scope.Target, Default: scope.DefaultLimit, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled()}
-			if err := bus.Dispatch(&query); err != nil {
+			query := models.GetUserQuotaByTargetQuery{UserId: c.UserId, Target: scope.Target, Default: scope.DefaultLimit, IsNgAlertEnabled: qs.Cfg.IsNgAlertEnabled(), IsPanelLibraryEnabled: qs.Cfg.IsPanelLibraryEnabled()}
+			if err := dispatch(&query); err != nil {
 				return true, err
 			}
 			if query.Result.Limit < 0 {
@@ -146,6 +159,13 @@ func (qs *QuotaService) getQuotaScopes(target string) ([]models.QuotaScope, erro
 			models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.ApiKey},
 		)
 		return scopes, nil
+	case "library_panel":
+		scopes = append(scopes,
+			models.QuotaScope{Name: "global", Target: target, DefaultLimit: qs.Cfg.Quota.Global.LibraryPanel},
+			models.QuotaScope{Name: "org", Target: target, DefaultLimit: qs.Cfg.Quota.Org.LibraryPanel},
+			models.QuotaScope{Name: "user", Target: target, DefaultLimit: qs.Cfg.Quota.User.LibraryPanel},
+		)
+		return scopes, nil
 	case "session":
 		scopes = append(scopes,
 			models.QuotaScope{Name: "global", Target: target, DefaultLimit: qs.Cfg.Quota.Global.Session},
@@ -51,6 +51,12 @@ func (ss *SQLStore) SaveDashboard(cmd models.SaveDashboardCommand) (*models.Dash
 	return cmd.Result, err
 }
 
+// SaveDashboardWithSession saves a dashboard with an open session, so it's saved in the same transaction as the
+// caller's other changes.
+func SaveDashboardWithSession(sess *DBSession, cmd *models.SaveDashboardCommand) error {
+	return saveDashboard(sess, cmd)
+}
+
 func saveDashboard(sess *DBSession, cmd *models.SaveDashboardCommand) error {
 	dash := cmd.GetDashboardModel()
 
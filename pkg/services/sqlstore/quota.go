@@ -1,7 +1,9 @@
 package sqlstore
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
@@ -10,6 +12,7 @@ import (
 )
 
 const ALERT_RULE_TARGET = "alert_rule"
+const LIBRARY_PANEL_TARGET = "library_panel"
 
 func init() {
 	bus.AddHandler("sql", GetOrgQuotaByTarget)
@@ -25,12 +28,39 @@ type targetCount struct {
 	Count int64
 }
 
+// userQuotaColumn returns the column identifying the user a target belongs to.
+func userQuotaColumn(target string) string {
+	if target == LIBRARY_PANEL_TARGET {
+		return "created_by"
+	}
+	return "user_id"
+}
+
+// quotaUsedSQL returns the query counting the rows of target that match conditions. Soft deleted library panels
+// don't count towards a quota.
+func quotaUsedSQL(target string, conditions ...string) string {
+	if target == LIBRARY_PANEL_TARGET {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s", dialect.Quote(target))
+	if len(conditions) > 0 {
+		rawSQL += " where " + strings.Join(conditions, " AND ")
+	}
+	return rawSQL
+}
+
 func GetOrgQuotaByTarget(query *models.GetOrgQuotaByTargetQuery) error {
+	return withDbSession(context.Background(), x, func(sess *DBSession) error {
+		return getOrgQuotaByTarget(sess, query)
+	})
+}
+
+func getOrgQuotaByTarget(sess *DBSession, query *models.GetOrgQuotaByTargetQuery) error {
 	quota := models.Quota{
 		Target: query.Target,
 		OrgId:  query.OrgId,
 	}
-	has, err := x.Get(&quota)
+	has, err := sess.Get(&quota)
 	if err != nil {
 		return err
 	} else if !has {
@@ -38,11 +68,11 @@ func GetOrgQuotaByTarget(query *models.GetOrgQuotaByTargetQuery) error {
 	}
 
 	var used int64
-	if query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled {
+	if (query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled) && (query.Target != LIBRARY_PANEL_TARGET || query.IsPanelLibraryEnabled) {
 		// get quota used.
-		rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s where org_id=?", dialect.Quote(query.Target))
+		rawSQL := quotaUsedSQL(query.Target, "org_id=?")
 		resp := make([]*targetCount, 0)
-		if err := x.SQL(rawSQL, query.OrgId).Find(&resp); err != nil {
+		if err := sess.SQL(rawSQL, query.OrgId).Find(&resp); err != nil {
 			return err
 		}
 		used = resp[0].Count
@@ -85,9 +115,9 @@ func GetOrgQuotas(query *models.GetOrgQuotasQuery) error {
 	result := make([]*models.OrgQuotaDTO, len(quotas))
 	for i, q := range quotas {
 		var used int64
-		if q.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled {
+		if (q.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled) && (q.Target != LIBRARY_PANEL_TARGET || query.IsPanelLibraryEnabled) {
 			// get quota used.
-			rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s where org_id=?", dialect.Quote(q.Target))
+			rawSQL := quotaUsedSQL(q.Target, "org_id=?")
 			resp := make([]*targetCount, 0)
 			if err := x.SQL(rawSQL, q.OrgId).Find(&resp); err != nil {
 				return err
@@ -137,11 +167,17 @@ func UpdateOrgQuota(cmd *models.UpdateOrgQuotaCmd) error {
 }
 
 func GetUserQuotaByTarget(query *models.GetUserQuotaByTargetQuery) error {
+	return withDbSession(context.Background(), x, func(sess *DBSession) error {
+		return getUserQuotaByTarget(sess, query)
+	})
+}
+
+func getUserQuotaByTarget(sess *DBSession, query *models.GetUserQuotaByTargetQuery) error {
 	quota := models.Quota{
 		Target: query.Target,
 		UserId: query.UserId,
 	}
-	has, err := x.Get(&quota)
+	has, err := sess.Get(&quota)
 	if err != nil {
 		return err
 	} else if !has {
@@ -149,11 +185,11 @@ func GetUserQuotaByTarget(query *models.GetUserQuotaByTargetQuery) error {
 	}
 
 	var used int64
-	if query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled {
+	if (query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled) && (query.Target != LIBRARY_PANEL_TARGET || query.IsPanelLibraryEnabled) {
 		// get quota used.
-		rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s where user_id=?", dialect.Quote(query.Target))
+		rawSQL := quotaUsedSQL(query.Target, userQuotaColumn(query.Target)+"=?")
 		resp := make([]*targetCount, 0)
-		if err := x.SQL(rawSQL, query.UserId).Find(&resp); err != nil {
+		if err := sess.SQL(rawSQL, query.UserId).Find(&resp); err != nil {
 			return err
 		}
 		used = resp[0].Count
@@ -196,9 +232,9 @@ func GetUserQuotas(query *models.GetUserQuotasQuery) error {
 	result := make([]*models.UserQuotaDTO, len(quotas))
 	for i, q := range quotas {
 		var used int64
-		if q.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled {
+		if (q.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled) && (q.Target != LIBRARY_PANEL_TARGET || query.IsPanelLibraryEnabled) {
 			// get quota used.
-			rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s where user_id=?", dialect.Quote(q.Target))
+			rawSQL := quotaUsedSQL(q.Target, userQuotaColumn(q.Target)+"=?")
 			resp := make([]*targetCount, 0)
 			if err := x.SQL(rawSQL, q.UserId).Find(&resp); err != nil {
 				return err
@@ -248,13 +284,19 @@ func UpdateUserQuota(cmd *models.UpdateUserQuotaCmd) error {
 }
 
 func GetGlobalQuotaByTarget(query *models.GetGlobalQuotaByTargetQuery) error {
+	return withDbSession(context.Background(), x, func(sess *DBSession) error {
+		return getGlobalQuotaByTarget(sess, query)
+	})
+}
+
+func getGlobalQuotaByTarget(sess *DBSession, query *models.GetGlobalQuotaByTargetQuery) error {
 	var used int64
-	if query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled {
+	if (query.Target != ALERT_RULE_TARGET || query.IsNgAlertEnabled) && (query.Target != LIBRARY_PANEL_TARGET || query.IsPanelLibraryEnabled) {
 		// get quota used.
 
-		rawSQL := fmt.Sprintf("SELECT COUNT(*) as count from %s", dialect.Quote(query.Target))
+		rawSQL := quotaUsedSQL(query.Target)
 		resp := make([]*targetCount, 0)
-		if err := x.SQL(rawSQL).Find(&resp); err != nil {
+		if err := sess.SQL(rawSQL).Find(&resp); err != nil {
 			return err
 		}
 		used = resp[0].Count
@@ -268,3 +310,18 @@ func GetGlobalQuotaByTarget(query *models.GetGlobalQuotaByTargetQuery) error {
 
 	return nil
 }
+
+// GetQuotaByTargetWithSession answers one of the quota by target queries using sess, so that callers can count the
+// used quota in the same transaction as the insert it guards.
+func GetQuotaByTargetWithSession(sess *DBSession, query interface{}) error {
+	switch q := query.(type) {
+	case *models.GetOrgQuotaByTargetQuery:
+		return getOrgQuotaByTarget(sess, q)
+	case *models.GetUserQuotaByTargetQuery:
+		return getUserQuotaByTarget(sess, q)
+	case *models.GetGlobalQuotaByTargetQuery:
+		return getGlobalQuotaByTarget(sess, q)
+	default:
+		return fmt.Errorf("unsupported quota query %T", query)
+	}
+}
@@ -20,23 +20,26 @@ func TestQuotaCommandsAndQueries(t *testing.T) {
 		setting.Quota = setting.QuotaSettings{
 			Enabled: true,
 			Org: &setting.OrgQuota{
-				User:       5,
-				Dashboard:  5,
-				DataSource: 5,
-				ApiKey:     5,
-				AlertRule:  5,
+				User:         5,
+				Dashboard:    5,
+				DataSource:   5,
+				ApiKey:       5,
+				AlertRule:    5,
+				LibraryPanel: 5,
 			},
 			User: &setting.UserQuota{
-				Org: 5,
+				Org:          5,
+				LibraryPanel: 5,
 			},
 			Global: &setting.GlobalQuota{
-				Org:        5,
-				User:       5,
-				Dashboard:  5,
-				DataSource: 5,
-				ApiKey:     5,
-				Session:    5,
-				AlertRule:  5,
+				Org:          5,
+				User:         5,
+				Dashboard:    5,
+				DataSource:   5,
+				ApiKey:       5,
+				Session:      5,
+				AlertRule:    5,
+				LibraryPanel: 5,
 			},
 		}
 
@@ -101,7 +104,7 @@ func TestQuotaCommandsAndQueries(t *testing.T) {
 				err = GetOrgQuotas(&query)
 
 				So(err, ShouldBeNil)
-				So(len(query.Result), ShouldEqual, 5)
+				So(len(query.Result), ShouldEqual, 6)
 				for _, res := range query.Result {
 					limit := 5 // default quota limit
 					used := 0
@@ -156,7 +159,7 @@ func TestQuotaCommandsAndQueries(t *testing.T) {
 				err = GetUserQuotas(&query)
 
 				So(err, ShouldBeNil)
-				So(len(query.Result), ShouldEqual, 1)
+				So(len(query.Result), ShouldEqual, 2)
 				So(query.Result[0].Limit, ShouldEqual, 10)
 				So(query.Result[0].Used, ShouldEqual, 1)
 			})
@@ -494,6 +494,18 @@ func InitTestDB(t ITestDB, opts ...InitTestDBOpt) *SQLStore {
 	return testSQLStore
 }
 
+// MigrateTestDB runs the migrations of a service on the test database, for services that only add their migrations
+// when a feature toggle is enabled. Migrations that already ran are skipped.
+func MigrateTestDB(t ITestDB, ss *SQLStore, service registry.DatabaseMigrator) {
+	t.Helper()
+
+	mg := migrator.NewMigrator(ss.engine)
+	service.AddMigration(mg)
+	if err := mg.Start(); err != nil {
+		t.Fatalf("Failed to migrate test database: %s", err)
+	}
+}
+
 func IsTestDbMySQL() bool {
 	if db, present := os.LookupEnv("GRAFANA_TEST_DB"); present {
 		return db == migrator.MySQL
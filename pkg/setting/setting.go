@@ -371,6 +371,37 @@ type Cfg struct {
 	// ExpressionsEnabled specifies whether expressions are enabled.
 	ExpressionsEnabled bool
 
+	// PanelLibraryPublicCatalogEnabled specifies whether library panels published to the catalog can be
+	// read without signing in.
+	PanelLibraryPublicCatalogEnabled bool
+	// PanelLibrarySearchLatencyBudget is the time after which library panel searches skip counting connected
+	// dashboards. Zero disables the budget.
+	PanelLibrarySearchLatencyBudget time.Duration
+	// PanelLibrarySmokeRenderEnabled specifies whether library panels published to the catalog are rendered
+	// nightly to find broken panels.
+	PanelLibrarySmokeRenderEnabled bool
+	// PanelLibrarySmokeRenderDatasource is the name of the datasource library panels are rendered against by the
+	// smoke render job. Empty keeps the datasources of the panels.
+	PanelLibrarySmokeRenderDatasource string
+	// PanelLibrarySmokeRenderFrom and PanelLibrarySmokeRenderTo are the time range of the smoke render job.
+	PanelLibrarySmokeRenderFrom string
+	PanelLibrarySmokeRenderTo   string
+	// PanelLibraryRequireUpdateApproval specifies whether dashboards keep the version of a library panel they were
+	// connected to until an update is accepted for them.
+	PanelLibraryRequireUpdateApproval bool
+	// PanelLibraryCanarySamplePercent is the share of connected dashboards that get a canary update of a library
+	// panel first.
+	PanelLibraryCanarySamplePercent int
+	// PanelLibraryCanarySoak is the time a canary update is monitored before it's applied to all dashboards.
+	PanelLibraryCanarySoak time.Duration
+	// PanelLibraryUIDStrategy is how uids of new library panels are generated: shortid, ulid or prefixed.
+	PanelLibraryUIDStrategy string
+	// PanelLibraryUIDPrefix is the prefix of uids generated by the prefixed strategy, e.g. the name of the instance.
+	PanelLibraryUIDPrefix string
+	// PanelLibraryEmbedTokenInQuery specifies whether library panel tokens are also accepted in the token query
+	// parameter of the embed endpoint, and not only in the Authorization header.
+	PanelLibraryEmbedTokenInQuery bool
+
 	ImageUploadProvider string
 }
 
@@ -528,6 +559,22 @@ func (cfg *Cfg) readExpressionsSettings() {
 	cfg.ExpressionsEnabled = expressions.Key("enabled").MustBool(true)
 }
 
+func (cfg *Cfg) readPanelLibrarySettings() {
+	panelLibrary := cfg.Raw.Section("panel_library")
+	cfg.PanelLibraryPublicCatalogEnabled = panelLibrary.Key("public_catalog_enabled").MustBool(false)
+	cfg.PanelLibrarySearchLatencyBudget = panelLibrary.Key("search_latency_budget").MustDuration(0)
+	cfg.PanelLibrarySmokeRenderEnabled = panelLibrary.Key("smoke_render_enabled").MustBool(false)
+	cfg.PanelLibrarySmokeRenderDatasource = panelLibrary.Key("smoke_render_datasource").MustString("")
+	cfg.PanelLibrarySmokeRenderFrom = panelLibrary.Key("smoke_render_from").MustString("now-6h")
+	cfg.PanelLibrarySmokeRenderTo = panelLibrary.Key("smoke_render_to").MustString("now")
+	cfg.PanelLibraryRequireUpdateApproval = panelLibrary.Key("require_update_approval").MustBool(false)
+	cfg.PanelLibraryCanarySamplePercent = panelLibrary.Key("canary_sample_percent").MustInt(10)
+	cfg.PanelLibraryCanarySoak = panelLibrary.Key("canary_soak").MustDuration(time.Hour)
+	cfg.PanelLibraryUIDStrategy = panelLibrary.Key("uid_strategy").MustString("shortid")
+	cfg.PanelLibraryUIDPrefix = panelLibrary.Key("uid_prefix").MustString("")
+	cfg.PanelLibraryEmbedTokenInQuery = panelLibrary.Key("embed_token_in_query").MustBool(false)
+}
+
 type AnnotationCleanupSettings struct {
 	MaxAge   time.Duration
 	MaxCount int64
@@ -910,6 +957,7 @@ func (cfg *Cfg) Load(args *CommandLineArgs) error {
 	cfg.readQuotaSettings()
 	cfg.readAnnotationSettings()
 	cfg.readExpressionsSettings()
+	cfg.readPanelLibrarySettings()
 	if err := cfg.readGrafanaEnvironmentMetrics(); err != nil {
 		return err
 	}
@@ -5,25 +5,28 @@ import (
 )
 
 type OrgQuota struct {
-	User       int64 `target:"org_user"`
-	DataSource int64 `target:"data_source"`
-	Dashboard  int64 `target:"dashboard"`
-	ApiKey     int64 `target:"api_key"`
-	AlertRule  int64 `target:"alert_rule"`
+	User         int64 `target:"org_user"`
+	DataSource   int64 `target:"data_source"`
+	Dashboard    int64 `target:"dashboard"`
+	ApiKey       int64 `target:"api_key"`
+	AlertRule    int64 `target:"alert_rule"`
+	LibraryPanel int64 `target:"library_panel"`
 }
 
 type UserQuota struct {
-	Org int64 `target:"org_user"`
+	Org          int64 `target:"org_user"`
+	LibraryPanel int64 `target:"library_panel"`
 }
 
 type GlobalQuota struct {
-	Org        int64 `target:"org"`
-	User       int64 `target:"user"`
-	DataSource int64 `target:"data_source"`
-	Dashboard  int64 `target:"dashboard"`
-	ApiKey     int64 `target:"api_key"`
-	Session    int64 `target:"-"`
-	AlertRule  int64 `target:"alert_rule"`
+	Org          int64 `target:"org"`
+	User         int64 `target:"user"`
+	DataSource   int64 `target:"data_source"`
+	Dashboard    int64 `target:"dashboard"`
+	ApiKey       int64 `target:"api_key"`
+	Session      int64 `target:"-"`
+	AlertRule    int64 `target:"alert_rule"`
+	LibraryPanel int64 `target:"library_panel"`
 }
 
 func (q *OrgQuota) ToMap() map[string]int64 {
@@ -72,29 +75,42 @@ func (cfg *Cfg) readQuotaSettings() {
 		alertOrgQuota = quota.Key("org_alert_rule").MustInt64(100)
 		alertGlobalQuota = quota.Key("global_alert_rule").MustInt64(-1)
 	}
+
+	var libraryPanelOrgQuota int64
+	var libraryPanelUserQuota int64
+	var libraryPanelGlobalQuota int64
+	if cfg.IsPanelLibraryEnabled() {
+		libraryPanelOrgQuota = quota.Key("org_library_panel").MustInt64(100)
+		libraryPanelUserQuota = quota.Key("user_library_panel").MustInt64(-1)
+		libraryPanelGlobalQuota = quota.Key("global_library_panel").MustInt64(-1)
+	}
+
 	// per ORG Limits
 	Quota.Org = &OrgQuota{
-		User:       quota.Key("org_user").MustInt64(10),
-		DataSource: quota.Key("org_data_source").MustInt64(10),
-		Dashboard:  quota.Key("org_dashboard").MustInt64(10),
-		ApiKey:     quota.Key("org_api_key").MustInt64(10),
-		AlertRule:  alertOrgQuota,
+		User:         quota.Key("org_user").MustInt64(10),
+		DataSource:   quota.Key("org_data_source").MustInt64(10),
+		Dashboard:    quota.Key("org_dashboard").MustInt64(10),
+		ApiKey:       quota.Key("org_api_key").MustInt64(10),
+		AlertRule:    alertOrgQuota,
+		LibraryPanel: libraryPanelOrgQuota,
 	}
 
 	// per User limits
 	Quota.User = &UserQuota{
-		Org: quota.Key("user_org").MustInt64(10),
+		Org:          quota.Key("user_org").MustInt64(10),
+		LibraryPanel: libraryPanelUserQuota,
 	}
 
 	// Global Limits
 	Quota.Global = &GlobalQuota{
-		User:       quota.Key("global_user").MustInt64(-1),
-		Org:        quota.Key("global_org").MustInt64(-1),
-		DataSource: quota.Key("global_data_source").MustInt64(-1),
-		Dashboard:  quota.Key("global_dashboard").MustInt64(-1),
-		ApiKey:     quota.Key("global_api_key").MustInt64(-1),
-		Session:    quota.Key("global_session").MustInt64(-1),
-		AlertRule:  alertGlobalQuota,
+		User:         quota.Key("global_user").MustInt64(-1),
+		Org:          quota.Key("global_org").MustInt64(-1),
+		DataSource:   quota.Key("global_data_source").MustInt64(-1),
+		Dashboard:    quota.Key("global_dashboard").MustInt64(-1),
+		ApiKey:       quota.Key("global_api_key").MustInt64(-1),
+		Session:      quota.Key("global_session").MustInt64(-1),
+		AlertRule:    alertGlobalQuota,
+		LibraryPanel: libraryPanelGlobalQuota,
 	}
 
 	cfg.Quota = Quota